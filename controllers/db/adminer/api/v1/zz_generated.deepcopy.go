@@ -92,6 +92,11 @@ func (in *AdminerSpec) DeepCopyInto(out *AdminerSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]DatabaseRef, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdminerSpec.
@@ -118,3 +123,18 @@ func (in *AdminerStatus) DeepCopy() *AdminerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatabaseRef) DeepCopyInto(out *DatabaseRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseRef.
+func (in *DatabaseRef) DeepCopy() *DatabaseRef {
+	if in == nil {
+		return nil
+	}
+	out := new(DatabaseRef)
+	in.DeepCopyInto(out)
+	return out
+}
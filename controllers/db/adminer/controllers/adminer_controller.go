@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"os"
+	"strconv"
 	"time"
 
 	nanoid "github.com/matoous/go-nanoid/v2"
@@ -34,9 +35,12 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	adminerv1 "github.com/labring/sealos/controllers/db/adminer/api/v1"
+	"github.com/labring/sealos/controllers/pkg/ingress"
 	"github.com/labring/sealos/controllers/pkg/utils/label"
 )
 
@@ -83,6 +87,9 @@ type AdminerReconciler struct {
 	image           string
 	secretName      string
 	secretNamespace string
+	gateway         ingress.GatewayGenerator
+	// engineImages are the console images of the engines other than sql, which use image
+	engineImages map[adminerv1.EngineType]string
 }
 
 //+kubebuilder:rbac:groups=adminer.db.sealos.io,resources=adminers,verbs=get;list;watch;create;update;patch;delete
@@ -93,6 +100,7 @@ type AdminerReconciler struct {
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 
 //-kubebuilder:rbac:groups=core,resources=endpoints,verbs=get;list;watch
 
@@ -218,8 +226,18 @@ func (r *AdminerReconciler) syncSecret(ctx context.Context, adminer *adminerv1.A
 		},
 	}
 
+	credentials, err := r.getDatabaseCredentials(ctx, adminer)
+	if err != nil {
+		return err
+	}
+	expectSecret, err := r.createSecret(adminer, credentials)
+	if err != nil {
+		return err
+	}
+
 	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
-		expectSecret := r.createSecret(adminer)
+		// the files of another engine are dropped
+		secret.Data = nil
 		secret.StringData = expectSecret.StringData
 		secret.Type = expectSecret.Type
 		return controllerutil.SetControllerReference(adminer, secret, r.Scheme)
@@ -244,10 +262,15 @@ func (r *AdminerReconciler) syncDeployment(ctx context.Context, adminer *adminer
 		Labels: recLabels,
 	}
 
+	image, err := r.getConsoleImage(adminer.Spec.Engine)
+	if err != nil {
+		return err
+	}
+
 	containers := []corev1.Container{
 		{
 			Name:  "adminer",
-			Image: r.image,
+			Image: image,
 			Ports: []corev1.ContainerPort{
 				{
 					Name:          "http",
@@ -270,7 +293,7 @@ func (r *AdminerReconciler) syncDeployment(ctx context.Context, adminer *adminer
 					Name: "servers-volume",
 					// Note: we cannot use subpath since k8s cannot auto update it's mounts.
 					// @see: https://kubernetes.io/docs/concepts/configuration/secret/#mounted-secrets-are-updated-automatically
-					MountPath: "/var/www/html/servers",
+					MountPath: getConsoleMountPath(adminer.Spec.Engine),
 					// MountPath: "/var/www/html/servers.php",
 					// SubPath:   "servers.php",
 					ReadOnly: true,
@@ -417,30 +440,12 @@ func (r *AdminerReconciler) syncService(ctx context.Context, adminer *adminerv1.
 }
 
 func (r *AdminerReconciler) syncIngress(ctx context.Context, adminer *adminerv1.Adminer, hostname string, recLabels map[string]string) error {
-	var err error
 	host := hostname + "." + r.adminerDomain
-	switch adminer.Spec.IngressType {
-	case adminerv1.Nginx:
-		err = r.syncNginxIngress(ctx, adminer, host, recLabels)
-	}
-	return err
-}
-
-func (r *AdminerReconciler) syncNginxIngress(ctx context.Context, adminer *adminerv1.Adminer, host string, recLabels map[string]string) error {
-	ingress := &networkingv1.Ingress{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      adminer.Name,
-			Namespace: adminer.Namespace,
-			Labels:    recLabels,
-		},
+	generator, err := r.getIngressGenerator(adminer.Spec.IngressType)
+	if err != nil {
+		return err
 	}
-	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, ingress, func() error {
-		expectIngress := r.createNginxIngress(adminer, host)
-		ingress.ObjectMeta.Annotations = expectIngress.ObjectMeta.Annotations
-		ingress.Spec.Rules = expectIngress.Spec.Rules
-		ingress.Spec.TLS = expectIngress.Spec.TLS
-		return controllerutil.SetControllerReference(adminer, ingress, r.Scheme)
-	}); err != nil {
+	if err := ingress.Sync(ctx, r.Client, r.Scheme, generator, r.createRoute(adminer, host, recLabels), adminer); err != nil {
 		return err
 	}
 
@@ -526,6 +531,40 @@ func getSecretNamespace() string {
 	return secretNamespace
 }
 
+// getEngineImages returns the console images of the engines other than sql, an engine without image is not enabled
+func getEngineImages() map[adminerv1.EngineType]string {
+	return map[adminerv1.EngineType]string{
+		adminerv1.MongoDB: os.Getenv("MONGODB_IMAGE"),
+		adminerv1.Redis:   os.Getenv("REDIS_IMAGE"),
+	}
+}
+
+// getGateway returns the Gateway that the HTTPRoutes are attached to, the gateway is disabled if GATEWAY_NAME is empty.
+// GATEWAY_CORS_FILTER is true if the Gateway implements the CORS filter, the routes of adminers are refused without it.
+func getGateway() ingress.GatewayGenerator {
+	corsFilter, _ := strconv.ParseBool(os.Getenv("GATEWAY_CORS_FILTER"))
+	return ingress.GatewayGenerator{
+		Name:             os.Getenv("GATEWAY_NAME"),
+		Namespace:        os.Getenv("GATEWAY_NAMESPACE"),
+		HTTPSectionName:  os.Getenv("GATEWAY_HTTP_SECTION_NAME"),
+		HTTPSSectionName: os.Getenv("GATEWAY_HTTPS_SECTION_NAME"),
+		CORSFilter:       corsFilter,
+	}
+}
+
+func (r *AdminerReconciler) findAdminersForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	adminers := &adminerv1.AdminerList{}
+	if err := r.List(ctx, adminers, client.InNamespace(secret.GetNamespace()), client.MatchingFields{SecretNameField: secret.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "list adminers of secret failed", "secret", client.ObjectKeyFromObject(secret))
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(adminers.Items))
+	for _, adminer := range adminers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&adminer)})
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *AdminerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.recorder = mgr.GetEventRecorderFor("sealos-db-adminer-controller")
@@ -534,9 +573,27 @@ func (r *AdminerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.image = getImage()
 	r.secretName = getSecretName()
 	r.secretNamespace = getSecretNamespace()
+	r.gateway = getGateway()
+	r.engineImages = getEngineImages()
 	r.Config = mgr.GetConfig()
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &adminerv1.Adminer{}, SecretNameField, func(obj client.Object) []string {
+		adminer := obj.(*adminerv1.Adminer)
+		secretNames := make([]string, 0, len(adminer.Spec.Databases))
+		for _, ref := range adminer.Spec.Databases {
+			secretNames = append(secretNames, ref.SecretName)
+		}
+		return secretNames
+	}); err != nil {
+		return err
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&adminerv1.Adminer{}).
 		Owns(&appsv1.Deployment{}).Owns(&corev1.Service{}).Owns(&corev1.Secret{}).Owns(&networkingv1.Ingress{}).
-		Complete(r)
+		// the console is synced once the credentials of its databases are changed
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findAdminersForSecret))
+	// the HTTPRoute is only watched if the gateway is configured, the CRDs of Gateway API may not be installed
+	if r.gateway.Name != "" {
+		b = b.Owns(ingress.NewHTTPRoute())
+	}
+	return b.Complete(r)
 }
@@ -0,0 +1,188 @@
+/*
+Copyright 2023 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	adminerv1 "github.com/labring/sealos/controllers/db/adminer/api/v1"
+)
+
+// the keys of a database credential secret, they are the keys of the connection credential of a database cluster
+const (
+	credentialHost     = "host"
+	credentialPort     = "port"
+	credentialEndpoint = "endpoint"
+	credentialUsername = "username"
+	credentialPassword = "password"
+	credentialDatabase = "database"
+)
+
+const (
+	// sqlServersFile is read by the servers plugin of the adminer image
+	sqlServersFile      = "servers.php"
+	sqlServersMountPath = "/var/www/html/servers"
+	// consoleConnectionsFile is read by the console images of the other engines
+	consoleConnectionsFile      = "connections.json"
+	consoleConnectionsMountPath = "/etc/db-console"
+
+	// SecretNameField indexes the adminers by the credential secrets of their databases
+	SecretNameField = ".spec.databases.secretName"
+)
+
+// urlSchemes are the schemes of the connection urls of engines, the sql schemes are the drivers of adminer
+var urlSchemes = map[adminerv1.EngineType]string{
+	adminerv1.MySQL:      "mysql",
+	adminerv1.PostgreSQL: "pgsql",
+	adminerv1.MongoDB:    "mongodb",
+	adminerv1.Redis:      "redis",
+}
+
+type databaseCredential struct {
+	Name     string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Database string
+}
+
+func parseDatabaseCredential(ref adminerv1.DatabaseRef, secret *corev1.Secret) (*databaseCredential, error) {
+	credential := &databaseCredential{
+		Name:     ref.Name,
+		Host:     string(secret.Data[credentialHost]),
+		Port:     string(secret.Data[credentialPort]),
+		Username: string(secret.Data[credentialUsername]),
+		Password: string(secret.Data[credentialPassword]),
+		Database: string(secret.Data[credentialDatabase]),
+	}
+	if credential.Name == "" {
+		credential.Name = ref.SecretName
+	}
+	if credential.Host == "" && len(secret.Data[credentialEndpoint]) > 0 {
+		host, port, err := net.SplitHostPort(string(secret.Data[credentialEndpoint]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint of secret %s: %w", ref.SecretName, err)
+		}
+		credential.Host, credential.Port = host, port
+	}
+	if credential.Host == "" {
+		return nil, fmt.Errorf("secret %s has neither %s nor %s", ref.SecretName, credentialHost, credentialEndpoint)
+	}
+	return credential, nil
+}
+
+// URL returns the connection url of the database of engine
+func (c *databaseCredential) URL(engine adminerv1.EngineType) string {
+	u := url.URL{
+		Scheme: urlSchemes[engine],
+		Host:   c.Host,
+	}
+	if u.Scheme == "" {
+		u.Scheme = urlSchemes[adminerv1.MySQL]
+	}
+	if c.Port != "" {
+		u.Host = net.JoinHostPort(c.Host, c.Port)
+	}
+	if c.Username != "" || c.Password != "" {
+		u.User = url.UserPassword(c.Username, c.Password)
+	}
+	if c.Database != "" {
+		u.Path = "/" + c.Database
+	}
+	if engine == adminerv1.MongoDB {
+		// the users of a mongodb cluster are created in the admin database
+		u.RawQuery = "authSource=admin"
+	}
+	return u.String()
+}
+
+// getDatabaseCredentials reads the credentials of the databases of adminer from their secrets
+func (r *AdminerReconciler) getDatabaseCredentials(ctx context.Context, adminer *adminerv1.Adminer) ([]*databaseCredential, error) {
+	credentials := make([]*databaseCredential, 0, len(adminer.Spec.Databases))
+	for _, ref := range adminer.Spec.Databases {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Name: ref.SecretName, Namespace: adminer.Namespace}, secret); err != nil {
+			return nil, fmt.Errorf("unable to get the credential of database %s: %w", ref.SecretName, err)
+		}
+		credential, err := parseDatabaseCredential(ref, secret)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, nil
+}
+
+// buildConsoleFiles returns the files of the console of engine that are mounted into the console
+func buildConsoleFiles(engine adminerv1.EngineType, connections []string, credentials []*databaseCredential) (map[string]string, error) {
+	if engine.IsSQL() {
+		connectionMap := parseConnections(connections)
+		for _, credential := range credentials {
+			connectionMap[credential.Name] = credential.URL(engine)
+		}
+		return map[string]string{sqlServersFile: buildServersFileContent(connectionMap)}, nil
+	}
+
+	if len(connections) > 0 {
+		return nil, fmt.Errorf("connections are only supported by sql engines, use databases for engine %s", engine)
+	}
+	type consoleConnection struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+	consoleConnections := make([]consoleConnection, 0, len(credentials))
+	for _, credential := range credentials {
+		consoleConnections = append(consoleConnections, consoleConnection{Name: credential.Name, URL: credential.URL(engine)})
+	}
+	sort.Slice(consoleConnections, func(i, j int) bool {
+		return consoleConnections[i].Name < consoleConnections[j].Name
+	})
+	content, err := json.Marshal(consoleConnections)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{consoleConnectionsFile: string(content)}, nil
+}
+
+// getConsoleMountPath returns the directory that the files of the console of engine are mounted to
+func getConsoleMountPath(engine adminerv1.EngineType) string {
+	if engine.IsSQL() {
+		return sqlServersMountPath
+	}
+	return consoleConnectionsMountPath
+}
+
+// getConsoleImage returns the console image of engine, an engine without image is not enabled
+func (r *AdminerReconciler) getConsoleImage(engine adminerv1.EngineType) (string, error) {
+	if engine.IsSQL() {
+		return r.image, nil
+	}
+	image := r.engineImages[engine]
+	if image == "" {
+		return "", fmt.Errorf("engine %s is not enabled, the console image is not configured", engine)
+	}
+	return image, nil
+}
@@ -19,83 +19,40 @@ package controllers
 import (
 	"fmt"
 	"strings"
-
-	networkingv1 "k8s.io/api/networking/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
 
 	adminerv1 "github.com/labring/sealos/controllers/db/adminer/api/v1"
+	"github.com/labring/sealos/controllers/pkg/ingress"
 )
 
-func (r *AdminerReconciler) createNginxIngress(adminer *adminerv1.Adminer, host string) *networkingv1.Ingress {
-	corsFormat := "https://%s,https://*.%s"
-	if !r.tlsEnabled {
-		corsFormat = "http://%s,http://*.%s"
-	}
-	cors := fmt.Sprintf(corsFormat, r.adminerDomain, r.adminerDomain)
-
-	annotations := map[string]string{
-		"kubernetes.io/ingress.class":                        "nginx",
-		"nginx.ingress.kubernetes.io/proxy-send-timeout":     "86400",
-		"nginx.ingress.kubernetes.io/proxy-read-timeout":     "86400",
-		"nginx.ingress.kubernetes.io/proxy-body-size":        "256m",
-		"nginx.ingress.kubernetes.io/proxy-buffer-size":      "64k",
-		"nginx.ingress.kubernetes.io/enable-cors":            "true",
-		"nginx.ingress.kubernetes.io/cors-allow-origin":      cors,
-		"nginx.ingress.kubernetes.io/cors-allow-methods":     "PUT, GET, POST, PATCH, OPTIONS",
-		"nginx.ingress.kubernetes.io/cors-allow-credentials": "false",
-		"nginx.ingress.kubernetes.io/configuration-snippet":  r.getNginxConfigurationSnippet(),
-	}
+// ingressTimeout keeps the long queries of adminer from being cut by the proxy
+const ingressTimeout = 86400 * time.Second
 
-	// add higress annotations support
-	higressAnno := r.getHigressAnnotations()
-	for k, v := range higressAnno {
-		annotations[k] = v
+func (r *AdminerReconciler) createRoute(adminer *adminerv1.Adminer, host string, recLabels map[string]string) *ingress.Route {
+	corsFormat := "https://%s"
+	if !r.tlsEnabled {
+		corsFormat = "http://%s"
 	}
-
-	objectMeta := metav1.ObjectMeta{
+	route := &ingress.Route{
 		Name:        adminer.Name,
 		Namespace:   adminer.Namespace,
-		Annotations: annotations,
-	}
-
-	pathType := networkingv1.PathTypePrefix
-	paths := []networkingv1.HTTPIngressPath{{
-		PathType: &pathType,
-		Path:     "/",
-		Backend: networkingv1.IngressBackend{
-			Service: &networkingv1.IngressServiceBackend{
-				Name: adminer.Name,
-				Port: networkingv1.ServiceBackendPort{
-					Number: 8080,
-				},
-			},
-		},
-	}}
-
-	rule := networkingv1.IngressRule{
-		Host: host,
-		IngressRuleValue: networkingv1.IngressRuleValue{
-			HTTP: &networkingv1.HTTPIngressRuleValue{
-				Paths: paths,
-			},
-		},
-	}
-
-	ingress := &networkingv1.Ingress{
-		ObjectMeta: objectMeta,
-		Spec: networkingv1.IngressSpec{
-			Rules: []networkingv1.IngressRule{rule},
+		Labels:      recLabels,
+		Host:        host,
+		ServiceName: adminer.Name,
+		ServicePort: 8080,
+		Timeout:     ingressTimeout,
+		MaxBodySize: "256m",
+		CORSOrigins: []string{
+			fmt.Sprintf(corsFormat, r.adminerDomain),
+			fmt.Sprintf(corsFormat, "*."+r.adminerDomain),
 		},
+		ResponseHeaders:       r.getResponseHeaders(),
+		RemoveResponseHeaders: []string{clearXFrameHeader},
 	}
-
 	if r.tlsEnabled {
-		tls := networkingv1.IngressTLS{
-			Hosts:      []string{host},
-			SecretName: r.secretName,
-		}
-		ingress.Spec.TLS = []networkingv1.IngressTLS{tls}
+		route.TLSSecretName = r.secretName
 	}
-	return ingress
+	return route
 }
 
 const (
@@ -108,38 +65,27 @@ const (
 	defaultConfigDomain = "cloud.sealos.io"
 )
 
-var (
-	defaultNginxConfigurationSnippet = fmt.Sprintf(`
-more_clear_headers "%s:";
-more_set_headers "%s: %s";
-more_set_headers "%s: %s";
-`, clearXFrameHeader, defaultCSPHeader, defaultCSPValue, defaultXSSHeader, defaultXSSValue)
-	defaultHigressAnnotations = map[string]string{
-		"higress.io/response-header-control-remove": clearXFrameHeader,
-		"higress.io/response-header-control-update": fmt.Sprintf(`
-%s "%s"
-%s "%s"
-`, defaultCSPHeader, defaultCSPValue, defaultXSSHeader, defaultXSSValue),
-	}
-)
-
-func (r *AdminerReconciler) getNginxConfigurationSnippet() string {
+func (r *AdminerReconciler) getResponseHeaders() []ingress.Header {
+	cspValue := defaultCSPValue
 	if defaultConfigDomain != r.adminerDomain {
-		return strings.ReplaceAll(defaultNginxConfigurationSnippet, defaultConfigDomain, r.adminerDomain)
+		cspValue = strings.ReplaceAll(cspValue, defaultConfigDomain, r.adminerDomain)
+	}
+	return []ingress.Header{
+		{Name: defaultCSPHeader, Value: cspValue},
+		{Name: defaultXSSHeader, Value: defaultXSSValue},
 	}
-
-	return defaultNginxConfigurationSnippet
 }
 
-func (r *AdminerReconciler) getHigressAnnotations() map[string]string {
-	if defaultConfigDomain != r.adminerDomain {
-		ret := map[string]string{}
-		for k, v := range defaultHigressAnnotations {
-			ret[k] = strings.ReplaceAll(v, defaultConfigDomain, r.adminerDomain)
+// getIngressGenerator returns the generator of ingressType
+func (r *AdminerReconciler) getIngressGenerator(ingressType adminerv1.IngressType) (ingress.Generator, error) {
+	switch ingressType {
+	case adminerv1.Nginx:
+		return ingress.NginxGenerator{}, nil
+	case adminerv1.Gateway:
+		if r.gateway.Name == "" {
+			return nil, fmt.Errorf("ingress type %s is not enabled, the gateway is not configured", ingressType)
 		}
-
-		return ret
+		return r.gateway, nil
 	}
-
-	return defaultHigressAnnotations
+	return nil, fmt.Errorf("unsupported ingress type %s", ingressType)
 }
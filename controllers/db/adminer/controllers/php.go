@@ -34,10 +34,16 @@ return [
 )
 
 var (
-	adminerPHPTmpl = template.Must(template.New("plugin").Parse(adminerPHPTemplate))
+	adminerPHPTmpl   = template.Must(template.New("plugin").Parse(adminerPHPTemplate))
+	phpQuoteReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
 )
 
 func buildConnectionFileContent(connections []string) string {
+	return buildServersFileContent(parseConnections(connections))
+}
+
+// parseConnections returns the connection strings keyed by the first label of their hosts
+func parseConnections(connections []string) map[string]string {
 	// TODO: support custom key name
 	connectionMap := map[string]string{}
 	for _, connection := range connections {
@@ -55,9 +61,17 @@ func buildConnectionFileContent(connections []string) string {
 
 		connectionMap[hostname] = connection
 	}
+	return connectionMap
+}
 
+func buildServersFileContent(connectionMap map[string]string) string {
+	// the values are quoted by php single quotes, the passwords may contain quotes and backslashes
+	quoted := make(map[string]string, len(connectionMap))
+	for k, v := range connectionMap {
+		quoted[phpQuoteReplacer.Replace(k)] = phpQuoteReplacer.Replace(v)
+	}
 	content, err := renderTemplate(adminerPHPTmpl, map[string]interface{}{
-		"connectionMap": connectionMap,
+		"connectionMap": quoted,
 	})
 	if err != nil {
 		ctrl.Log.WithName("build").V(1).Info("create connection php file failed", "err", err)
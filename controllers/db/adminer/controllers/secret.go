@@ -23,18 +23,20 @@ import (
 	adminerv1 "github.com/labring/sealos/controllers/db/adminer/api/v1"
 )
 
-func (r *AdminerReconciler) createSecret(adminer *adminerv1.Adminer) *corev1.Secret {
+func (r *AdminerReconciler) createSecret(adminer *adminerv1.Adminer, credentials []*databaseCredential) (*corev1.Secret, error) {
+	files, err := buildConsoleFiles(adminer.Spec.Engine, adminer.Spec.Connections, credentials)
+	if err != nil {
+		return nil, err
+	}
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      adminer.Name,
 			Namespace: adminer.Namespace,
 		},
 
-		StringData: map[string]string{
-			"servers.php": buildConnectionFileContent(adminer.Spec.Connections),
-		},
-		Type: corev1.SecretTypeOpaque,
+		StringData: files,
+		Type:       corev1.SecretTypeOpaque,
 	}
 
-	return secret
+	return secret, nil
 }
@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -54,6 +55,31 @@ type RuntimeRef struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+type TemplateUpgradePolicy string
+
+const (
+	// TemplateUpgradeManual keeps the devbox on its template version until the version is changed
+	TemplateUpgradeManual TemplateUpgradePolicy = "Manual"
+	// TemplateUpgradeAuto moves the devbox to the latest version of the template once it is released
+	TemplateUpgradeAuto TemplateUpgradePolicy = "Auto"
+)
+
+// TemplateRef pins a devbox to a version of a cluster scoped DevboxTemplate
+type TemplateRef struct {
+	// Name is the name of the DevboxTemplate
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Version is the template version the devbox is on, it is set to the latest version if empty.
+	// Changing it migrates the devbox to another version, the devbox pod is recreated with the new config.
+	// +kubebuilder:validation:Optional
+	Version string `json:"version,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Manual;Auto
+	// +kubebuilder:default=Manual
+	UpgradePolicy TemplateUpgradePolicy `json:"upgradePolicy,omitempty"`
+}
+
 type NetworkSpec struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:Enum=NodePort;Tailnet
@@ -62,9 +88,11 @@ type NetworkSpec struct {
 	ExtraPorts []corev1.ContainerPort `json:"extraPorts"`
 }
 
+// Config is the runtime config of a devbox. The fields set neither in the devbox nor in its template are defaulted
+// by the controller instead of the API server, so that the template is not overridden by the defaults
 type Config struct {
+	// User defaults to devbox
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default=devbox
 	User string `json:"user"`
 
 	// +kubebuilder:validation:Optional
@@ -76,25 +104,25 @@ type Config struct {
 	Command []string `json:"command,omitempty"`
 	// kubebuilder:validation:Optional
 	Args []string `json:"args,omitempty"`
+	// WorkingDir defaults to /home/devbox/project
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default=/home/devbox/project
 	WorkingDir string `json:"workingDir,omitempty"`
 	// +kubebuilder:validation:Optional
 	Env []corev1.EnvVar `json:"env,omitempty"`
 
+	// ReleaseCommand defaults to /bin/bash -c
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default={/bin/bash,-c}
 	ReleaseCommand []string `json:"releaseCommand,omitempty"`
+	// ReleaseArgs defaults to /home/devbox/project/entrypoint.sh
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default={/home/devbox/project/entrypoint.sh}
 	ReleaseArgs []string `json:"releaseArgs,omitempty"`
 
 	// TODO: in v1alpha2 api we need fix the port and app port into one field and create a new type for it.
+	// Ports defaults to the ssh port 22
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default={{name:"devbox-ssh-port",containerPort:22,protocol:TCP}}
 	Ports []corev1.ContainerPort `json:"ports,omitempty"`
+	// AppPorts defaults to the app port 8080
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default={{name:"devbox-app-port",port:8080,protocol:TCP}}
 	AppPorts []corev1.ServicePort `json:"appPorts,omitempty"`
 
 	// +kubebuilder:validation:Optional
@@ -104,6 +132,7 @@ type Config struct {
 }
 
 // DevboxSpec defines the desired state of Devbox
+// +kubebuilder:validation:XValidation:rule="has(self.templateRef) || (has(self.image) && size(self.image) > 0)",message="image is required if templateRef is not set"
 type DevboxSpec struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:Enum=Running;Stopped;Shutdown
@@ -115,13 +144,18 @@ type DevboxSpec struct {
 	// +kubebuilder:default=false
 	Squash bool `json:"squash"`
 
-	// +kubebuilder:validation:Required
+	// Image is the base image of the devbox, it is inherited from the template if empty
+	// +kubebuilder:validation:Optional
 	Image string `json:"image"`
 
 	// +kubebuilder:validation:Optional
 	TemplateID string `json:"templateID"`
 
-	// +kubebuilder:validation:Required
+	// TemplateRef inherits the image and config of a DevboxTemplate, the fields set in Image and Config override them
+	// +kubebuilder:validation:Optional
+	TemplateRef *TemplateRef `json:"templateRef,omitempty"`
+
+	// +kubebuilder:validation:Optional
 	Config Config `json:"config"`
 
 	// +kubebuilder:validation:Required
@@ -135,6 +169,80 @@ type DevboxSpec struct {
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
 	// +kubebuilder:validation:Optional
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// IdlePolicy overrides the idle timeouts of the namespace and controller
+	// +kubebuilder:validation:Optional
+	IdlePolicy *IdlePolicy `json:"idlePolicy,omitempty"`
+
+	// RetentionPolicy overrides the commit history retention of the controller
+	// +kubebuilder:validation:Optional
+	RetentionPolicy *RetentionPolicy `json:"retentionPolicy,omitempty"`
+
+	// Workspace is a persistent volume mounted into the devbox, it is not part of the writable layer of the
+	// container and so it is excluded from commits
+	// +kubebuilder:validation:Optional
+	Workspace *WorkspaceSpec `json:"workspace,omitempty"`
+
+	// Restore restarts the devbox from an earlier successful commit, it is cleared once the devbox pod is created
+	// +kubebuilder:validation:Optional
+	Restore *RestoreSpec `json:"restore,omitempty"`
+}
+
+// RestoreSpec points to a successful commit of this devbox, or of another devbox in the namespace to fork it
+type RestoreSpec struct {
+	// Image is the image of a commit whose status is Success
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+	// DevboxName is the devbox whose commit history contains the image, this devbox is used if empty
+	// +kubebuilder:validation:Optional
+	DevboxName string `json:"devboxName,omitempty"`
+}
+
+type WorkspaceReclaimPolicy string
+
+const (
+	// WorkspaceReclaimRetain keeps the workspace volume when the devbox is shutdown
+	WorkspaceReclaimRetain WorkspaceReclaimPolicy = "Retain"
+	// WorkspaceReclaimDelete deletes the workspace volume when the devbox is shutdown
+	WorkspaceReclaimDelete WorkspaceReclaimPolicy = "Delete"
+)
+
+// WorkspaceSpec is a persistent volume claim created and owned by the devbox
+type WorkspaceSpec struct {
+	// Size is the requested storage of the volume, it can be increased if the storage class allows expansion
+	// +kubebuilder:validation:Required
+	Size resource.Quantity `json:"size"`
+	// StorageClassName is the storage class of the volume, the default storage class is used if empty
+	// +kubebuilder:validation:Optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// MountPath is the path the volume is mounted at in the devbox
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=/home/devbox/project
+	MountPath string `json:"mountPath,omitempty"`
+	// ShutdownPolicy decides whether the volume is kept when the devbox is shutdown, it is always deleted with the devbox
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Retain
+	ShutdownPolicy WorkspaceReclaimPolicy `json:"shutdownPolicy,omitempty"`
+}
+
+// RetentionPolicy prunes old commit history and deletes their images from the registry
+type RetentionPolicy struct {
+	// KeepSuccessful is the number of latest successful commits to keep, commits referenced by a DevBoxRelease are always kept
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	KeepSuccessful int32 `json:"keepSuccessful"`
+}
+
+// IdlePolicy stops or shuts down the devbox after no ssh session, cpu usage or commit is seen for a while
+type IdlePolicy struct {
+	// StopAfter is the idle duration after which the devbox is stopped, 0 disables it
+	// +kubebuilder:validation:Optional
+	StopAfter *metav1.Duration `json:"stopAfter,omitempty"`
+	// ShutdownAfter is the idle duration after which the devbox is shutdown, 0 disables it
+	// +kubebuilder:validation:Optional
+	ShutdownAfter *metav1.Duration `json:"shutdownAfter,omitempty"`
 }
 
 type NetworkStatus struct {
@@ -145,7 +253,7 @@ type NetworkStatus struct {
 	// +kubebuilder:validation:Optional
 	NodePort int32 `json:"nodePort"`
 
-	// todo TailNet
+	// TailNet is the address of the devbox in the tailnet
 	// +kubebuilder:validation:Optional
 	TailNet string `json:"tailnet"`
 }
@@ -174,6 +282,9 @@ type CommitHistory struct {
 	Node string `json:"node"`
 	// ContainerID is the container id
 	ContainerID string `json:"containerID"`
+	// RestoredFrom is the image the pod is restored from, it is empty if the pod starts from the last successful commit
+	// +kubebuilder:validation:Optional
+	RestoredFrom string `json:"restoredFrom,omitempty"`
 }
 
 type DevboxPhase string
@@ -210,8 +321,34 @@ type DevboxStatus struct {
 	State corev1.ContainerState `json:"state"`
 	// +kubebuilder:validation:Optional
 	LastTerminationState corev1.ContainerState `json:"lastState"`
+
+	// LastActivityTime is the last time an ssh session, cpu usage or commit is seen
+	// +kubebuilder:validation:Optional
+	LastActivityTime *metav1.Time `json:"lastActivityTime,omitempty"`
+	// AppPorts are the app ports of the devbox merged with its template
+	// +kubebuilder:validation:Optional
+	AppPorts []corev1.ServicePort `json:"appPorts,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// DevboxConditionIdle is true when the devbox is stopped or shutdown by the idle policy
+	DevboxConditionIdle = "Idle"
+
+	DevboxReasonActive       = "Active"
+	DevboxReasonIdleStopped  = "IdleStopped"
+	DevboxReasonIdleShutdown = "IdleShutdown"
+
+	// DevboxConditionRestored reports the result of the last restore
+	DevboxConditionRestored = "Restored"
+
+	DevboxReasonRestored     = "Restored"
+	DevboxReasonInvalidImage = "InvalidImage"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="State",type="string",JSONPath=".spec.state"
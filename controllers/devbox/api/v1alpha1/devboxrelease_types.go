@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,6 +29,23 @@ type DevBoxReleaseSpec struct {
 	NewTag string `json:"newTag"`
 	// +kubebuilder:validation:Optional
 	Notes string `json:"notes,omitempty"`
+	// Deploy rolls out the release image to a Deployment after the release tag is created
+	// +kubebuilder:validation:Optional
+	Deploy *DeploySpec `json:"deploy,omitempty"`
+}
+
+// DeploySpec describes the Deployment running the release, ports are taken from devbox AppPorts
+// and the container runs devbox ReleaseCommand and ReleaseArgs
+type DeploySpec struct {
+	// Name is the name of the Deployment
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=1
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Resource defaults to the resource of devbox
+	// +kubebuilder:validation:Optional
+	Resource corev1.ResourceList `json:"resource,omitempty"`
 }
 
 type DevboxReleasePhase string
@@ -48,6 +66,47 @@ type DevBoxReleaseStatus struct {
 	Phase DevboxReleasePhase `json:"phase"`
 	// +kubebuilder:validation:Optional
 	OriginalImage string `json:"originalImage"`
+	// +kubebuilder:validation:Optional
+	Deploy *DeployStatus `json:"deploy,omitempty"`
+	// +kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// DevBoxReleaseConditionConflict is true when the Deployment in Spec.Deploy is not managed by the releases of the devbox
+	DevBoxReleaseConditionConflict = "Conflict"
+
+	DevBoxReleaseReasonDeploymentConflict = "DeploymentConflict"
+	DevBoxReleaseReasonNoConflict         = "NoConflict"
+)
+
+type DeployPhase string
+
+const (
+	// DeployPhaseProgressing means the Deployment is rolling out the release image
+	DeployPhaseProgressing DeployPhase = "Progressing"
+	// DeployPhaseAvailable means all replicas of the Deployment run the release image
+	DeployPhaseAvailable DeployPhase = "Available"
+	// DeployPhaseRolledBack means the rollout failed and the Deployment is rolled back to the previous image
+	DeployPhaseRolledBack DeployPhase = "RolledBack"
+	// DeployPhaseFailed means the rollout failed and there is no previous image to roll back to
+	DeployPhaseFailed DeployPhase = "Failed"
+)
+
+type DeployStatus struct {
+	// Name is the name of the Deployment
+	Name string `json:"name"`
+	// Image is the release image rolled out to the Deployment
+	Image string `json:"image"`
+	// PreviousImage is the image of the Deployment before the release, used for rollback
+	// +kubebuilder:validation:Optional
+	PreviousImage string `json:"previousImage,omitempty"`
+	// +kubebuilder:validation:Optional
+	Phase DeployPhase `json:"phase,omitempty"`
+	// +kubebuilder:validation:Optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -56,6 +115,7 @@ type DevBoxReleaseStatus struct {
 // +kubebuilder:printcolumn:name="NewTag",type="string",JSONPath=".spec.newTag"
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="OriginalImage",type="string",JSONPath=".status.originalImage"
+// +kubebuilder:printcolumn:name="Deploy",type="string",JSONPath=".status.deploy.phase"
 
 // DevBoxRelease is the Schema for the devboxreleases API
 type DevBoxRelease struct {
@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DevboxTemplateVersion is a released version of a template, it should not be changed once devboxes are pinned to it
+type DevboxTemplateVersion struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Image is the base image of devboxes which have no successful commit yet
+	// +kubebuilder:validation:Required
+	Image string `json:"image"`
+	// Config is the default config of devboxes, the fields set in a devbox override it
+	// +kubebuilder:validation:Optional
+	Config Config `json:"config"`
+}
+
+// DevboxTemplateSpec defines the desired state of DevboxTemplate
+type DevboxTemplateSpec struct {
+	// LatestVersion is the version new devboxes and devboxes with the Auto upgrade policy are pinned to
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	LatestVersion string `json:"latestVersion"`
+	// Versions are the released versions of the template, keep a version until no devbox is pinned to it
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Versions []DevboxTemplateVersion `json:"versions"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="LatestVersion",type="string",JSONPath=".spec.latestVersion"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// DevboxTemplate is the Schema for the devboxtemplates API
+type DevboxTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DevboxTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DevboxTemplateList contains a list of DevboxTemplate
+type DevboxTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DevboxTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DevboxTemplate{}, &DevboxTemplateList{})
+}
@@ -17,24 +17,77 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+type OperationAction string
+
+const (
+	// OperationActionRestart recreates the devbox pod, the current pod is committed as usual
+	OperationActionRestart OperationAction = "Restart"
+	// OperationActionResetSSHKey generates a new ssh key pair for the devbox and restarts it if running
+	OperationActionResetSSHKey OperationAction = "ResetSSHKey"
+	// OperationActionUpdateResource changes the resource of the devbox
+	OperationActionUpdateResource OperationAction = "UpdateResource"
+	// OperationActionExportWorkspace copies the image of the last successful commit to another image
+	OperationActionExportWorkspace OperationAction = "ExportWorkspace"
+)
 
 // OperationRequestSpec defines the desired state of OperationRequest
 type OperationRequestSpec struct {
+	// DevboxName is the devbox in the namespace of the request to operate on
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	DevboxName string `json:"devboxName"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Restart;ResetSSHKey;UpdateResource;ExportWorkspace
+	Action OperationAction `json:"action"`
+
+	// Resource is the new resource of the devbox, it is required by UpdateResource
+	// +kubebuilder:validation:Optional
+	Resource corev1.ResourceList `json:"resource,omitempty"`
+	// ExportImage is the image the workspace is exported to, it is required by ExportWorkspace.
+	// It must be in the registry of the commits and in a repository under the namespace of the devbox.
+	// Only committed changes are exported, restart the devbox first to export the latest changes,
+	// the persistent workspace volume is not committed and not exported.
+	// +kubebuilder:validation:Optional
+	ExportImage string `json:"exportImage,omitempty"`
 }
 
+type OperationRequestPhase string
+
+const (
+	OperationRequestPhasePending    OperationRequestPhase = "Pending"
+	OperationRequestPhaseProcessing OperationRequestPhase = "Processing"
+	OperationRequestPhaseCompleted  OperationRequestPhase = "Completed"
+	OperationRequestPhaseFailed     OperationRequestPhase = "Failed"
+)
+
 // OperationRequestStatus defines the observed state of OperationRequest
 type OperationRequestStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Phase is the recently observed lifecycle phase of the request
 	//+kubebuilder:default:=Pending
 	//+kubebuilder:validation:Enum=Pending;Processing;Completed;Failed
+	Phase OperationRequestPhase `json:"phase,omitempty"`
+	// Message is a human readable message about the result of the request
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+	// Image is the exported image of ExportWorkspace
+	// +kubebuilder:validation:Optional
+	Image string `json:"image,omitempty"`
+	// StartTime is recorded before the action is performed, Restart and ResetSSHKey are not performed again once it is set
+	// +kubebuilder:validation:Optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the request is completed or failed, the request is deleted after the retention time
+	// +kubebuilder:validation:Optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
+// +kubebuilder:printcolumn:name="Devbox",type="string",JSONPath=".spec.devboxName"
+// +kubebuilder:printcolumn:name="Action",type="string",JSONPath=".spec.action"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
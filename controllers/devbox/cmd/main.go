@@ -28,6 +28,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/ptr"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/labels"
@@ -46,9 +47,11 @@ import (
 
 	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
 	"github.com/labring/sealos/controllers/devbox/internal/controller"
+	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/idle"
 	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/matcher"
 	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/registry"
 	utilresource "github.com/labring/sealos/controllers/devbox/internal/controller/utils/resource"
+	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/tailnet"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -77,6 +80,10 @@ func main() {
 	var registryAddr string
 	var registryUser string
 	var registryPassword string
+	var registryPlainHTTP bool
+	var registryInsecure bool
+	var registryCAFile string
+	var keepSuccessfulCommits int
 	// resource flag
 	var requestCPURate float64
 	var requestMemoryRate float64
@@ -93,6 +100,22 @@ func main() {
 	var configBurst int
 	// config restart predicate duration
 	var restartPredicateDuration time.Duration
+	// tailnet flag
+	var tailnetCoordinatorURL string
+	var tailnetAPIKey string
+	var tailnetLoginServer string
+	var tailnetSidecarImage string
+	var tailnetUser string
+	var tailnetAuthKeyTTL time.Duration
+	// idle flag
+	var idleStopAfter time.Duration
+	var idleShutdownAfter time.Duration
+	var idleCPUThreshold string
+	var idleProbeInterval time.Duration
+	var idleEnabled bool
+	// operation request flag
+	var operationRequestExpirationTime time.Duration
+	var operationRequestRetentionTime time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -109,6 +132,10 @@ func main() {
 	flag.StringVar(&registryAddr, "registry-addr", "sealos.hub:5000", "The address of the registry")
 	flag.StringVar(&registryUser, "registry-user", "admin", "The user of the registry")
 	flag.StringVar(&registryPassword, "registry-password", "passw0rd", "The password of the registry")
+	flag.BoolVar(&registryPlainHTTP, "registry-plain-http", false, "If set, plain http is allowed when the registry does not serve https")
+	flag.BoolVar(&registryInsecure, "registry-insecure", false, "If set, tls certificate of the registry is not verified")
+	flag.StringVar(&registryCAFile, "registry-ca-file", "", "The PEM encoded CA certificates used to verify the registry")
+	flag.IntVar(&keepSuccessfulCommits, "keep-successful-commits", 0, "The default number of successful commits kept in devbox commit history, 0 keeps all")
 	// resource flag
 	flag.Float64Var(&requestCPURate, "request-cpu-rate", 10, "The request rate of cpu limit in devbox.")
 	flag.Float64Var(&requestMemoryRate, "request-memory-rate", 10, "The request rate of memory limit in devbox.")
@@ -125,6 +152,22 @@ func main() {
 	flag.IntVar(&configBurst, "config-burst", 100, "The burst of the config")
 	// config restart predicate duration
 	flag.DurationVar(&restartPredicateDuration, "restart-predicate-duration", 2*time.Hour, "Sets the restart predicate time duration for devbox controller restart. By default, the duration is set to 2 hours.")
+	// tailnet flag, tailnet network type will be disabled if coordinator url is empty
+	flag.StringVar(&tailnetCoordinatorURL, "tailnet-coordinator-url", "", "The url of the Headscale server used as tailnet coordinator")
+	flag.StringVar(&tailnetAPIKey, "tailnet-api-key", "", "The Headscale api key")
+	flag.StringVar(&tailnetUser, "tailnet-user", "devbox", "The Headscale user that owns devbox nodes")
+	flag.DurationVar(&tailnetAuthKeyTTL, "tailnet-auth-key-ttl", 30*24*time.Hour, "How long a tailnet pre-auth key of a devbox is valid, it is renewed a day before it expires")
+	flag.StringVar(&tailnetLoginServer, "tailnet-login-server", "", "The login server used by tailnet sidecar, defaults to tailnet coordinator url")
+	flag.StringVar(&tailnetSidecarImage, "tailnet-sidecar-image", "tailscale/tailscale:stable", "The image of tailnet sidecar")
+	// idle flag, the timeouts can be overridden by namespace annotations and devbox spec, 0 disables them
+	flag.DurationVar(&idleStopAfter, "idle-stop-after", 0, "The default idle duration after which devbox is stopped, 0 disables it")
+	flag.DurationVar(&idleShutdownAfter, "idle-shutdown-after", 0, "The default idle duration after which devbox is shutdown, 0 disables it")
+	flag.StringVar(&idleCPUThreshold, "idle-cpu-threshold", "50m", "The cpu usage below which devbox is considered idle")
+	flag.DurationVar(&idleProbeInterval, "idle-probe-interval", 5*time.Minute, "The interval of sampling devbox activity")
+	flag.BoolVar(&idleEnabled, "enable-idle", false, "Enable idle detection for policies set by namespace annotations or devbox spec, it is implied by a non-zero idle timeout")
+	// operation request flag
+	flag.DurationVar(&operationRequestExpirationTime, "operation-request-expiration-time", 10*time.Minute, "The time after creation an operation request fails if it is still not completed")
+	flag.DurationVar(&operationRequestRetentionTime, "operation-request-retention-time", 24*time.Hour, "The time a completed or failed operation request is kept before it is deleted")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -207,9 +250,11 @@ func main() {
 
 		NewCache: func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
 			opts.ByObject = map[client.Object]cache.ByObject{
-				&corev1.Service{}: {Label: cacheObjLabelSelector},
-				&corev1.Pod{}:     {Label: cacheObjLabelSelector},
-				&corev1.Secret{}:  {Label: cacheObjLabelSelector},
+				&corev1.Service{}:               {Label: cacheObjLabelSelector},
+				&corev1.Pod{}:                   {Label: cacheObjLabelSelector},
+				&corev1.Secret{}:                {Label: cacheObjLabelSelector},
+				&appsv1.Deployment{}:            {Label: cacheObjLabelSelector},
+				&corev1.PersistentVolumeClaim{}: {Label: cacheObjLabelSelector},
 			}
 			return cache.New(config, opts)
 		},
@@ -236,10 +281,46 @@ func main() {
 		podMatchers = append(podMatchers, matcher.EphemeralStorageMatcher{})
 	}
 
+	var tailnetCoordinator tailnet.Coordinator
+	if tailnetCoordinatorURL != "" {
+		tailnetCoordinator = tailnet.NewClient(tailnetCoordinatorURL, tailnetAPIKey, tailnetUser, tailnetAuthKeyTTL)
+		if tailnetLoginServer == "" {
+			tailnetLoginServer = tailnetCoordinatorURL
+		}
+	}
+
+	// idle detection probes every running devbox, only wire it in when some idle policy may apply
+	idlePolicy := idle.Policy{
+		StopAfter:     idleStopAfter,
+		ShutdownAfter: idleShutdownAfter,
+	}
+	var idleProber idle.Prober
+	if idleEnabled || idlePolicy.Enabled() {
+		if idleProber, err = idle.NewPodProber(mgr.GetClient(), mgr.GetConfig()); err != nil {
+			setupLog.Error(err, "unable to create idle prober")
+			os.Exit(1)
+		}
+	}
+	idleCPU := resource.MustParse(idleCPUThreshold)
+
+	registryClient := &registry.Client{
+		Username:           registryUser,
+		Password:           registryPassword,
+		PlainHTTP:          registryPlainHTTP,
+		InsecureSkipVerify: registryInsecure,
+	}
+	if registryCAFile != "" {
+		if registryClient.RootCAs, err = registry.LoadRootCAs(registryCAFile); err != nil {
+			setupLog.Error(err, "unable to load registry ca file")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controller.DevboxReconciler{
 		Client:              mgr.GetClient(),
 		Scheme:              mgr.GetScheme(),
 		CommitImageRegistry: registryAddr,
+		Registry:            registryClient,
 		Recorder:            mgr.GetEventRecorderFor("devbox-controller"),
 		RequestRate: utilresource.RequestRate{
 			CPU:    requestCPURate,
@@ -252,6 +333,14 @@ func main() {
 		},
 		PodMatchers:              podMatchers,
 		DebugMode:                debugMode,
+		Tailnet:                  tailnetCoordinator,
+		TailnetLoginServer:       tailnetLoginServer,
+		TailnetSidecarImage:      tailnetSidecarImage,
+		IdleProber:               idleProber,
+		IdlePolicy:               idlePolicy,
+		IdleCPUThreshold:         idleCPU.MilliValue(),
+		IdleProbeInterval:        idleProbeInterval,
+		KeepSuccessfulCommits:    int32(keepSuccessfulCommits),
 		RestartPredicateDuration: restartPredicateDuration,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Devbox")
@@ -259,24 +348,25 @@ func main() {
 	}
 
 	if err = (&controller.DevBoxReleaseReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Registry: &registry.Client{
-			Username: registryUser,
-			Password: registryPassword,
-		},
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
+		Registry:  registryClient,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DevBoxRelease")
 		os.Exit(1)
 	}
-	//if err = (&controller.OperationRequestReconciler{
-	//	CommitImageRegistry: registryAddr,
-	//	Client:              mgr.GetClient(),
-	//	Scheme:              mgr.GetScheme(),
-	//}).SetupWithManager(mgr); err != nil {
-	//	setupLog.Error(err, "unable to create controller", "controller", "OperationRequest")
-	//	os.Exit(1)
-	//}
+	if err = (&controller.OperationRequestReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Registry:       registryClient,
+		Recorder:       mgr.GetEventRecorderFor("devbox-operationrequest-controller"),
+		ExpirationTime: operationRequestExpirationTime,
+		RetentionTime:  operationRequestRetentionTime,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OperationRequest")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -18,19 +18,29 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	reference "github.com/google/go-containerregistry/pkg/name"
+
 	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
 	"github.com/labring/sealos/controllers/devbox/internal/controller/helper"
+	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/idle"
 	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/matcher"
+	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/registry"
 	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/resource"
+	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/tailnet"
 	"github.com/labring/sealos/controllers/devbox/label"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/tools/record"
@@ -43,14 +53,21 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // DevboxReconciler reconciles a Devbox object
 type DevboxReconciler struct {
 	CommitImageRegistry string
 
+	// Registry deletes the images of pruned commit history, nil disables pruning.
+	Registry *registry.Client
+	// KeepSuccessfulCommits is the default number of successful commits to keep, 0 keeps all.
+	KeepSuccessfulCommits int32
+
 	RequestRate      resource.RequestRate
 	EphemeralStorage resource.EphemeralStorage
 
@@ -58,6 +75,17 @@ type DevboxReconciler struct {
 
 	DebugMode bool
 
+	// Tailnet registers devboxes with the Tailnet network type, nil disables it.
+	Tailnet             tailnet.Coordinator
+	TailnetLoginServer  string
+	TailnetSidecarImage string
+
+	// IdleProber samples devbox activity for idle stop and shutdown, nil disables it.
+	IdleProber        idle.Prober
+	IdlePolicy        idle.Policy
+	IdleCPUThreshold  int64
+	IdleProbeInterval time.Duration
+
 	client.Client
 	Scheme                   *runtime.Scheme
 	Recorder                 record.EventRecorder
@@ -67,13 +95,19 @@ type DevboxReconciler struct {
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxes/finalizers,verbs=update
+// +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxreleases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxtemplates,verbs=get;list;watch
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=runtimes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=runtimeclasses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=pods,verbs=*
 // +kubebuilder:rbac:groups="",resources=pods/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=services,verbs=*
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=*
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete;deletecollection
 // +kubebuilder:rbac:groups="",resources=events,verbs=*
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=get
 
 func (r *DevboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -119,8 +153,27 @@ func (r *DevboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, nil
 	}
 
-	devbox.Status.Network.Type = devbox.Spec.NetworkSpec.Type
-	_ = r.Status().Update(ctx, devbox)
+	// pin the devbox to a template version and inherit its image and config
+	if devbox.Spec.TemplateRef != nil {
+		logger.Info("syncing template")
+		if err := r.syncTemplate(ctx, devbox); err != nil {
+			logger.Error(err, "sync template failed")
+			r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Sync template failed", "%v", err)
+			return ctrl.Result{}, err
+		}
+		logger.Info("sync template success", "template", devbox.Spec.TemplateRef.Name, "version", devbox.Spec.TemplateRef.Version)
+	}
+	helper.SetDevboxConfigDefaults(&devbox.Spec.Config)
+
+	if devbox.Status.Network.Type != devbox.Spec.NetworkSpec.Type ||
+		!equality.Semantic.DeepEqual(devbox.Status.AppPorts, devbox.Spec.Config.AppPorts) {
+		devbox.Status.Network.Type = devbox.Spec.NetworkSpec.Type
+		devbox.Status.AppPorts = devbox.Spec.Config.AppPorts
+		if err := r.Status().Update(ctx, devbox); err != nil {
+			logger.Error(err, "update devbox status failed")
+			return ctrl.Result{}, err
+		}
+	}
 
 	// create or update secret
 	logger.Info("syncing secret")
@@ -132,10 +185,12 @@ func (r *DevboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	logger.Info("sync secret success")
 	r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Sync secret success", "Sync secret success")
 
-	// create service if network type is NodePort
-	if devbox.Spec.NetworkSpec.Type == devboxv1alpha1.NetworkTypeNodePort {
+	var tailnetRequeueAfter time.Duration
+	switch devbox.Spec.NetworkSpec.Type {
+	case devboxv1alpha1.NetworkTypeNodePort:
+		// create service if network type is NodePort
 		logger.Info("syncing service")
-		if err := r.Get(ctx, req.NamespacedName, devbox); err != nil {
+		if err := r.getDevbox(ctx, req.NamespacedName, devbox); err != nil {
 			return ctrl.Result{}, err
 		}
 		if err := r.syncService(ctx, devbox, recLabels); err != nil {
@@ -145,6 +200,43 @@ func (r *DevboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 		logger.Info("sync service success")
 		r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Sync service success", "Sync service success")
+	case devboxv1alpha1.NetworkTypeTailnet:
+		// register tailnet node instead of allocating a NodePort
+		logger.Info("syncing tailnet")
+		if err := r.getDevbox(ctx, req.NamespacedName, devbox); err != nil {
+			return ctrl.Result{}, err
+		}
+		var err error
+		if tailnetRequeueAfter, err = r.syncTailnet(ctx, devbox); err != nil {
+			logger.Error(err, "sync tailnet failed")
+			r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Sync tailnet failed", "%v", err)
+			return ctrl.Result{}, err
+		}
+		logger.Info("sync tailnet success")
+		r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Sync tailnet success", "Sync tailnet success")
+	}
+
+	// create, resize or delete workspace volume claim
+	if devbox.Spec.Workspace != nil {
+		logger.Info("syncing workspace")
+		if err := r.syncWorkspace(ctx, devbox, recLabels); err != nil {
+			logger.Error(err, "sync workspace failed")
+			r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Sync workspace failed", "%v", err)
+			return ctrl.Result{}, err
+		}
+		logger.Info("sync workspace success")
+		r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Sync workspace success", "Sync workspace success")
+	}
+
+	// check the commit to restore from before the pod is recreated
+	if devbox.Spec.Restore != nil {
+		logger.Info("syncing restore")
+		if err := r.syncRestore(ctx, devbox); err != nil {
+			logger.Error(err, "sync restore failed")
+			r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Sync restore failed", "%v", err)
+			return ctrl.Result{}, err
+		}
+		logger.Info("sync restore success")
 	}
 
 	// create or update pod
@@ -157,8 +249,104 @@ func (r *DevboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	logger.Info("sync pod success")
 	r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Sync pod success", "Sync pod success")
 
+	// prune commit history and delete their images
+	if r.Registry != nil {
+		logger.Info("syncing commit history")
+		if err := r.syncCommitHistory(ctx, req.NamespacedName); err != nil {
+			logger.Error(err, "sync commit history failed")
+			r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Sync commit history failed", "%v", err)
+			return ctrl.Result{}, err
+		}
+		logger.Info("sync commit history success")
+	}
+
+	// stop or shutdown devbox if it is idle
+	var requeueAfter time.Duration
+	if r.IdleProber != nil {
+		logger.Info("syncing idle")
+		var err error
+		if requeueAfter, err = r.syncIdle(ctx, req.NamespacedName, recLabels); err != nil {
+			logger.Error(err, "sync idle failed")
+			r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Sync idle failed", "%v", err)
+			return ctrl.Result{}, err
+		}
+		logger.Info("sync idle success", "requeueAfter", requeueAfter)
+	}
+
+	if tailnetRequeueAfter > 0 && (requeueAfter == 0 || tailnetRequeueAfter < requeueAfter) {
+		requeueAfter = tailnetRequeueAfter
+	}
+
 	logger.Info("devbox reconcile success")
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// syncTemplate moves the devbox to the target version of its template, then merges the version into devbox.
+// Only the version in TemplateRef is written back, the merged devbox is kept in memory.
+func (r *DevboxReconciler) syncTemplate(ctx context.Context, devbox *devboxv1alpha1.Devbox) error {
+	template := &devboxv1alpha1.DevboxTemplate{}
+	if err := r.Get(ctx, client.ObjectKey{Name: devbox.Spec.TemplateRef.Name}, template); err != nil {
+		return err
+	}
+	current := devbox.Spec.TemplateRef.Version
+	target := helper.GetDevboxTemplateTargetVersion(devbox, template)
+	if target != current {
+		if _, err := helper.GetDevboxTemplateVersion(template, target); err != nil {
+			return err
+		}
+		key := client.ObjectKeyFromObject(devbox)
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latestDevbox := &devboxv1alpha1.Devbox{}
+			if err := r.Get(ctx, key, latestDevbox); err != nil {
+				return err
+			}
+			if latestDevbox.Spec.TemplateRef == nil {
+				return nil
+			}
+			latestDevbox.Spec.TemplateRef.Version = target
+			return r.Update(ctx, latestDevbox)
+		})
+		if err != nil {
+			return err
+		}
+		if current != "" {
+			r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Devbox template upgraded", "Devbox template %s upgraded from %s to %s", template.Name, current, target)
+		}
+		if err := r.Get(ctx, key, devbox); err != nil {
+			return err
+		}
+	}
+	return applyDevboxTemplate(ctx, r.Client, devbox)
+}
+
+// getDevbox gets the latest devbox and merges its template version into it
+func (r *DevboxReconciler) getDevbox(ctx context.Context, key types.NamespacedName, devbox *devboxv1alpha1.Devbox) error {
+	if err := r.Get(ctx, key, devbox); err != nil {
+		return err
+	}
+	return applyDevboxTemplate(ctx, r.Client, devbox)
+}
+
+// applyDevboxTemplate merges the pinned template version into devbox and defaults the fields set in neither of them,
+// the template is skipped if devbox has no template or is not pinned yet. The merged devbox must not be written back
+// to the cluster.
+func applyDevboxTemplate(ctx context.Context, c client.Client, devbox *devboxv1alpha1.Devbox) error {
+	ref := devbox.Spec.TemplateRef
+	if ref == nil || ref.Version == "" {
+		helper.SetDevboxConfigDefaults(&devbox.Spec.Config)
+		return nil
+	}
+	template := &devboxv1alpha1.DevboxTemplate{}
+	if err := c.Get(ctx, client.ObjectKey{Name: ref.Name}, template); err != nil {
+		return err
+	}
+	version, err := helper.GetDevboxTemplateVersion(template, ref.Version)
+	if err != nil {
+		return err
+	}
+	helper.ApplyDevboxTemplate(devbox, version)
+	helper.SetDevboxConfigDefaults(&devbox.Spec.Config)
+	return nil
 }
 
 func (r *DevboxReconciler) syncSecret(ctx context.Context, devbox *devboxv1alpha1.Devbox, recLabels map[string]string) error {
@@ -223,6 +411,84 @@ func (r *DevboxReconciler) syncSecret(ctx context.Context, devbox *devboxv1alpha
 	return nil
 }
 
+// syncRestore refuses to restore from a commit which is not successful, the restore is cleared and reported in conditions.
+func (r *DevboxReconciler) syncRestore(ctx context.Context, devbox *devboxv1alpha1.Devbox) error {
+	source := devbox
+	if name := devbox.Spec.Restore.DevboxName; name != "" && name != devbox.Name {
+		source = &devboxv1alpha1.Devbox{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: devbox.Namespace, Name: name}, source); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			source = nil
+		}
+	}
+	var err error
+	if source == nil {
+		err = fmt.Errorf("devbox %s to restore from not found", devbox.Spec.Restore.DevboxName)
+	} else {
+		err = helper.ValidateRestoreImage(source, devbox.Spec.Restore.Image)
+	}
+	if err == nil {
+		return nil
+	}
+	r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Restore devbox failed", "%v", err)
+	return r.finishRestore(ctx, devbox, metav1.ConditionFalse, devboxv1alpha1.DevboxReasonInvalidImage, err.Error())
+}
+
+// finishRestore clears the restore of devbox and records its result in the Restored condition
+func (r *DevboxReconciler) finishRestore(ctx context.Context, devbox *devboxv1alpha1.Devbox, status metav1.ConditionStatus, reason, message string) error {
+	key := client.ObjectKeyFromObject(devbox)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latestDevbox := &devboxv1alpha1.Devbox{}
+		if err := r.Get(ctx, key, latestDevbox); err != nil {
+			return err
+		}
+		latestDevbox.Spec.Restore = nil
+		return r.Update(ctx, latestDevbox)
+	})
+	if err != nil {
+		return err
+	}
+	devbox.Spec.Restore = nil
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latestDevbox := &devboxv1alpha1.Devbox{}
+		if err := r.Get(ctx, key, latestDevbox); err != nil {
+			return err
+		}
+		meta.SetStatusCondition(&latestDevbox.Status.Conditions, metav1.Condition{
+			Type:               devboxv1alpha1.DevboxConditionRestored,
+			Status:             status,
+			ObservedGeneration: latestDevbox.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+		return r.Status().Update(ctx, latestDevbox)
+	})
+}
+
+// syncWorkspace creates or resizes the workspace volume claim, it is deleted on shutdown if the policy is Delete.
+// The claim is protected by kubernetes until the devbox pod is gone, so it is safe to delete it before the pod.
+func (r *DevboxReconciler) syncWorkspace(ctx context.Context, devbox *devboxv1alpha1.Devbox, recLabels map[string]string) error {
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      helper.WorkspaceClaimName(devbox),
+			Namespace: devbox.Namespace,
+		},
+	}
+	if devbox.Spec.State == devboxv1alpha1.DevboxStateShutdown && devbox.Spec.Workspace.ShutdownPolicy == devboxv1alpha1.WorkspaceReclaimDelete {
+		return client.IgnoreNotFound(r.Delete(ctx, claim))
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, claim, func() error {
+		helper.MutateWorkspaceClaim(claim, devbox, recLabels)
+		return controllerutil.SetControllerReference(devbox, claim, r.Scheme)
+	})
+	if err != nil && helper.IsExceededQuotaError(err) {
+		r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Devbox is exceeded quota", "Devbox is exceeded quota")
+	}
+	return err
+}
+
 func (r *DevboxReconciler) syncPod(ctx context.Context, devbox *devboxv1alpha1.Devbox, recLabels map[string]string) error {
 	logger := log.FromContext(ctx)
 
@@ -285,13 +551,27 @@ func (r *DevboxReconciler) syncPod(ctx context.Context, devbox *devboxv1alpha1.D
 				logger.Info("devbox is exceeded quota, change devbox state to Stopped")
 				r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Devbox is exceeded quota", "Devbox is exceeded quota")
 				devbox.Spec.State = devboxv1alpha1.DevboxStateStopped
-				_ = r.Update(ctx, devbox)
+				// the devbox in memory may be merged with its template, only the state is written back
+				_ = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+					latestDevbox := &devboxv1alpha1.Devbox{}
+					if err := r.Get(ctx, client.ObjectKeyFromObject(devbox), latestDevbox); err != nil {
+						return err
+					}
+					latestDevbox.Spec.State = devboxv1alpha1.DevboxStateStopped
+					return r.Update(ctx, latestDevbox)
+				})
 				return nil
 			}
 			if err != nil {
 				logger.Error(err, "create pod failed")
 				return err
 			}
+			if devbox.Spec.Restore != nil {
+				logger.Info("devbox restored", "image", nextCommitHistory.RestoredFrom)
+				r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Devbox restored", "Devbox restored from %s", nextCommitHistory.RestoredFrom)
+				return r.finishRestore(ctx, devbox, metav1.ConditionTrue, devboxv1alpha1.DevboxReasonRestored,
+					fmt.Sprintf("Devbox restored from %s", nextCommitHistory.RestoredFrom))
+			}
 			return nil
 		case 1:
 			pod := &podList.Items[0]
@@ -307,6 +587,11 @@ func (r *DevboxReconciler) syncPod(ctx context.Context, devbox *devboxv1alpha1.D
 				logger.Info("pod has been deleted")
 				return r.handlePodDeleted(ctx, devbox, pod)
 			}
+			// restore is requested, the pod is committed on deletion and recreated from the restored image
+			if devbox.Spec.Restore != nil {
+				logger.Info("restore devbox, recreate pod", "image", devbox.Spec.Restore.Image)
+				return r.deletePod(ctx, devbox, pod)
+			}
 			switch matcher.PodMatchExpectations(expectPod, pod, r.PodMatchers...) {
 			case true:
 				// pod match expectations
@@ -387,7 +672,7 @@ func (r *DevboxReconciler) syncService(ctx context.Context, devbox *devboxv1alph
 	switch devbox.Spec.State {
 	case devboxv1alpha1.DevboxStateShutdown:
 		err := r.Client.Delete(ctx, service)
-		if err != nil && !errors.IsNotFound(err) {
+		if err != nil && !apierrors.IsNotFound(err) {
 			return err
 		}
 		devbox.Status.Network = devboxv1alpha1.NetworkStatus{
@@ -442,6 +727,257 @@ func (r *DevboxReconciler) syncService(ctx context.Context, devbox *devboxv1alph
 	return nil
 }
 
+const (
+	// tailnetAuthKeyRenewBefore is how long before its expiration the tailnet auth key is renewed
+	tailnetAuthKeyRenewBefore = 24 * time.Hour
+	// tailnetJoinCheckInterval is how often the address of a running devbox is checked until it joins the tailnet
+	tailnetJoinCheckInterval = 10 * time.Second
+)
+
+// syncTailnet stores a pre-auth key for the sidecar in devbox secret and reports the tailnet address,
+// it returns how long to wait before the address is checked again if the node has not joined yet.
+func (r *DevboxReconciler) syncTailnet(ctx context.Context, devbox *devboxv1alpha1.Devbox) (time.Duration, error) {
+	if r.Tailnet == nil {
+		return 0, fmt.Errorf("tailnet network is not enabled in devbox controller")
+	}
+	// release the NodePort if the devbox is switched from NodePort
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      devbox.Name + "-svc",
+			Namespace: devbox.Namespace,
+		},
+	}
+	if err := r.Client.Delete(ctx, service); err != nil && !apierrors.IsNotFound(err) {
+		return 0, err
+	}
+
+	switch devbox.Spec.State {
+	case devboxv1alpha1.DevboxStateShutdown:
+		if err := r.Tailnet.Deregister(ctx, helper.TailnetNodeName(devbox)); err != nil {
+			return 0, fmt.Errorf("failed to deregister tailnet node: %w", err)
+		}
+		devbox.Status.Network = devboxv1alpha1.NetworkStatus{
+			Type: devboxv1alpha1.NetworkTypeTailnet,
+		}
+		return 0, r.Status().Update(ctx, devbox)
+	case devboxv1alpha1.DevboxStateRunning, devboxv1alpha1.DevboxStateStopped:
+		// the sidecar joins the tailnet with the auth key in devbox secret, the key is renewed
+		// before it expires so that a restarted pod can join again
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: devbox.Namespace, Name: devbox.Name}, secret); err != nil {
+			return 0, fmt.Errorf("failed to get secret: %w", err)
+		}
+		expiration, _ := time.Parse(time.RFC3339, string(secret.Data[helper.TailnetAuthKeyExpiration]))
+		if len(secret.Data[helper.TailnetAuthKey]) == 0 || time.Until(expiration) < tailnetAuthKeyRenewBefore {
+			key, err := r.Tailnet.CreateAuthKey(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("failed to create tailnet auth key: %w", err)
+			}
+			if secret.Data == nil {
+				secret.Data = make(map[string][]byte)
+			}
+			secret.Data[helper.TailnetAuthKey] = []byte(key.Key)
+			secret.Data[helper.TailnetAuthKeyExpiration] = []byte(key.Expiration.UTC().Format(time.RFC3339))
+			if err := r.Update(ctx, secret); err != nil {
+				return 0, fmt.Errorf("failed to update secret: %w", err)
+			}
+		}
+
+		var requeueAfter time.Duration
+		address, err := r.Tailnet.Address(ctx, helper.TailnetNodeName(devbox))
+		switch {
+		case errors.Is(err, tailnet.ErrorNodeNotFound):
+			if devbox.Spec.State == devboxv1alpha1.DevboxStateRunning {
+				requeueAfter = tailnetJoinCheckInterval
+			}
+		case err != nil:
+			return 0, fmt.Errorf("failed to get tailnet address: %w", err)
+		}
+		if devbox.Status.Network.Type == devboxv1alpha1.NetworkTypeTailnet && devbox.Status.Network.TailNet == address {
+			return requeueAfter, nil
+		}
+		devbox.Status.Network = devboxv1alpha1.NetworkStatus{
+			Type:    devboxv1alpha1.NetworkTypeTailnet,
+			TailNet: address,
+		}
+		return requeueAfter, r.Status().Update(ctx, devbox)
+	}
+	return 0, nil
+}
+
+// syncCommitHistory prunes commit history by the retention policy, images of pruned commits are deleted from registry.
+func (r *DevboxReconciler) syncCommitHistory(ctx context.Context, key types.NamespacedName) error {
+	logger := log.FromContext(ctx)
+
+	devbox := &devboxv1alpha1.Devbox{}
+	if err := r.Get(ctx, key, devbox); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	keep := r.KeepSuccessfulCommits
+	if devbox.Spec.RetentionPolicy != nil {
+		keep = devbox.Spec.RetentionPolicy.KeepSuccessful
+	}
+	if keep <= 0 {
+		return nil
+	}
+	// release tags share the manifest with the original image, deleting it deletes the release tags too
+	releases := &devboxv1alpha1.DevBoxReleaseList{}
+	if err := r.List(ctx, releases, client.InNamespace(devbox.Namespace)); err != nil {
+		return err
+	}
+	retainedImages := make(map[string]bool)
+	for _, release := range releases.Items {
+		if release.Spec.DevboxName == devbox.Name && release.Status.OriginalImage != "" {
+			retainedImages[release.Status.OriginalImage] = true
+		}
+	}
+	// devboxes restored or forked from this devbox start from its images
+	devboxes := &devboxv1alpha1.DevboxList{}
+	if err := r.List(ctx, devboxes, client.InNamespace(devbox.Namespace)); err != nil {
+		return err
+	}
+	for i := range devboxes.Items {
+		for _, image := range helper.GetRestoreReferencedImages(&devboxes.Items[i]) {
+			retainedImages[image] = true
+		}
+	}
+	kept, pruned := helper.PruneCommitHistory(devbox.Status.CommitHistory, int(keep), retainedImages)
+	if len(pruned) == 0 {
+		return nil
+	}
+	for _, c := range pruned {
+		if err := r.deleteCommitImage(ctx, c.Image); err != nil {
+			// keep the commit history and retry next time
+			logger.Error(err, "delete commit image failed", "image", c.Image)
+			r.Recorder.Eventf(devbox, corev1.EventTypeWarning, "Delete commit image failed", "%v", err)
+			kept = append(kept, c)
+			continue
+		}
+		logger.Info("commit history pruned", "pod", c.Pod, "image", c.Image)
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Time.After(kept[j].Time.Time)
+	})
+	devbox.Status.CommitHistory = kept
+	return r.Status().Update(ctx, devbox)
+}
+
+func (r *DevboxReconciler) deleteCommitImage(ctx context.Context, image string) error {
+	ref, err := reference.ParseReference(image)
+	if err != nil {
+		return err
+	}
+	err = r.Registry.DeleteImage(ctx, ref.Context().RegistryStr(), ref.Context().RepositoryStr(), ref.Identifier())
+	if errors.Is(err, registry.ErrorManifestNotFound) {
+		return nil
+	}
+	return err
+}
+
+// syncIdle records the last activity of devbox and moves it to Stopped or Shutdown after the idle timeout,
+// it returns when the devbox should be checked again.
+func (r *DevboxReconciler) syncIdle(ctx context.Context, key types.NamespacedName, recLabels map[string]string) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+
+	devbox := &devboxv1alpha1.Devbox{}
+	if err := r.Get(ctx, key, devbox); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+	if devbox.Spec.State == devboxv1alpha1.DevboxStateShutdown {
+		return 0, nil
+	}
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: devbox.Namespace}, namespace); err != nil {
+		return 0, err
+	}
+	policy, err := idle.ResolvePolicy(devbox.Spec.IdlePolicy, namespace.Annotations, r.IdlePolicy)
+	if err != nil {
+		return 0, err
+	}
+	if !policy.Enabled() {
+		return 0, nil
+	}
+
+	now := time.Now()
+	lastActivity := idle.LastActivity(devbox)
+	var probeAfter time.Duration
+	if devbox.Spec.State == devboxv1alpha1.DevboxStateRunning {
+		probeAfter = r.IdleProbeInterval
+		active, err := r.probeActivity(ctx, devbox, recLabels)
+		if err != nil {
+			// never stop a devbox whose activity is unknown
+			logger.Error(err, "probe devbox activity failed")
+			active = true
+		}
+		if active {
+			lastActivity = now
+		}
+	}
+	decision := idle.Evaluate(policy, devbox.Spec.State, lastActivity, now)
+
+	oldStatus := devbox.Status.DeepCopy()
+	devbox.Status.LastActivityTime = &metav1.Time{Time: lastActivity}
+	switch {
+	case decision.State != "":
+		meta.SetStatusCondition(&devbox.Status.Conditions, metav1.Condition{
+			Type:               devboxv1alpha1.DevboxConditionIdle,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: devbox.Generation,
+			Reason:             decision.Reason,
+			Message:            decision.Message,
+		})
+	case devbox.Spec.State == devboxv1alpha1.DevboxStateRunning:
+		meta.SetStatusCondition(&devbox.Status.Conditions, metav1.Condition{
+			Type:               devboxv1alpha1.DevboxConditionIdle,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: devbox.Generation,
+			Reason:             devboxv1alpha1.DevboxReasonActive,
+			Message:            "Devbox is active",
+		})
+	}
+	if !equality.Semantic.DeepEqual(oldStatus, &devbox.Status) {
+		if err := r.Status().Update(ctx, devbox); err != nil {
+			return 0, err
+		}
+	}
+
+	if decision.State == "" {
+		requeueAfter := decision.RequeueAfter
+		if probeAfter > 0 && (requeueAfter == 0 || probeAfter < requeueAfter) {
+			requeueAfter = probeAfter
+		}
+		return requeueAfter, nil
+	}
+	// the state change triggers another reconcile to stop the pod
+	logger.Info("devbox is idle, change devbox state", "state", decision.State, "lastActivity", lastActivity)
+	devbox.Spec.State = decision.State
+	if err := r.Update(ctx, devbox); err != nil {
+		return 0, err
+	}
+	r.Recorder.Eventf(devbox, corev1.EventTypeNormal, decision.Reason, decision.Message)
+	return 0, nil
+}
+
+// probeActivity reports whether the devbox pod has ssh sessions or cpu usage, a pod that is not running yet counts as active.
+func (r *DevboxReconciler) probeActivity(ctx context.Context, devbox *devboxv1alpha1.Devbox, recLabels map[string]string) (bool, error) {
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList, client.InNamespace(devbox.Namespace), client.MatchingLabels(recLabels)); err != nil {
+		return false, err
+	}
+	if len(podList.Items) != 1 {
+		return true, nil
+	}
+	pod := &podList.Items[0]
+	if pod.Status.Phase != corev1.PodRunning || !pod.DeletionTimestamp.IsZero() {
+		return true, nil
+	}
+	activity, err := r.IdleProber.Probe(ctx, pod, devbox.Name)
+	if err != nil {
+		return false, err
+	}
+	return activity.Active(r.IdleCPUThreshold), nil
+}
+
 // create a new pod, add predicated status to nextCommitHistory
 func (r *DevboxReconciler) createPod(ctx context.Context, devbox *devboxv1alpha1.Devbox, expectPod *corev1.Pod, nextCommitHistory *devboxv1alpha1.CommitHistory) error {
 	logger := log.FromContext(ctx)
@@ -503,6 +1039,12 @@ func (r *DevboxReconciler) handlePodDeleted(ctx context.Context, devbox *devboxv
 }
 
 func (r *DevboxReconciler) removeAll(ctx context.Context, devbox *devboxv1alpha1.Devbox, recLabels map[string]string) error {
+	// Deregister tailnet node
+	if devbox.Spec.NetworkSpec.Type == devboxv1alpha1.NetworkTypeTailnet && r.Tailnet != nil {
+		if err := r.Tailnet.Deregister(ctx, helper.TailnetNodeName(devbox)); err != nil {
+			return err
+		}
+	}
 	// Delete Pod
 	podList := &corev1.PodList{}
 	if err := r.List(ctx, podList, client.InNamespace(devbox.Namespace), client.MatchingLabels(recLabels)); err != nil {
@@ -518,6 +1060,10 @@ func (r *DevboxReconciler) removeAll(ctx context.Context, devbox *devboxv1alpha1
 	if err := r.deleteResourcesByLabels(ctx, &corev1.Pod{}, devbox.Namespace, recLabels); err != nil {
 		return err
 	}
+	// Delete workspace volume claim
+	if err := r.deleteResourcesByLabels(ctx, &corev1.PersistentVolumeClaim{}, devbox.Namespace, recLabels); err != nil {
+		return err
+	}
 	// Delete Service
 	if err := r.deleteResourcesByLabels(ctx, &corev1.Service{}, devbox.Namespace, recLabels); err != nil {
 		return err
@@ -553,6 +1099,8 @@ func (r *DevboxReconciler) generateDevboxPod(devbox *devboxv1alpha1.Devbox, next
 	var imageName string
 	if r.DebugMode {
 		imageName = devbox.Spec.Image
+	} else if devbox.Spec.Restore != nil {
+		imageName = devbox.Spec.Restore.Image
 	} else {
 		imageName = helper.GetLastSuccessCommitImageName(devbox)
 	}
@@ -563,6 +1111,13 @@ func (r *DevboxReconciler) generateDevboxPod(devbox *devboxv1alpha1.Devbox, next
 	volumeMounts := devbox.Spec.Config.VolumeMounts
 	volumeMounts = append(volumeMounts, helper.GenerateSSHVolumeMounts()...)
 
+	var initContainers []corev1.Container
+	if devbox.Spec.Workspace != nil {
+		volumes = append(volumes, helper.GenerateWorkspaceVolume(devbox))
+		volumeMounts = append(volumeMounts, helper.GenerateWorkspaceVolumeMount(devbox))
+		initContainers = append(initContainers, helper.GenerateWorkspaceInitContainer(devbox, imageName))
+	}
+
 	containers := []corev1.Container{
 		{
 			Name:         devbox.ObjectMeta.Name,
@@ -577,6 +1132,10 @@ func (r *DevboxReconciler) generateDevboxPod(devbox *devboxv1alpha1.Devbox, next
 			Resources:  helper.GenerateResourceRequirements(devbox, r.RequestRate, r.EphemeralStorage)},
 	}
 
+	if devbox.Spec.NetworkSpec.Type == devboxv1alpha1.NetworkTypeTailnet {
+		initContainers = append(initContainers, helper.GenerateTailnetSidecar(devbox, r.TailnetSidecarImage, r.TailnetLoginServer))
+	}
+
 	terminationGracePeriodSeconds := 300
 	automountServiceAccountToken := false
 
@@ -595,9 +1154,10 @@ func (r *DevboxReconciler) generateDevboxPod(devbox *devboxv1alpha1.Devbox, next
 			AutomountServiceAccountToken:  ptr.To(automountServiceAccountToken),
 			RestartPolicy:                 corev1.RestartPolicyNever,
 
-			Hostname:   devbox.Name,
-			Containers: containers,
-			Volumes:    volumes,
+			Hostname:       devbox.Name,
+			InitContainers: initContainers,
+			Containers:     containers,
+			Volumes:        volumes,
 
 			RuntimeClassName: runtimeClassNamePtr,
 
@@ -614,13 +1174,17 @@ func (r *DevboxReconciler) generateDevboxPod(devbox *devboxv1alpha1.Devbox, next
 
 func (r *DevboxReconciler) generateNextCommitHistory(devbox *devboxv1alpha1.Devbox) *devboxv1alpha1.CommitHistory {
 	now := time.Now()
-	return &devboxv1alpha1.CommitHistory{
+	commit := &devboxv1alpha1.CommitHistory{
 		Image:            r.generateImageName(devbox),
 		Time:             metav1.Time{Time: now},
 		Pod:              devbox.Name + "-" + rand.String(5),
 		Status:           devboxv1alpha1.CommitStatusPending,
 		PredicatedStatus: devboxv1alpha1.CommitStatusPending,
 	}
+	if devbox.Spec.Restore != nil {
+		commit.RestoredFrom = devbox.Spec.Restore.Image
+	}
+	return commit
 }
 
 func (r *DevboxReconciler) generateImageName(devbox *devboxv1alpha1.Devbox) string {
@@ -646,6 +1210,22 @@ func (p *ControllerRestartPredicate) Create(e event.CreateEvent) bool {
 	return e.Object.GetCreationTimestamp().Time.After(p.checkTime)
 }
 
+// findDevboxesForTemplate enqueues the devboxes referencing template, so that they are upgraded or regenerated
+func (r *DevboxReconciler) findDevboxesForTemplate(ctx context.Context, template client.Object) []reconcile.Request {
+	devboxList := &devboxv1alpha1.DevboxList{}
+	if err := r.List(ctx, devboxList); err != nil {
+		log.FromContext(ctx).Error(err, "list devboxes failed", "template", template.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, devbox := range devboxList.Items {
+		if devbox.Spec.TemplateRef != nil && devbox.Spec.TemplateRef.Name == template.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&devbox)})
+		}
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DevboxReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -654,6 +1234,8 @@ func (r *DevboxReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&corev1.Pod{}, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})). // enqueue request if pod spec/status is updated
 		Owns(&corev1.Service{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Owns(&corev1.Secret{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&devboxv1alpha1.DevboxTemplate{}, handler.EnqueueRequestsFromMapFunc(r.findDevboxesForTemplate), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		WithEventFilter(NewControllerRestartPredicate(r.RestartPredicateDuration)).
 		Complete(r)
 }
@@ -28,6 +28,11 @@ import (
 	"github.com/labring/sealos/controllers/devbox/internal/controller/helper"
 	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/registry"
 
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -40,13 +45,17 @@ import (
 // DevBoxReleaseReconciler reconciles a DevBoxRelease object
 type DevBoxReleaseReconciler struct {
 	client.Client
-	Registry *registry.Client
-	Scheme   *runtime.Scheme
+	// APIReader reads Deployments that are not in the cache
+	APIReader client.Reader
+	Registry  *registry.Client
+	Scheme    *runtime.Scheme
 }
 
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxreleases,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxreleases/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=devbox.sealos.io,resources=devboxreleases/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list
 
 func (r *DevBoxReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -62,6 +71,11 @@ func (r *DevBoxReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			}
 		}
 	} else {
+		logger.Info("Deleting release tag", "devbox", devboxRelease.Spec.DevboxName, "newTag", devboxRelease.Spec.NewTag)
+		if err := r.DeleteReleaseTag(ctx, devboxRelease); err != nil {
+			logger.Error(err, "Failed to delete release tag", "devbox", devboxRelease.Spec.DevboxName, "newTag", devboxRelease.Spec.NewTag)
+			return ctrl.Result{}, err
+		}
 		if controllerutil.RemoveFinalizer(devboxRelease, devboxv1alpha1.FinalizerName) {
 			if err := r.Update(ctx, devboxRelease); err != nil {
 				return ctrl.Result{}, err
@@ -101,6 +115,17 @@ func (r *DevBoxReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			return ctrl.Result{}, err
 		}
 	}
+	if devboxRelease.Status.Phase == devboxv1alpha1.DevboxReleasePhaseSuccess && devboxRelease.Spec.Deploy != nil {
+		logger.Info("Deploying release", "devbox", devboxRelease.Spec.DevboxName, "newTag", devboxRelease.Spec.NewTag, "deployment", devboxRelease.Spec.Deploy.Name)
+		requeueAfter, err := r.SyncDeploy(ctx, devboxRelease)
+		if err != nil {
+			logger.Error(err, "Failed to deploy release", "devbox", devboxRelease.Spec.DevboxName, "newTag", devboxRelease.Spec.NewTag)
+			return ctrl.Result{}, err
+		}
+		if requeueAfter > 0 {
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+	}
 	logger.Info("Reconciliation complete", "devbox", devboxRelease.Spec.DevboxName, "newTag", devboxRelease.Spec.NewTag)
 	return ctrl.Result{}, nil
 }
@@ -133,12 +158,236 @@ func (r *DevBoxReleaseReconciler) CreateReleaseTag(ctx context.Context, devboxRe
 		logger.Error(err, "Failed to update status", "devbox", devboxRelease.Spec.DevboxName, "newTag", devboxRelease.Spec.NewTag)
 		return err
 	}
-	return r.Registry.TagImage(hostName, imageName, oldTag, devboxRelease.Spec.NewTag)
+	return r.Registry.TagImage(ctx, hostName, imageName, oldTag, devboxRelease.Spec.NewTag)
 }
 
-func (r *DevBoxReleaseReconciler) DeleteReleaseTag(_ context.Context, _ *devboxv1alpha1.DevBoxRelease) error {
-	//todo only delete CR without doing any other operations
-	return nil
+// SyncDeploy rolls out the release image to the Deployment in Spec.Deploy and tracks the rollout in status,
+// the Deployment is rolled back to the previous image if the rollout exceeds its progress deadline.
+// Spec.Deploy is applied on every reconcile, so that changes of replicas and resources take effect after the rollout.
+// Only Deployments owned by or labeled for the releases of the devbox are updated, others are reported as a Conflict.
+func (r *DevBoxReleaseReconciler) SyncDeploy(ctx context.Context, devboxRelease *devboxv1alpha1.DevBoxRelease) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+	devbox := &devboxv1alpha1.Devbox{}
+	devboxInfo := types.NamespacedName{
+		Name:      devboxRelease.Spec.DevboxName,
+		Namespace: devboxRelease.Namespace,
+	}
+	if err := r.Get(ctx, devboxInfo, devbox); err != nil {
+		return 0, err
+	}
+	// the release runs with the config the devbox inherits from its template
+	if err := applyDevboxTemplate(ctx, r.Client, devbox); err != nil {
+		return 0, err
+	}
+	deploymentInfo := types.NamespacedName{
+		Name:      devboxRelease.Spec.Deploy.Name,
+		Namespace: devboxRelease.Namespace,
+	}
+	deployment := &appsv1.Deployment{}
+	exists := true
+	if err := r.getDeployment(ctx, deploymentInfo, deployment); apierrors.IsNotFound(err) {
+		exists = false
+	} else if err != nil {
+		return 0, err
+	}
+
+	if exists && !helper.IsReleaseDeploymentOf(deployment, devboxRelease) {
+		logger.Info("Deployment is not managed by the releases of devbox, skip deploying", "deployment", deploymentInfo.Name)
+		return 0, r.setDeployConflict(ctx, devboxRelease, metav1.ConditionTrue, devboxv1alpha1.DevBoxReleaseReasonDeploymentConflict,
+			fmt.Sprintf("Deployment %s exists and is not managed by the releases of devbox %s", deploymentInfo.Name, devboxRelease.Spec.DevboxName))
+	}
+	if err := r.setDeployConflict(ctx, devboxRelease, metav1.ConditionFalse, devboxv1alpha1.DevBoxReleaseReasonNoConflict, ""); err != nil {
+		return 0, err
+	}
+
+	status := devboxRelease.Status.Deploy
+	takeOver := status == nil || status.Name != deploymentInfo.Name
+	if exists && !takeOver && deployment.Annotations[helper.ReleaseAnnotation] != devboxRelease.Name {
+		// a later release of the devbox has rolled out to the Deployment
+		logger.Info("Deployment is taken over by another release", "deployment", deploymentInfo.Name, "release", deployment.Annotations[helper.ReleaseAnnotation])
+		return 0, nil
+	}
+	if takeOver {
+		hostName, imageName, _, err := r.GetImageInfo(devbox, devboxRelease)
+		if err != nil {
+			return 0, err
+		}
+		image := fmt.Sprintf("%s/%s:%s", hostName, imageName, devboxRelease.Spec.NewTag)
+		// remember the previous image before rolling out, so that we can roll back to it
+		previousImage := ""
+		if exists {
+			previousImage = helper.GetReleaseDeploymentImage(deployment)
+		}
+		if previousImage == image {
+			previousImage = ""
+		}
+		status = &devboxv1alpha1.DeployStatus{
+			Name:          deploymentInfo.Name,
+			Image:         image,
+			PreviousImage: previousImage,
+			Phase:         devboxv1alpha1.DeployPhaseProgressing,
+		}
+		devboxRelease.Status.Deploy = status
+		if err := r.Status().Update(ctx, devboxRelease); err != nil {
+			return 0, err
+		}
+	}
+
+	image := status.Image
+	if status.Phase == devboxv1alpha1.DeployPhaseRolledBack {
+		image = status.PreviousImage
+	}
+	if !exists {
+		deployment = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      deploymentInfo.Name,
+				Namespace: deploymentInfo.Namespace,
+			},
+		}
+		helper.MutateReleaseDeployment(deployment, devbox, devboxRelease, image)
+		if err := r.Create(ctx, deployment); err != nil {
+			return 0, err
+		}
+	} else {
+		current := deployment.DeepCopy()
+		helper.MutateReleaseDeployment(deployment, devbox, devboxRelease, image)
+		if !equality.Semantic.DeepEqual(current, deployment) {
+			if err := r.Update(ctx, deployment); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	phase, message := helper.GetDeploymentRolloutPhase(deployment)
+	switch {
+	case status.Phase == devboxv1alpha1.DeployPhaseRolledBack:
+		// the release image is not rolled out again, only the rolled back Deployment is tracked
+		phase, message = status.Phase, status.Message
+	case phase == devboxv1alpha1.DeployPhaseFailed && status.PreviousImage != "":
+		logger.Info("Rollout failed, rolling back", "deployment", deployment.Name, "image", status.PreviousImage)
+		helper.SetReleaseDeploymentImage(deployment, status.PreviousImage)
+		if err := r.Update(ctx, deployment); err != nil {
+			return 0, err
+		}
+		phase = devboxv1alpha1.DeployPhaseRolledBack
+		message = fmt.Sprintf("rollout failed: %s, rolled back to %s", message, status.PreviousImage)
+	}
+	newStatus := status.DeepCopy()
+	newStatus.Phase = phase
+	newStatus.Message = message
+	newStatus.ReadyReplicas = deployment.Status.ReadyReplicas
+	if !equality.Semantic.DeepEqual(status, newStatus) {
+		devboxRelease.Status.Deploy = newStatus
+		if err := r.Status().Update(ctx, devboxRelease); err != nil {
+			return 0, err
+		}
+	}
+	if phase == devboxv1alpha1.DeployPhaseProgressing {
+		return time.Second * 10, nil
+	}
+	return 0, nil
+}
+
+// setDeployConflict updates the Conflict condition of the release when it changes
+func (r *DevBoxReleaseReconciler) setDeployConflict(ctx context.Context, devboxRelease *devboxv1alpha1.DevBoxRelease,
+	status metav1.ConditionStatus, reason, message string) error {
+	if status == metav1.ConditionFalse && meta.FindStatusCondition(devboxRelease.Status.Conditions, devboxv1alpha1.DevBoxReleaseConditionConflict) == nil {
+		return nil
+	}
+	if !meta.SetStatusCondition(&devboxRelease.Status.Conditions, metav1.Condition{
+		Type:               devboxv1alpha1.DevBoxReleaseConditionConflict,
+		Status:             status,
+		ObservedGeneration: devboxRelease.Generation,
+		Reason:             reason,
+		Message:            message,
+	}) {
+		return nil
+	}
+	return r.Status().Update(ctx, devboxRelease)
+}
+
+// getDeployment reads the Deployment from the cache, the cache only holds Deployments with the sealos labels,
+// so a missing Deployment is read from the API server again before it is created.
+func (r *DevBoxReleaseReconciler) getDeployment(ctx context.Context, key types.NamespacedName, deployment *appsv1.Deployment) error {
+	err := r.Get(ctx, key, deployment)
+	if apierrors.IsNotFound(err) && r.APIReader != nil {
+		return r.APIReader.Get(ctx, key, deployment)
+	}
+	return err
+}
+
+// DeleteReleaseTag deletes the release tag from registry. The release tag shares the manifest with the original image,
+// so it is left to the commit history pruning while the original image is still in use, and it is kept while
+// a Deployment or StatefulSet of the namespace still runs the release tag or the original image.
+func (r *DevBoxReleaseReconciler) DeleteReleaseTag(ctx context.Context, devboxRelease *devboxv1alpha1.DevBoxRelease) error {
+	if devboxRelease.Status.Phase != devboxv1alpha1.DevboxReleasePhaseSuccess || devboxRelease.Status.OriginalImage == "" {
+		return nil
+	}
+	devbox := &devboxv1alpha1.Devbox{}
+	devboxInfo := types.NamespacedName{
+		Name:      devboxRelease.Spec.DevboxName,
+		Namespace: devboxRelease.Namespace,
+	}
+	if err := r.Get(ctx, devboxInfo, devbox); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	for _, c := range devbox.Status.CommitHistory {
+		if c.Image == devboxRelease.Status.OriginalImage {
+			return nil
+		}
+	}
+	releases := &devboxv1alpha1.DevBoxReleaseList{}
+	if err := r.List(ctx, releases, client.InNamespace(devboxRelease.Namespace)); err != nil {
+		return err
+	}
+	for _, release := range releases.Items {
+		if release.Name != devboxRelease.Name && release.DeletionTimestamp.IsZero() &&
+			release.Status.OriginalImage == devboxRelease.Status.OriginalImage {
+			return nil
+		}
+	}
+	hostName, imageName, _, err := r.GetImageInfo(devbox, devboxRelease)
+	if err != nil {
+		return err
+	}
+	releaseImage := fmt.Sprintf("%s/%s:%s", hostName, imageName, devboxRelease.Spec.NewTag)
+	inUse, err := r.imageInUse(ctx, devboxRelease.Namespace, releaseImage, devboxRelease.Status.OriginalImage)
+	if err != nil || inUse {
+		return err
+	}
+	err = r.Registry.DeleteImage(ctx, hostName, imageName, devboxRelease.Spec.NewTag)
+	if errors.Is(err, registry.ErrorManifestNotFound) {
+		return nil
+	}
+	return err
+}
+
+// imageInUse reports whether a Deployment or StatefulSet of the namespace runs one of the images. The workloads are
+// read from the API server, the cache only holds the Deployments with the sealos labels.
+func (r *DevBoxReleaseReconciler) imageInUse(ctx context.Context, namespace string, images ...string) (bool, error) {
+	reader := client.Reader(r.Client)
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	deployments := &appsv1.DeploymentList{}
+	if err := reader.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for i := range deployments.Items {
+		if helper.PodSpecUsesImage(&deployments.Items[i].Spec.Template.Spec, images...) {
+			return true, nil
+		}
+	}
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := reader.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for i := range statefulSets.Items {
+		if helper.PodSpecUsesImage(&statefulSets.Items[i].Spec.Template.Spec, images...) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (r *DevBoxReleaseReconciler) GetImageInfo(devbox *devboxv1alpha1.Devbox, devboxRelease *devboxv1alpha1.DevBoxRelease) (string, string, string, error) {
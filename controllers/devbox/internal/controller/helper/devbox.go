@@ -21,6 +21,8 @@ import (
 
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/pem"
 
 	"golang.org/x/crypto/ssh"
@@ -36,6 +38,14 @@ import (
 
 const (
 	DevBoxPartOf = "devbox"
+	// TailnetAuthKey is the key of the tailnet pre-auth key in the devbox secret
+	TailnetAuthKey = "SEALOS_DEVBOX_TAILNET_AUTH_KEY"
+	// TailnetAuthKeyExpiration is the key of the expiration time of the tailnet pre-auth key in the devbox secret
+	TailnetAuthKeyExpiration = "SEALOS_DEVBOX_TAILNET_AUTH_KEY_EXPIRATION"
+	// WorkspaceVolumeName is the name of the workspace volume in the devbox pod
+	WorkspaceVolumeName = "workspace"
+
+	workspaceInitMountPath = "/mnt/workspace"
 )
 
 func GeneratePodLabels(devbox *devboxv1alpha1.Devbox) map[string]string {
@@ -121,6 +131,31 @@ func MergeCommitHistory(devbox *devboxv1alpha1.Devbox, latestDevbox *devboxv1alp
 	return res
 }
 
+// PruneCommitHistory splits commit history into kept and pruned ones, the latest keep successful commits,
+// commits not finished yet and commits whose image is in retainedImages are kept.
+func PruneCommitHistory(history []*devboxv1alpha1.CommitHistory, keep int, retainedImages map[string]bool) (kept, pruned []*devboxv1alpha1.CommitHistory) {
+	sorted := make([]*devboxv1alpha1.CommitHistory, len(history))
+	copy(sorted, history)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Time.After(sorted[j].Time.Time)
+	})
+	successful := 0
+	for _, c := range sorted {
+		switch {
+		case c.Status == devboxv1alpha1.CommitStatusSuccess && successful < keep:
+			successful++
+			kept = append(kept, c)
+		case c.Status != devboxv1alpha1.CommitStatusSuccess && c.Status != devboxv1alpha1.CommitStatusFailed:
+			kept = append(kept, c)
+		case retainedImages[c.Image]:
+			kept = append(kept, c)
+		default:
+			pruned = append(pruned, c)
+		}
+	}
+	return kept, pruned
+}
+
 func GenerateSSHKeyPair() ([]byte, []byte, error) {
 	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
@@ -275,15 +310,54 @@ func GetLastPredicatedSuccessCommitHistory(devbox *devboxv1alpha1.Devbox) *devbo
 	return nil
 }
 
+// GetLastSuccessCommitImageName returns the image of the last successful commit, if a restore happened after it
+// and the restored pod has not committed successfully yet, the image restored from is returned instead.
 func GetLastSuccessCommitImageName(devbox *devboxv1alpha1.Devbox) string {
 	if len(devbox.Status.CommitHistory) == 0 {
 		return devbox.Spec.Image
 	}
-	commit := GetLastSuccessCommitHistory(devbox)
-	if commit == nil {
-		return devbox.Spec.Image
+	// Sort commit history by time in descending order
+	sort.Slice(devbox.Status.CommitHistory, func(i, j int) bool {
+		return devbox.Status.CommitHistory[i].Time.After(devbox.Status.CommitHistory[j].Time.Time)
+	})
+	for _, commit := range devbox.Status.CommitHistory {
+		if commit.Status == devboxv1alpha1.CommitStatusSuccess {
+			return commit.Image
+		}
+		if commit.RestoredFrom != "" {
+			return commit.RestoredFrom
+		}
 	}
-	return commit.Image
+	return devbox.Spec.Image
+}
+
+// ValidateRestoreImage checks that image is a successful commit in the commit history of source
+func ValidateRestoreImage(source *devboxv1alpha1.Devbox, image string) error {
+	for _, commit := range source.Status.CommitHistory {
+		if commit.Image != image {
+			continue
+		}
+		if commit.Status != devboxv1alpha1.CommitStatusSuccess {
+			return fmt.Errorf("commit %s of devbox %s is %s, only successful commits can be restored", image, source.Name, commit.Status)
+		}
+		return nil
+	}
+	return fmt.Errorf("commit %s not found in commit history of devbox %s", image, source.Name)
+}
+
+// GetRestoreReferencedImages returns the images devbox is going to start from because of a restore,
+// they must be kept when the commit history of the devbox restored from is pruned.
+func GetRestoreReferencedImages(devbox *devboxv1alpha1.Devbox) []string {
+	var images []string
+	if devbox.Spec.Restore != nil {
+		images = append(images, devbox.Spec.Restore.Image)
+	}
+	for _, commit := range devbox.Status.CommitHistory {
+		if commit.RestoredFrom != "" && commit.Status != devboxv1alpha1.CommitStatusSuccess {
+			images = append(images, commit.RestoredFrom)
+		}
+	}
+	return images
 }
 
 func GenerateSSHVolumeMounts() []corev1.VolumeMount {
@@ -326,6 +400,148 @@ func GenerateSSHVolume(devbox *devboxv1alpha1.Devbox) corev1.Volume {
 	}
 }
 
+// WorkspaceClaimName returns the name of the workspace volume claim of devbox
+func WorkspaceClaimName(devbox *devboxv1alpha1.Devbox) string {
+	return devbox.Name + "-workspace"
+}
+
+// GetWorkspaceMountPath returns the mount path of the workspace volume, it defaults to the working directory
+func GetWorkspaceMountPath(devbox *devboxv1alpha1.Devbox) string {
+	if devbox.Spec.Workspace.MountPath != "" {
+		return devbox.Spec.Workspace.MountPath
+	}
+	return devbox.Spec.Config.WorkingDir
+}
+
+// GenerateWorkspaceVolume generates a volume for the workspace volume claim
+func GenerateWorkspaceVolume(devbox *devboxv1alpha1.Devbox) corev1.Volume {
+	return corev1.Volume{
+		Name: WorkspaceVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: WorkspaceClaimName(devbox),
+			},
+		},
+	}
+}
+
+// GenerateWorkspaceVolumeMount generates the volume mount of the workspace in the devbox container, writes to
+// the mount path go to the volume instead of the writable layer of the container, so they are never committed.
+func GenerateWorkspaceVolumeMount(devbox *devboxv1alpha1.Devbox) corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      WorkspaceVolumeName,
+		MountPath: GetWorkspaceMountPath(devbox),
+	}
+}
+
+// GenerateWorkspaceInitContainer generates an init container seeding an empty workspace volume with the content
+// of the mount path in image, so that the project files of the template are not hidden by the volume.
+func GenerateWorkspaceInitContainer(devbox *devboxv1alpha1.Devbox, image string) corev1.Container {
+	return corev1.Container{
+		Name:    "workspace-init",
+		Image:   image,
+		Command: []string{"/bin/sh", "-c"},
+		Args: []string{fmt.Sprintf(`if [ -z "$(ls -A %[2]s)" ] && [ -d %[1]q ]; then cp -a %[1]q/. %[2]s/; fi`,
+			GetWorkspaceMountPath(devbox), workspaceInitMountPath)},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      WorkspaceVolumeName,
+				MountPath: workspaceInitMountPath,
+			},
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("32Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			},
+		},
+	}
+}
+
+// MutateWorkspaceClaim updates claim to the workspace spec of devbox, storage class and access modes are
+// immutable and only set on creation, the size is only increased since volumes can not be shrunk.
+func MutateWorkspaceClaim(claim *corev1.PersistentVolumeClaim, devbox *devboxv1alpha1.Devbox, labels map[string]string) {
+	workspace := devbox.Spec.Workspace
+	if claim.Labels == nil {
+		claim.Labels = make(map[string]string)
+	}
+	for k, v := range labels {
+		claim.Labels[k] = v
+	}
+	if claim.CreationTimestamp.IsZero() {
+		claim.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		claim.Spec.StorageClassName = workspace.StorageClassName
+	}
+	if claim.Spec.Resources.Requests == nil {
+		claim.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	if current, ok := claim.Spec.Resources.Requests[corev1.ResourceStorage]; !ok || workspace.Size.Cmp(current) > 0 {
+		claim.Spec.Resources.Requests[corev1.ResourceStorage] = workspace.Size.DeepCopy()
+	}
+}
+
+// tailnetNodeNameMaxLen is the limit of a hostname label
+const tailnetNodeNameMaxLen = 63
+
+// TailnetNodeName returns the name of the devbox node in the tailnet, names longer than
+// a hostname label are truncated and suffixed with a hash to keep them unique
+func TailnetNodeName(devbox *devboxv1alpha1.Devbox) string {
+	name := devbox.Namespace + "-" + devbox.Name
+	if len(name) <= tailnetNodeNameMaxLen {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(sum[:])[:8]
+	return strings.TrimRight(name[:tailnetNodeNameMaxLen-len(suffix)-1], "-") + "-" + suffix
+}
+
+// GenerateTailnetSidecar generates a sidecar container joining the devbox to the tailnet,
+// it runs as a restartable init container so that the devbox container is still the first one.
+func GenerateTailnetSidecar(devbox *devboxv1alpha1.Devbox, image string, loginServer string) corev1.Container {
+	return corev1.Container{
+		Name:          "tailnet",
+		Image:         image,
+		RestartPolicy: ptr.To(corev1.ContainerRestartPolicyAlways),
+		Env: []corev1.EnvVar{
+			{
+				Name: "TS_AUTHKEY",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: devbox.Name},
+						Key:                  TailnetAuthKey,
+					},
+				},
+			},
+			{
+				Name:  "TS_HOSTNAME",
+				Value: TailnetNodeName(devbox),
+			},
+			{
+				Name:  "TS_USERSPACE",
+				Value: "true",
+			},
+			{
+				Name:  "TS_EXTRA_ARGS",
+				Value: "--login-server=" + loginServer,
+			},
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("32Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			},
+		},
+	}
+}
+
 // GenerateResourceRequirements generates the resource requirements for the Devbox pod
 func GenerateResourceRequirements(devbox *devboxv1alpha1.Devbox, requestRate utilsresource.RequestRate, ephemeralStorage utilsresource.EphemeralStorage) corev1.ResourceRequirements {
 	return corev1.ResourceRequirements{
@@ -0,0 +1,179 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+	"github.com/labring/sealos/controllers/devbox/label"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestPruneCommitHistory(t *testing.T) {
+	now := time.Now()
+	commit := func(image string, age time.Duration, status devboxv1alpha1.CommitStatus) *devboxv1alpha1.CommitHistory {
+		return &devboxv1alpha1.CommitHistory{Image: image, Time: metav1.NewTime(now.Add(-age)), Status: status}
+	}
+	history := []*devboxv1alpha1.CommitHistory{
+		commit("a", 6*time.Hour, devboxv1alpha1.CommitStatusSuccess),
+		commit("b", 5*time.Hour, devboxv1alpha1.CommitStatusSuccess),
+		commit("c", 4*time.Hour, devboxv1alpha1.CommitStatusFailed),
+		commit("d", 3*time.Hour, devboxv1alpha1.CommitStatusSuccess),
+		commit("e", 2*time.Hour, devboxv1alpha1.CommitStatusSuccess),
+		commit("f", time.Hour, devboxv1alpha1.CommitStatusPending),
+	}
+
+	kept, pruned := PruneCommitHistory(history, 2, map[string]bool{"a": true})
+	images := func(history []*devboxv1alpha1.CommitHistory) []string {
+		var res []string
+		for _, c := range history {
+			res = append(res, c.Image)
+		}
+		return res
+	}
+	if got, want := images(kept), []string{"f", "e", "d", "a"}; !slices.Equal(got, want) {
+		t.Errorf("PruneCommitHistory() kept = %v, want %v", got, want)
+	}
+	if got, want := images(pruned), []string{"c", "b"}; !slices.Equal(got, want) {
+		t.Errorf("PruneCommitHistory() pruned = %v, want %v", got, want)
+	}
+	if history[0].Image != "a" {
+		t.Error("PruneCommitHistory() should not reorder the input")
+	}
+}
+
+func TestMutateWorkspaceClaim(t *testing.T) {
+	devbox := &devboxv1alpha1.Devbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "devbox-sample"},
+		Spec: devboxv1alpha1.DevboxSpec{
+			Workspace: &devboxv1alpha1.WorkspaceSpec{
+				Size:             resource.MustParse("10Gi"),
+				StorageClassName: ptr.To("openebs-lvmpv"),
+			},
+		},
+	}
+	claim := &corev1.PersistentVolumeClaim{}
+	MutateWorkspaceClaim(claim, devbox, map[string]string{label.AppPartOf: DevBoxPartOf})
+	if got := claim.Spec.Resources.Requests[corev1.ResourceStorage]; got.Cmp(resource.MustParse("10Gi")) != 0 {
+		t.Errorf("MutateWorkspaceClaim() size = %s, want 10Gi", got.String())
+	}
+	if claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName != "openebs-lvmpv" {
+		t.Errorf("MutateWorkspaceClaim() storage class = %v", claim.Spec.StorageClassName)
+	}
+	if claim.Labels[label.AppPartOf] != DevBoxPartOf {
+		t.Errorf("MutateWorkspaceClaim() labels = %v", claim.Labels)
+	}
+
+	// volumes can not be shrunk
+	claim.CreationTimestamp = metav1.Now()
+	devbox.Spec.Workspace.Size = resource.MustParse("5Gi")
+	MutateWorkspaceClaim(claim, devbox, nil)
+	if got := claim.Spec.Resources.Requests[corev1.ResourceStorage]; got.Cmp(resource.MustParse("10Gi")) != 0 {
+		t.Errorf("MutateWorkspaceClaim() shrunk size = %s, want 10Gi", got.String())
+	}
+	devbox.Spec.Workspace.Size = resource.MustParse("20Gi")
+	MutateWorkspaceClaim(claim, devbox, nil)
+	if got := claim.Spec.Resources.Requests[corev1.ResourceStorage]; got.Cmp(resource.MustParse("20Gi")) != 0 {
+		t.Errorf("MutateWorkspaceClaim() expanded size = %s, want 20Gi", got.String())
+	}
+}
+
+func TestGenerateWorkspaceVolumeMount(t *testing.T) {
+	devbox := &devboxv1alpha1.Devbox{
+		Spec: devboxv1alpha1.DevboxSpec{
+			Config:    devboxv1alpha1.Config{WorkingDir: "/home/devbox/project"},
+			Workspace: &devboxv1alpha1.WorkspaceSpec{Size: resource.MustParse("1Gi")},
+		},
+	}
+	if got := GenerateWorkspaceVolumeMount(devbox); got.Name != WorkspaceVolumeName || got.MountPath != "/home/devbox/project" {
+		t.Errorf("GenerateWorkspaceVolumeMount() = %+v, want workspace volume at working dir", got)
+	}
+	devbox.Spec.Workspace.MountPath = "/data"
+	if got := GenerateWorkspaceVolumeMount(devbox); got.MountPath != "/data" {
+		t.Errorf("GenerateWorkspaceVolumeMount() mount path = %s, want /data", got.MountPath)
+	}
+}
+
+func TestValidateRestoreImage(t *testing.T) {
+	devbox := &devboxv1alpha1.Devbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "devbox-sample"},
+		Status: devboxv1alpha1.DevboxStatus{
+			CommitHistory: []*devboxv1alpha1.CommitHistory{
+				{Image: "a", Status: devboxv1alpha1.CommitStatusSuccess},
+				{Image: "b", Status: devboxv1alpha1.CommitStatusFailed},
+			},
+		},
+	}
+	if err := ValidateRestoreImage(devbox, "a"); err != nil {
+		t.Errorf("ValidateRestoreImage() error = %v", err)
+	}
+	if err := ValidateRestoreImage(devbox, "b"); err == nil {
+		t.Error("ValidateRestoreImage() expected error for failed commit")
+	}
+	if err := ValidateRestoreImage(devbox, "c"); err == nil {
+		t.Error("ValidateRestoreImage() expected error for unknown commit")
+	}
+}
+
+func TestGetLastSuccessCommitImageNameRestored(t *testing.T) {
+	now := time.Now()
+	devbox := &devboxv1alpha1.Devbox{
+		Spec: devboxv1alpha1.DevboxSpec{Image: "base"},
+		Status: devboxv1alpha1.DevboxStatus{
+			CommitHistory: []*devboxv1alpha1.CommitHistory{
+				{Image: "a", Time: metav1.NewTime(now.Add(-3 * time.Hour)), Status: devboxv1alpha1.CommitStatusSuccess},
+				{Image: "b", Time: metav1.NewTime(now.Add(-2 * time.Hour)), Status: devboxv1alpha1.CommitStatusSuccess},
+				{Image: "c", Time: metav1.NewTime(now.Add(-time.Hour)), Status: devboxv1alpha1.CommitStatusFailed, RestoredFrom: "a"},
+			},
+		},
+	}
+	if got := GetLastSuccessCommitImageName(devbox); got != "a" {
+		t.Errorf("GetLastSuccessCommitImageName() = %s, want image restored from", got)
+	}
+	if got := GetRestoreReferencedImages(devbox); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("GetRestoreReferencedImages() = %v, want [a]", got)
+	}
+	devbox.Status.CommitHistory = append(devbox.Status.CommitHistory,
+		&devboxv1alpha1.CommitHistory{Image: "d", Time: metav1.NewTime(now), Status: devboxv1alpha1.CommitStatusSuccess})
+	if got := GetLastSuccessCommitImageName(devbox); got != "d" {
+		t.Errorf("GetLastSuccessCommitImageName() = %s, want d", got)
+	}
+}
+
+func TestTailnetNodeName(t *testing.T) {
+	devbox := &devboxv1alpha1.Devbox{ObjectMeta: metav1.ObjectMeta{Namespace: "ns-user", Name: "devbox"}}
+	if got := TailnetNodeName(devbox); got != "ns-user-devbox" {
+		t.Errorf("TailnetNodeName() = %s, want ns-user-devbox", got)
+	}
+
+	devbox.Name = strings.Repeat("a", 60)
+	got := TailnetNodeName(devbox)
+	if len(got) > 63 || !strings.HasPrefix(got, "ns-user-aaaa") {
+		t.Errorf("TailnetNodeName() = %s, want a hostname label of at most 63 characters", got)
+	}
+	other := devbox.DeepCopy()
+	other.Name = strings.Repeat("a", 59) + "b"
+	if TailnetNodeName(other) == got {
+		t.Errorf("TailnetNodeName() of different devboxes must not collide")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"strings"
+
+	reference "github.com/google/go-containerregistry/pkg/name"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ValidateOperationRequest checks that the action of request can be performed on devbox
+func ValidateOperationRequest(request *devboxv1alpha1.OperationRequest, devbox *devboxv1alpha1.Devbox) error {
+	switch request.Spec.Action {
+	case devboxv1alpha1.OperationActionRestart:
+		if devbox.Spec.State != devboxv1alpha1.DevboxStateRunning {
+			return fmt.Errorf("devbox %s is %s, only running devbox can be restarted", devbox.Name, devbox.Spec.State)
+		}
+	case devboxv1alpha1.OperationActionResetSSHKey:
+	case devboxv1alpha1.OperationActionUpdateResource:
+		if len(request.Spec.Resource) == 0 {
+			return fmt.Errorf("resource is required by %s", request.Spec.Action)
+		}
+		for name, quantity := range request.Spec.Resource {
+			if quantity.Sign() <= 0 {
+				return fmt.Errorf("resource %s must be positive", name)
+			}
+		}
+	case devboxv1alpha1.OperationActionExportWorkspace:
+		if request.Spec.ExportImage == "" {
+			return fmt.Errorf("export image is required by %s", request.Spec.Action)
+		}
+		commit := GetLastSuccessCommitHistory(devbox)
+		if commit == nil {
+			return fmt.Errorf("devbox %s has no successful commit to export", devbox.Name)
+		}
+		if err := validateExportImage(request.Spec.ExportImage, commit.Image, devbox.Namespace); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid action %s", request.Spec.Action)
+	}
+	return nil
+}
+
+// validateExportImage limits the export image to the repositories of namespace in the registry of the commit image,
+// the image is pushed with the credentials of the controller and must not reach other namespaces or registries.
+func validateExportImage(exportImage, commitImage, namespace string) error {
+	src, err := reference.ParseReference(commitImage)
+	if err != nil {
+		return err
+	}
+	dst, err := reference.ParseReference(exportImage)
+	if err != nil {
+		return fmt.Errorf("invalid export image %s: %w", exportImage, err)
+	}
+	if dst.Context().RegistryStr() != src.Context().RegistryStr() {
+		return fmt.Errorf("export image %s must be in registry %s", exportImage, src.Context().RegistryStr())
+	}
+	if !strings.HasPrefix(dst.Context().RepositoryStr(), namespace+"/") {
+		return fmt.Errorf("export image %s must be in repository %s/%s", exportImage, src.Context().RegistryStr(), namespace)
+	}
+	return nil
+}
+
+// ResetSSHKeys replaces the ssh key pair in the devbox secret, the new public key is the only authorized key
+func ResetSSHKeys(secret *corev1.Secret) error {
+	publicKey, privateKey, err := GenerateSSHKeyPair()
+	if err != nil {
+		return fmt.Errorf("failed to generate SSH key pair: %w", err)
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data["SEALOS_DEVBOX_PUBLIC_KEY"] = publicKey
+	secret.Data["SEALOS_DEVBOX_PRIVATE_KEY"] = privateKey
+	secret.Data["SEALOS_DEVBOX_AUTHORIZED_KEYS"] = publicKey
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateOperationRequest(t *testing.T) {
+	running := &devboxv1alpha1.Devbox{Spec: devboxv1alpha1.DevboxSpec{State: devboxv1alpha1.DevboxStateRunning}}
+	stopped := &devboxv1alpha1.Devbox{Spec: devboxv1alpha1.DevboxSpec{State: devboxv1alpha1.DevboxStateStopped}}
+	committed := &devboxv1alpha1.Devbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "devbox-sample", Namespace: "ns-user"},
+		Status: devboxv1alpha1.DevboxStatus{
+			CommitHistory: []*devboxv1alpha1.CommitHistory{
+				{Image: "sealos.hub:5000/ns-user/devbox-sample:abcde-2024-01-01-000000", Status: devboxv1alpha1.CommitStatusSuccess},
+			},
+		},
+	}
+	request := func(spec devboxv1alpha1.OperationRequestSpec) *devboxv1alpha1.OperationRequest {
+		return &devboxv1alpha1.OperationRequest{Spec: spec}
+	}
+
+	tests := []struct {
+		name    string
+		request *devboxv1alpha1.OperationRequest
+		devbox  *devboxv1alpha1.Devbox
+		wantErr bool
+	}{
+		{
+			name:    "restart running devbox",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionRestart}),
+			devbox:  running,
+		},
+		{
+			name:    "restart stopped devbox",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionRestart}),
+			devbox:  stopped,
+			wantErr: true,
+		},
+		{
+			name:    "reset ssh key of stopped devbox",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionResetSSHKey}),
+			devbox:  stopped,
+		},
+		{
+			name: "update resource",
+			request: request(devboxv1alpha1.OperationRequestSpec{
+				Action:   devboxv1alpha1.OperationActionUpdateResource,
+				Resource: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			}),
+			devbox: running,
+		},
+		{
+			name:    "update resource without resource",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionUpdateResource}),
+			devbox:  running,
+			wantErr: true,
+		},
+		{
+			name: "update resource to zero",
+			request: request(devboxv1alpha1.OperationRequestSpec{
+				Action:   devboxv1alpha1.OperationActionUpdateResource,
+				Resource: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("0")},
+			}),
+			devbox:  running,
+			wantErr: true,
+		},
+		{
+			name:    "export workspace",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionExportWorkspace, ExportImage: "sealos.hub:5000/ns-user/app:v1"}),
+			devbox:  committed,
+		},
+		{
+			name:    "export workspace without commit",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionExportWorkspace, ExportImage: "sealos.hub:5000/ns-user/app:v1"}),
+			devbox:  running,
+			wantErr: true,
+		},
+		{
+			name:    "export workspace to another namespace",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionExportWorkspace, ExportImage: "sealos.hub:5000/ns-admin/app:v1"}),
+			devbox:  committed,
+			wantErr: true,
+		},
+		{
+			name:    "export workspace to another registry",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionExportWorkspace, ExportImage: "docker.io/ns-user/app:v1"}),
+			devbox:  committed,
+			wantErr: true,
+		},
+		{
+			name:    "export workspace without image",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: devboxv1alpha1.OperationActionExportWorkspace}),
+			devbox:  committed,
+			wantErr: true,
+		},
+		{
+			name:    "invalid action",
+			request: request(devboxv1alpha1.OperationRequestSpec{Action: "Delete"}),
+			devbox:  running,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateOperationRequest(tt.request, tt.devbox); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOperationRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResetSSHKeys(t *testing.T) {
+	secret := &corev1.Secret{Data: map[string][]byte{
+		"SEALOS_DEVBOX_JWT_SECRET":      []byte("jwt"),
+		"SEALOS_DEVBOX_PUBLIC_KEY":      []byte("old"),
+		"SEALOS_DEVBOX_AUTHORIZED_KEYS": []byte("old\nother"),
+	}}
+	if err := ResetSSHKeys(secret); err != nil {
+		t.Fatalf("ResetSSHKeys() error = %v", err)
+	}
+	publicKey := secret.Data["SEALOS_DEVBOX_PUBLIC_KEY"]
+	if bytes.Equal(publicKey, []byte("old")) || len(secret.Data["SEALOS_DEVBOX_PRIVATE_KEY"]) == 0 {
+		t.Error("ResetSSHKeys() should generate a new key pair")
+	}
+	if !bytes.Equal(secret.Data["SEALOS_DEVBOX_AUTHORIZED_KEYS"], publicKey) {
+		t.Error("ResetSSHKeys() should only authorize the new public key")
+	}
+	if string(secret.Data["SEALOS_DEVBOX_JWT_SECRET"]) != "jwt" {
+		t.Error("ResetSSHKeys() should keep other keys")
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+
+	reference "github.com/google/go-containerregistry/pkg/name"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+	"github.com/labring/sealos/controllers/devbox/label"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// ReleaseComponent is the component label of release workloads
+	ReleaseComponent = "release"
+	// ReleaseAnnotation records the DevBoxRelease rolled out to the Deployment
+	ReleaseAnnotation = "devbox.sealos.io/release"
+	// ReleaseDevboxAnnotation records the devbox whose releases are rolled out to the Deployment
+	ReleaseDevboxAnnotation = "devbox.sealos.io/release-devbox"
+
+	releaseProgressDeadlineSeconds = 600
+)
+
+// GenerateReleaseLabels returns the labels of the release Deployment, they are also used to cache it
+func GenerateReleaseLabels(name string) map[string]string {
+	return label.RecommendedLabels(&label.Recommended{
+		Name:      name,
+		Component: ReleaseComponent,
+		ManagedBy: label.DefaultManagedBy,
+		PartOf:    DevBoxPartOf,
+	})
+}
+
+// GenerateReleasePodLabels returns the labels of release pods, part-of label is left out
+// so that devbox controller does not take them as devbox pods
+func GenerateReleasePodLabels(name string) map[string]string {
+	return label.RecommendedLabels(&label.Recommended{
+		Name:      name,
+		Component: ReleaseComponent,
+		ManagedBy: label.DefaultManagedBy,
+	})
+}
+
+// IsReleaseDeploymentOf reports whether deployment is owned by release or labeled for the releases of its devbox,
+// any other Deployment with the same name must not be taken over
+func IsReleaseDeploymentOf(deployment *appsv1.Deployment, release *devboxv1alpha1.DevBoxRelease) bool {
+	if metav1.IsControlledBy(deployment, release) {
+		return true
+	}
+	for k, v := range GenerateReleaseLabels(release.Spec.Deploy.Name) {
+		if deployment.Labels[k] != v {
+			return false
+		}
+	}
+	return deployment.Annotations[ReleaseDevboxAnnotation] == release.Spec.DevboxName
+}
+
+// MutateReleaseDeployment updates deployment to run image with the release config of devbox
+func MutateReleaseDeployment(deployment *appsv1.Deployment, devbox *devboxv1alpha1.Devbox, release *devboxv1alpha1.DevBoxRelease, image string) {
+	spec := release.Spec.Deploy
+	if deployment.Labels == nil {
+		deployment.Labels = make(map[string]string)
+	}
+	for k, v := range GenerateReleaseLabels(spec.Name) {
+		deployment.Labels[k] = v
+	}
+	if deployment.Annotations == nil {
+		deployment.Annotations = make(map[string]string)
+	}
+	deployment.Annotations[ReleaseAnnotation] = release.Name
+	deployment.Annotations[ReleaseDevboxAnnotation] = release.Spec.DevboxName
+
+	podLabels := GenerateReleasePodLabels(spec.Name)
+	// selector is immutable, only set it on creation, pods of an adopted Deployment keep matching its selector
+	if deployment.Spec.Selector == nil {
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: podLabels}
+	}
+	for k, v := range deployment.Spec.Selector.MatchLabels {
+		podLabels[k] = v
+	}
+	deployment.Spec.Replicas = spec.Replicas
+	if deployment.Spec.Replicas == nil {
+		deployment.Spec.Replicas = ptr.To(int32(1))
+	}
+	deployment.Spec.ProgressDeadlineSeconds = ptr.To(int32(releaseProgressDeadlineSeconds))
+	deployment.Spec.Template.Labels = podLabels
+
+	var ports []corev1.ContainerPort
+	for _, p := range devbox.Spec.Config.AppPorts {
+		port := p.TargetPort.IntVal
+		if port == 0 {
+			port = p.Port
+		}
+		ports = append(ports, corev1.ContainerPort{
+			Name:          p.Name,
+			ContainerPort: port,
+			Protocol:      p.Protocol,
+		})
+	}
+	resources := spec.Resource
+	if len(resources) == 0 {
+		resources = devbox.Spec.Resource
+	}
+	container := corev1.Container{
+		Name:       spec.Name,
+		Image:      image,
+		Command:    devbox.Spec.Config.ReleaseCommand,
+		Args:       devbox.Spec.Config.ReleaseArgs,
+		WorkingDir: devbox.Spec.Config.WorkingDir,
+		Env:        devbox.Spec.Config.Env,
+		Ports:      ports,
+		Resources: corev1.ResourceRequirements{
+			Limits: resources.DeepCopy(),
+		},
+	}
+	deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
+	deployment.Spec.Template.Spec.AutomountServiceAccountToken = ptr.To(false)
+	deployment.Spec.Template.Spec.RuntimeClassName = nil
+	if devbox.Spec.RuntimeClassName != "" {
+		deployment.Spec.Template.Spec.RuntimeClassName = ptr.To(devbox.Spec.RuntimeClassName)
+	}
+}
+
+// SetReleaseDeploymentImage sets the image of the release container of deployment
+func SetReleaseDeploymentImage(deployment *appsv1.Deployment, image string) {
+	if len(deployment.Spec.Template.Spec.Containers) > 0 {
+		deployment.Spec.Template.Spec.Containers[0].Image = image
+	}
+}
+
+// GetReleaseDeploymentImage returns the image of the release container of deployment
+func GetReleaseDeploymentImage(deployment *appsv1.Deployment) string {
+	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return ""
+	}
+	return deployment.Spec.Template.Spec.Containers[0].Image
+}
+
+// GetDeploymentRolloutPhase returns the rollout phase of deployment, it is failed if the progress deadline is exceeded
+func GetDeploymentRolloutPhase(deployment *appsv1.Deployment) (devboxv1alpha1.DeployPhase, string) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return devboxv1alpha1.DeployPhaseProgressing, "waiting for deployment spec update to be observed"
+	}
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			return devboxv1alpha1.DeployPhaseFailed, c.Message
+		}
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	switch {
+	case status.UpdatedReplicas < replicas:
+		return devboxv1alpha1.DeployPhaseProgressing, fmt.Sprintf("%d of %d replicas updated", status.UpdatedReplicas, replicas)
+	case status.Replicas > status.UpdatedReplicas:
+		return devboxv1alpha1.DeployPhaseProgressing, fmt.Sprintf("%d old replicas pending termination", status.Replicas-status.UpdatedReplicas)
+	case status.AvailableReplicas < status.UpdatedReplicas:
+		return devboxv1alpha1.DeployPhaseProgressing, fmt.Sprintf("%d of %d updated replicas available", status.AvailableReplicas, status.UpdatedReplicas)
+	}
+	return devboxv1alpha1.DeployPhaseAvailable, "rollout finished"
+}
+
+// PodSpecUsesImage reports whether a container of spec runs one of the images,
+// the images are compared by reference so that nginx is the same as docker.io/library/nginx:latest
+func PodSpecUsesImage(spec *corev1.PodSpec, images ...string) bool {
+	containers := make([]corev1.Container, 0, len(spec.InitContainers)+len(spec.Containers))
+	containers = append(containers, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, c := range containers {
+		for _, image := range images {
+			if sameImage(c.Image, image) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameImage(a, b string) bool {
+	if a == b {
+		return true
+	}
+	refA, err := reference.ParseReference(a)
+	if err != nil {
+		return false
+	}
+	refB, err := reference.ParseReference(b)
+	if err != nil {
+		return false
+	}
+	return refA.Name() == refB.Name()
+}
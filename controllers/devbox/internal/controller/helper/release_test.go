@@ -0,0 +1,190 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+	"github.com/labring/sealos/controllers/devbox/label"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+func TestMutateReleaseDeployment(t *testing.T) {
+	devbox := &devboxv1alpha1.Devbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "devbox-sample"},
+		Spec: devboxv1alpha1.DevboxSpec{
+			Resource: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			Config: devboxv1alpha1.Config{
+				ReleaseCommand: []string{"/bin/bash", "-c"},
+				ReleaseArgs:    []string{"/home/devbox/project/entrypoint.sh"},
+				AppPorts: []corev1.ServicePort{
+					{Name: "http", Port: 80, TargetPort: intstr.FromInt32(8080), Protocol: corev1.ProtocolTCP},
+				},
+			},
+		},
+	}
+	release := &devboxv1alpha1.DevBoxRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "devbox-sample-v1"},
+		Spec: devboxv1alpha1.DevBoxReleaseSpec{
+			DevboxName: "devbox-sample",
+			NewTag:     "v1",
+			Deploy:     &devboxv1alpha1.DeploySpec{Name: "app", Replicas: ptr.To(int32(2))},
+		},
+	}
+	deployment := &appsv1.Deployment{}
+	MutateReleaseDeployment(deployment, devbox, release, "sealos.hub:5000/default/devbox-sample:v1")
+
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("replicas = %d, want 2", *deployment.Spec.Replicas)
+	}
+	if _, ok := deployment.Spec.Template.Labels[label.AppPartOf]; ok {
+		t.Error("release pods should not be labeled as devbox pods")
+	}
+	c := deployment.Spec.Template.Spec.Containers[0]
+	if c.Image != "sealos.hub:5000/default/devbox-sample:v1" || c.Args[0] != "/home/devbox/project/entrypoint.sh" {
+		t.Errorf("container = %+v", c)
+	}
+	if len(c.Ports) != 1 || c.Ports[0].ContainerPort != 8080 {
+		t.Errorf("ports = %+v, want container port 8080", c.Ports)
+	}
+	if !c.Resources.Limits.Cpu().Equal(resource.MustParse("1")) {
+		t.Errorf("limits = %v, want devbox resource", c.Resources.Limits)
+	}
+	if deployment.Annotations[ReleaseAnnotation] != release.Name {
+		t.Errorf("annotations = %v", deployment.Annotations)
+	}
+
+	adopted := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	MutateReleaseDeployment(adopted, devbox, release, "sealos.hub:5000/default/devbox-sample:v1")
+	if adopted.Labels[label.AppManagedBy] != label.DefaultManagedBy || adopted.Labels[label.AppPartOf] != DevBoxPartOf {
+		t.Errorf("adopted labels = %v, want the cache labels", adopted.Labels)
+	}
+	if adopted.Spec.Template.Labels["app"] != "web" {
+		t.Errorf("adopted pod labels = %v, want to match the selector", adopted.Spec.Template.Labels)
+	}
+}
+
+func TestGetDeploymentRolloutPhase(t *testing.T) {
+	tests := []struct {
+		name   string
+		status appsv1.DeploymentStatus
+		want   devboxv1alpha1.DeployPhase
+	}{
+		{
+			name:   "not observed",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 1},
+			want:   devboxv1alpha1.DeployPhaseProgressing,
+		},
+		{
+			name:   "old replicas",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 3},
+			want:   devboxv1alpha1.DeployPhaseProgressing,
+		},
+		{
+			name:   "available",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			want:   devboxv1alpha1.DeployPhaseAvailable,
+		},
+		{
+			name: "deadline exceeded",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+			}},
+			want: devboxv1alpha1.DeployPhaseFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+				Status:     tt.status,
+			}
+			if got, _ := GetDeploymentRolloutPhase(deployment); got != tt.want {
+				t.Errorf("GetDeploymentRolloutPhase() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsReleaseDeploymentOf(t *testing.T) {
+	devbox := &devboxv1alpha1.Devbox{ObjectMeta: metav1.ObjectMeta{Name: "devbox-sample"}}
+	release := &devboxv1alpha1.DevBoxRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "devbox-sample-v2", UID: "uid-v2"},
+		Spec: devboxv1alpha1.DevBoxReleaseSpec{
+			DevboxName: "devbox-sample",
+			NewTag:     "v2",
+			Deploy:     &devboxv1alpha1.DeploySpec{Name: "app"},
+		},
+	}
+	previous := release.DeepCopy()
+	previous.Name = "devbox-sample-v1"
+	released := &appsv1.Deployment{}
+	MutateReleaseDeployment(released, devbox, previous, "sealos.hub:5000/default/devbox-sample:v1")
+	if !IsReleaseDeploymentOf(released, release) {
+		t.Error("Deployment of a previous release of the devbox should be managed")
+	}
+
+	other := release.DeepCopy()
+	other.Spec.DevboxName = "other"
+	if IsReleaseDeploymentOf(released, other) {
+		t.Error("Deployment of the releases of another devbox should not be managed")
+	}
+	launchpad := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"app": "app"}}}
+	if IsReleaseDeploymentOf(launchpad, release) {
+		t.Error("Deployment without release labels should not be managed")
+	}
+	launchpad.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "devbox.sealos.io/v1alpha1", Kind: "DevBoxRelease", Name: release.Name, UID: release.UID, Controller: ptr.To(true)},
+	}
+	if !IsReleaseDeploymentOf(launchpad, release) {
+		t.Error("Deployment controlled by the release should be managed")
+	}
+}
+
+func TestPodSpecUsesImage(t *testing.T) {
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Image: "busybox"}},
+		Containers:     []corev1.Container{{Name: "app", Image: "registry.io/ns-test/devbox-sample:v1"}},
+	}
+	tests := []struct {
+		name   string
+		images []string
+		want   bool
+	}{
+		{name: "release tag", images: []string{"registry.io/ns-test/devbox-sample:v1"}, want: true},
+		{name: "init container", images: []string{"docker.io/library/busybox:latest"}, want: true},
+		{name: "other tag", images: []string{"registry.io/ns-test/devbox-sample:v2"}, want: false},
+		{name: "other repository", images: []string{"registry.io/ns-test/other:v1", ""}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PodSpecUsesImage(spec, tt.images...); got != tt.want {
+				t.Errorf("PodSpecUsesImage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GetDevboxTemplateVersion returns the version of template named version
+func GetDevboxTemplateVersion(template *devboxv1alpha1.DevboxTemplate, version string) (*devboxv1alpha1.DevboxTemplateVersion, error) {
+	for i := range template.Spec.Versions {
+		if template.Spec.Versions[i].Name == version {
+			return &template.Spec.Versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("version %s not found in devbox template %s", version, template.Name)
+}
+
+// GetDevboxTemplateTargetVersion returns the version the devbox should be pinned to, which is the latest version
+// of template if the devbox is not pinned yet or upgrades automatically
+func GetDevboxTemplateTargetVersion(devbox *devboxv1alpha1.Devbox, template *devboxv1alpha1.DevboxTemplate) string {
+	ref := devbox.Spec.TemplateRef
+	if ref.Version == "" || ref.UpgradePolicy == devboxv1alpha1.TemplateUpgradeAuto {
+		return template.Spec.LatestVersion
+	}
+	return ref.Version
+}
+
+// ApplyDevboxTemplate merges the image and config of version into the spec of devbox, the fields set in devbox win.
+// The merged devbox is only used to generate its resources, it must not be written back to the cluster.
+func ApplyDevboxTemplate(devbox *devboxv1alpha1.Devbox, version *devboxv1alpha1.DevboxTemplateVersion) {
+	if devbox.Spec.Image == "" {
+		devbox.Spec.Image = version.Image
+	}
+	devbox.Spec.Config = MergeDevboxConfig(version.Config, devbox.Spec.Config)
+}
+
+// SetDevboxConfigDefaults fills the fields of config that are set neither in the devbox nor in its template.
+// It must be called on the merged config, otherwise the defaults would override the template.
+func SetDevboxConfigDefaults(config *devboxv1alpha1.Config) {
+	if config.User == "" {
+		config.User = "devbox"
+	}
+	if config.WorkingDir == "" {
+		config.WorkingDir = "/home/devbox/project"
+	}
+	if len(config.ReleaseCommand) == 0 {
+		config.ReleaseCommand = []string{"/bin/bash", "-c"}
+	}
+	if len(config.ReleaseArgs) == 0 {
+		config.ReleaseArgs = []string{"/home/devbox/project/entrypoint.sh"}
+	}
+	if len(config.Ports) == 0 {
+		config.Ports = []corev1.ContainerPort{{Name: "devbox-ssh-port", ContainerPort: 22, Protocol: corev1.ProtocolTCP}}
+	}
+	if len(config.AppPorts) == 0 {
+		config.AppPorts = []corev1.ServicePort{{Name: "devbox-app-port", Port: 8080, Protocol: corev1.ProtocolTCP}}
+	}
+}
+
+// MergeDevboxConfig overrides base with the fields set in override. Maps are merged by key, env by name,
+// ports by port number and volumes by name, other fields are replaced if they are set in override.
+func MergeDevboxConfig(base, override devboxv1alpha1.Config) devboxv1alpha1.Config {
+	merged := *base.DeepCopy()
+	override = *override.DeepCopy()
+
+	if override.User != "" {
+		merged.User = override.User
+	}
+	merged.Labels = mergeMap(merged.Labels, override.Labels)
+	merged.Annotations = mergeMap(merged.Annotations, override.Annotations)
+	if len(override.Command) > 0 {
+		merged.Command = override.Command
+	}
+	if len(override.Args) > 0 {
+		merged.Args = override.Args
+	}
+	if override.WorkingDir != "" {
+		merged.WorkingDir = override.WorkingDir
+	}
+	merged.Env = mergeByKey(merged.Env, override.Env, func(e corev1.EnvVar) string { return e.Name })
+	if len(override.ReleaseCommand) > 0 {
+		merged.ReleaseCommand = override.ReleaseCommand
+	}
+	if len(override.ReleaseArgs) > 0 {
+		merged.ReleaseArgs = override.ReleaseArgs
+	}
+	merged.Ports = mergeByKey(merged.Ports, override.Ports, func(p corev1.ContainerPort) string {
+		return portKey(p.ContainerPort, p.Protocol)
+	})
+	merged.AppPorts = mergeByKey(merged.AppPorts, override.AppPorts, func(p corev1.ServicePort) string {
+		return portKey(p.Port, p.Protocol)
+	})
+	merged.VolumeMounts = mergeByKey(merged.VolumeMounts, override.VolumeMounts, func(m corev1.VolumeMount) string { return m.MountPath })
+	merged.Volumes = mergeByKey(merged.Volumes, override.Volumes, func(v corev1.Volume) string { return v.Name })
+	return merged
+}
+
+func portKey(port int32, protocol corev1.Protocol) string {
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	return strconv.Itoa(int(port)) + "/" + string(protocol)
+}
+
+func mergeMap(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	if base == nil {
+		base = make(map[string]string, len(override))
+	}
+	maps.Copy(base, override)
+	return base
+}
+
+// mergeByKey replaces the items of base with the items of override which have the same key, and appends the rest
+func mergeByKey[T any](base, override []T, key func(T) string) []T {
+	if len(override) == 0 {
+		return base
+	}
+	index := make(map[string]int, len(base))
+	for i, item := range base {
+		index[key(item)] = i
+	}
+	for _, item := range override {
+		if i, ok := index[key(item)]; ok {
+			base[i] = item
+			continue
+		}
+		index[key(item)] = len(base)
+		base = append(base, item)
+	}
+	return base
+}
@@ -0,0 +1,125 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeDevboxConfig(t *testing.T) {
+	base := devboxv1alpha1.Config{
+		User:       "devbox",
+		WorkingDir: "/home/devbox/project",
+		Labels:     map[string]string{"runtime": "go", "tier": "base"},
+		Env: []corev1.EnvVar{
+			{Name: "GOPROXY", Value: "https://proxy.golang.org"},
+			{Name: "GOPATH", Value: "/home/devbox/go"},
+		},
+		AppPorts: []corev1.ServicePort{{Name: "devbox-app-port", Port: 8080, Protocol: corev1.ProtocolTCP}},
+		Command:  []string{"/bin/bash", "-c"},
+	}
+	override := devboxv1alpha1.Config{
+		Labels:   map[string]string{"tier": "custom"},
+		Env:      []corev1.EnvVar{{Name: "GOPROXY", Value: "https://goproxy.io"}, {Name: "DEBUG", Value: "1"}},
+		AppPorts: []corev1.ServicePort{{Name: "http", Port: 8080}, {Name: "grpc", Port: 9090}},
+	}
+	merged := MergeDevboxConfig(base, override)
+
+	if merged.User != "devbox" || merged.WorkingDir != "/home/devbox/project" || len(merged.Command) != 2 {
+		t.Errorf("unset fields should be inherited, got %+v", merged)
+	}
+	if merged.Labels["runtime"] != "go" || merged.Labels["tier"] != "custom" {
+		t.Errorf("labels = %v", merged.Labels)
+	}
+	wantEnv := []corev1.EnvVar{
+		{Name: "GOPROXY", Value: "https://goproxy.io"},
+		{Name: "GOPATH", Value: "/home/devbox/go"},
+		{Name: "DEBUG", Value: "1"},
+	}
+	if len(merged.Env) != len(wantEnv) {
+		t.Fatalf("env = %v, want %v", merged.Env, wantEnv)
+	}
+	for i := range wantEnv {
+		if merged.Env[i] != wantEnv[i] {
+			t.Errorf("env[%d] = %v, want %v", i, merged.Env[i], wantEnv[i])
+		}
+	}
+	if len(merged.AppPorts) != 2 || merged.AppPorts[0].Name != "http" || merged.AppPorts[1].Port != 9090 {
+		t.Errorf("app ports = %v", merged.AppPorts)
+	}
+	if base.Labels["tier"] != "base" || base.Env[0].Value != "https://proxy.golang.org" {
+		t.Error("MergeDevboxConfig() should not modify base")
+	}
+}
+
+func TestGetDevboxTemplateTargetVersion(t *testing.T) {
+	template := &devboxv1alpha1.DevboxTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "go"},
+		Spec: devboxv1alpha1.DevboxTemplateSpec{
+			LatestVersion: "v2",
+			Versions:      []devboxv1alpha1.DevboxTemplateVersion{{Name: "v1", Image: "go:v1"}, {Name: "v2", Image: "go:v2"}},
+		},
+	}
+	tests := []struct {
+		name string
+		ref  devboxv1alpha1.TemplateRef
+		want string
+	}{
+		{name: "not pinned", ref: devboxv1alpha1.TemplateRef{Name: "go"}, want: "v2"},
+		{name: "manual", ref: devboxv1alpha1.TemplateRef{Name: "go", Version: "v1", UpgradePolicy: devboxv1alpha1.TemplateUpgradeManual}, want: "v1"},
+		{name: "auto", ref: devboxv1alpha1.TemplateRef{Name: "go", Version: "v1", UpgradePolicy: devboxv1alpha1.TemplateUpgradeAuto}, want: "v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devbox := &devboxv1alpha1.Devbox{Spec: devboxv1alpha1.DevboxSpec{TemplateRef: &tt.ref}}
+			if got := GetDevboxTemplateTargetVersion(devbox, template); got != tt.want {
+				t.Errorf("GetDevboxTemplateTargetVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := GetDevboxTemplateVersion(template, "v3"); err == nil {
+		t.Error("GetDevboxTemplateVersion() should fail for unknown version")
+	}
+	devbox := &devboxv1alpha1.Devbox{Spec: devboxv1alpha1.DevboxSpec{Image: "custom:latest"}}
+	version, _ := GetDevboxTemplateVersion(template, "v1")
+	ApplyDevboxTemplate(devbox, version)
+	if devbox.Spec.Image != "custom:latest" {
+		t.Errorf("image = %s, the image of devbox should win", devbox.Spec.Image)
+	}
+}
+
+func TestSetDevboxConfigDefaults(t *testing.T) {
+	config := devboxv1alpha1.Config{
+		User:  "root",
+		Ports: []corev1.ContainerPort{{Name: "ssh", ContainerPort: 2222}},
+	}
+	SetDevboxConfigDefaults(&config)
+
+	if config.User != "root" || len(config.Ports) != 1 || config.Ports[0].ContainerPort != 2222 {
+		t.Errorf("set fields should be kept, got %+v", config)
+	}
+	if config.WorkingDir != "/home/devbox/project" || len(config.ReleaseCommand) != 2 || len(config.ReleaseArgs) != 1 {
+		t.Errorf("unset fields should be defaulted, got %+v", config)
+	}
+	if len(config.AppPorts) != 1 || config.AppPorts[0].Port != 8080 {
+		t.Errorf("app ports = %v", config.AppPorts)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+	"github.com/labring/sealos/controllers/devbox/internal/controller/helper"
+	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/registry"
+	"github.com/labring/sealos/controllers/devbox/label"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// OperationRequestReconciler reconciles a OperationRequest object
+type OperationRequestReconciler struct {
+	client.Client
+	// Registry copies images for ExportWorkspace, nil fails the export requests.
+	Registry *registry.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// ExpirationTime is the time after creation a request fails if it is still not completed
+	ExpirationTime time.Duration
+	// RetentionTime is the time a completed or failed request is kept before it is deleted
+	RetentionTime time.Duration
+}
+
+// +kubebuilder:rbac:groups=devbox.sealos.io,resources=operationrequests,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=devbox.sealos.io,resources=operationrequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=devbox.sealos.io,resources=operationrequests/finalizers,verbs=update
+
+func (r *OperationRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	request := &devboxv1alpha1.OperationRequest{}
+	if err := r.Get(ctx, req.NamespacedName, request); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !request.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// delete the request once it is finished for the retention time
+	if isOperationRequestFinished(request) {
+		retainFor := time.Until(request.CreationTimestamp.Add(r.RetentionTime))
+		if request.Status.CompletionTime != nil {
+			retainFor = time.Until(request.Status.CompletionTime.Add(r.RetentionTime))
+		}
+		if retainFor > 0 {
+			return ctrl.Result{RequeueAfter: retainFor}, nil
+		}
+		logger.Info("delete finished operation request", "phase", request.Status.Phase)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, request))
+	}
+	if request.CreationTimestamp.Add(r.ExpirationTime).Before(time.Now()) {
+		logger.Info("operation request expired")
+		return r.finish(ctx, request, devboxv1alpha1.OperationRequestPhaseFailed, "Operation request expired before it is completed")
+	}
+
+	logger.Info("processing operation request", "devbox", request.Spec.DevboxName, "action", request.Spec.Action)
+	if request.Status.Phase != devboxv1alpha1.OperationRequestPhaseProcessing {
+		request.Status.Phase = devboxv1alpha1.OperationRequestPhaseProcessing
+		if err := r.Status().Update(ctx, request); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	devbox := &devboxv1alpha1.Devbox{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: request.Namespace, Name: request.Spec.DevboxName}, devbox); err != nil {
+		if apierrors.IsNotFound(err) {
+			return r.finish(ctx, request, devboxv1alpha1.OperationRequestPhaseFailed, fmt.Sprintf("Devbox %s not found", request.Spec.DevboxName))
+		}
+		return ctrl.Result{}, err
+	}
+	if err := helper.ValidateOperationRequest(request, devbox); err != nil {
+		return r.finish(ctx, request, devboxv1alpha1.OperationRequestPhaseFailed, err.Error())
+	}
+
+	// restart and ssh key reset must not be performed twice when the final status update fails,
+	// the start time is persisted before acting so that a retry finds it
+	oneShot := isOneShotOperation(request.Spec.Action)
+	if oneShot && request.Status.StartTime != nil {
+		logger.Info("operation request already started, skip performing it again", "startTime", request.Status.StartTime)
+		return r.finish(ctx, request, devboxv1alpha1.OperationRequestPhaseCompleted, fmt.Sprintf("%s of devbox %s completed", request.Spec.Action, devbox.Name))
+	}
+	if request.Status.StartTime == nil {
+		request.Status.StartTime = &metav1.Time{Time: time.Now()}
+		if err := r.Status().Update(ctx, request); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	r.Recorder.Eventf(devbox, corev1.EventTypeNormal, "Operation requested", "%s requested by operation request %s", request.Spec.Action, request.Name)
+	var err error
+	switch request.Spec.Action {
+	case devboxv1alpha1.OperationActionRestart:
+		err = r.restartDevbox(ctx, devbox)
+	case devboxv1alpha1.OperationActionResetSSHKey:
+		err = r.resetSSHKey(ctx, devbox)
+	case devboxv1alpha1.OperationActionUpdateResource:
+		err = r.updateResource(ctx, request, devbox)
+	case devboxv1alpha1.OperationActionExportWorkspace:
+		err = r.exportWorkspace(ctx, request, devbox)
+	}
+	if err != nil {
+		logger.Error(err, "operation request failed", "devbox", request.Spec.DevboxName, "action", request.Spec.Action)
+		r.Recorder.Eventf(request, corev1.EventTypeWarning, "Operation failed", "%v", err)
+		// the registry will not find the image on retry, and a one-shot action may have partly taken effect
+		if oneShot || errors.Is(err, registry.ErrorManifestNotFound) {
+			return r.finish(ctx, request, devboxv1alpha1.OperationRequestPhaseFailed, err.Error())
+		}
+		return ctrl.Result{}, err
+	}
+	logger.Info("operation request completed", "devbox", request.Spec.DevboxName, "action", request.Spec.Action)
+	return r.finish(ctx, request, devboxv1alpha1.OperationRequestPhaseCompleted, fmt.Sprintf("%s of devbox %s completed", request.Spec.Action, devbox.Name))
+}
+
+// finish records the result of request and requeues it to be deleted after the retention time
+func (r *OperationRequestReconciler) finish(ctx context.Context, request *devboxv1alpha1.OperationRequest, phase devboxv1alpha1.OperationRequestPhase, message string) (ctrl.Result, error) {
+	request.Status.Phase = phase
+	request.Status.Message = message
+	request.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	if err := r.Status().Update(ctx, request); err != nil {
+		return ctrl.Result{}, err
+	}
+	eventType := corev1.EventTypeNormal
+	if phase == devboxv1alpha1.OperationRequestPhaseFailed {
+		eventType = corev1.EventTypeWarning
+	}
+	r.Recorder.Eventf(request, eventType, string(phase), "%s", message)
+	return ctrl.Result{RequeueAfter: r.RetentionTime}, nil
+}
+
+// restartDevbox deletes the devbox pod, devbox controller commits it and creates a new one
+func (r *OperationRequestReconciler) restartDevbox(ctx context.Context, devbox *devboxv1alpha1.Devbox) error {
+	recLabels := label.RecommendedLabels(&label.Recommended{
+		Name:      devbox.Name,
+		ManagedBy: label.DefaultManagedBy,
+		PartOf:    devboxv1alpha1.DevBoxPartOf,
+	})
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(devbox.Namespace), client.MatchingLabels(recLabels)); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		if err := r.Delete(ctx, &podList.Items[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetSSHKey generates a new key pair in the devbox secret, the keys are mounted by sub path so the devbox is restarted
+func (r *OperationRequestReconciler) resetSSHKey(ctx context.Context, devbox *devboxv1alpha1.Devbox) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: devbox.Namespace, Name: devbox.Name}, secret); err != nil {
+			return err
+		}
+		if err := helper.ResetSSHKeys(secret); err != nil {
+			return err
+		}
+		return r.Update(ctx, secret)
+	})
+	if err != nil {
+		return err
+	}
+	if devbox.Spec.State != devboxv1alpha1.DevboxStateRunning {
+		return nil
+	}
+	return r.restartDevbox(ctx, devbox)
+}
+
+func (r *OperationRequestReconciler) updateResource(ctx context.Context, request *devboxv1alpha1.OperationRequest, devbox *devboxv1alpha1.Devbox) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latestDevbox := &devboxv1alpha1.Devbox{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(devbox), latestDevbox); err != nil {
+			return err
+		}
+		latestDevbox.Spec.Resource = request.Spec.Resource.DeepCopy()
+		return r.Update(ctx, latestDevbox)
+	})
+}
+
+// exportWorkspace copies the image of the last successful commit to the export image
+func (r *OperationRequestReconciler) exportWorkspace(ctx context.Context, request *devboxv1alpha1.OperationRequest, devbox *devboxv1alpha1.Devbox) error {
+	if r.Registry == nil {
+		return fmt.Errorf("registry is not configured")
+	}
+	commit := helper.GetLastSuccessCommitHistory(devbox)
+	if err := r.Registry.CopyImage(ctx, commit.Image, request.Spec.ExportImage); err != nil {
+		return fmt.Errorf("failed to copy image %s to %s: %w", commit.Image, request.Spec.ExportImage, err)
+	}
+	request.Status.Image = request.Spec.ExportImage
+	return nil
+}
+
+// isOneShotOperation reports whether performing the action twice has a different effect than performing it once
+func isOneShotOperation(action devboxv1alpha1.OperationAction) bool {
+	return action == devboxv1alpha1.OperationActionRestart || action == devboxv1alpha1.OperationActionResetSSHKey
+}
+
+func isOperationRequestFinished(request *devboxv1alpha1.OperationRequest) bool {
+	return request.Status.Phase == devboxv1alpha1.OperationRequestPhaseCompleted ||
+		request.Status.Phase == devboxv1alpha1.OperationRequestPhaseFailed
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OperationRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&devboxv1alpha1.OperationRequest{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,138 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idle
+
+import (
+	"fmt"
+	"time"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+)
+
+const (
+	// AnnotationStopAfter on a namespace sets the idle stop timeout of devboxes in it
+	AnnotationStopAfter = "devbox.sealos.io/idle-stop-after"
+	// AnnotationShutdownAfter on a namespace sets the idle shutdown timeout of devboxes in it
+	AnnotationShutdownAfter = "devbox.sealos.io/idle-shutdown-after"
+)
+
+// Policy is the resolved idle timeouts of a devbox, zero disables the timeout.
+type Policy struct {
+	StopAfter     time.Duration
+	ShutdownAfter time.Duration
+}
+
+func (p Policy) Enabled() bool {
+	return p.StopAfter > 0 || p.ShutdownAfter > 0
+}
+
+// ResolvePolicy merges the idle timeouts, devbox spec takes precedence over namespace annotations,
+// and namespace annotations take precedence over controller defaults.
+func ResolvePolicy(spec *devboxv1alpha1.IdlePolicy, annotations map[string]string, defaults Policy) (Policy, error) {
+	policy := defaults
+	if v, ok := annotations[AnnotationStopAfter]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return policy, fmt.Errorf("invalid annotation %s: %w", AnnotationStopAfter, err)
+		}
+		policy.StopAfter = d
+	}
+	if v, ok := annotations[AnnotationShutdownAfter]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return policy, fmt.Errorf("invalid annotation %s: %w", AnnotationShutdownAfter, err)
+		}
+		policy.ShutdownAfter = d
+	}
+	if spec != nil {
+		if spec.StopAfter != nil {
+			policy.StopAfter = spec.StopAfter.Duration
+		}
+		if spec.ShutdownAfter != nil {
+			policy.ShutdownAfter = spec.ShutdownAfter.Duration
+		}
+	}
+	return policy, nil
+}
+
+// LastActivity returns the latest of the recorded activity time, the last commit time and the creation time.
+func LastActivity(devbox *devboxv1alpha1.Devbox) time.Time {
+	last := devbox.CreationTimestamp.Time
+	if t := devbox.Status.LastActivityTime; t != nil && t.After(last) {
+		last = t.Time
+	}
+	for _, c := range devbox.Status.CommitHistory {
+		if c != nil && c.Time.After(last) {
+			last = c.Time.Time
+		}
+	}
+	return last
+}
+
+// Decision is the result of evaluating the idle policy.
+type Decision struct {
+	// State is the state the devbox should move to, empty means no change
+	State   devboxv1alpha1.DevboxState
+	Reason  string
+	Message string
+	// RequeueAfter is the time until the next timeout, zero means no timeout is pending
+	RequeueAfter time.Duration
+}
+
+// Evaluate decides whether the devbox in state should be stopped or shutdown given its last activity.
+func Evaluate(policy Policy, state devboxv1alpha1.DevboxState, lastActivity, now time.Time) Decision {
+	idleFor := now.Sub(lastActivity)
+	if idleFor < 0 {
+		idleFor = 0
+	}
+	var decision Decision
+	next := func(d time.Duration) {
+		if d <= 0 {
+			return
+		}
+		if remaining := d - idleFor; decision.RequeueAfter == 0 || remaining < decision.RequeueAfter {
+			decision.RequeueAfter = remaining
+		}
+	}
+	switch state {
+	case devboxv1alpha1.DevboxStateRunning:
+		if policy.ShutdownAfter > 0 && idleFor >= policy.ShutdownAfter {
+			return shutdown(idleFor)
+		}
+		if policy.StopAfter > 0 && idleFor >= policy.StopAfter {
+			decision.State = devboxv1alpha1.DevboxStateStopped
+			decision.Reason = devboxv1alpha1.DevboxReasonIdleStopped
+			decision.Message = fmt.Sprintf("Devbox has been idle for %s, stopped", idleFor.Round(time.Second))
+			next(policy.ShutdownAfter)
+			return decision
+		}
+		next(policy.StopAfter)
+		next(policy.ShutdownAfter)
+	case devboxv1alpha1.DevboxStateStopped:
+		if policy.ShutdownAfter > 0 && idleFor >= policy.ShutdownAfter {
+			return shutdown(idleFor)
+		}
+		next(policy.ShutdownAfter)
+	}
+	return decision
+}
+
+func shutdown(idleFor time.Duration) Decision {
+	return Decision{
+		State:   devboxv1alpha1.DevboxStateShutdown,
+		Reason:  devboxv1alpha1.DevboxReasonIdleShutdown,
+		Message: fmt.Sprintf("Devbox has been idle for %s, shutdown", idleFor.Round(time.Second)),
+	}
+}
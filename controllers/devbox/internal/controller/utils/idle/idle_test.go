@@ -0,0 +1,138 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idle
+
+import (
+	"testing"
+	"time"
+
+	devboxv1alpha1 "github.com/labring/sealos/controllers/devbox/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolvePolicy(t *testing.T) {
+	defaults := Policy{StopAfter: time.Hour, ShutdownAfter: 24 * time.Hour}
+	annotations := map[string]string{AnnotationStopAfter: "30m"}
+	spec := &devboxv1alpha1.IdlePolicy{ShutdownAfter: &metav1.Duration{Duration: 0}}
+
+	policy, err := ResolvePolicy(spec, annotations, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.StopAfter != 30*time.Minute || policy.ShutdownAfter != 0 {
+		t.Errorf("ResolvePolicy() = %+v", policy)
+	}
+	if _, err := ResolvePolicy(nil, map[string]string{AnnotationShutdownAfter: "1 day"}, defaults); err == nil {
+		t.Error("ResolvePolicy() expected error for invalid annotation")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	policy := Policy{StopAfter: time.Hour, ShutdownAfter: 24 * time.Hour}
+
+	tests := []struct {
+		name         string
+		policy       Policy
+		state        devboxv1alpha1.DevboxState
+		idleFor      time.Duration
+		expectState  devboxv1alpha1.DevboxState
+		expectReason string
+		expectNext   time.Duration
+	}{
+		{
+			name:       "running and active",
+			policy:     policy,
+			state:      devboxv1alpha1.DevboxStateRunning,
+			idleFor:    10 * time.Minute,
+			expectNext: 50 * time.Minute,
+		},
+		{
+			name:         "running and idle",
+			policy:       policy,
+			state:        devboxv1alpha1.DevboxStateRunning,
+			idleFor:      2 * time.Hour,
+			expectState:  devboxv1alpha1.DevboxStateStopped,
+			expectReason: devboxv1alpha1.DevboxReasonIdleStopped,
+			expectNext:   22 * time.Hour,
+		},
+		{
+			name:         "running and idle for long",
+			policy:       policy,
+			state:        devboxv1alpha1.DevboxStateRunning,
+			idleFor:      25 * time.Hour,
+			expectState:  devboxv1alpha1.DevboxStateShutdown,
+			expectReason: devboxv1alpha1.DevboxReasonIdleShutdown,
+		},
+		{
+			name:       "stopped",
+			policy:     policy,
+			state:      devboxv1alpha1.DevboxStateStopped,
+			idleFor:    2 * time.Hour,
+			expectNext: 22 * time.Hour,
+		},
+		{
+			name:         "stopped and idle for long",
+			policy:       policy,
+			state:        devboxv1alpha1.DevboxStateStopped,
+			idleFor:      24 * time.Hour,
+			expectState:  devboxv1alpha1.DevboxStateShutdown,
+			expectReason: devboxv1alpha1.DevboxReasonIdleShutdown,
+		},
+		{
+			name:    "disabled",
+			state:   devboxv1alpha1.DevboxStateRunning,
+			idleFor: 100 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Evaluate(tt.policy, tt.state, now.Add(-tt.idleFor), now)
+			if d.State != tt.expectState || d.Reason != tt.expectReason || d.RequeueAfter != tt.expectNext {
+				t.Errorf("Evaluate() = %+v", d)
+			}
+		})
+	}
+}
+
+func TestLastActivity(t *testing.T) {
+	now := time.Now()
+	devbox := &devboxv1alpha1.Devbox{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+		Status: devboxv1alpha1.DevboxStatus{
+			LastActivityTime: &metav1.Time{Time: now.Add(-30 * time.Minute)},
+			CommitHistory: []*devboxv1alpha1.CommitHistory{
+				{Time: metav1.NewTime(now.Add(-50 * time.Minute))},
+				{Time: metav1.NewTime(now.Add(-10 * time.Minute))},
+			},
+		},
+	}
+	if got := LastActivity(devbox); !got.Equal(now.Add(-10 * time.Minute)) {
+		t.Errorf("LastActivity() = %v", got)
+	}
+}
+
+func TestCountEstablished(t *testing.T) {
+	content := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0016 0100007F:D2C4 01 00000000:00000000 02:000A6F1B 00000000     0        0 2 1 0000000000000000 20 4 30 10 -1
+   2: 0A00000F:1F90 0A000001:C350 01 00000000:00000000 00:00000000 00000000  1000        0 3 1 0000000000000000 20 4 30 10 -1
+  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0000000000000000FFFF00000F00000A:0016 0000000000000000FFFF00000100000A:D10E 01 00000000:00000000 02:000A5E0C 00000000     0        0 4 1 0000000000000000 20 4 29 10 -1
+`
+	if got := CountEstablished(content, 22); got != 2 {
+		t.Errorf("CountEstablished() = %d, want 2", got)
+	}
+}
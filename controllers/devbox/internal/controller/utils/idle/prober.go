@@ -0,0 +1,173 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idle
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultSSHPort = 22
+
+var podMetricsGVK = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetrics"}
+
+// Activity is the activity signals sampled from a devbox pod.
+type Activity struct {
+	SSHSessions int
+	// CPU is the cpu usage of the devbox container in millicores
+	CPU int64
+}
+
+// Active reports whether any ssh session is open or the cpu usage reaches cpuThreshold millicores.
+func (a *Activity) Active(cpuThreshold int64) bool {
+	return a.SSHSessions > 0 || a.CPU >= cpuThreshold
+}
+
+// Prober samples the activity of the devbox container in pod.
+type Prober interface {
+	Probe(ctx context.Context, pod *corev1.Pod, container string) (*Activity, error)
+}
+
+// PodProber counts established ssh connections in the pod and reads cpu usage from metrics api.
+type PodProber struct {
+	Client    client.Client
+	Config    *rest.Config
+	Clientset kubernetes.Interface
+}
+
+func NewPodProber(c client.Client, config *rest.Config) (*PodProber, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &PodProber{Client: c, Config: config, Clientset: clientset}, nil
+}
+
+func (p *PodProber) Probe(ctx context.Context, pod *corev1.Pod, container string) (*Activity, error) {
+	sessions, err := p.sshSessions(ctx, pod, container)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count ssh sessions: %w", err)
+	}
+	cpu, err := p.cpuUsage(ctx, pod, container)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cpu usage: %w", err)
+	}
+	return &Activity{SSHSessions: sessions, CPU: cpu}, nil
+}
+
+func (p *PodProber) sshSessions(ctx context.Context, pod *corev1.Pod, container string) (int, error) {
+	req := p.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   []string{"sh", "-c", "cat /proc/net/tcp /proc/net/tcp6 2>/dev/null || true"},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(p.Config, "POST", req.URL())
+	if err != nil {
+		return 0, err
+	}
+	var stdout, stderr bytes.Buffer
+	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return 0, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return CountEstablished(stdout.String(), sshPort(pod, container)), nil
+}
+
+func (p *PodProber) cpuUsage(ctx context.Context, pod *corev1.Pod, container string) (int64, error) {
+	metrics := &unstructured.Unstructured{}
+	metrics.SetGroupVersionKind(podMetricsGVK)
+	if err := p.Client.Get(ctx, client.ObjectKeyFromObject(pod), metrics); err != nil {
+		return 0, err
+	}
+	containers, _, err := unstructured.NestedSlice(metrics.Object, "containers")
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range containers {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["name"] != container {
+			continue
+		}
+		cpu, _, err := unstructured.NestedString(m, "usage", "cpu")
+		if err != nil {
+			return 0, err
+		}
+		q, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			return 0, err
+		}
+		return q.MilliValue(), nil
+	}
+	return 0, fmt.Errorf("container %s not found in pod metrics", container)
+}
+
+func sshPort(pod *corev1.Pod, container string) int64 {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != container {
+			continue
+		}
+		for _, port := range c.Ports {
+			if port.Name == "devbox-ssh-port" {
+				return int64(port.ContainerPort)
+			}
+		}
+	}
+	return defaultSSHPort
+}
+
+// CountEstablished counts the established tcp connections on local port in the content of /proc/net/tcp{,6}.
+func CountEstablished(content string, port int64) int {
+	const stateEstablished = "01"
+	count := 0
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sl local_address rem_address st ...
+		if len(fields) < 4 || fields[3] != stateEstablished {
+			continue
+		}
+		i := strings.LastIndex(fields[1], ":")
+		if i < 0 {
+			continue
+		}
+		local, err := strconv.ParseInt(fields[1][i+1:], 16, 64)
+		if err != nil {
+			continue
+		}
+		if local == port {
+			count++
+		}
+	}
+	return count
+}
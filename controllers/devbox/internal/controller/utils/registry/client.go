@@ -15,84 +15,155 @@
 package registry
 
 import (
-	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
-	"io"
+	"fmt"
 	"net/http"
+	"os"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
 
+// Client talks to an OCI distribution registry. It supports https with custom CAs, basic and bearer token auth,
+// docker and OCI manifests as well as manifest lists and image indexes.
 type Client struct {
 	Username string
 	Password string
+	// PlainHTTP allows falling back to plain http if the registry does not serve https.
+	PlainHTTP bool
+	// InsecureSkipVerify skips tls verification of the registry.
+	InsecureSkipVerify bool
+	// RootCAs is used to verify the registry certificate, system roots are used if nil.
+	RootCAs *x509.CertPool
+
+	once      sync.Once
+	transport http.RoundTripper
 }
 
 var (
 	ErrorManifestNotFound = errors.New("manifest not found")
 )
 
-// TODO: refactor tag image, use go package to do this
+// LoadRootCAs returns the system cert pool with the PEM encoded certificates in caFile appended.
+func LoadRootCAs(caFile string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+	return pool, nil
+}
 
-func (t *Client) TagImage(hostName string, imageName string, oldTag string, newTag string) error {
-	manifest, err := t.pullManifest(t.Username, t.Password, hostName, imageName, oldTag)
+// TagImage tags the manifest of oldTag with newTag, manifest lists and image indexes are tagged as is.
+func (t *Client) TagImage(ctx context.Context, hostName string, imageName string, oldTag string, newTag string) error {
+	src, err := t.reference(hostName, imageName, oldTag)
 	if err != nil {
 		return err
 	}
-	return t.pushManifest(t.Username, t.Password, hostName, imageName, newTag, manifest)
+	desc, err := remote.Get(src, t.options(ctx)...)
+	if err != nil {
+		return convertError(err)
+	}
+	return convertError(remote.Tag(src.Context().Tag(newTag), desc, t.options(ctx)...))
 }
 
-func (t *Client) pullManifest(username string, password string, hostName string, imageName string, tag string) ([]byte, error) {
-	var (
-		client = http.DefaultClient
-		url    = "http://" + hostName + "/v2/" + imageName + "/manifests/" + tag
-	)
-	req, err := http.NewRequest("GET", url, nil)
+// CopyImage copies image src to dst, blobs are mounted across repositories of the same registry instead of uploaded.
+func (t *Client) CopyImage(ctx context.Context, src string, dst string) error {
+	srcRef, err := name.ParseReference(src, t.nameOptions()...)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	req.SetBasicAuth(username, password)
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-
-	resp, err := client.Do(req)
+	dstRef, err := name.ParseReference(dst, t.nameOptions()...)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, ErrorManifestNotFound
+	desc, err := remote.Get(srcRef, t.options(ctx)...)
+	if err != nil {
+		return convertError(err)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(resp.Status)
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return convertError(remote.WriteIndex(dstRef, index, t.options(ctx)...))
 	}
-
-	bodyText, err := io.ReadAll(resp.Body)
+	image, err := desc.Image()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return bodyText, nil
+	return convertError(remote.Write(dstRef, image, t.options(ctx)...))
 }
 
-func (t *Client) pushManifest(username string, password string, hostName string, imageName string, tag string, manifest []byte) error {
-	var (
-		client = http.DefaultClient
-		url    = "http://" + hostName + "/v2/" + imageName + "/manifests/" + tag
-	)
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(manifest))
+// DeleteImage deletes the manifest of the tag, note that registry deletes manifest by digest,
+// so all tags sharing the same manifest are deleted too.
+func (t *Client) DeleteImage(ctx context.Context, hostName string, imageName string, tag string) error {
+	ref, err := t.reference(hostName, imageName, tag)
 	if err != nil {
 		return err
 	}
+	desc, err := remote.Head(ref, t.options(ctx)...)
+	if err != nil {
+		return convertError(err)
+	}
+	return convertError(remote.Delete(ref.Context().Digest(desc.Digest.String()), t.options(ctx)...))
+}
 
-	req.SetBasicAuth(username, password)
-	req.Header.Set("Content-type", "application/vnd.docker.distribution.manifest.v2+json")
+func (t *Client) reference(hostName string, imageName string, tag string) (name.Reference, error) {
+	return name.ParseReference(hostName+"/"+imageName+":"+tag, t.nameOptions()...)
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+func (t *Client) nameOptions() []name.Option {
+	if t.PlainHTTP {
+		return []name.Option{name.Insecure}
 	}
+	return nil
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return errors.New(resp.Status)
+func (t *Client) options(ctx context.Context) []remote.Option {
+	t.once.Do(func() {
+		tr := remote.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = &tls.Config{
+			RootCAs:            t.RootCAs,
+			InsecureSkipVerify: t.InsecureSkipVerify, // #nosec G402
+			MinVersion:         tls.VersionTLS12,
+		}
+		t.transport = tr
+	})
+	auth := authn.Anonymous
+	if t.Username != "" || t.Password != "" {
+		auth = &authn.Basic{Username: t.Username, Password: t.Password}
 	}
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuth(auth),
+		remote.WithTransport(t.transport),
+	}
+}
 
-	return nil
+func convertError(err error) error {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		if terr.StatusCode == http.StatusNotFound {
+			return ErrorManifestNotFound
+		}
+		for _, e := range terr.Errors {
+			if e.Code == transport.ManifestUnknownErrorCode || e.Code == transport.NameUnknownErrorCode {
+				return ErrorManifestNotFound
+			}
+		}
+	}
+	return err
 }
@@ -14,48 +14,192 @@
 
 package registry
 
-import "testing"
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
 
-func TestClient_TagImage(t1 *testing.T) {
-	type fields struct {
-		Username string
-		Password string
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	testUser     = "admin"
+	testPassword = "passw0rd"
+	testToken    = "token"
+)
+
+// newTestRegistry starts an in-process tls registry that requires a bearer token issued for basic auth.
+func newTestRegistry(t *testing.T, handler http.Handler) (*httptest.Server, *Client) {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != testUser || pass != testPassword {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	return server, &Client{Username: testUser, Password: testPassword, RootCAs: pool}
+}
+
+func newInMemoryRegistry() http.Handler {
+	return ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0)))
+}
+
+func hostOf(server *httptest.Server) string {
+	return strings.TrimPrefix(server.URL, "https://")
+}
+
+func get(t *testing.T, c *Client, ref string) *remote.Descriptor {
+	t.Helper()
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Get(r, c.options(context.Background())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+func push(t *testing.T, c *Client, ref string, index bool) v1.Hash {
+	t.Helper()
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
 	}
-	type args struct {
-		hostName  string
-		imageName string
-		oldTag    string
-		newTag    string
+	if index {
+		idx, err := random.Index(256, 1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.WriteIndex(r, idx, c.options(context.Background())...); err != nil {
+			t.Fatal(err)
+		}
+		digest, _ := idx.Digest()
+		return digest
 	}
+	img, err := random.Image(256, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(r, img, c.options(context.Background())...); err != nil {
+		t.Fatal(err)
+	}
+	digest, _ := img.Digest()
+	return digest
+}
+
+func TestClient_TagImage(t *testing.T) {
+	server, c := newTestRegistry(t, newInMemoryRegistry())
+	host := hostOf(server)
+	ctx := context.Background()
+
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name      string
+		index     bool
+		mediaType types.MediaType
 	}{
-		{
-			name: "Test1",
-			fields: fields{
-				Username: "admin",
-				Password: "passw0rd",
-			},
-			args: args{
-				hostName:  "sealos.hub:5000",
-				imageName: "default/devbox-sample",
-				oldTag:    "2024-08-21-072021",
-				newTag:    "test",
-			},
-		},
+		{name: "image", mediaType: types.DockerManifestSchema2},
+		{name: "index", index: true, mediaType: types.OCIImageIndex},
 	}
 	for _, tt := range tests {
-		t1.Run(tt.name, func(t1 *testing.T) {
-			t := &Client{
-				Username: tt.fields.Username,
-				Password: tt.fields.Password,
+		t.Run(tt.name, func(t *testing.T) {
+			imageName := "default/devbox-" + tt.name
+			digest := push(t, c, host+"/"+imageName+":2024-08-21-072021", tt.index)
+			if err := c.TagImage(ctx, host, imageName, "2024-08-21-072021", "release"); err != nil {
+				t.Fatalf("TagImage() error = %v", err)
+			}
+			desc := get(t, c, host+"/"+imageName+":release")
+			if desc.Digest != digest {
+				t.Errorf("TagImage() digest = %s, want %s", desc.Digest, digest)
 			}
-			if err := t.TagImage(tt.args.hostName, tt.args.imageName, tt.args.oldTag, tt.args.newTag); (err != nil) != tt.wantErr {
-				t1.Errorf("TagImage() error = %v, wantErr %v", err, tt.wantErr)
+			if tt.index && desc.MediaType != tt.mediaType {
+				t.Errorf("TagImage() media type = %s, want %s", desc.MediaType, tt.mediaType)
 			}
 		})
 	}
+
+	if err := c.TagImage(ctx, host, "default/devbox-image", "not-found", "release"); !errors.Is(err, ErrorManifestNotFound) {
+		t.Errorf("TagImage() error = %v, want %v", err, ErrorManifestNotFound)
+	}
+}
+
+func TestClient_CopyImage(t *testing.T) {
+	var mounts atomic.Int32
+	reg := newInMemoryRegistry()
+	server, c := newTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the in-memory registry shares blobs across repositories, hide them from the target repository
+		if r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/other/devbox-b/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost && r.URL.Query().Get("mount") != "" && r.URL.Query().Get("from") == "default/devbox-a" {
+			mounts.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	host := hostOf(server)
+	ctx := context.Background()
+
+	digest := push(t, c, host+"/default/devbox-a:v1", false)
+	if err := c.CopyImage(ctx, host+"/default/devbox-a:v1", host+"/other/devbox-b:v1"); err != nil {
+		t.Fatalf("CopyImage() error = %v", err)
+	}
+	desc := get(t, c, host+"/other/devbox-b:v1")
+	if desc.Digest != digest {
+		t.Errorf("CopyImage() digest = %s, want %s", desc.Digest, digest)
+	}
+	if mounts.Load() == 0 {
+		t.Error("CopyImage() expected blobs to be mounted from source repository")
+	}
+}
+
+func TestClient_DeleteImage(t *testing.T) {
+	server, c := newTestRegistry(t, newInMemoryRegistry())
+	host := hostOf(server)
+	ctx := context.Background()
+
+	push(t, c, host+"/default/devbox-sample:v1", false)
+	if err := c.DeleteImage(ctx, host, "default/devbox-sample", "v1"); err != nil {
+		t.Fatalf("DeleteImage() error = %v", err)
+	}
+	if err := c.DeleteImage(ctx, host, "default/devbox-sample", "v1"); !errors.Is(err, ErrorManifestNotFound) {
+		t.Errorf("DeleteImage() error = %v, want %v", err, ErrorManifestNotFound)
+	}
+}
+
+func TestClient_UntrustedCertificate(t *testing.T) {
+	server, c := newTestRegistry(t, newInMemoryRegistry())
+	c.RootCAs = x509.NewCertPool()
+	if err := c.TagImage(context.Background(), hostOf(server), "default/devbox-sample", "v1", "v2"); err == nil {
+		t.Error("TagImage() expected error for untrusted certificate")
+	}
 }
@@ -0,0 +1,233 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrorNodeNotFound = errors.New("tailnet node not found")
+)
+
+// AuthKey is a pre-auth key used by the sidecar of a devbox to join the tailnet.
+type AuthKey struct {
+	Key        string
+	Expiration time.Time
+}
+
+// Coordinator manages devbox nodes in the tailnet coordination server.
+type Coordinator interface {
+	// CreateAuthKey creates a reusable and ephemeral pre-auth key, the node joined with it
+	// is removed by the coordination server once it is offline.
+	CreateAuthKey(ctx context.Context) (*AuthKey, error)
+	// Address returns the IPv4 address of the node, ErrorNodeNotFound is returned if the
+	// node has not joined the tailnet yet.
+	Address(ctx context.Context, name string) (string, error)
+	// Deregister removes the node, removing a missing node is not an error.
+	Deregister(ctx context.Context, name string) error
+}
+
+// Client is a Coordinator backed by the REST API of Headscale v0.26 or later,
+// the API key is created with `headscale apikeys create`. All devbox nodes belong to User.
+type Client struct {
+	URL    string
+	APIKey string
+	// User is the Headscale user that owns devbox nodes, it is created if missing.
+	User string
+	// KeyTTL is how long a pre-auth key is valid.
+	KeyTTL time.Duration
+
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	userID string
+}
+
+func NewClient(url, apiKey, user string, keyTTL time.Duration) *Client {
+	return &Client{
+		URL:    strings.TrimSuffix(url, "/"),
+		APIKey: apiKey,
+		User:   user,
+		KeyTTL: keyTTL,
+	}
+}
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type node struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	GivenName   string   `json:"givenName"`
+	IPAddresses []string `json:"ipAddresses"`
+}
+
+type preAuthKey struct {
+	Key        string    `json:"key"`
+	Expiration time.Time `json:"expiration"`
+}
+
+type createPreAuthKeyRequest struct {
+	User       string    `json:"user"`
+	Reusable   bool      `json:"reusable"`
+	Ephemeral  bool      `json:"ephemeral"`
+	Expiration time.Time `json:"expiration"`
+}
+
+func (c *Client) CreateAuthKey(ctx context.Context) (*AuthKey, error) {
+	userID, err := c.ensureUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(&createPreAuthKeyRequest{
+		User:       userID,
+		Reusable:   true,
+		Ephemeral:  true,
+		Expiration: time.Now().Add(c.KeyTTL).UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := struct {
+		PreAuthKey preAuthKey `json:"preAuthKey"`
+	}{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/preauthkey", body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to create pre-auth key: %w", err)
+	}
+	return &AuthKey{Key: resp.PreAuthKey.Key, Expiration: resp.PreAuthKey.Expiration}, nil
+}
+
+func (c *Client) Address(ctx context.Context, name string) (string, error) {
+	nodes, err := c.findNodes(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	for _, n := range nodes {
+		for _, ip := range n.IPAddresses {
+			if addr, err := netip.ParseAddr(ip); err == nil && addr.Is4() {
+				return ip, nil
+			}
+		}
+	}
+	return "", ErrorNodeNotFound
+}
+
+func (c *Client) Deregister(ctx context.Context, name string) error {
+	nodes, err := c.findNodes(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := c.do(ctx, http.MethodDelete, "/api/v1/node/"+url.PathEscape(n.ID), nil, nil); err != nil {
+			return fmt.Errorf("failed to delete node %s: %w", n.ID, err)
+		}
+	}
+	return nil
+}
+
+// findNodes returns the nodes of User named name, the given name is the hostname
+// of the sidecar unless it is renamed in Headscale.
+func (c *Client) findNodes(ctx context.Context, name string) ([]node, error) {
+	resp := struct {
+		Nodes []node `json:"nodes"`
+	}{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/node?user="+url.QueryEscape(c.User), nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	var ret []node
+	for _, n := range resp.Nodes {
+		if n.GivenName == name || n.Name == name {
+			ret = append(ret, n)
+		}
+	}
+	return ret, nil
+}
+
+func (c *Client) ensureUser(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.userID != "" {
+		return c.userID, nil
+	}
+	list := struct {
+		Users []user `json:"users"`
+	}{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/user?name="+url.QueryEscape(c.User), nil, &list); err != nil {
+		return "", fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, u := range list.Users {
+		if u.Name == c.User {
+			c.userID = u.ID
+			return c.userID, nil
+		}
+	}
+	body, err := json.Marshal(&user{Name: c.User})
+	if err != nil {
+		return "", err
+	}
+	created := struct {
+		User user `json:"user"`
+	}{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/user", body, &created); err != nil {
+		return "", fmt.Errorf("failed to create user %s: %w", c.User, err)
+	}
+	c.userID = created.User.ID
+	return c.userID, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("headscale returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,69 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailnet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/labring/sealos/controllers/devbox/internal/controller/utils/tailnet/tailnettest"
+)
+
+func TestClient(t *testing.T) {
+	server := tailnettest.NewServer("secret")
+	defer server.Close()
+	ctx := context.Background()
+
+	client := NewClient(server.URL, "secret", "devbox", time.Hour)
+	key, err := client.CreateAuthKey(ctx)
+	if err != nil {
+		t.Fatalf("CreateAuthKey() error = %v", err)
+	}
+	if key.Key != tailnettest.AuthKey || time.Until(key.Expiration) <= 0 {
+		t.Errorf("CreateAuthKey() = %+v", key)
+	}
+	if users := server.Users(); len(users) != 1 || users[0].Name != "devbox" {
+		t.Errorf("CreateAuthKey() must create the user, got %+v", users)
+	}
+	if req := server.PreAuthKeyRequests()[0]; req.User != "1" || !req.Reusable || !req.Ephemeral {
+		t.Errorf("CreateAuthKey() request = %+v", req)
+	}
+
+	if _, err = client.Address(ctx, "ns-devbox"); !errors.Is(err, ErrorNodeNotFound) {
+		t.Errorf("Address() of a node not joined error = %v", err)
+	}
+	server.AddNode(tailnettest.Node{ID: "5", Name: "ns-devbox", GivenName: "ns-devbox", IPAddresses: []string{"fd7a:115c:a1e0::1", "100.64.0.1"}})
+	server.AddNode(tailnettest.Node{ID: "6", Name: "ns-other", GivenName: "ns-other", IPAddresses: []string{"100.64.0.2"}})
+	addr, err := client.Address(ctx, "ns-devbox")
+	if err != nil || addr != "100.64.0.1" {
+		t.Errorf("Address() = %s, %v, want 100.64.0.1", addr, err)
+	}
+
+	if err = client.Deregister(ctx, "ns-devbox"); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	if err = client.Deregister(ctx, "ns-devbox"); err != nil {
+		t.Errorf("Deregister() of a missing node error = %v", err)
+	}
+	if nodes := server.Nodes(); len(nodes) != 1 || nodes[0].ID != "6" {
+		t.Errorf("Deregister() nodes = %+v", nodes)
+	}
+
+	if _, err = NewClient(server.URL, "wrong", "devbox", time.Hour).CreateAuthKey(ctx); err == nil {
+		t.Errorf("CreateAuthKey() with wrong api key must fail")
+	}
+}
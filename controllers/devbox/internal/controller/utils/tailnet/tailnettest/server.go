@@ -0,0 +1,139 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tailnettest provides a fake coordination server serving the subset of
+// the Headscale REST API used by tailnet.Client, for tests.
+package tailnettest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const AuthKey = "hskey-auth-test"
+
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Node struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	GivenName   string   `json:"givenName"`
+	IPAddresses []string `json:"ipAddresses"`
+}
+
+type PreAuthKeyRequest struct {
+	User       string    `json:"user"`
+	Reusable   bool      `json:"reusable"`
+	Ephemeral  bool      `json:"ephemeral"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// Server is a fake coordination server, every issued auth key is AuthKey.
+type Server struct {
+	*httptest.Server
+	apiKey string
+
+	mu    sync.Mutex
+	users []User
+	nodes []Node
+	keys  []PreAuthKeyRequest
+}
+
+// NewServer starts a fake coordination server accepting apiKey, close it when done.
+func NewServer(apiKey string) *Server {
+	s := &Server{apiKey: apiKey}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AddNode registers a node as if a devbox joined the tailnet.
+func (s *Server) AddNode(n Node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n.ID == "" {
+		n.ID = strconv.Itoa(len(s.nodes) + 100)
+	}
+	s.nodes = append(s.nodes, n)
+}
+
+func (s *Server) Users() []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]User(nil), s.users...)
+}
+
+func (s *Server) Nodes() []Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Node(nil), s.nodes...)
+}
+
+// PreAuthKeyRequests returns the requests received to create auth keys.
+func (s *Server) PreAuthKeyRequests() []PreAuthKeyRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PreAuthKeyRequest(nil), s.keys...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/user":
+		var users []User
+		for _, u := range s.users {
+			if u.Name == r.URL.Query().Get("name") {
+				users = append(users, u)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"users": users})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/user":
+		u := User{}
+		_ = json.NewDecoder(r.Body).Decode(&u)
+		u.ID = strconv.Itoa(len(s.users) + 1)
+		s.users = append(s.users, u)
+		_ = json.NewEncoder(w).Encode(map[string]any{"user": u})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/preauthkey":
+		req := PreAuthKeyRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.keys = append(s.keys, req)
+		_ = json.NewEncoder(w).Encode(map[string]any{"preAuthKey": map[string]any{"key": AuthKey, "expiration": req.Expiration}})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/node":
+		_ = json.NewEncoder(w).Encode(map[string]any{"nodes": s.nodes})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/v1/node/"):
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/node/")
+		for i, n := range s.nodes {
+			if n.ID == id {
+				s.nodes = append(s.nodes[:i], s.nodes[i+1:]...)
+				_, _ = w.Write([]byte("{}"))
+				return
+			}
+		}
+		http.Error(w, "node not found", http.StatusNotFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -14,12 +14,38 @@
 
 package v1alpha
 
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ClusterResource is the capacity of a cluster, cpu is in cores and mem is in bytes
 type ClusterResource struct {
 	Node int64 `json:"node"`
 	CPU  int64 `json:"cpu"`
 	Mem  int64 `json:"mem"`
 }
 
+// NewClusterResource sums the capacity of nodes, it is the resource reported by the heartbeat
+// and the resource that the entitlements of a cluster license are enforced against
+func NewClusterResource(nodes []corev1.Node) *ClusterResource {
+	totalCPU := resource.NewQuantity(0, resource.DecimalSI)
+	totalMem := resource.NewQuantity(0, resource.DecimalSI)
+
+	for _, node := range nodes {
+		cpu := node.Status.Capacity["cpu"]
+		mem := node.Status.Capacity["memory"]
+		totalCPU.Add(cpu)
+		totalMem.Add(mem)
+	}
+
+	return &ClusterResource{
+		Node: int64(len(nodes)),
+		CPU:  totalCPU.Value(),
+		Mem:  totalMem.Value(),
+	}
+}
+
 type Request struct {
 	ClusterID       string           `json:"clusterID"`
 	ClusterResource *ClusterResource `json:"clusterResource"`
@@ -24,7 +24,6 @@ import (
 	"github.com/labring/sealos/controllers/job/heartbeat/internal/util"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func GetClusterResources() (*v1alpha.ClusterResource, error) {
@@ -37,21 +36,7 @@ func GetClusterResources() (*v1alpha.ClusterResource, error) {
 		return nil, err
 	}
 
-	totalCPU := resource.NewQuantity(0, resource.DecimalSI)
-	totalMem := resource.NewQuantity(0, resource.DecimalSI)
-
-	for _, node := range nodeList.Items {
-		cpu := node.Status.Capacity["cpu"]
-		mem := node.Status.Capacity["memory"]
-		totalCPU.Add(cpu)
-		totalMem.Add(mem)
-	}
-
-	return &v1alpha.ClusterResource{
-		Node: int64(len(nodeList.Items)),
-		CPU:  totalCPU.Value(),
-		Mem:  totalMem.Value(),
-	}, err
+	return v1alpha.NewClusterResource(nodeList.Items), nil
 }
 
 func GetClusterID() (string, error) {
@@ -32,6 +32,10 @@ type LicenseSpec struct {
 	//+kubebuilder:validation:Enum=Account;Cluster
 	Type  LicenseType `json:"type,omitempty"`
 	Token string      `json:"token,omitempty"`
+	// Renewals are the tokens of the licenses that renew Token, a renewal must be issued for the same type
+	// and cluster and expire after the license it renews. The latest renewal extends the expiration time
+	// and replaces the entitlements of a cluster license, it does not recharge the balance of an account license.
+	Renewals []string `json:"renewals,omitempty"`
 }
 
 type LicenseStatusPhase string
@@ -40,6 +44,20 @@ const (
 	LicenseStatusPhasePending LicenseStatusPhase = "Pending"
 	LicenseStatusPhaseFailed  LicenseStatusPhase = "Failed"
 	LicenseStatusPhaseActive  LicenseStatusPhase = "Active"
+	// LicenseStatusPhaseGracePeriod is an expired license in its grace period, it fails when the grace period
+	// ends unless it is renewed before then
+	LicenseStatusPhaseGracePeriod LicenseStatusPhase = "GracePeriod"
+)
+
+// the condition types of a license
+const (
+	// ConditionTypeExpiring is true once the license expires within the warning period
+	ConditionTypeExpiring = "Expiring"
+	// ConditionTypeEntitlementExceeded is true if the cluster exceeds the entitlements of a cluster license
+	ConditionTypeEntitlementExceeded = "EntitlementExceeded"
+	// ConditionTypeDegraded is true while the license is in its grace period. An account license is not
+	// recharged while a cluster license of the cluster is degraded.
+	ConditionTypeDegraded = "Degraded"
 )
 
 type ValidationCode int
@@ -54,13 +72,20 @@ const (
 
 // LicenseStatus defines the observed state of License
 type LicenseStatus struct {
-	//+kubebuilder:validation:Enum=Pending;Failed;Active
+	//+kubebuilder:validation:Enum=Pending;Failed;Active;GracePeriod
 	//+kubebuilder:default=Pending
 	Phase          LicenseStatusPhase `json:"phase,omitempty"`
 	ValidationCode ValidationCode     `json:"validationCode,omitempty"`
 	Reason         string             `json:"reason,omitempty"`
 	ActivationTime metav1.Time        `json:"activationTime,omitempty"`
 	ExpirationTime metav1.Time        `json:"expirationTime,omitempty"`
+	// GracePeriodEndTime is the time that an expired license fails
+	GracePeriodEndTime metav1.Time `json:"gracePeriodEndTime,omitempty"`
+	// Renewals is the number of the renewals applied to the license
+	Renewals int `json:"renewals,omitempty"`
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
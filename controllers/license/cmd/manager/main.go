@@ -20,6 +20,7 @@ import (
 	"context"
 	"flag"
 	"os"
+	"time"
 
 	database2 "github.com/labring/sealos/controllers/pkg/database"
 	"github.com/labring/sealos/controllers/pkg/database/cockroach"
@@ -39,6 +40,7 @@ import (
 	"github.com/labring/sealos/controllers/license/internal/controller"
 	utilid "github.com/labring/sealos/controllers/license/internal/util/clusterid"
 	"github.com/labring/sealos/controllers/license/internal/util/database"
+	notificationv1 "github.com/labring/sealos/controllers/pkg/notification/api/v1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -52,6 +54,7 @@ func init() {
 
 	utilruntime.Must(accountv1.AddToScheme(scheme))
 	utilruntime.Must(licensev1.AddToScheme(scheme))
+	utilruntime.Must(notificationv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -59,12 +62,18 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var gracePeriod time.Duration
+	var expirationWarning time.Duration
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&gracePeriod, "grace-period", 7*24*time.Hour,
+		"How long an expired license stays in its grace period before it fails.")
+	flag.DurationVar(&expirationWarning, "expiration-warning", 30*24*time.Hour,
+		"How long before the expiration a license is marked as expiring and a notification is sent.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -124,7 +133,11 @@ func main() {
 		}
 	}()
 
-	if err = (&controller.LicenseReconciler{ClusterID: clusterID}).SetupWithManager(mgr, accountDB); err != nil {
+	if err = (&controller.LicenseReconciler{
+		ClusterID:         clusterID,
+		GracePeriod:       gracePeriod,
+		ExpirationWarning: expirationWarning,
+	}).SetupWithManager(mgr, accountDB); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "License")
 		os.Exit(1)
 	}
@@ -6,6 +6,7 @@ require (
 	github.com/go-logr/logr v1.4.2
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/labring/sealos/controllers/account v0.0.0-00010101000000-000000000000
+	github.com/labring/sealos/controllers/job/heartbeat v0.0.0-00010101000000-000000000000
 	github.com/labring/sealos/controllers/pkg v0.0.0-20240715064441-d1193f70675b
 	github.com/mitchellh/mapstructure v1.5.1-0.20220423185008-bf980b35cac4
 	github.com/onsi/ginkgo/v2 v2.20.1
@@ -97,6 +98,7 @@ require (
 
 replace (
 	github.com/labring/sealos/controllers/account => ../account
+	github.com/labring/sealos/controllers/job/heartbeat => ../job/heartbeat
 	github.com/labring/sealos/controllers/user => ../user
 )
 
@@ -20,6 +20,7 @@ import (
 	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -39,7 +40,8 @@ type LicenseActivator struct {
 
 func (l *LicenseActivator) Active(license *licensev1.License) error {
 	// TODO mv to active function
-	if license.Spec.Type == licensev1.AccountLicenseType {
+	// the balance is recharged only on the first activation, a renewal does not recharge it again
+	if license.Spec.Type == licensev1.AccountLicenseType && license.Status.ActivationTime.IsZero() {
 		if err := l.Recharge(license); err != nil {
 			return fmt.Errorf("recharge account failed: %w", err)
 		}
@@ -49,7 +51,11 @@ func (l *LicenseActivator) Active(license *licensev1.License) error {
 		return err
 	}
 	license.Status.ExpirationTime = metav1.NewTime(exp)
-	license.Status.ActivationTime = metav1.NewTime(time.Now())
+	if license.Status.ActivationTime.IsZero() {
+		license.Status.ActivationTime = metav1.NewTime(time.Now())
+	}
+	license.Status.GracePeriodEndTime = metav1.Time{}
+	license.Status.Reason = ""
 	license.Status.Phase = licensev1.LicenseStatusPhaseActive
 
 	if err := l.Status().Update(context.Background(), license); err != nil {
@@ -58,6 +64,21 @@ func (l *LicenseActivator) Active(license *licensev1.License) error {
 	return nil
 }
 
+// ClusterDegraded reports whether a cluster license of the cluster is degraded
+func (l *LicenseActivator) ClusterDegraded(ctx context.Context) (bool, error) {
+	licenses := &licensev1.LicenseList{}
+	if err := l.List(ctx, licenses); err != nil {
+		return false, fmt.Errorf("list licenses failed: %w", err)
+	}
+	for i := range licenses.Items {
+		if licenses.Items[i].Spec.Type == licensev1.ClusterLicenseType &&
+			meta.IsStatusConditionTrue(licenses.Items[i].Status.Conditions, licensev1.ConditionTypeDegraded) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (l *LicenseActivator) Recharge(license *licensev1.License) error {
 	claims, err := licenseutil.GetClaims(license)
 	if err != nil {
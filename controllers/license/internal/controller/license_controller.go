@@ -19,10 +19,13 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -42,9 +45,14 @@ type LicenseReconciler struct {
 	//finalizer *ctrlsdk.Finalizer
 
 	ClusterID string
+	// GracePeriod is how long an expired license stays in its grace period before it fails
+	GracePeriod time.Duration
+	// ExpirationWarning is how long before the expiration the license is marked as expiring
+	ExpirationWarning time.Duration
 
 	validator *LicenseValidator
 	activator *LicenseActivator
+	notifier  *LicenseNotifier
 }
 
 var requeueRes = ctrl.Result{RequeueAfter: time.Minute}
@@ -53,6 +61,7 @@ var requeueRes = ctrl.Result{RequeueAfter: time.Minute}
 // +kubebuilder:rbac:groups=license.sealos.io,resources=licenses/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=license.sealos.io,resources=licenses/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=notification.sealos.io,resources=notifications,verbs=get;list;watch;create;update;patch
 
 func (r *LicenseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	r.Logger.V(1).Info("start reconcile for license")
@@ -69,16 +78,41 @@ func (r *LicenseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 func (r *LicenseReconciler) reconcile(ctx context.Context, license *licensev1.License) (ctrl.Result, error) {
 	r.Logger.V(1).Info("reconcile for license", "license", license.Namespace+"/"+license.Name)
 	// check if license is valid
-	valid, err := r.validator.Validate(license)
+	valid, clusterInfo, err := r.validator.Validate(license)
 	if err != nil {
 		r.Logger.V(1).Error(err, "failed to validate license")
 		return requeueRes, err
 	}
 
-	claims, err := licenseutil.GetClaims(license)
+	claims, renewals, renewalErrs := licenseutil.GetEffectiveClaims(license)
+	if claims == nil {
+		return ctrl.Result{}, renewalErrs[0]
+	}
+	for _, err := range renewalErrs {
+		r.Logger.Info("ignore invalid renewal", "license", license.Namespace+"/"+license.Name, "error", err.Error())
+	}
+	license.Status.Renewals = renewals
+
+	exp, err := licenseutil.GetLicenseExpireTime(license)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	now := time.Now()
+	lastPhase := license.Status.Phase
+
+	if claims.Type == licensev1.ClusterLicenseType {
+		exceeded, err := clusterInfo.ExceededEntitlements(&claims.Data)
+		if err != nil {
+			return requeueRes, err
+		}
+		if len(exceeded) > 0 {
+			setCondition(license, licensev1.ConditionTypeEntitlementExceeded, true, "EntitlementExceeded",
+				"cluster exceeds the entitlements of license: "+strings.Join(exceeded, ", "))
+		} else {
+			setCondition(license, licensev1.ConditionTypeEntitlementExceeded, false, "WithinEntitlements",
+				"cluster is within the entitlements of license")
+		}
+	}
 
 	switch valid {
 	case licensev1.ValidationClusterIDMismatch:
@@ -96,9 +130,29 @@ func (r *LicenseReconciler) reconcile(ctx context.Context, license *licensev1.Li
 		_ = r.Status().Update(ctx, license)
 		return requeueRes, nil
 	case licensev1.ValidationExpired:
-		license.Status.Phase = licensev1.LicenseStatusPhaseFailed
 		license.Status.ValidationCode = valid
+		license.Status.ExpirationTime = metav1.NewTime(exp)
+		setCondition(license, licensev1.ConditionTypeExpiring, false, "Expired", "license is expired")
+		graceEnd := exp.Add(r.GracePeriod)
+		if now.Before(graceEnd) {
+			license.Status.Phase = licensev1.LicenseStatusPhaseGracePeriod
+			license.Status.GracePeriodEndTime = metav1.NewTime(graceEnd)
+			license.Status.Reason = fmt.Sprintf("license is expired, it fails at %s", graceEnd.Format(time.RFC3339))
+			setCondition(license, licensev1.ConditionTypeDegraded, true, "GracePeriod",
+				fmt.Sprintf("license is in its grace period until %s", graceEnd.Format(time.RFC3339)))
+			if lastPhase != licensev1.LicenseStatusPhaseGracePeriod {
+				r.notify(ctx, license, NoticeGracePeriod, graceEnd)
+			}
+			r.Logger.V(1).Info("license is in grace period", "license", license.Namespace+"/"+license.Name, "grace period end time", graceEnd)
+			_ = r.Status().Update(ctx, license)
+			return requeueAt(now, graceEnd), nil
+		}
+		license.Status.Phase = licensev1.LicenseStatusPhaseFailed
 		license.Status.Reason = "license is expired"
+		setCondition(license, licensev1.ConditionTypeDegraded, false, "Expired", "license is expired")
+		if lastPhase != licensev1.LicenseStatusPhaseFailed {
+			r.notify(ctx, license, NoticeExpired, graceEnd)
+		}
 		r.Logger.V(1).Info("license is invalid", "license", license.Namespace+"/"+license.Name, "reason", valid)
 		_ = r.Status().Update(ctx, license)
 		return requeueRes, nil
@@ -115,16 +169,76 @@ func (r *LicenseReconciler) reconcile(ctx context.Context, license *licensev1.Li
 		r.Logger.V(1).Info("unknown validation code", "code", valid)
 	}
 
-	if license.Spec.Type == licensev1.AccountLicenseType && license.Status.Phase == licensev1.LicenseStatusPhaseActive {
-		r.Logger.V(1).Info("license is active, skip reconcile", "license", license.Namespace+"/"+license.Name)
-		return requeueRes, nil
+	license.Status.ValidationCode = valid
+	warningStart := exp.Add(-r.ExpirationWarning)
+	expiring := !exp.IsZero() && !now.Before(warningStart)
+	if setCondition(license, licensev1.ConditionTypeExpiring, expiring, "ExpirationWarning",
+		fmt.Sprintf("license expires at %s", exp.Format(time.RFC3339))) && expiring {
+		r.notify(ctx, license, NoticeExpiring, exp)
+	}
+
+	setCondition(license, licensev1.ConditionTypeDegraded, false, "Valid", "license is valid")
+
+	if license.Spec.Type == licensev1.AccountLicenseType && license.Status.ActivationTime.IsZero() {
+		degraded, err := r.activator.ClusterDegraded(ctx)
+		if err != nil {
+			return requeueRes, err
+		}
+		if degraded {
+			license.Status.Phase = licensev1.LicenseStatusPhasePending
+			license.Status.Reason = "the cluster license is in its grace period, renew it to recharge the account"
+			r.Logger.V(1).Info("cluster license is degraded, skip recharge", "license", license.Namespace+"/"+license.Name)
+			_ = r.Status().Update(ctx, license)
+			return requeueRes, nil
+		}
 	}
 
 	if err := r.activator.Active(license); err != nil {
 		r.Logger.V(1).Error(err, "failed to active license")
 		return requeueRes, err
 	}
-	return ctrl.Result{RequeueAfter: time.Minute * 30}, nil
+
+	res := ctrl.Result{RequeueAfter: time.Minute * 30}
+	switch {
+	case exp.IsZero():
+	case !expiring:
+		res = requeueAt(now, warningStart)
+	default:
+		res = requeueAt(now, exp)
+	}
+	return res, nil
+}
+
+func (r *LicenseReconciler) notify(ctx context.Context, license *licensev1.License, noticeType NoticeType, at time.Time) {
+	if err := r.notifier.Notify(ctx, license, noticeType, at); err != nil {
+		r.Logger.Error(err, "failed to send license notification", "license", license.Namespace+"/"+license.Name, "type", noticeType)
+	}
+}
+
+// setCondition sets the condition of license and reports whether the condition becomes true
+func setCondition(license *licensev1.License, conditionType string, status bool, reason, message string) bool {
+	wasTrue := meta.IsStatusConditionTrue(license.Status.Conditions, conditionType)
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: license.Generation,
+	}
+	if status {
+		condition.Status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&license.Status.Conditions, condition)
+	return status && !wasTrue
+}
+
+// requeueAt requeues at the time t, but no later than 30 minutes so that the cluster resource is revalidated
+func requeueAt(now, t time.Time) ctrl.Result {
+	after := t.Sub(now)
+	if after <= 0 || after > time.Minute*30 {
+		after = time.Minute * 30
+	}
+	return ctrl.Result{RequeueAfter: after}
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -143,6 +257,10 @@ func (r *LicenseReconciler) SetupWithManager(mgr ctrl.Manager, accountDB databas
 		accountDB: accountDB,
 	}
 
+	r.notifier = &LicenseNotifier{
+		Client: r.Client,
+	}
+
 	// reconcile on generation change
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&licensev1.License{}, builder.WithPredicates(predicate.And(predicate.GenerationChangedPredicate{}))).
@@ -0,0 +1,109 @@
+// Copyright © 2023 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	licensev1 "github.com/labring/sealos/controllers/license/api/v1"
+	notificationv1 "github.com/labring/sealos/controllers/pkg/notification/api/v1"
+)
+
+type NoticeType string
+
+const (
+	NoticeExpiring    NoticeType = "Expiring"
+	NoticeGracePeriod NoticeType = "GracePeriod"
+	NoticeExpired     NoticeType = "Expired"
+)
+
+const (
+	languageZh      = "zh"
+	fromEn          = "License System"
+	fromZh          = "许可证系统"
+	readStatusLabel = "isRead"
+	falseStatus     = "false"
+	noticeTimeFmt   = "2006-01-02 15:04:05"
+)
+
+var titleTemplateEN = map[NoticeType]string{
+	NoticeExpiring:    "License Expiring",
+	NoticeGracePeriod: "License Expired",
+	NoticeExpired:     "License Invalid",
+}
+
+var titleTemplateZH = map[NoticeType]string{
+	NoticeExpiring:    "许可证即将过期",
+	NoticeGracePeriod: "许可证已过期",
+	NoticeExpired:     "许可证已失效",
+}
+
+var noticeTemplateEN = map[NoticeType]string{
+	NoticeExpiring:    "Your license %s expires at %s, please renew it in time.",
+	NoticeGracePeriod: "Your license %s has expired and fails at %s. Please renew it before then.",
+	NoticeExpired:     "Your license %s has expired and its grace period ended at %s, please renew it.",
+}
+
+var noticeTemplateZH = map[NoticeType]string{
+	NoticeExpiring:    "您的许可证 %s 将于 %s 过期，请及时续期。",
+	NoticeGracePeriod: "您的许可证 %s 已过期，将于 %s 失效，请在此之前续期。",
+	NoticeExpired:     "您的许可证 %s 已过期，宽限期已于 %s 结束，请续期。",
+}
+
+// LicenseNotifier sends desktop notifications to the namespace of a license
+type LicenseNotifier struct {
+	client.Client
+}
+
+// Notify creates or updates the notification of noticeType for the license, at is the time shown in the message
+func (n *LicenseNotifier) Notify(ctx context.Context, license *licensev1.License, noticeType NoticeType, at time.Time) error {
+	ntf := &notificationv1.Notification{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "license-" + license.Name + "-" + strings.ToLower(string(noticeType)),
+			Namespace: license.Namespace,
+		},
+	}
+	atStr := at.Local().Format(noticeTimeFmt)
+	_, err := controllerutil.CreateOrUpdate(ctx, n.Client, ntf, func() error {
+		ntf.Spec = notificationv1.NotificationSpec{
+			Title:        titleTemplateEN[noticeType],
+			Message:      fmt.Sprintf(noticeTemplateEN[noticeType], license.Name, atStr),
+			From:         fromEn,
+			Importance:   notificationv1.High,
+			DesktopPopup: true,
+			Timestamp:    time.Now().UTC().Unix(),
+			I18n: map[string]notificationv1.I18n{
+				languageZh: {
+					Title:   titleTemplateZH[noticeType],
+					From:    fromZh,
+					Message: fmt.Sprintf(noticeTemplateZH[noticeType], license.Name, atStr),
+				},
+			},
+		}
+		if ntf.Labels == nil {
+			ntf.Labels = make(map[string]string)
+		}
+		ntf.Labels[readStatusLabel] = falseStatus
+		return nil
+	})
+	return err
+}
@@ -20,9 +20,9 @@ import (
 	"github.com/go-logr/logr"
 
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/labring/sealos/controllers/job/heartbeat/api/v1alpha"
 	licensev1 "github.com/labring/sealos/controllers/license/api/v1"
 	"github.com/labring/sealos/controllers/license/internal/util/claims"
 	"github.com/labring/sealos/controllers/license/internal/util/cluster"
@@ -35,29 +35,23 @@ type LicenseValidator struct {
 	ClusterID string
 }
 
-func (v *LicenseValidator) Validate(license *licensev1.License) (licensev1.ValidationCode, error) {
+// Validate validates the license against the cluster, and returns the cluster info that it is validated against
+func (v *LicenseValidator) Validate(license *licensev1.License) (licensev1.ValidationCode, *cluster.Info, error) {
 	nodeList := &v1.NodeList{}
 	if err := v.Client.List(context.Background(), nodeList); err != nil {
-		return licensev1.ValidationError, err
+		return licensev1.ValidationError, nil, err
 	}
-	nodeCount := len(nodeList.Items)
-	totalCPU := resource.NewQuantity(0, resource.DecimalSI)
-	totalMemory := resource.NewQuantity(0, resource.BinarySI)
-
-	for _, node := range nodeList.Items {
-		allocatable := node.Status.Allocatable
-		totalCPU.Add(*allocatable.Cpu())
-		totalMemory.Add(*allocatable.Memory())
-	}
-
+	// the entitlements are enforced against the same resource that the heartbeat reports
+	resource := v1alpha.NewClusterResource(nodeList.Items)
 	clusterInfo := &cluster.Info{
 		ClusterID: v.ClusterID,
 		ClusterClaimData: claims.ClusterClaimData{
-			NodeCount:   nodeCount,
-			TotalCPU:    int(totalCPU.MilliValue() / 1000),
-			TotalMemory: int(totalMemory.Value() / (1024 * 1024 * 1024)),
+			NodeCount:   int(resource.Node),
+			TotalCPU:    int(resource.CPU),
+			TotalMemory: int(resource.Mem / (1024 * 1024 * 1024)),
 		},
 	}
 	v.Logger.Info("Validating license", "cluster info", clusterInfo, "license token", license.Spec.Token)
-	return licenseutil.IsLicenseValid(license, clusterInfo, v.ClusterID)
+	valid, err := licenseutil.IsLicenseValid(license, clusterInfo, v.ClusterID)
+	return valid, clusterInfo, err
 }
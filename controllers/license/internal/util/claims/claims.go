@@ -15,6 +15,8 @@
 package claims
 
 import (
+	"fmt"
+
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/mitchellh/mapstructure"
 
@@ -61,8 +63,21 @@ type ClusterClaimData struct {
 // Compare compares the claims with the data
 // return true if the claims is equal or lager to the data
 func (c *ClusterClaimData) Compare(data *ClusterClaimData) bool {
-	if c.NodeCount > data.NodeCount || c.TotalCPU > data.TotalCPU || c.TotalMemory > data.TotalMemory {
-		return false
+	return len(c.Exceeded(data)) == 0
+}
+
+// Exceeded returns the entitlements in data that the usage c exceeds,
+// each one is described as "<entitlement> <usage>/<entitled>"
+func (c *ClusterClaimData) Exceeded(data *ClusterClaimData) []string {
+	var exceeded []string
+	if c.NodeCount > data.NodeCount {
+		exceeded = append(exceeded, fmt.Sprintf("nodeCount %d/%d", c.NodeCount, data.NodeCount))
+	}
+	if c.TotalCPU > data.TotalCPU {
+		exceeded = append(exceeded, fmt.Sprintf("totalCPU %d/%d", c.TotalCPU, data.TotalCPU))
+	}
+	if c.TotalMemory > data.TotalMemory {
+		exceeded = append(exceeded, fmt.Sprintf("totalMemory %d/%d", c.TotalMemory, data.TotalMemory))
 	}
-	return true
+	return exceeded
 }
@@ -16,6 +16,7 @@ package claims
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -79,3 +80,32 @@ func TestClaimData_SwitchToClusterData(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterClaimData_Exceeded(t *testing.T) {
+	entitlements := &ClusterClaimData{NodeCount: 3, TotalCPU: 16, TotalMemory: 32}
+	tests := []struct {
+		name  string
+		usage ClusterClaimData
+		want  []string
+	}{
+		{
+			name:  "within the entitlements",
+			usage: ClusterClaimData{NodeCount: 3, TotalCPU: 16, TotalMemory: 32},
+		},
+		{
+			name:  "exceeds the node count and memory",
+			usage: ClusterClaimData{NodeCount: 4, TotalCPU: 16, TotalMemory: 64},
+			want:  []string{"nodeCount 4/3", "totalMemory 64/32"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.usage.Exceeded(entitlements); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Exceeded() = %v, want %v", got, tt.want)
+			}
+			if got := tt.usage.Compare(entitlements); got != (len(tt.want) == 0) {
+				t.Errorf("Compare() = %v, want %v", got, len(tt.want) == 0)
+			}
+		})
+	}
+}
@@ -33,3 +33,12 @@ func (i *Info) CompareWithClaimData(data *claims.ClaimData) bool {
 	}
 	return i.ClusterClaimData.Compare(cdata)
 }
+
+// ExceededEntitlements returns the entitlements of a cluster license that the cluster exceeds
+func (i *Info) ExceededEntitlements(data *claims.ClaimData) ([]string, error) {
+	cdata := &claims.ClusterClaimData{}
+	if err := data.SwitchToClusterData(cdata); err != nil {
+		return nil, err
+	}
+	return i.ClusterClaimData.Exceeded(cdata), nil
+}
@@ -24,3 +24,4 @@ var ErrClaimsConvent = fmt.Errorf("the claims data provided appears to be invali
 var ErrClusterIDNotMatch = fmt.Errorf("the cluster id provided appears to be invalid")
 var ErrClusterLicenseNotMatch = fmt.Errorf("the cluster license provided appears to be invalid")
 var ErrLicenseExpired = fmt.Errorf("the license provided appears to be expired")
+var ErrRenewalNotExtended = fmt.Errorf("the renewal provided appears to expire before the license")
@@ -16,6 +16,7 @@ package license
 
 import (
 	"encoding/base64"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -28,8 +29,15 @@ import (
 	"github.com/labring/sealos/controllers/pkg/crypto"
 )
 
+// the claims are validated by validateClaims instead of the parser, an expired license may still be in its grace period
+var parser = jwt.NewParser(jwt.WithoutClaimsValidation())
+
 func ParseLicenseToken(license *licensev1.License) (*jwt.Token, error) {
-	token, err := jwt.ParseWithClaims(license.Spec.Token, &utilclaims.Claims{},
+	return ParseToken(license.Spec.Token)
+}
+
+func ParseToken(tokenString string) (*jwt.Token, error) {
+	token, err := parser.ParseWithClaims(tokenString, &utilclaims.Claims{},
 		func(_ *jwt.Token) (interface{}, error) {
 			decodeKey, err := base64.StdEncoding.DecodeString(key.EncryptionKey)
 			if err != nil {
@@ -44,11 +52,34 @@ func ParseLicenseToken(license *licensev1.License) (*jwt.Token, error) {
 	if err != nil {
 		return nil, err
 	}
+	claims, ok := token.Claims.(*utilclaims.Claims)
+	if !ok {
+		return nil, errors.ErrClaimsConvent
+	}
+	if err := validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
 	return token, nil
 }
 
+// validateClaims validates the claims like the parser does except the expiration,
+// which is checked by IsLicenseValid
+func validateClaims(claims *utilclaims.Claims, now time.Time) error {
+	if !claims.VerifyIssuedAt(now, false) {
+		return jwt.ErrTokenUsedBeforeIssued
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return jwt.ErrTokenNotValidYet
+	}
+	return nil
+}
+
 func GetClaims(license *licensev1.License) (*utilclaims.Claims, error) {
-	token, err := ParseLicenseToken(license)
+	return getTokenClaims(license.Spec.Token)
+}
+
+func getTokenClaims(tokenString string) (*utilclaims.Claims, error) {
+	token, err := ParseToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
@@ -59,17 +90,58 @@ func GetClaims(license *licensev1.License) (*utilclaims.Claims, error) {
 	return claims, nil
 }
 
-func IsLicenseValid(license *licensev1.License, clusterInfo *cluster.Info, clusterID string) (licensev1.ValidationCode, error) {
-	token, err := ParseLicenseToken(license)
+// GetEffectiveClaims returns the claims of the latest valid renewal of the license, or the claims of
+// the license itself if it is not renewed, and the number of valid renewals.
+// Invalid renewals are ignored, they are returned as renewalErrs.
+func GetEffectiveClaims(license *licensev1.License) (claims *utilclaims.Claims, renewals int, renewalErrs []error) {
+	claims, err := GetClaims(license)
 	if err != nil {
-		return licensev1.ValidationError, err
+		return nil, 0, []error{err}
 	}
-	if !token.Valid {
-		return licensev1.ValidationExpired, nil
+	base := claims
+	for i, token := range license.Spec.Renewals {
+		renewal, err := getTokenClaims(token)
+		if err == nil {
+			err = validateRenewal(base, renewal)
+		}
+		if err != nil {
+			renewalErrs = append(renewalErrs, fmt.Errorf("renewal %d: %w", i, err))
+			continue
+		}
+		renewals++
+		if expireTime(renewal).After(expireTime(claims)) {
+			claims = renewal
+		}
 	}
-	claims, err := GetClaims(license)
-	if err != nil {
-		return licensev1.ValidationError, err
+	return claims, renewals, renewalErrs
+}
+
+func validateRenewal(base, renewal *utilclaims.Claims) error {
+	if renewal.Type != base.Type {
+		return errors.ErrLicenseTypeNotMatch
+	}
+	if renewal.ClusterID != base.ClusterID {
+		return errors.ErrClusterIDNotMatch
+	}
+	// a license without expiration can not be renewed
+	if base.ExpiresAt == nil || renewal.ExpiresAt == nil || !renewal.ExpiresAt.After(base.ExpiresAt.Time) {
+		return errors.ErrRenewalNotExtended
+	}
+	return nil
+}
+
+// expireTime returns the zero time if the claims never expire
+func expireTime(claims *utilclaims.Claims) time.Time {
+	if claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.UTC()
+}
+
+func IsLicenseValid(license *licensev1.License, clusterInfo *cluster.Info, clusterID string) (licensev1.ValidationCode, error) {
+	claims, _, errs := GetEffectiveClaims(license)
+	if claims == nil {
+		return licensev1.ValidationError, errs[0]
 	}
 	// if clusterID is empty, it means this license is a super license.
 	if claims.ClusterID != "" && claims.ClusterID != clusterID {
@@ -81,13 +153,20 @@ func IsLicenseValid(license *licensev1.License, clusterInfo *cluster.Info, clust
 			return licensev1.ValidationClusterInfoMismatch, nil
 		}
 	}
+
+	// the expiration is checked last, only a license that is otherwise valid is in its grace period
+	if exp := expireTime(claims); !exp.IsZero() && !time.Now().Before(exp) {
+		return licensev1.ValidationExpired, nil
+	}
 	return licensev1.ValidationSuccess, nil
 }
 
+// GetLicenseExpireTime returns the expiration time of the license extended by its renewals,
+// the zero time is returned if the license never expires
 func GetLicenseExpireTime(license *licensev1.License) (time.Time, error) {
-	claims, err := GetClaims(license)
-	if err != nil {
-		return time.Time{}, err
+	claims, _, errs := GetEffectiveClaims(license)
+	if claims == nil {
+		return time.Time{}, errs[0]
 	}
-	return claims.ExpiresAt.UTC(), nil
+	return expireTime(claims), nil
 }
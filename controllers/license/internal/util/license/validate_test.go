@@ -15,13 +15,16 @@
 package license
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 
 	licensev1 "github.com/labring/sealos/controllers/license/api/v1"
 	utilclaims "github.com/labring/sealos/controllers/license/internal/util/claims"
 	"github.com/labring/sealos/controllers/license/internal/util/cluster"
+	utilerrors "github.com/labring/sealos/controllers/license/internal/util/errors"
 )
 
 func TestIsLicenseValid(t *testing.T) {
@@ -133,3 +136,102 @@ func TestGetClaims(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRenewal(t *testing.T) {
+	now := time.Now()
+	newClaims := func(licenseType licensev1.LicenseType, clusterID string, exp time.Time) *utilclaims.Claims {
+		claims := &utilclaims.Claims{Type: licenseType, ClusterID: clusterID}
+		if !exp.IsZero() {
+			claims.ExpiresAt = jwt.NewNumericDate(exp)
+		}
+		return claims
+	}
+	base := newClaims(licensev1.ClusterLicenseType, "cluster", now)
+	tests := []struct {
+		name    string
+		base    *utilclaims.Claims
+		renewal *utilclaims.Claims
+		wantErr error
+	}{
+		{
+			name:    "extends the license",
+			base:    base,
+			renewal: newClaims(licensev1.ClusterLicenseType, "cluster", now.Add(time.Hour)),
+		},
+		{
+			name:    "type mismatch",
+			base:    base,
+			renewal: newClaims(licensev1.AccountLicenseType, "cluster", now.Add(time.Hour)),
+			wantErr: utilerrors.ErrLicenseTypeNotMatch,
+		},
+		{
+			name:    "cluster id mismatch",
+			base:    base,
+			renewal: newClaims(licensev1.ClusterLicenseType, "other", now.Add(time.Hour)),
+			wantErr: utilerrors.ErrClusterIDNotMatch,
+		},
+		{
+			name:    "expires before the license",
+			base:    base,
+			renewal: newClaims(licensev1.ClusterLicenseType, "cluster", now.Add(-time.Hour)),
+			wantErr: utilerrors.ErrRenewalNotExtended,
+		},
+		{
+			name:    "license never expires",
+			base:    newClaims(licensev1.ClusterLicenseType, "cluster", time.Time{}),
+			renewal: newClaims(licensev1.ClusterLicenseType, "cluster", now.Add(time.Hour)),
+			wantErr: utilerrors.ErrRenewalNotExtended,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRenewal(tt.base, tt.renewal); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateRenewal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateClaims(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		claims  *utilclaims.Claims
+		wantErr error
+	}{
+		{
+			name: "valid",
+			claims: &utilclaims.Claims{RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now.Add(-time.Hour)),
+				NotBefore: jwt.NewNumericDate(now.Add(-time.Hour)),
+			}},
+		},
+		{
+			name: "expired",
+			claims: &utilclaims.Claims{RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(now.Add(-time.Hour)),
+			}},
+		},
+		{
+			name: "issued in the future",
+			claims: &utilclaims.Claims{RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			}},
+			wantErr: jwt.ErrTokenUsedBeforeIssued,
+		},
+		{
+			name: "not valid yet",
+			claims: &utilclaims.Claims{RegisteredClaims: jwt.RegisteredClaims{
+				NotBefore: jwt.NewNumericDate(now.Add(time.Hour)),
+			}},
+			wantErr: jwt.ErrTokenNotValidYet,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateClaims(tt.claims, now); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1751,6 +1751,10 @@ func (c *Cockroach) InitTables() error {
 	if err != nil {
 		return fmt.Errorf("failed to create table: %v", err)
 	}
+	// audit logs of the user controller are kept with the workspaces in the region database
+	if err = CreateTableIfNotExist(c.Localdb, types.UserAuditLog{}); err != nil {
+		return fmt.Errorf("failed to create table: %v", err)
+	}
 
 	// TODO: remove this after migration
 	if !c.DB.Migrator().HasColumn(&types.Payment{}, `activityType`) {
@@ -0,0 +1,93 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cockroach
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/labring/sealos/controllers/pkg/types"
+)
+
+// CreateUserAuditLog appends an audit log of the user controller to the region database
+func (c *Cockroach) CreateUserAuditLog(log *types.UserAuditLog) error {
+	if err := c.Localdb.Create(log).Error; err != nil {
+		return fmt.Errorf("failed to create user audit log: %w", err)
+	}
+	return nil
+}
+
+// GetUserAuditLogs returns the audit logs of a workspace in the time range, the latest first
+func (c *Cockroach) GetUserAuditLogs(req *types.GetUserAuditLogsReq) (*types.GetUserAuditLogsResp, error) {
+	if req.Namespace == "" {
+		return nil, fmt.Errorf("empty namespace")
+	}
+	page, pageSize := req.Page, req.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	start, end := req.StartTime, req.EndTime
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+
+	query := c.Localdb.Model(&types.UserAuditLog{}).
+		Where(`namespace = ? AND "createdAt" BETWEEN ? AND ?`, req.Namespace, start, end)
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	var (
+		logs  []types.UserAuditLog
+		count int64
+	)
+	if err := query.Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to count user audit logs: %w", err)
+	}
+	if err := query.Order(`"createdAt" DESC`).Limit(pageSize).Offset((page - 1) * pageSize).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user audit logs: %w", err)
+	}
+	return &types.GetUserAuditLogsResp{
+		AuditLogs: logs,
+		LimitResp: types.LimitResp{
+			Total:     count,
+			TotalPage: (count + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}, nil
+}
+
+// GetWorkspaceRole returns the role of the user in a workspace of the region, it is empty if the user is not a member
+func (c *Cockroach) GetWorkspaceRole(workspace string, userUID uuid.UUID) (types.Role, error) {
+	var roles []types.Role
+	err := c.Localdb.Table("Workspace").
+		Select(`"UserWorkspace".role`).
+		Joins(`JOIN "UserWorkspace" ON "Workspace".uid = "UserWorkspace"."workspaceUid"`).
+		Joins(`JOIN "UserCr" ON "UserWorkspace"."userCrUid" = "UserCr".uid`).
+		Where(`"Workspace".id = ? AND "UserCr"."userUid" = ?`, workspace, userUID).
+		Where(`"UserWorkspace".status = ?`, types.JoinStatusInWorkspace).
+		Limit(1).
+		Scan(&roles).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get role of user %s in workspace %s: %w", userUID, workspace, err)
+	}
+	if len(roles) == 0 {
+		return "", nil
+	}
+	return roles[0], nil
+}
@@ -0,0 +1,218 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// HTTPRouteGVK is the HTTPRoute of Gateway API, it is handled as unstructured so that
+// the controllers do not depend on the Gateway API CRDs unless the gateway backend is used
+var HTTPRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// GatewayGenerator generates an HTTPRoute attached to a Gateway. TLS is terminated by the listeners of the
+// Gateway, a route with TLS is attached to HTTPSSectionName and a route without TLS to HTTPSectionName.
+// A route that can not be expressed by an HTTPRoute of the Gateway is refused instead of being exposed
+// without its checks.
+type GatewayGenerator struct {
+	Name      string
+	Namespace string
+	// HTTPSectionName and HTTPSSectionName are the listeners of the Gateway, the route is attached to
+	// all the listeners that match its host if the section name is empty
+	HTTPSectionName  string
+	HTTPSSectionName string
+	// CORSFilter is true if the Gateway implements the CORS filter of HTTPRoute, which is in the experimental
+	// channel of Gateway API since v1.3, the routes with CORS origins are refused without it
+	CORSFilter bool
+}
+
+// NewHTTPRoute returns an empty HTTPRoute, it is used to watch the HTTPRoutes owned by a controller
+func NewHTTPRoute() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(HTTPRouteGVK)
+	return route
+}
+
+func (g GatewayGenerator) New(route *Route) client.Object {
+	obj := NewHTTPRoute()
+	obj.SetName(route.Name)
+	obj.SetNamespace(route.Namespace)
+	return obj
+}
+
+func (g GatewayGenerator) Apply(route *Route, obj client.Object) error {
+	httpRoute, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("gateway backend requires an unstructured HTTPRoute, got %T", obj)
+	}
+	if g.Name == "" {
+		return fmt.Errorf("gateway of HTTPRoute %s/%s is not configured", route.Namespace, route.Name)
+	}
+	mergeLabels(httpRoute, route.Labels)
+
+	parentRef := map[string]interface{}{
+		"group": HTTPRouteGVK.Group,
+		"kind":  "Gateway",
+		"name":  g.Name,
+	}
+	if g.Namespace != "" {
+		parentRef["namespace"] = g.Namespace
+	}
+	sectionName := g.HTTPSectionName
+	if route.TLSSecretName != "" {
+		sectionName = g.HTTPSSectionName
+	}
+	if sectionName != "" {
+		parentRef["sectionName"] = sectionName
+	}
+
+	backendRule := map[string]interface{}{
+		"backendRefs": []interface{}{
+			map[string]interface{}{
+				"name": route.ServiceName,
+				"port": int64(route.ServicePort),
+			},
+		},
+	}
+	filters, err := g.filters(route)
+	if err != nil {
+		return err
+	}
+	if len(filters) > 0 {
+		backendRule["filters"] = filters
+	}
+	if route.Timeout > 0 {
+		backendRule["timeouts"] = map[string]interface{}{
+			"request":        route.Timeout.String(),
+			"backendRequest": route.Timeout.String(),
+		}
+	}
+	rules := []interface{}{backendRule}
+	if route.SameSiteOnly {
+		// the rule with a header match takes precedence over the rule of the same path without it, the other
+		// requests fall back to the rule without backends, which the Gateway answers with 404
+		backendRule["matches"] = []interface{}{
+			pathMatch(headerMatch("Exact", "Upgrade", "websocket")),
+			pathMatch(headerMatch("RegularExpression", "Sec-Fetch-Site", "same-.*")),
+		}
+		rules = append(rules, map[string]interface{}{
+			"matches": []interface{}{pathMatch()},
+		})
+	} else {
+		backendRule["matches"] = []interface{}{pathMatch()}
+	}
+
+	httpRoute.Object["spec"] = map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"hostnames":  []interface{}{route.Host},
+		"rules":      rules,
+	}
+	return nil
+}
+
+// filters returns the filters of the rule that forwards route to its service
+func (g GatewayGenerator) filters(route *Route) ([]interface{}, error) {
+	if route.NginxSnippet != "" {
+		return nil, fmt.Errorf("nginx snippet of HTTPRoute %s/%s can not be expressed by a gateway", route.Namespace, route.Name)
+	}
+	var filters []interface{}
+	if len(route.CORSOrigins) > 0 {
+		if !g.CORSFilter {
+			return nil, fmt.Errorf("CORS origins of HTTPRoute %s/%s require the CORS filter of gateway %s", route.Namespace, route.Name, g.Name)
+		}
+		origins := make([]interface{}, 0, len(route.CORSOrigins))
+		for _, origin := range route.CORSOrigins {
+			origins = append(origins, origin)
+		}
+		filters = append(filters, map[string]interface{}{
+			"type": "CORS",
+			"cors": map[string]interface{}{
+				"allowOrigins":     origins,
+				"allowMethods":     []interface{}{"PUT", "GET", "POST", "PATCH", "OPTIONS"},
+				"allowCredentials": false,
+			},
+		})
+	}
+	if modifier := headerModifier(route.RequestHeaders, nil); modifier != nil {
+		filters = append(filters, map[string]interface{}{
+			"type":                  "RequestHeaderModifier",
+			"requestHeaderModifier": modifier,
+		})
+	}
+	if modifier := headerModifier(route.ResponseHeaders, route.RemoveResponseHeaders); modifier != nil {
+		filters = append(filters, map[string]interface{}{
+			"type":                   "ResponseHeaderModifier",
+			"responseHeaderModifier": modifier,
+		})
+	}
+	return filters, nil
+}
+
+// pathMatch returns the match of all the paths with headers
+func pathMatch(headers ...interface{}) map[string]interface{} {
+	match := map[string]interface{}{
+		"path": map[string]interface{}{
+			"type":  "PathPrefix",
+			"value": "/",
+		},
+	}
+	if len(headers) > 0 {
+		match["headers"] = headers
+	}
+	return match
+}
+
+// headerMatch returns the match of a header, the RegularExpression type is implementation-specific in Gateway API
+func headerMatch(matchType, name, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":  matchType,
+		"name":  name,
+		"value": value,
+	}
+}
+
+// headerModifier returns the header modifier filter that sets headers and removes the headers in remove,
+// a header with an empty value is removed as well
+func headerModifier(headers []Header, remove []string) map[string]interface{} {
+	var set, removed []interface{}
+	for _, header := range headers {
+		if header.Value == "" {
+			removed = append(removed, header.Name)
+			continue
+		}
+		set = append(set, map[string]interface{}{
+			"name":  header.Name,
+			"value": header.Value,
+		})
+	}
+	for _, name := range remove {
+		removed = append(removed, name)
+	}
+	if len(set) == 0 && len(removed) == 0 {
+		return nil
+	}
+	modifier := map[string]interface{}{}
+	if len(set) > 0 {
+		modifier["set"] = set
+	}
+	if len(removed) > 0 {
+		modifier["remove"] = removed
+	}
+	return modifier
+}
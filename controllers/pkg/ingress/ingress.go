@@ -0,0 +1,101 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ingress exposes the web apps of controllers, such as the terminal and adminer, through the
+// ingress controller or gateway of a cluster. A Route describes how an app is exposed and a Generator
+// turns it into the object of a backend, so that the routing, TLS and headers are the same on every backend.
+package ingress
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Route is the exposure of the service of an app on a host
+type Route struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+
+	Host        string
+	ServiceName string
+	ServicePort int32
+
+	// TLSSecretName is the certificate of host, the route is served over plain http if it is empty
+	TLSSecretName string
+	// Timeout is the timeout of proxying to the app, a long timeout keeps websockets open
+	Timeout time.Duration
+	// MaxBodySize is the maximum size of a request body, such as 32m
+	MaxBodySize string
+	// CORSOrigins are the origins allowed to call the app, the gateway backend requires the CORS filter of the gateway
+	CORSOrigins []string
+	// SameSiteOnly rejects the requests other than websocket upgrades unless their Sec-Fetch-Site is same-origin
+	// or same-site, so that the pages of other sites can not send requests to the app on behalf of the user
+	SameSiteOnly bool
+
+	// RequestHeaders are set on the requests to the app, a header with an empty value is removed.
+	// It is how the secret header of an app is injected, so that the app only accepts the requests through the route.
+	RequestHeaders []Header
+	// ResponseHeaders are set on the responses of the app
+	ResponseHeaders []Header
+	// RemoveResponseHeaders are removed from the responses of the app
+	RemoveResponseHeaders []string
+
+	// NginxSnippet is appended to the configuration snippet of the nginx backend for the checks
+	// that can not be described by a route, the other backends refuse a route with a snippet
+	NginxSnippet string
+}
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Generator generates the object of a backend for a route
+type Generator interface {
+	// New returns an empty object of the backend with the name and namespace of route
+	New(route *Route) client.Object
+	// Apply sets the desired state of route on obj that is returned by New
+	Apply(route *Route, obj client.Object) error
+}
+
+// Sync creates or updates the object of route generated by generator, the object is owned by owner
+func Sync(ctx context.Context, c client.Client, scheme *runtime.Scheme, generator Generator, route *Route, owner client.Object) error {
+	obj := generator.New(route)
+	_, err := controllerutil.CreateOrUpdate(ctx, c, obj, func() error {
+		if err := generator.Apply(route, obj); err != nil {
+			return err
+		}
+		return controllerutil.SetControllerReference(owner, obj, scheme)
+	})
+	return err
+}
+
+func mergeLabels(obj client.Object, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	current := obj.GetLabels()
+	if current == nil {
+		current = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		current[k] = v
+	}
+	obj.SetLabels(current)
+}
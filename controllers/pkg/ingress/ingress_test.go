@@ -0,0 +1,151 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newTestRoute() *Route {
+	return &Route{
+		Name:          "terminal",
+		Namespace:     "ns-test",
+		Labels:        map[string]string{"app": "terminal"},
+		Host:          "tabc.cloud.sealos.io",
+		ServiceName:   "terminal-svc",
+		ServicePort:   8080,
+		TLSSecretName: "wildcard-cert",
+		Timeout:       86400 * time.Second,
+		MaxBodySize:   "32m",
+		CORSOrigins:   []string{"https://cloud.sealos.io", "https://*.cloud.sealos.io"},
+		RequestHeaders: []Header{
+			{Name: "Authorization", Value: ""},
+			{Name: "X-SEALOS-ABCDE", Value: "1"},
+		},
+		SameSiteOnly: true,
+	}
+}
+
+func TestNginxGenerator(t *testing.T) {
+	route := newTestRoute()
+	obj := NginxGenerator{}.New(route)
+	if err := (NginxGenerator{}).Apply(route, obj); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	ingress := obj.(*networkingv1.Ingress)
+
+	want := map[string]string{
+		"kubernetes.io/ingress.class":                        "nginx",
+		"nginx.ingress.kubernetes.io/proxy-send-timeout":     "86400",
+		"nginx.ingress.kubernetes.io/proxy-read-timeout":     "86400",
+		"nginx.ingress.kubernetes.io/proxy-body-size":        "32m",
+		"nginx.ingress.kubernetes.io/proxy-buffer-size":      "64k",
+		"nginx.ingress.kubernetes.io/enable-cors":            "true",
+		"nginx.ingress.kubernetes.io/cors-allow-origin":      "https://cloud.sealos.io,https://*.cloud.sealos.io",
+		"nginx.ingress.kubernetes.io/cors-allow-methods":     "PUT, GET, POST, PATCH, OPTIONS",
+		"nginx.ingress.kubernetes.io/cors-allow-credentials": "false",
+		"nginx.ingress.kubernetes.io/configuration-snippet":  nginxSameSiteSnippet + "\nproxy_set_header Authorization \"\";\nproxy_set_header X-SEALOS-ABCDE \"1\";",
+		"higress.io/request-header-control-update":           "\nAuthorization \"\"\nX-SEALOS-ABCDE \"1\"",
+	}
+	if !reflect.DeepEqual(ingress.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", ingress.Annotations, want)
+	}
+	if len(ingress.Spec.TLS) != 1 || ingress.Spec.TLS[0].SecretName != "wildcard-cert" {
+		t.Errorf("tls = %v, want secret wildcard-cert", ingress.Spec.TLS)
+	}
+	if ingress.Labels["app"] != "terminal" {
+		t.Errorf("labels = %v, want app=terminal", ingress.Labels)
+	}
+}
+
+func TestGatewayGenerator(t *testing.T) {
+	route := newTestRoute()
+	route.ResponseHeaders = []Header{{Name: "X-Xss-Protection", Value: "1; mode=block"}}
+	route.RemoveResponseHeaders = []string{"X-Frame-Options"}
+	generator := GatewayGenerator{Name: "sealos", Namespace: "sealos-system", HTTPSectionName: "http", HTTPSSectionName: "https", CORSFilter: true}
+	obj := generator.New(route)
+	if err := generator.Apply(route, obj); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	httpRoute := obj.(*unstructured.Unstructured)
+	if httpRoute.GroupVersionKind() != HTTPRouteGVK {
+		t.Errorf("gvk = %v, want %v", httpRoute.GroupVersionKind(), HTTPRouteGVK)
+	}
+
+	parentRefs, _, _ := unstructured.NestedSlice(httpRoute.Object, "spec", "parentRefs")
+	if sectionName := parentRefs[0].(map[string]interface{})["sectionName"]; sectionName != "https" {
+		t.Errorf("sectionName = %s, want https", sectionName)
+	}
+	rules, _, _ := unstructured.NestedSlice(httpRoute.Object, "spec", "rules")
+	if len(rules) != 2 {
+		t.Fatalf("rules = %v, want 2 rules", rules)
+	}
+	rule := rules[0].(map[string]interface{})
+	wantMatches := []interface{}{
+		pathMatch(headerMatch("Exact", "Upgrade", "websocket")),
+		pathMatch(headerMatch("RegularExpression", "Sec-Fetch-Site", "same-.*")),
+	}
+	if !reflect.DeepEqual(rule["matches"], wantMatches) {
+		t.Errorf("matches = %v, want %v", rule["matches"], wantMatches)
+	}
+	// the requests of other sites fall back to the rule without backends
+	if fallback := rules[1].(map[string]interface{}); fallback["backendRefs"] != nil || fallback["filters"] != nil {
+		t.Errorf("fallback rule = %v, want a rule without backends", fallback)
+	}
+	wantFilters := []interface{}{
+		map[string]interface{}{
+			"type": "CORS",
+			"cors": map[string]interface{}{
+				"allowOrigins":     []interface{}{"https://cloud.sealos.io", "https://*.cloud.sealos.io"},
+				"allowMethods":     []interface{}{"PUT", "GET", "POST", "PATCH", "OPTIONS"},
+				"allowCredentials": false,
+			},
+		},
+		map[string]interface{}{
+			"type": "RequestHeaderModifier",
+			"requestHeaderModifier": map[string]interface{}{
+				"set":    []interface{}{map[string]interface{}{"name": "X-SEALOS-ABCDE", "value": "1"}},
+				"remove": []interface{}{"Authorization"},
+			},
+		},
+		map[string]interface{}{
+			"type": "ResponseHeaderModifier",
+			"responseHeaderModifier": map[string]interface{}{
+				"set":    []interface{}{map[string]interface{}{"name": "X-Xss-Protection", "value": "1; mode=block"}},
+				"remove": []interface{}{"X-Frame-Options"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(rule["filters"], wantFilters) {
+		t.Errorf("filters = %v, want %v", rule["filters"], wantFilters)
+	}
+	if err := (GatewayGenerator{}).Apply(route, NewHTTPRoute()); err == nil {
+		t.Errorf("Apply() without gateway error = nil, want error")
+	}
+	generator.CORSFilter = false
+	if err := generator.Apply(route, NewHTTPRoute()); err == nil {
+		t.Errorf("Apply() of CORS origins without CORS filter error = nil, want error")
+	}
+	route.CORSOrigins = nil
+	route.NginxSnippet = "\nset $flag 0;"
+	if err := generator.Apply(route, NewHTTPRoute()); err == nil {
+		t.Errorf("Apply() of nginx snippet error = nil, want error")
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	nginxProxyBufferSize = "64k"
+
+	// TODO : higress currently do not support
+	nginxSameSiteSnippet = `
+set $flag 0;
+if ($http_upgrade = 'websocket') {set $flag "${flag}1";}
+if ($http_sec_fetch_site !~ 'same-.*') {set $flag "${flag}2";}
+if ($flag = '02'){ return 403; }`
+)
+
+// NginxGenerator generates an Ingress of ingress-nginx, the higress annotations are also set
+// so that the Ingress is served the same by higress
+type NginxGenerator struct{}
+
+func (NginxGenerator) New(route *Route) client.Object {
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      route.Name,
+			Namespace: route.Namespace,
+		},
+	}
+}
+
+func (NginxGenerator) Apply(route *Route, obj client.Object) error {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return fmt.Errorf("nginx backend requires an Ingress, got %T", obj)
+	}
+	mergeLabels(ingress, route.Labels)
+	ingress.Annotations = nginxAnnotations(route)
+
+	pathType := networkingv1.PathTypePrefix
+	ingress.Spec.Rules = []networkingv1.IngressRule{{
+		Host: route.Host,
+		IngressRuleValue: networkingv1.IngressRuleValue{
+			HTTP: &networkingv1.HTTPIngressRuleValue{
+				Paths: []networkingv1.HTTPIngressPath{{
+					PathType: &pathType,
+					Path:     "/",
+					Backend: networkingv1.IngressBackend{
+						Service: &networkingv1.IngressServiceBackend{
+							Name: route.ServiceName,
+							Port: networkingv1.ServiceBackendPort{
+								Number: route.ServicePort,
+							},
+						},
+					},
+				}},
+			},
+		},
+	}}
+	ingress.Spec.TLS = nil
+	if route.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{
+			Hosts:      []string{route.Host},
+			SecretName: route.TLSSecretName,
+		}}
+	}
+	return nil
+}
+
+func nginxAnnotations(route *Route) map[string]string {
+	timeout := strconv.Itoa(int(route.Timeout.Seconds()))
+	annotations := map[string]string{
+		"kubernetes.io/ingress.class":                    "nginx",
+		"nginx.ingress.kubernetes.io/proxy-send-timeout": timeout,
+		"nginx.ingress.kubernetes.io/proxy-read-timeout": timeout,
+		"nginx.ingress.kubernetes.io/proxy-body-size":    route.MaxBodySize,
+		"nginx.ingress.kubernetes.io/proxy-buffer-size":  nginxProxyBufferSize,
+	}
+	if len(route.CORSOrigins) > 0 {
+		annotations["nginx.ingress.kubernetes.io/enable-cors"] = "true"
+		annotations["nginx.ingress.kubernetes.io/cors-allow-origin"] = strings.Join(route.CORSOrigins, ",")
+		annotations["nginx.ingress.kubernetes.io/cors-allow-methods"] = "PUT, GET, POST, PATCH, OPTIONS"
+		annotations["nginx.ingress.kubernetes.io/cors-allow-credentials"] = "false"
+	}
+
+	snippet := route.NginxSnippet
+	if route.SameSiteOnly {
+		snippet = nginxSameSiteSnippet + snippet
+	}
+	for _, header := range route.RequestHeaders {
+		snippet += fmt.Sprintf("\nproxy_set_header %s \"%s\";", header.Name, header.Value)
+	}
+	for _, name := range route.RemoveResponseHeaders {
+		snippet += fmt.Sprintf("\nmore_clear_headers \"%s:\";", name)
+	}
+	for _, header := range route.ResponseHeaders {
+		snippet += fmt.Sprintf("\nmore_set_headers \"%s: %s\";", header.Name, header.Value)
+	}
+	if snippet != "" {
+		annotations["nginx.ingress.kubernetes.io/configuration-snippet"] = snippet
+	}
+
+	if len(route.RequestHeaders) > 0 {
+		annotations["higress.io/request-header-control-update"] = higressHeaders(route.RequestHeaders)
+	}
+	if len(route.RemoveResponseHeaders) > 0 {
+		annotations["higress.io/response-header-control-remove"] = strings.Join(route.RemoveResponseHeaders, ",")
+	}
+	if len(route.ResponseHeaders) > 0 {
+		annotations["higress.io/response-header-control-update"] = higressHeaders(route.ResponseHeaders)
+	}
+	return annotations
+}
+
+func higressHeaders(headers []Header) string {
+	var b strings.Builder
+	for _, header := range headers {
+		fmt.Fprintf(&b, "\n%s \"%s\"", header.Name, header.Value)
+	}
+	return b.String()
+}
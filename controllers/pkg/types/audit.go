@@ -0,0 +1,47 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserAuditLog is an append-only record of a change made by the user controller to the users of a region,
+// such as a role granted in a workspace, a renewed kubeconfig or a deleted user.
+type UserAuditLog struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	CreatedAt time.Time `gorm:"column:createdAt;type:timestamp(3) with time zone;default:current_timestamp;index" json:"createdAt"`
+	// Actor is the kubernetes user that requested the change, it is empty if the change is made by the controller itself
+	Actor     string `gorm:"column:actor;type:text" json:"actor"`
+	Action    string `gorm:"column:action;type:text;not null" json:"action"`
+	User      string `gorm:"column:user;type:text;not null;index" json:"user"`
+	Namespace string `gorm:"column:namespace;type:text;index" json:"namespace"`
+	Role      string `gorm:"column:role;type:text" json:"role"`
+	Before    string `gorm:"column:before;type:text" json:"before"`
+	After     string `gorm:"column:after;type:text" json:"after"`
+	Result    string `gorm:"column:result;type:text;not null" json:"result"`
+	Message   string `gorm:"column:message;type:text" json:"message"`
+}
+
+func (UserAuditLog) TableName() string {
+	return "UserAuditLog"
+}
+
+const (
+	UserAuditResultSucceeded = "Succeeded"
+	UserAuditResultFailed    = "Failed"
+)
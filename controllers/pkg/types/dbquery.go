@@ -67,3 +67,16 @@ type LimitResp struct {
 	Total     int64 `json:"total"`
 	TotalPage int64 `json:"totalPage"`
 }
+
+type GetUserAuditLogsReq struct {
+	// Namespace is the workspace of the audit logs
+	Namespace string `json:"namespace"`
+	// can be empty to get the audit logs of all actions
+	Action   string `json:"action"`
+	LimitReq `json:",inline"`
+}
+
+type GetUserAuditLogsResp struct {
+	AuditLogs []UserAuditLog `json:"auditLogs"`
+	LimitResp `json:",inline"`
+}
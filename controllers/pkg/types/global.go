@@ -182,9 +182,9 @@ const (
 	OauthProviderTypeGithub   OauthProviderType = "GITHUB"
 	//OauthProviderTypeWechat   OauthProviderType = "WECHAT"
 
-	RoleOwner Role = "OWNER"
+	RoleOwner   Role = "OWNER"
+	RoleManager Role = "MANAGER"
 	//RoleDeveloper Role = "DEVELOPER"
-	//RoleManager   Role = "MANAGER"
 
 	JoinStatusInWorkspace JoinStatus = "IN_WORKSPACE"
 )
@@ -23,11 +23,13 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
-// +kubebuilder:validation:Enum=nginx
+// +kubebuilder:validation:Enum=nginx;gateway
 type IngressType string
 
 const (
 	Nginx IngressType = "nginx"
+	// Gateway exposes the terminal by an HTTPRoute of Gateway API
+	Gateway IngressType = "gateway"
 )
 
 // TerminalSpec defines the desired state of Terminal
@@ -50,6 +52,17 @@ type TerminalSpec struct {
 	//+kubebuilder:validation:Optional
 	//+kubebuilder:default=nginx
 	IngressType IngressType `json:"ingressType"`
+	// IdleTimeout closes a session of the terminal without any input for the duration, such as 30m,
+	// a session is never closed for idleness if it is empty
+	//+kubebuilder:validation:Optional
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// MaxSessionDuration closes a session of the terminal once it lasts for the duration, such as 8h
+	//+kubebuilder:validation:Optional
+	MaxSessionDuration string `json:"maxSessionDuration,omitempty"`
+	// RecordSessions records the sessions of the terminal in asciinema format, the recordings are kept in the
+	// bucket of the controller so that they can not be changed or deleted by the user of the terminal
+	//+kubebuilder:validation:Optional
+	RecordSessions bool `json:"recordSessions,omitempty"`
 }
 
 // TerminalStatus defines the observed state of Terminal
@@ -58,6 +71,15 @@ type TerminalStatus struct {
 	ServiceName       string `json:"serviceName"`
 	SecretHeader      string `json:"secretHeader"`
 	Domain            string `json:"domain"`
+	// Recordings are the latest session recordings uploaded to the bucket of the controller, the newest first
+	Recordings []Recording `json:"recordings,omitempty"`
+}
+
+// Recording is a session recording of a terminal in the bucket of the controller
+type Recording struct {
+	Key          string      `json:"key"`
+	Size         int64       `json:"size"`
+	LastModified metav1.Time `json:"lastModified"`
 }
 
 //+kubebuilder:object:root=true
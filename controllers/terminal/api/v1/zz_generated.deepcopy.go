@@ -25,13 +25,29 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Recording) DeepCopyInto(out *Recording) {
+	*out = *in
+	in.LastModified.DeepCopyInto(&out.LastModified)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Recording.
+func (in *Recording) DeepCopy() *Recording {
+	if in == nil {
+		return nil
+	}
+	out := new(Recording)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Terminal) DeepCopyInto(out *Terminal) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Terminal.
@@ -107,6 +123,13 @@ func (in *TerminalSpec) DeepCopy() *TerminalSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TerminalStatus) DeepCopyInto(out *TerminalStatus) {
 	*out = *in
+	if in.Recordings != nil {
+		in, out := &in.Recordings, &out.Recordings
+		*out = make([]Recording, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TerminalStatus.
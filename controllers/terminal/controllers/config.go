@@ -24,5 +24,42 @@ type Config struct {
 }
 
 type TerminalConfig struct {
-	IngressTLSSecretName string `yaml:"ingressTLSSecretName"`
+	IngressTLSSecretName string        `yaml:"ingressTLSSecretName"`
+	Gateway              GatewayConfig `yaml:"gateway"`
+	Session              SessionConfig `yaml:"session"`
+}
+
+// SessionConfig is the session proxy that enforces the session limits of terminals and records their sessions,
+// and the bucket of the recordings. The credential of the bucket and the key of the upload tokens are read from
+// the envs of the controller, so that they never reach the terminals.
+type SessionConfig struct {
+	// ProxyImage is the image of the session proxy, which is the image of the controller,
+	// the terminals with session limits are rejected if it is empty
+	ProxyImage string `yaml:"proxyImage"`
+	// RecordingURL is the recording server of the controller that the session proxies upload to,
+	// the terminals that record their sessions are rejected if it or RecordingBucket is empty
+	RecordingURL      string `yaml:"recordingURL"`
+	RecordingEndpoint string `yaml:"recordingEndpoint"`
+	RecordingBucket   string `yaml:"recordingBucket"`
+	RecordingSecure   bool   `yaml:"recordingSecure"`
+}
+
+func (c SessionConfig) RecordingEnabled() bool {
+	return c.RecordingURL != "" && c.RecordingBucket != ""
+}
+
+// GatewayConfig is the Gateway that the HTTPRoutes of terminals are attached to,
+// the terminals of ingress type gateway are rejected if Name is empty
+type GatewayConfig struct {
+	Name             string `yaml:"name"`
+	Namespace        string `yaml:"namespace"`
+	HTTPSectionName  string `yaml:"httpSectionName"`
+	HTTPSSectionName string `yaml:"httpsSectionName"`
+	// CORSFilter is true if the Gateway implements the CORS filter of HTTPRoute,
+	// the routes of terminals are refused without it
+	CORSFilter bool `yaml:"corsFilter"`
+}
+
+func (c GatewayConfig) Enabled() bool {
+	return c.Name != ""
 }
@@ -18,85 +18,58 @@ package controllers
 
 import (
 	"fmt"
+	"time"
 
-	networkingv1 "k8s.io/api/networking/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
+	"github.com/labring/sealos/controllers/pkg/ingress"
 	terminalv1 "github.com/labring/sealos/controllers/terminal/api/v1"
 )
 
-const (
-	//TODO : higress currently do not support
-	safeConfigurationSnippet = `
-set $flag 0;
-if ($http_upgrade = 'websocket') {set $flag "${flag}1";}
-if ($http_sec_fetch_site !~ 'same-.*') {set $flag "${flag}2";}
-if ($flag = '02'){ return 403; }`
-)
-
-func (r *TerminalReconciler) createNginxIngress(terminal *terminalv1.Terminal, host string) *networkingv1.Ingress {
-	cors := fmt.Sprintf("https://%s,https://*.%s", r.CtrConfig.Global.CloudDomain+r.getPort(), r.CtrConfig.Global.CloudDomain+r.getPort())
+// ingressTimeout keeps the websocket of a terminal open for a day
+const ingressTimeout = 86400 * time.Second
 
-	secretHeader := terminal.Status.SecretHeader
-	configurationSnippet := safeConfigurationSnippet + `
-proxy_set_header Authorization "";
-proxy_set_header ` + secretHeader + ` "1";`
-
-	higressReqHeaderUpdate := `
-Authorization ""
-` + secretHeader + ` "1"`
-
-	objectMeta := metav1.ObjectMeta{
-		Name:      terminal.Name,
-		Namespace: terminal.Namespace,
-		Annotations: map[string]string{
-			"kubernetes.io/ingress.class":                        "nginx",
-			"nginx.ingress.kubernetes.io/proxy-send-timeout":     "86400",
-			"nginx.ingress.kubernetes.io/proxy-read-timeout":     "86400",
-			"nginx.ingress.kubernetes.io/proxy-body-size":        "32m",
-			"nginx.ingress.kubernetes.io/proxy-buffer-size":      "64k",
-			"nginx.ingress.kubernetes.io/enable-cors":            "true",
-			"nginx.ingress.kubernetes.io/cors-allow-origin":      cors,
-			"nginx.ingress.kubernetes.io/cors-allow-methods":     "PUT, GET, POST, PATCH, OPTIONS",
-			"nginx.ingress.kubernetes.io/cors-allow-credentials": "false",
-			"nginx.ingress.kubernetes.io/configuration-snippet":  configurationSnippet,
-			"higress.io/request-header-control-update":           higressReqHeaderUpdate,
-		},
+func (r *TerminalReconciler) createRoute(terminal *terminalv1.Terminal, host string, recLabels map[string]string) *ingress.Route {
+	cors := []string{
+		fmt.Sprintf("https://%s", r.CtrConfig.Global.CloudDomain+r.getPort()),
+		fmt.Sprintf("https://*.%s", r.CtrConfig.Global.CloudDomain+r.getPort()),
 	}
-
-	pathType := networkingv1.PathTypePrefix
-	paths := []networkingv1.HTTPIngressPath{{
-		PathType: &pathType,
-		Path:     "/",
-		Backend: networkingv1.IngressBackend{
-			Service: &networkingv1.IngressServiceBackend{
-				Name: terminal.Status.ServiceName,
-				Port: networkingv1.ServiceBackendPort{
-					Number: 8080,
-				},
-			},
-		},
-	}}
-	rule := networkingv1.IngressRule{
-		Host: host,
-		IngressRuleValue: networkingv1.IngressRuleValue{
-			HTTP: &networkingv1.HTTPIngressRuleValue{
-				Paths: paths,
-			},
+	return &ingress.Route{
+		Name:          terminal.Name,
+		Namespace:     terminal.Namespace,
+		Labels:        recLabels,
+		Host:          host,
+		ServiceName:   terminal.Status.ServiceName,
+		ServicePort:   8080,
+		TLSSecretName: r.CtrConfig.TerminalConfig.IngressTLSSecretName,
+		Timeout:       ingressTimeout,
+		MaxBodySize:   "32m",
+		CORSOrigins:   cors,
+		// the terminal only accepts the requests with the secret header, the token of
+		// the user must not be passed through to the terminal
+		RequestHeaders: []ingress.Header{
+			{Name: "Authorization", Value: ""},
+			{Name: terminal.Status.SecretHeader, Value: "1"},
 		},
+		SameSiteOnly: true,
 	}
+}
 
-	tls := networkingv1.IngressTLS{
-		Hosts:      []string{host},
-		SecretName: r.CtrConfig.TerminalConfig.IngressTLSSecretName,
-	}
-
-	ingress := &networkingv1.Ingress{
-		ObjectMeta: objectMeta,
-		Spec: networkingv1.IngressSpec{
-			Rules: []networkingv1.IngressRule{rule},
-			TLS:   []networkingv1.IngressTLS{tls},
-		},
+// getIngressGenerator returns the generator of ingressType
+func (r *TerminalReconciler) getIngressGenerator(ingressType terminalv1.IngressType) (ingress.Generator, error) {
+	switch ingressType {
+	case terminalv1.Nginx:
+		return ingress.NginxGenerator{}, nil
+	case terminalv1.Gateway:
+		gateway := r.CtrConfig.TerminalConfig.Gateway
+		if !gateway.Enabled() {
+			return nil, fmt.Errorf("ingress type %s is not enabled, the gateway is not configured", ingressType)
+		}
+		return ingress.GatewayGenerator{
+			Name:             gateway.Name,
+			Namespace:        gateway.Namespace,
+			HTTPSectionName:  gateway.HTTPSectionName,
+			HTTPSSectionName: gateway.HTTPSSectionName,
+			CORSFilter:       gateway.CORSFilter,
+		}, nil
 	}
-	return ingress
+	return nil, fmt.Errorf("unsupported ingress type %s", ingressType)
 }
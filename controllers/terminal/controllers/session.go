@@ -0,0 +1,161 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	terminalv1 "github.com/labring/sealos/controllers/terminal/api/v1"
+	"github.com/labring/sealos/controllers/terminal/recording"
+	"github.com/labring/sealos/controllers/terminal/sessionproxy"
+)
+
+const (
+	SessionProxyContainerName = "session-proxy"
+	// SessionProxyTerminationGracePeriodSeconds gives the session proxy the time to upload the recordings of
+	// the sessions closed when the pod is terminated
+	SessionProxyTerminationGracePeriodSeconds = 60
+	// MaxStatusRecordings keeps the status of a terminal small, the older recordings are still kept in the bucket
+	MaxStatusRecordings = 20
+)
+
+// request and limit for the session proxy of a terminal
+const (
+	SessionProxyCPURequest    = "0.01"
+	SessionProxyMemoryRequest = "16Mi"
+	SessionProxyCPULimit      = "0.1"
+	SessionProxyMemoryLimit   = "64Mi"
+)
+
+// needsSessionProxy returns true if the sessions of terminal are limited or recorded
+func needsSessionProxy(terminal *terminalv1.Terminal) bool {
+	return terminal.Spec.IdleTimeout != "" || terminal.Spec.MaxSessionDuration != "" || terminal.Spec.RecordSessions
+}
+
+// getSessionProxy returns the sidecar that the service of terminal targets to enforce its session limits and
+// record its sessions, so that they do not depend on the tty image. It returns nil if the sessions are not
+// limited nor recorded. The token of the session proxy only allows to upload new recordings of the terminal,
+// the credential of the bucket is kept by the controller.
+func (r *TerminalReconciler) getSessionProxy(terminal *terminalv1.Terminal) (*corev1.Container, error) {
+	if !needsSessionProxy(terminal) {
+		return nil, nil
+	}
+	session := r.CtrConfig.TerminalConfig.Session
+	if session.ProxyImage == "" {
+		return nil, errors.New("session proxy is not configured, the sessions can not be limited or recorded")
+	}
+	envs := []corev1.EnvVar{
+		{Name: "UPSTREAM", Value: "http://127.0.0.1:8080"},
+		{Name: "LISTEN", Value: ":" + strconv.Itoa(sessionproxy.DefaultPort)},
+		// the proxy leaves a margin of the termination grace period to stop itself
+		{Name: "SHUTDOWN_TIMEOUT", Value: (SessionProxyTerminationGracePeriodSeconds*time.Second - 5*time.Second).String()},
+	}
+	if terminal.Spec.IdleTimeout != "" {
+		if _, err := time.ParseDuration(terminal.Spec.IdleTimeout); err != nil {
+			return nil, fmt.Errorf("invalid idle timeout %s: %w", terminal.Spec.IdleTimeout, err)
+		}
+		envs = append(envs, corev1.EnvVar{Name: "IDLE_TIMEOUT", Value: terminal.Spec.IdleTimeout})
+	}
+	if terminal.Spec.MaxSessionDuration != "" {
+		if _, err := time.ParseDuration(terminal.Spec.MaxSessionDuration); err != nil {
+			return nil, fmt.Errorf("invalid max session duration %s: %w", terminal.Spec.MaxSessionDuration, err)
+		}
+		envs = append(envs, corev1.EnvVar{Name: "MAX_SESSION_DURATION", Value: terminal.Spec.MaxSessionDuration})
+	}
+	if terminal.Spec.RecordSessions {
+		if r.RecordingStore == nil {
+			return nil, errors.New("recording is not configured, the sessions can not be recorded")
+		}
+		envs = append(envs,
+			corev1.EnvVar{Name: "RECORDING_URL", Value: session.RecordingURL},
+			corev1.EnvVar{Name: "RECORDING_TOKEN", Value: recording.Token(r.RecordingTokenKey, terminal.Namespace, terminal.Name)},
+			corev1.EnvVar{Name: "NAMESPACE", Value: terminal.Namespace},
+			corev1.EnvVar{Name: "TERMINAL_NAME", Value: terminal.Name},
+		)
+	}
+	return &corev1.Container{
+		Name:  SessionProxyContainerName,
+		Image: session.ProxyImage,
+		Args:  []string{sessionproxy.Command},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "session",
+				Protocol:      corev1.ProtocolTCP,
+				ContainerPort: sessionproxy.DefaultPort,
+			},
+		},
+		Env: envs,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				"cpu":    resource.MustParse(SessionProxyCPURequest),
+				"memory": resource.MustParse(SessionProxyMemoryRequest),
+			},
+			Limits: corev1.ResourceList{
+				"cpu":    resource.MustParse(SessionProxyCPULimit),
+				"memory": resource.MustParse(SessionProxyMemoryLimit),
+			},
+		},
+	}, nil
+}
+
+// syncRecordings lists the recordings of the terminal in the bucket of the controller into the status
+func (r *TerminalReconciler) syncRecordings(ctx context.Context, terminal *terminalv1.Terminal) error {
+	var recordings []terminalv1.Recording
+	if terminal.Spec.RecordSessions && r.RecordingStore != nil {
+		var err error
+		if recordings, err = r.RecordingStore.List(ctx, recording.Prefix(terminal.Namespace, terminal.Name)); err != nil {
+			return err
+		}
+		recordings = latestRecordings(recordings)
+	}
+	if recordingsEqual(terminal.Status.Recordings, recordings) {
+		return nil
+	}
+	terminal.Status.Recordings = recordings
+	return r.Status().Update(ctx, terminal)
+}
+
+// latestRecordings returns the newest MaxStatusRecordings recordings, the newest first
+func latestRecordings(recordings []terminalv1.Recording) []terminalv1.Recording {
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return recordings[j].LastModified.Before(&recordings[i].LastModified)
+	})
+	if len(recordings) > MaxStatusRecordings {
+		recordings = recordings[:MaxStatusRecordings]
+	}
+	return recordings
+}
+
+func recordingsEqual(a, b []terminalv1.Recording) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].Size != b[i].Size || !a[i].LastModified.Equal(&b[i].LastModified) {
+			return false
+		}
+	}
+	return true
+}
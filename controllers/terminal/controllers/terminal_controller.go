@@ -39,8 +39,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
+	"github.com/labring/sealos/controllers/pkg/ingress"
 	"github.com/labring/sealos/controllers/pkg/utils/label"
 	terminalv1 "github.com/labring/sealos/controllers/terminal/api/v1"
+	"github.com/labring/sealos/controllers/terminal/recording"
+	"github.com/labring/sealos/controllers/terminal/sessionproxy"
 )
 
 const TerminalPartOf = "terminal"
@@ -72,6 +75,9 @@ const (
 	SecretHeaderPrefix = "X-SEALOS-"
 )
 
+// RecordingSyncInterval is the interval of listing the recordings of a terminal
+const RecordingSyncInterval = 5 * time.Minute
+
 // TerminalReconciler reconciles a Terminal object
 type TerminalReconciler struct {
 	client.Client
@@ -79,6 +85,10 @@ type TerminalReconciler struct {
 	recorder  record.EventRecorder
 	Config    *rest.Config
 	CtrConfig *Config
+	// RecordingStore is the bucket of the session recordings, the sessions can not be recorded if it is nil
+	RecordingStore recording.Store
+	// RecordingTokenKey signs the tokens that the session proxies upload the recordings with
+	RecordingTokenKey []byte
 }
 
 //+kubebuilder:rbac:groups=terminal.sealos.io,resources=terminals,verbs=get;list;watch;create;update;patch;delete
@@ -88,6 +98,7 @@ type TerminalReconciler struct {
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 
 func (r *TerminalReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx, "terminal", req.NamespacedName)
@@ -165,36 +176,27 @@ func (r *TerminalReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, err
 	}
 
+	// the recordings are only listed for review, a failure must not break the terminal
+	if err := r.syncRecordings(ctx, terminal); err != nil {
+		logger.Error(err, "sync recordings failed")
+		r.recorder.Eventf(terminal, corev1.EventTypeWarning, "Sync recordings failed", "%v", err)
+	}
+
 	r.recorder.Eventf(terminal, corev1.EventTypeNormal, "Created", "create terminal success: %v", terminal.Name)
 	duration, _ := time.ParseDuration(terminal.Spec.Keepalived)
+	if terminal.Spec.RecordSessions && (duration == 0 || duration > RecordingSyncInterval) {
+		duration = RecordingSyncInterval
+	}
 	return ctrl.Result{RequeueAfter: duration}, nil
 }
 
 func (r *TerminalReconciler) syncIngress(ctx context.Context, terminal *terminalv1.Terminal, hostname string, recLabels map[string]string) error {
-	var err error
 	host := hostname + "." + r.CtrConfig.Global.CloudDomain
-	switch terminal.Spec.IngressType {
-	case terminalv1.Nginx:
-		err = r.syncNginxIngress(ctx, terminal, host, recLabels)
-	}
-	return err
-}
-
-func (r *TerminalReconciler) syncNginxIngress(ctx context.Context, terminal *terminalv1.Terminal, host string, recLabels map[string]string) error {
-	ingress := &networkingv1.Ingress{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      terminal.Name,
-			Namespace: terminal.Namespace,
-			Labels:    recLabels,
-		},
+	generator, err := r.getIngressGenerator(terminal.Spec.IngressType)
+	if err != nil {
+		return err
 	}
-	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, ingress, func() error {
-		expectIngress := r.createNginxIngress(terminal, host)
-		ingress.ObjectMeta.Annotations = expectIngress.ObjectMeta.Annotations
-		ingress.Spec.Rules = expectIngress.Spec.Rules
-		ingress.Spec.TLS = expectIngress.Spec.TLS
-		return controllerutil.SetControllerReference(terminal, ingress, r.Scheme)
-	}); err != nil {
+	if err := ingress.Sync(ctx, r.Client, r.Scheme, generator, r.createRoute(terminal, host, recLabels), terminal); err != nil {
 		return err
 	}
 
@@ -208,11 +210,16 @@ func (r *TerminalReconciler) syncNginxIngress(ctx context.Context, terminal *ter
 }
 
 func (r *TerminalReconciler) syncService(ctx context.Context, terminal *terminalv1.Terminal, recLabels map[string]string) error {
+	// the sessions go through the session proxy if they are limited or recorded
+	targetPort := intstr.FromInt(8080)
+	if needsSessionProxy(terminal) {
+		targetPort = intstr.FromInt(sessionproxy.DefaultPort)
+	}
 	expectServiceSpec := corev1.ServiceSpec{
 		Selector: recLabels,
 		Type:     corev1.ServiceTypeClusterIP,
 		Ports: []corev1.ServicePort{
-			{Name: "tty", Port: 8080, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP},
+			{Name: "tty", Port: 8080, TargetPort: targetPort, Protocol: corev1.ProtocolTCP},
 		},
 	}
 
@@ -279,6 +286,10 @@ func (r *TerminalReconciler) syncDeployment(ctx context.Context, terminal *termi
 		// Add secret header
 		{Name: "AUTH_HEADER", Value: terminal.Status.SecretHeader},
 	}
+	sessionProxy, err := r.getSessionProxy(terminal)
+	if err != nil {
+		return err
+	}
 
 	containers = []corev1.Container{
 		{
@@ -298,6 +309,11 @@ func (r *TerminalReconciler) syncDeployment(ctx context.Context, terminal *termi
 			},
 		},
 	}
+	terminationGracePeriodSeconds := int64(corev1.DefaultTerminationGracePeriodSeconds)
+	if sessionProxy != nil {
+		containers = append(containers, *sessionProxy)
+		terminationGracePeriodSeconds = SessionProxyTerminationGracePeriodSeconds
+	}
 
 	expectDeploymentSpec := appsv1.DeploymentSpec{
 		Replicas: terminal.Spec.Replicas,
@@ -305,7 +321,8 @@ func (r *TerminalReconciler) syncDeployment(ctx context.Context, terminal *termi
 		Template: corev1.PodTemplateSpec{
 			ObjectMeta: templateObjMeta,
 			Spec: corev1.PodSpec{
-				Containers: containers,
+				Containers:                    containers,
+				TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
 			},
 		},
 	}
@@ -319,6 +336,7 @@ func (r *TerminalReconciler) syncDeployment(ctx context.Context, terminal *termi
 		deployment.Spec.Replicas = expectDeploymentSpec.Replicas
 		deployment.Spec.Selector = expectDeploymentSpec.Selector
 		deployment.Spec.Template.ObjectMeta.Labels = expectDeploymentSpec.Template.Labels
+		deployment.Spec.Template.Spec.TerminationGracePeriodSeconds = expectDeploymentSpec.Template.Spec.TerminationGracePeriodSeconds
 		if len(deployment.Spec.Template.Spec.Containers) == 0 {
 			deployment.Spec.Template.Spec.Containers = containers
 		} else {
@@ -327,6 +345,8 @@ func (r *TerminalReconciler) syncDeployment(ctx context.Context, terminal *termi
 			deployment.Spec.Template.Spec.Containers[0].Ports = containers[0].Ports
 			deployment.Spec.Template.Spec.Containers[0].Env = containers[0].Env
 			deployment.Spec.Template.Spec.Containers[0].Resources = containers[0].Resources
+			// the session proxy is added or removed with the session limits of the terminal
+			deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers[:1], containers[1:]...)
 		}
 
 		if deployment.Spec.Template.Spec.Hostname == "" {
@@ -401,10 +421,14 @@ func (r *TerminalReconciler) generateSecretHeader() string {
 func (r *TerminalReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.recorder = mgr.GetEventRecorderFor("sealos-terminal-controller")
 	r.Config = mgr.GetConfig()
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&terminalv1.Terminal{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Owns(&appsv1.Deployment{}, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
 		Owns(&corev1.Service{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
-		Owns(&networkingv1.Ingress{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
-		Complete(r)
+		Owns(&networkingv1.Ingress{}, builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	// the HTTPRoute is only watched if the gateway is configured, the CRDs of Gateway API may not be installed
+	if r.CtrConfig.TerminalConfig.Gateway.Enabled() {
+		b = b.Owns(ingress.NewHTTPRoute(), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+	return b.Complete(r)
 }
@@ -40,6 +40,8 @@ import (
 	"github.com/labring/sealos/controllers/pkg/utils/label"
 	terminalv1 "github.com/labring/sealos/controllers/terminal/api/v1"
 	"github.com/labring/sealos/controllers/terminal/controllers"
+	"github.com/labring/sealos/controllers/terminal/recording"
+	"github.com/labring/sealos/controllers/terminal/sessionproxy"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,16 +57,28 @@ func init() {
 }
 
 func main() {
+	// the controller image also runs the session proxy sidecar of the terminals
+	if len(os.Args) > 1 && os.Args[1] == sessionproxy.Command {
+		ctrl.SetLogger(zap.New())
+		if err := sessionproxy.Run(ctrl.SetupSignalHandler()); err != nil {
+			setupLog.Error(err, "fail to run session proxy")
+			os.Exit(1)
+		}
+		return
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var configFilePath string
+	var recordingAddr string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&configFilePath, "config-file-path", "/config.yaml", "The path of the config file")
+	flag.StringVar(&recordingAddr, "recording-bind-address", ":8082", "The address the session recordings are uploaded to.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -120,11 +134,30 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.TerminalReconciler{
+	reconciler := &controllers.TerminalReconciler{
 		Client:    mgr.GetClient(),
 		Scheme:    mgr.GetScheme(),
 		CtrConfig: config,
-	}).SetupWithManager(mgr); err != nil {
+	}
+	if session := config.TerminalConfig.Session; session.RecordingEnabled() {
+		store, err := recording.NewMinioStore(session.RecordingEndpoint, os.Getenv("RECORDING_ACCESS_KEY"),
+			os.Getenv("RECORDING_SECRET_KEY"), session.RecordingBucket, session.RecordingSecure)
+		if err != nil {
+			setupLog.Error(err, "unable to create recording store")
+			os.Exit(1)
+		}
+		tokenKey := []byte(os.Getenv("RECORDING_TOKEN_KEY"))
+		if len(tokenKey) == 0 {
+			setupLog.Error(nil, "RECORDING_TOKEN_KEY is required to record the sessions")
+			os.Exit(1)
+		}
+		reconciler.RecordingStore, reconciler.RecordingTokenKey = store, tokenKey
+		if err := mgr.Add(&recording.Server{Addr: recordingAddr, Store: store, TokenKey: tokenKey}); err != nil {
+			setupLog.Error(err, "unable to add recording server")
+			os.Exit(1)
+		}
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Terminal")
 		os.Exit(1)
 	}
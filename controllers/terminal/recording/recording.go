@@ -0,0 +1,183 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package recording keeps the session recordings of terminals in the bucket of the terminal controller.
+// The session proxies of terminals upload the recordings to the Server of the controller, which puts them
+// in the bucket with the credential of the controller, so that the users can not change or delete them.
+package recording
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	terminalv1 "github.com/labring/sealos/controllers/terminal/api/v1"
+)
+
+const (
+	Format      = "asciicast-v2"
+	Suffix      = ".cast"
+	ContentType = "application/x-asciicast"
+	// MaxSize is the maximum size of a recording of a session
+	MaxSize = 512 << 20
+
+	uploadPath = "/recordings/"
+)
+
+// UploadURL returns the url of server that the recordings of terminal namespace/name are uploaded to
+func UploadURL(server, namespace, name string) string {
+	return strings.TrimSuffix(server, "/") + uploadPath + url.PathEscape(namespace) + "/" + url.PathEscape(name)
+}
+
+// Prefix returns the prefix of the recordings of terminal namespace/name in the bucket
+func Prefix(namespace, name string) string {
+	return namespace + "/" + name + "/"
+}
+
+// Token returns the token that the session proxy of terminal namespace/name uploads its recordings with,
+// it is signed by key of the controller and only allows to upload new recordings of the terminal
+func Token(key []byte, namespace, name string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(namespace + "/" + name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Store keeps the recordings in the bucket of the controller
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	List(ctx context.Context, prefix string) ([]terminalv1.Recording, error)
+}
+
+// MinioStore is a Store in an S3 compatible object storage
+type MinioStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewMinioStore(endpoint, accessKey, secretKey, bucket string, secure bool) (*MinioStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: secure,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MinioStore{client: client, bucket: bucket}, nil
+}
+
+func (s *MinioStore) Put(ctx context.Context, key string, r io.Reader) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{ContentType: ContentType})
+	return err
+}
+
+func (s *MinioStore) List(ctx context.Context, prefix string) ([]terminalv1.Recording, error) {
+	var recordings []terminalv1.Recording
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("unable to list the recordings in bucket %s: %w", s.bucket, object.Err)
+		}
+		if !strings.HasSuffix(object.Key, Suffix) {
+			continue
+		}
+		recordings = append(recordings, terminalv1.Recording{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: metav1.NewTime(object.LastModified),
+		})
+	}
+	return recordings, nil
+}
+
+// Server receives the recordings uploaded by the session proxies and puts them in Store, it is run
+// by the manager of the controller
+type Server struct {
+	Addr     string
+	Store    Store
+	TokenKey []byte
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, uploadPath), "/")
+	if !strings.HasPrefix(r.URL.Path, uploadPath) || !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !hmac.Equal([]byte(token), []byte(Token(s.TokenKey, namespace, name))) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	// the key is chosen by the server, so that a session proxy can not overwrite the existing recordings
+	key := Prefix(namespace, name) + time.Now().UTC().Format("20060102T150405Z") + "-" + rand.String(5) + Suffix
+	if err := s.Store.Put(r.Context(), key, http.MaxBytesReader(w, r.Body, MaxSize)); err != nil {
+		log.FromContext(r.Context()).Error(err, "failed to put the recording", "key", key)
+		status := http.StatusInternalServerError
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Start serves the uploads until ctx is done
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		return err
+	}
+}
+
+// NeedLeaderElection returns false, the recordings are received by all the replicas of the controller
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recording
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	terminalv1 "github.com/labring/sealos/controllers/terminal/api/v1"
+)
+
+type memoryStore map[string]string
+
+func (s memoryStore) Put(_ context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s[key] = string(data)
+	return nil
+}
+
+func (s memoryStore) List(context.Context, string) ([]terminalv1.Recording, error) {
+	return nil, nil
+}
+
+func TestServer(t *testing.T) {
+	store := memoryStore{}
+	key := []byte("controller-key")
+	server := httptest.NewServer(&Server{Store: store, TokenKey: key})
+	defer server.Close()
+
+	upload := func(namespace, name, token string) int {
+		req, err := http.NewRequest(http.MethodPost, UploadURL(server.URL, namespace, name), strings.NewReader("{\"version\":2}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := upload("ns-alice", "terminal", Token(key, "ns-alice", "terminal")); status != http.StatusCreated {
+		t.Fatalf("upload status = %d, want %d", status, http.StatusCreated)
+	}
+	// a token only allows to upload the recordings of its own terminal
+	if status := upload("ns-bob", "terminal", Token(key, "ns-alice", "terminal")); status != http.StatusUnauthorized {
+		t.Errorf("upload of another terminal status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := upload("ns-alice", "terminal", Token([]byte("user-key"), "ns-alice", "terminal")); status != http.StatusUnauthorized {
+		t.Errorf("upload with a forged token status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if len(store) != 1 {
+		t.Fatalf("recordings = %v, want 1 recording", store)
+	}
+	for k, v := range store {
+		if !strings.HasPrefix(k, Prefix("ns-alice", "terminal")) || !strings.HasSuffix(k, Suffix) || v != "{\"version\":2}\n" {
+			t.Errorf("recording %s = %q, want a cast under %s", k, v, Prefix("ns-alice", "terminal"))
+		}
+	}
+}
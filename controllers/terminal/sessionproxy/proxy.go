@@ -0,0 +1,469 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sessionproxy is the sidecar of a terminal that enforces the session limits of the terminal
+// and records its sessions. It proxies the ttyd of the terminal, so that the limits do not depend on the
+// tty image: a session is closed once it is idle for the idle timeout or lasts for the max session
+// duration, and its output is recorded in asciinema format and uploaded to the terminal controller.
+package sessionproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/labring/sealos/controllers/terminal/recording"
+)
+
+// Command is the argument of the controller binary that runs the session proxy instead of the controller
+const Command = "session-proxy"
+
+// DefaultPort is the port of the session proxy, the tty listens on 8080 in the same pod
+const DefaultPort = 8090
+
+// DefaultShutdownTimeout is how long the proxy waits for the sessions to be closed and uploaded when it is stopped
+const DefaultShutdownTimeout = 25 * time.Second
+
+// the messages of ttyd are prefixed by their type, the first message of a client is a JSON with its size
+const (
+	ttydInput  = '0'
+	ttydResize = '1'
+	ttydOutput = '0'
+)
+
+const (
+	checkInterval = time.Second
+	uploadTimeout = 5 * time.Minute
+)
+
+// Uploader uploads the recording of a session
+type Uploader interface {
+	Upload(ctx context.Context, cast io.ReadSeeker) error
+}
+
+type Config struct {
+	// Upstream is the ttyd of the terminal, such as http://127.0.0.1:8080
+	Upstream *url.URL
+	// IdleTimeout closes a session without any input for the duration, it is disabled if it is 0
+	IdleTimeout time.Duration
+	// MaxSessionDuration closes a session once it lasts for the duration, it is disabled if it is 0
+	MaxSessionDuration time.Duration
+	// Uploader uploads the recordings of the sessions, the sessions are not recorded if it is nil
+	Uploader Uploader
+}
+
+// Proxy proxies the http requests to the tty and enforces the limits of the websocket sessions
+type Proxy struct {
+	config        Config
+	proxy         *httputil.ReverseProxy
+	upgrader      websocket.Upgrader
+	checkInterval time.Duration
+
+	// the websocket sessions are hijacked from the http server, so they are tracked by the proxy itself
+	mu       sync.Mutex
+	closing  bool
+	shutdown chan struct{}
+	sessions sync.WaitGroup
+}
+
+func New(config Config) *Proxy {
+	return &Proxy{
+		config: config,
+		proxy:  httputil.NewSingleHostReverseProxy(config.Upstream),
+		upgrader: websocket.Upgrader{
+			// the origin is checked by the ingress of the terminal
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		checkInterval: checkInterval,
+		shutdown:      make(chan struct{}),
+	}
+}
+
+// Shutdown closes the sessions and waits for their recordings to be uploaded until ctx is done,
+// new sessions are refused once it is called
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closing {
+		p.closing = true
+		close(p.shutdown)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.sessions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("sessions are not closed before shutdown: %w", ctx.Err())
+	}
+}
+
+// addSession tracks a new session, it returns false once the proxy is shutting down
+func (p *Proxy) addSession() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closing {
+		return false
+	}
+	p.sessions.Add(1)
+	return true
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		p.proxy.ServeHTTP(w, r)
+		return
+	}
+	p.serveSession(w, r)
+}
+
+func (p *Proxy) serveSession(w http.ResponseWriter, r *http.Request) {
+	logger := log.FromContext(r.Context())
+	if !p.addSession() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer p.sessions.Done()
+	upstream, resp, err := p.dial(r)
+	if err != nil {
+		logger.Error(err, "failed to connect the tty")
+		status := http.StatusBadGateway
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer upstream.Close()
+	responseHeader := http.Header{}
+	if protocol := upstream.Subprotocol(); protocol != "" {
+		responseHeader.Set("Sec-WebSocket-Protocol", protocol)
+	}
+	client, err := p.upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		logger.Error(err, "failed to upgrade the session")
+		return
+	}
+	defer client.Close()
+
+	s := &session{start: time.Now()}
+	s.lastInput.Store(s.start.UnixNano())
+	var cast *os.File
+	if p.config.Uploader != nil {
+		if cast, err = os.CreateTemp("", "session-*"+recording.Suffix); err != nil {
+			logger.Error(err, "failed to create the recording, the session is refused")
+			_ = client.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "session can not be recorded"), time.Now().Add(time.Second))
+			return
+		}
+		defer os.Remove(cast.Name())
+		defer cast.Close()
+		s.recorder = NewRecorder(cast, s.start)
+	}
+
+	// the session ends once any side of it is closed
+	var (
+		wg       sync.WaitGroup
+		doneOnce sync.Once
+	)
+	done := make(chan struct{})
+	pump := func(pump func(from, to *websocket.Conn) error, from, to *websocket.Conn) {
+		defer wg.Done()
+		_ = pump(from, to)
+		doneOnce.Do(func() { close(done) })
+	}
+	wg.Add(2)
+	go pump(s.pumpInput, client, upstream)
+	go pump(s.pumpOutput, upstream, client)
+	code, reason := p.wait(done, s)
+	deadline := time.Now().Add(time.Second)
+	_ = client.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	_ = upstream.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	client.Close()
+	upstream.Close()
+	wg.Wait()
+
+	if cast != nil {
+		if err := p.upload(cast, s.recorder); err != nil {
+			logger.Error(err, "failed to upload the recording of the session")
+		}
+	}
+}
+
+// dial connects the tty with the headers of r, the secret header of the terminal is passed through
+func (p *Proxy) dial(r *http.Request) (*websocket.Conn, *http.Response, error) {
+	target := *p.config.Upstream
+	target.Scheme = "ws"
+	if p.config.Upstream.Scheme == "https" {
+		target.Scheme = "wss"
+	}
+	target.Path = r.URL.Path
+	target.RawQuery = r.URL.RawQuery
+	header := http.Header{}
+	for name, values := range r.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol":
+		default:
+			header[name] = values
+		}
+	}
+	dialer := websocket.Dialer{
+		Subprotocols:     websocket.Subprotocols(r),
+		HandshakeTimeout: 10 * time.Second,
+	}
+	return dialer.DialContext(r.Context(), target.String(), header)
+}
+
+// wait returns the close code and reason of the session once a side of it is closed or it exceeds a limit
+func (p *Proxy) wait(done <-chan struct{}, s *session) (int, string) {
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return websocket.CloseNormalClosure, ""
+		case <-p.shutdown:
+			return websocket.CloseGoingAway, "terminal is shutting down"
+		case now := <-ticker.C:
+			if p.config.MaxSessionDuration > 0 && now.Sub(s.start) >= p.config.MaxSessionDuration {
+				return websocket.ClosePolicyViolation, fmt.Sprintf("session exceeds the max session duration %s", p.config.MaxSessionDuration)
+			}
+			if p.config.IdleTimeout > 0 && now.Sub(time.Unix(0, s.lastInput.Load())) >= p.config.IdleTimeout {
+				return websocket.ClosePolicyViolation, fmt.Sprintf("session is idle for %s", p.config.IdleTimeout)
+			}
+		}
+	}
+}
+
+func (p *Proxy) upload(cast *os.File, recorder *Recorder) error {
+	if err := recorder.Err(); err != nil {
+		return err
+	}
+	info, err := cast.Stat()
+	if err != nil {
+		return err
+	}
+	// nothing is uploaded for a session without any output
+	if info.Size() == 0 {
+		return nil
+	}
+	if _, err := cast.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+	defer cancel()
+	return p.config.Uploader.Upload(ctx, cast)
+}
+
+type session struct {
+	start     time.Time
+	lastInput atomic.Int64
+	recorder  *Recorder
+}
+
+// pumpInput copies the messages of the client to the tty, the input keeps the session active
+func (s *session) pumpInput(client, upstream *websocket.Conn) error {
+	for {
+		messageType, data, err := client.ReadMessage()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if len(data) > 0 {
+			switch data[0] {
+			case ttydInput:
+				s.lastInput.Store(now.UnixNano())
+			case ttydResize, '{':
+				s.resize(now, data)
+			}
+		}
+		if err := upstream.WriteMessage(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+// pumpOutput copies the messages of the tty to the client, the output is recorded
+func (s *session) pumpOutput(upstream, client *websocket.Conn) error {
+	for {
+		messageType, data, err := upstream.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if s.recorder != nil && len(data) > 0 && data[0] == ttydOutput {
+			s.recorder.Output(time.Now(), data[1:])
+		}
+		if err := client.WriteMessage(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) resize(at time.Time, data []byte) {
+	if s.recorder == nil {
+		return
+	}
+	if data[0] == ttydResize {
+		data = data[1:]
+	}
+	size := struct {
+		Columns int `json:"columns"`
+		Rows    int `json:"rows"`
+	}{}
+	if err := json.Unmarshal(data, &size); err != nil {
+		return
+	}
+	s.recorder.Resize(at, size.Columns, size.Rows)
+}
+
+// HTTPUploader uploads the recordings to the recording server of the terminal controller,
+// the token authenticates the session proxy of the terminal
+type HTTPUploader struct {
+	URL       string
+	Namespace string
+	Name      string
+	Token     string
+	Client    *http.Client
+}
+
+func (u *HTTPUploader) Upload(ctx context.Context, cast io.ReadSeeker) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(time.Duration(attempt) * 5 * time.Second):
+			}
+		}
+		if _, err = cast.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err = u.upload(ctx, cast); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (u *HTTPUploader) upload(ctx context.Context, cast io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, recording.UploadURL(u.URL, u.Namespace, u.Name), cast)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+u.Token)
+	req.Header.Set("Content-Type", recording.ContentType)
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload the recording: %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// Run runs the session proxy configured by the envs of the sidecar until ctx is done
+func Run(ctx context.Context) error {
+	upstream, err := url.Parse(getEnv("UPSTREAM", "http://127.0.0.1:8080"))
+	if err != nil {
+		return fmt.Errorf("invalid upstream: %w", err)
+	}
+	config := Config{Upstream: upstream}
+	if config.IdleTimeout, err = parseDurationEnv("IDLE_TIMEOUT"); err != nil {
+		return err
+	}
+	if config.MaxSessionDuration, err = parseDurationEnv("MAX_SESSION_DURATION"); err != nil {
+		return err
+	}
+	shutdownTimeout, err := parseDurationEnv("SHUTDOWN_TIMEOUT")
+	if err != nil {
+		return err
+	}
+	if shutdownTimeout == 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+	if server := os.Getenv("RECORDING_URL"); server != "" {
+		config.Uploader = &HTTPUploader{
+			URL:       server,
+			Namespace: os.Getenv("NAMESPACE"),
+			Name:      os.Getenv("TERMINAL_NAME"),
+			Token:     os.Getenv("RECORDING_TOKEN"),
+		}
+	}
+
+	proxy := New(config)
+	server := &http.Server{
+		Addr:              getEnv("LISTEN", fmt.Sprintf(":%d", DefaultPort)),
+		Handler:           proxy,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+	select {
+	case <-ctx.Done():
+		// the server does not wait for the hijacked sessions, the proxy closes them and waits for their
+		// recordings to be uploaded before the pod is killed
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return errors.Join(server.Shutdown(shutdownCtx), proxy.Shutdown(shutdownCtx))
+	case err := <-errCh:
+		return err
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func parseDurationEnv(key string) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %s: %w", key, value, err)
+	}
+	return duration, nil
+}
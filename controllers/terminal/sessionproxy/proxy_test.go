@@ -0,0 +1,254 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sessionproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type uploaderFunc func(ctx context.Context, cast io.ReadSeeker) error
+
+func (f uploaderFunc) Upload(ctx context.Context, cast io.ReadSeeker) error {
+	return f(ctx, cast)
+}
+
+// newTTY returns a ttyd that echoes the input as output and requires the secret header
+func newTTY(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"tty"}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-SEALOS-ABCDE") != "1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !websocket.IsWebSocketUpgrade(r) {
+			_, _ = w.Write([]byte("index"))
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if len(data) > 0 && data[0] == ttydInput {
+				if err := conn.WriteMessage(websocket.BinaryMessage, append([]byte{ttydOutput}, data[1:]...)); err != nil {
+					return
+				}
+			}
+		}
+	}))
+}
+
+func TestProxySession(t *testing.T) {
+	tty := newTTY(t)
+	defer tty.Close()
+	upstream, _ := url.Parse(tty.URL)
+	uploaded := make(chan []byte, 1)
+	proxy := New(Config{
+		Upstream:    upstream,
+		IdleTimeout: 300 * time.Millisecond,
+		Uploader: uploaderFunc(func(_ context.Context, cast io.ReadSeeker) error {
+			data, err := io.ReadAll(cast)
+			uploaded <- data
+			return err
+		}),
+	})
+	proxy.checkInterval = 10 * time.Millisecond
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	header := http.Header{"X-SEALOS-ABCDE": []string{"1"}}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without secret header = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	dialer := websocket.Dialer{Subprotocols: []string{"tty"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != "tty" {
+		t.Errorf("subprotocol = %q, want tty", conn.Subprotocol())
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"AuthToken":"","columns":100,"rows":30}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("0ls\r")); err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil || string(data) != "0ls\r" {
+		t.Fatalf("output = %q, %v, want 0ls", data, err)
+	}
+
+	// the session is closed by the proxy once it is idle
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Fatalf("read after idle timeout error = %v, want close %d", err, websocket.ClosePolicyViolation)
+	}
+
+	var cast []byte
+	select {
+	case cast = <-uploaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording is not uploaded")
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(cast))
+	var lines []json.RawMessage
+	for scanner.Scan() {
+		lines = append(lines, append(json.RawMessage(nil), scanner.Bytes()...))
+	}
+	if len(lines) != 2 {
+		t.Fatalf("recording = %s, want a header and an event", cast)
+	}
+	var castHeader struct {
+		Version int `json:"version"`
+		Width   int `json:"width"`
+		Height  int `json:"height"`
+	}
+	if err := json.Unmarshal(lines[0], &castHeader); err != nil || castHeader.Version != 2 || castHeader.Width != 100 || castHeader.Height != 30 {
+		t.Errorf("header = %s, want version 2 and size 100x30", lines[0])
+	}
+	var event []interface{}
+	if err := json.Unmarshal(lines[1], &event); err != nil || len(event) != 3 || event[1] != "o" || event[2] != "ls\r" {
+		t.Errorf("event = %s, want output ls", lines[1])
+	}
+}
+
+func TestProxyMaxSessionDuration(t *testing.T) {
+	tty := newTTY(t)
+	defer tty.Close()
+	upstream, _ := url.Parse(tty.URL)
+	proxy := New(Config{Upstream: upstream, MaxSessionDuration: 300 * time.Millisecond})
+	proxy.checkInterval = 10 * time.Millisecond
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws",
+		http.Header{"X-SEALOS-ABCDE": []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the input does not keep a session open for longer than the max session duration
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := conn.WriteMessage(websocket.BinaryMessage, []byte("0a")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Fatalf("read after max session duration error = %v, want close %d", err, websocket.ClosePolicyViolation)
+	}
+}
+
+func TestProxyShutdown(t *testing.T) {
+	tty := newTTY(t)
+	defer tty.Close()
+	upstream, _ := url.Parse(tty.URL)
+	var uploaded atomic.Bool
+	proxy := New(Config{
+		Upstream: upstream,
+		Uploader: uploaderFunc(func(_ context.Context, cast io.ReadSeeker) error {
+			// the upload is slower than the close of the session
+			time.Sleep(200 * time.Millisecond)
+			uploaded.Store(true)
+			return nil
+		}),
+	})
+	proxy.checkInterval = 10 * time.Millisecond
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	header := http.Header{"X-SEALOS-ABCDE": []string{"1"}}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("0ls\r")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := proxy.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if !uploaded.Load() {
+		t.Error("Shutdown() returns before the recording is uploaded")
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("read after shutdown error = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("session after shutdown = %v, %v, want status %d", resp, err, http.StatusServiceUnavailable)
+	}
+}
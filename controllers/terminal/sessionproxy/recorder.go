@@ -0,0 +1,103 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sessionproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// Recorder writes a session in the asciicast v2 format of asciinema, see
+// https://docs.asciinema.org/manual/asciicast/v2/. The header is written with the first event, so that
+// the size of the terminal sent by the client before any output is recorded in the header.
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+	width   int
+	height  int
+	started bool
+	err     error
+}
+
+func NewRecorder(w io.Writer, start time.Time) *Recorder {
+	return &Recorder{w: w, start: start, width: defaultWidth, height: defaultHeight}
+}
+
+// Resize records the new size of the terminal
+func (r *Recorder) Resize(at time.Time, width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	if !r.started {
+		r.width, r.height = width, height
+		return
+	}
+	r.event(at, "r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// Output records the output of the terminal
+func (r *Recorder) Output(at time.Time, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event(at, "o", string(data))
+}
+
+// Err returns the first error of writing the recording
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) event(at time.Time, code, data string) {
+	if r.err != nil {
+		return
+	}
+	if !r.started {
+		r.started = true
+		r.write(map[string]interface{}{
+			"version":   2,
+			"width":     r.width,
+			"height":    r.height,
+			"timestamp": r.start.Unix(),
+		})
+	}
+	r.write([]interface{}{at.Sub(r.start).Seconds(), code, data})
+}
+
+func (r *Recorder) write(v interface{}) {
+	if r.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+		return
+	}
+	_, r.err = r.w.Write(append(data, '\n'))
+}
@@ -23,8 +23,20 @@ import (
 // DeleteRequestSpec defines the desired state of DeleteRequest
 type DeleteRequestSpec struct {
 	User string `json:"user,omitempty"`
+	// WorkspacePolicy decides what happens to the group workspaces still owned by the user, Block keeps the
+	// request pending until they are transferred, Transfer hands each of them to its top member first.
+	//+kubebuilder:default:=Block
+	//+kubebuilder:validation:Enum=Block;Transfer
+	WorkspacePolicy WorkspacePolicy `json:"workspacePolicy,omitempty"`
 }
 
+type WorkspacePolicy string
+
+const (
+	WorkspacePolicyBlock    WorkspacePolicy = "Block"
+	WorkspacePolicyTransfer WorkspacePolicy = "Transfer"
+)
+
 // DeleteRequestStatus defines the observed state of DeleteRequest
 type DeleteRequestStatus struct {
 	//+kubebuilder:validation:Enum=Pending;Processing;Completed;Failed
@@ -45,6 +45,8 @@ const (
 	UserAnnotationOwnerKey   = "user.sealos.io/owner"
 	UserLabelOwnerKey        = "user.sealos.io/owner"
 	UserAnnotationDisplayKey = "user.sealos.io/display-name"
+	// UserAnnotationRequesterKey refers to the kubernetes user who created a request, it is recorded in the audit log
+	UserAnnotationRequesterKey = "user.sealos.io/requester"
 )
 
 const (
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// InvitationLabelInviteeKey refers to the user who is invited
+	InvitationLabelInviteeKey = "user.sealos.io/invitee"
+	// InvitationTokenKey is the key of the token in the secret of an invitation
+	InvitationTokenKey = "token"
+)
+
+// InvitationSpec defines the desired state of Invitation
+type InvitationSpec struct {
+	// User is the invitee, any user with the token can accept the invitation if it is empty.
+	// +optional
+	User string `json:"user,omitempty"`
+	// Role is the role granted to the invitee in the workspace when it accepts the invitation,
+	// it is Manager, Developer or the name of a WorkspaceRole. The owner is only handed over by a transfer.
+	// +kubebuilder:validation:MinLength=1
+	Role RoleType `json:"role"`
+	// RoleCeiling is the most powerful role the invitee can choose when it accepts the invitation,
+	// only Role can be accepted if it is not set.
+	// +optional
+	//+kubebuilder:validation:Enum=Manager;Developer
+	RoleCeiling RoleType `json:"roleCeiling,omitempty"`
+	// TTL is how long the invitation can be accepted after it is created.
+	// +optional
+	//+kubebuilder:default:="72h"
+	TTL metav1.Duration `json:"ttl,omitempty"`
+	// Accept accepts the invitation on behalf of the invitee.
+	// +optional
+	Accept *InvitationAcceptance `json:"accept,omitempty"`
+	// Revoked revokes a pending invitation.
+	// +optional
+	Revoked bool `json:"revoked,omitempty"`
+}
+
+type InvitationAcceptance struct {
+	// User is the user who accepts the invitation, it must be the user who sets the acceptance.
+	User string `json:"user"`
+	// Token is the single-use token of the invitation.
+	Token string `json:"token"`
+	// Role is the role chosen by the invitee, it must not be more powerful than the role ceiling. Default is the role of the invitation.
+	// +optional
+	Role RoleType `json:"role,omitempty"`
+}
+
+type InvitationPhase string
+
+const (
+	InvitationPending  InvitationPhase = "Pending"
+	InvitationAccepted InvitationPhase = "Accepted"
+	InvitationExpired  InvitationPhase = "Expired"
+	InvitationRevoked  InvitationPhase = "Revoked"
+)
+
+// InvitationStatus defines the observed state of Invitation
+type InvitationStatus struct {
+	// Phase is the recently observed lifecycle phase of invitation.
+	//+kubebuilder:default:=Pending
+	//+kubebuilder:validation:Enum=Pending;Accepted;Expired;Revoked
+	Phase InvitationPhase `json:"phase,omitempty"`
+	// TokenSecretName is the secret in the namespace which keeps the token to share with the invitee.
+	// +optional
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+	// TokenHash is the sha256 of the token.
+	// +optional
+	TokenHash string `json:"tokenHash,omitempty"`
+	// ExpirationTime is when the pending invitation expires.
+	// +optional
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+	// AcceptedBy is the user who accepted the invitation.
+	// +optional
+	AcceptedBy string `json:"acceptedBy,omitempty"`
+	// AcceptedRole is the role granted when the invitation is accepted.
+	// +optional
+	AcceptedRole RoleType `json:"acceptedRole,omitempty"`
+	// AcceptedTime is when the invitation is accepted.
+	// +optional
+	AcceptedTime *metav1.Time `json:"acceptedTime,omitempty"`
+	// Message is a human-readable message of the last rejected acceptance.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+//+kubebuilder:printcolumn:name="Role",type="string",JSONPath=".spec.role"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Expiration",type="date",JSONPath=".status.expirationTime"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// Invitation is the Schema for the invitations API, it invites a user to the workspace of its namespace.
+// The role is granted only when the invitee accepts it with the token before it expires.
+type Invitation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InvitationSpec   `json:"spec,omitempty"`
+	Status InvitationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// InvitationList contains a list of Invitation
+type InvitationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Invitation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Invitation{}, &InvitationList{})
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var invitationlog = logf.Log.WithName("invitation-resource")
+
+// SetupWebhookWithManager sets up the webhook, usersSubject returns the subjects a user authenticates as
+func (r *Invitation) SetupWebhookWithManager(mgr ctrl.Manager, usersSubject func(user string) []rbacv1.Subject) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&InvitationValidator{Client: mgr.GetClient(), UsersSubject: usersSubject}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-user-sealos-io-v1-invitation,mutating=false,failurePolicy=fail,sideEffects=None,groups=user.sealos.io,resources=invitations,verbs=create;update,versions=v1,name=vinvitation.kb.io,admissionReviewVersions=v1
+//+kubebuilder:object:generate=false
+
+type InvitationValidator struct {
+	client.Client
+	UsersSubject func(user string) []rbacv1.Subject
+}
+
+// ValidateCreate checks the inviter ranks above the role and the role ceiling of the invitation and has at least
+// their permissions. The owner of a workspace is never invited, it is handed over by a transfer.
+func (r InvitationValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	invitation, ok := obj.(*Invitation)
+	if !ok {
+		return admission.Warnings{"obj convert Invitation is error"}, errors.New("obj convert Invitation is error")
+	}
+	if invitation.Spec.Accept != nil {
+		return admission.Warnings{"invitation can not be accepted when it is created"}, errors.New("invitation can not be accepted when it is created")
+	}
+	if invitation.Spec.Role == OwnerRoleType || invitation.Spec.RoleCeiling == OwnerRoleType {
+		return admission.Warnings{"owner can not be invited"}, errors.New("owner can not be invited, transfer the workspace instead")
+	}
+	roles := []RoleType{invitation.Spec.Role}
+	if invitation.Spec.RoleCeiling != "" && invitation.Spec.RoleCeiling != invitation.Spec.Role {
+		roles = append(roles, invitation.Spec.RoleCeiling)
+	}
+	for _, role := range roles {
+		if err := validateRoleEscalation(ctx, r.Client, invitation.Namespace, role); err != nil {
+			invitationlog.Info("deny invitation", "name", invitation.Name, "role", role, "reason", err.Error())
+			return admission.Warnings{err.Error()}, err
+		}
+	}
+	return admission.Warnings{}, nil
+}
+
+// ValidateUpdate only allows an invitation to be accepted once by the accepting user itself, or revoked by the
+// managers of the workspace
+func (r InvitationValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldInvitation, ok := oldObj.(*Invitation)
+	if !ok {
+		return admission.Warnings{"obj convert Invitation error"}, errors.New("obj convert Invitation error")
+	}
+	newInvitation, ok := newObj.(*Invitation)
+	if !ok {
+		return admission.Warnings{"obj convert Invitation error"}, errors.New("obj convert Invitation error")
+	}
+	oldSpec, newSpec := oldInvitation.Spec, newInvitation.Spec
+	if oldSpec.User != newSpec.User || oldSpec.Role != newSpec.Role || oldSpec.RoleCeiling != newSpec.RoleCeiling || oldSpec.TTL != newSpec.TTL {
+		return admission.Warnings{"invitation spec do not support update"}, errors.New("invitation spec do not support update")
+	}
+	if oldSpec.Revoked && !newSpec.Revoked {
+		return admission.Warnings{"revoked invitation can not be restored"}, errors.New("revoked invitation can not be restored")
+	}
+	acceptChanged := (oldSpec.Accept == nil) != (newSpec.Accept == nil) ||
+		(oldSpec.Accept != nil && *oldSpec.Accept != *newSpec.Accept)
+	if acceptChanged && oldInvitation.Status.Phase != "" && oldInvitation.Status.Phase != InvitationPending {
+		return admission.Warnings{"invitation is finished"}, errors.New("invitation is finished")
+	}
+	accepted := acceptChanged && newSpec.Accept != nil
+	if accepted {
+		if err := r.validateAcceptingUser(ctx, newSpec.Accept.User); err != nil {
+			invitationlog.Info("deny invitation acceptance", "name", newInvitation.Name, "reason", err.Error())
+			return admission.Warnings{err.Error()}, err
+		}
+	}
+	// every user is allowed to update invitations to accept them, anything else is only changed by the managers
+	if !accepted || !onlyAcceptanceChanged(oldInvitation, newInvitation) {
+		if err := validateInvitationManager(ctx, r.Client, newInvitation.Namespace); err != nil {
+			invitationlog.Info("deny invitation update", "name", newInvitation.Name, "reason", err.Error())
+			return admission.Warnings{err.Error()}, err
+		}
+	}
+	return admission.Warnings{}, nil
+}
+
+// onlyAcceptanceChanged returns true if nothing but the acceptance of the invitation is changed
+func onlyAcceptanceChanged(oldInvitation, newInvitation *Invitation) bool {
+	oldSpec, newSpec := oldInvitation.Spec, newInvitation.Spec
+	oldSpec.Accept, newSpec.Accept = nil, nil
+	return equality.Semantic.DeepEqual(oldSpec, newSpec) &&
+		equality.Semantic.DeepEqual(oldInvitation.Labels, newInvitation.Labels) &&
+		equality.Semantic.DeepEqual(oldInvitation.Annotations, newInvitation.Annotations) &&
+		equality.Semantic.DeepEqual(oldInvitation.Finalizers, newInvitation.Finalizers) &&
+		equality.Semantic.DeepEqual(oldInvitation.OwnerReferences, newInvitation.OwnerReferences)
+}
+
+// validateInvitationManager checks the requester ranks at least as Manager in the workspace namespace
+func validateInvitationManager(ctx context.Context, c client.Client, namespace string) error {
+	request, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get admission request: %w", err)
+	}
+	rank, err := getRequesterRank(ctx, c, namespace, request)
+	if err != nil {
+		return err
+	}
+	if rank < ManagerRoleType.Rank() {
+		return fmt.Errorf("user %s can only accept the invitations of workspace %s", request.UserInfo.Username, namespace)
+	}
+	return nil
+}
+
+// validateAcceptingUser checks the acceptance is set by user itself, so that no one accepts an invitation on
+// behalf of another user whose token it got hold of
+func (r InvitationValidator) validateAcceptingUser(ctx context.Context, user string) error {
+	request, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get admission request: %w", err)
+	}
+	if !isUser(r.UsersSubject(user), request.UserInfo) {
+		return fmt.Errorf("user %s can not accept the invitation on behalf of user %s", request.UserInfo.Username, user)
+	}
+	return nil
+}
+
+func (r InvitationValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return admission.Warnings{}, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// loadRBAC creates the rbac object in file of config/rbac
+func loadRBAC(file string, obj client.Object) {
+	f, err := os.Open(filepath.Join("..", "..", "config", "rbac", file))
+	Expect(err).NotTo(HaveOccurred())
+	defer f.Close()
+	Expect(yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(obj)).To(Succeed())
+	Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+}
+
+var _ = Describe("Invitation webhook", func() {
+	const namespace = "ns-inviter"
+
+	var inviteeClient client.Client
+
+	BeforeEach(func() {
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace},
+		}))).To(Succeed())
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: string(DeveloperRoleType), Namespace: namespace},
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+				Verbs:     []string{"get"},
+			}},
+		}))).To(Succeed())
+
+		// the invitee is a user without any role in the workspace of the invitation
+		invitee, err := testEnv.AddUser(envtest.User{Name: "system:serviceaccount:user-system:invitee"}, cfg)
+		Expect(err).NotTo(HaveOccurred())
+		inviteeClient, err = client.New(invitee.Config(), client.Options{Scheme: k8sClient.Scheme()})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("lets the invitee accept an invitation as a non-admin user", func() {
+		loadRBAC("invitation_acceptor_role.yaml", &rbacv1.ClusterRole{})
+		loadRBAC("invitation_acceptor_role_binding.yaml", &rbacv1.ClusterRoleBinding{})
+
+		invitation := &Invitation{
+			ObjectMeta: metav1.ObjectMeta{Name: "invite-invitee", Namespace: namespace},
+			Spec:       InvitationSpec{User: "invitee", Role: DeveloperRoleType},
+		}
+		Expect(k8sClient.Create(ctx, invitation)).To(Succeed())
+
+		By("revoking the invitation as the invitee")
+		Expect(inviteeClient.Get(ctx, client.ObjectKeyFromObject(invitation), invitation)).To(Succeed())
+		invitation.Spec.Revoked = true
+		Expect(inviteeClient.Update(ctx, invitation)).NotTo(Succeed())
+
+		By("accepting the invitation on behalf of another user")
+		Expect(inviteeClient.Get(ctx, client.ObjectKeyFromObject(invitation), invitation)).To(Succeed())
+		invitation.Spec.Accept = &InvitationAcceptance{User: "other", Token: "token"}
+		Expect(inviteeClient.Update(ctx, invitation)).NotTo(Succeed())
+
+		By("accepting the invitation as the invitee")
+		Expect(inviteeClient.Get(ctx, client.ObjectKeyFromObject(invitation), invitation)).To(Succeed())
+		invitation.Spec.Accept = &InvitationAcceptance{User: "invitee", Token: "token"}
+		Expect(inviteeClient.Update(ctx, invitation)).To(Succeed())
+	})
+})
@@ -25,18 +25,22 @@ type OperationrequestSpec struct {
 	// Namespace is the workspace that needs to be operated.
 	Namespace string `json:"namespace,omitempty"`
 	User      string `json:"user,omitempty"`
-	// +kubebuilder:validation:Enum=Owner;Manager;Developer
+	// Role is the role granted to the user, it is Owner, Manager, Developer or the name of a WorkspaceRole.
+	// +kubebuilder:validation:MinLength=1
 	Role RoleType `json:"role,omitempty"`
-	// +kubebuilder:validation:Enum=Grant;Update;Deprive
+	// Action is the operation on the role of the user, Transfer makes the user the owner of the group workspace
+	// and downgrades the previous owner to Manager.
+	// +kubebuilder:validation:Enum=Grant;Update;Deprive;Transfer
 	Action ActionType `json:"action,omitempty"`
 }
 
 type ActionType string
 
 const (
-	Grant   ActionType = "Grant"
-	Update  ActionType = "Update"
-	Deprive ActionType = "Deprive"
+	Grant    ActionType = "Grant"
+	Update   ActionType = "Update"
+	Deprive  ActionType = "Deprive"
+	Transfer ActionType = "Transfer"
 )
 
 // OperationrequestStatus defines the observed state of Operationrequest
@@ -19,7 +19,13 @@ package v1
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -30,9 +36,10 @@ import (
 // log is for logging in this package.
 var operationrequestlog = logf.Log.WithName("operationrequest-resource")
 
-func (r *Operationrequest) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// SetupWebhookWithManager sets up the webhooks, usersSubject returns the subjects a user authenticates as
+func (r *Operationrequest) SetupWebhookWithManager(mgr ctrl.Manager, usersSubject func(user string) []rbacv1.Subject) error {
 	m := &ReqMutator{Client: mgr.GetClient()}
-	v := &ReqValidator{Client: mgr.GetClient()}
+	v := &ReqValidator{Client: mgr.GetClient(), UsersSubject: usersSubject}
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		WithDefaulter(m).
@@ -47,7 +54,7 @@ type ReqMutator struct {
 	client.Client
 }
 
-func (r ReqMutator) Default(_ context.Context, obj runtime.Object) error {
+func (r ReqMutator) Default(ctx context.Context, obj runtime.Object) error {
 	req, ok := obj.(*Operationrequest)
 	if !ok {
 		return errors.New("obj convert Operationrequest is error")
@@ -56,6 +63,12 @@ func (r ReqMutator) Default(_ context.Context, obj runtime.Object) error {
 	operationrequestlog.Info("mutate", "name", req.Name)
 	req.ObjectMeta = initAnnotationAndLabels(req.ObjectMeta)
 	req.Labels[UserLabelOwnerKey] = req.Spec.User
+	// the requester is only recorded on creation, it is immutable afterwards
+	if req.CreationTimestamp.IsZero() {
+		if request, err := admission.RequestFromContext(ctx); err == nil {
+			req.Annotations[UserAnnotationRequesterKey] = request.UserInfo.Username
+		}
+	}
 	return nil
 }
 
@@ -64,6 +77,7 @@ func (r ReqMutator) Default(_ context.Context, obj runtime.Object) error {
 
 type ReqValidator struct {
 	client.Client
+	UsersSubject func(user string) []rbacv1.Subject
 }
 
 func (r ReqValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
@@ -88,9 +102,196 @@ func (r ReqValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (a
 			return admission.Warnings{"there is a request not completed, can not create new request"}, errors.New("there is a request not completed, can not create new request")
 		}
 	}
+
+	if req.Spec.Action == Transfer && req.Spec.Role != OwnerRoleType {
+		err := fmt.Errorf("transfer request must grant role %s, not %s", OwnerRoleType, req.Spec.Role)
+		return admission.Warnings{err.Error()}, err
+	}
+	var denied error
+	switch req.Spec.Action {
+	case Deprive:
+	case Transfer:
+		// the owner is never granted by a rank, it is handed over by the current owner
+		request, err := admission.RequestFromContext(ctx)
+		if err != nil {
+			return admission.Warnings{err.Error()}, err
+		}
+		if denied = r.validateWorkspaceOwner(ctx, request, req.Spec.Namespace); denied == nil {
+			denied = validateRolePermissions(ctx, r.Client, request, req.Spec.Namespace, req.Spec.Role)
+		}
+	default:
+		denied = validateRoleEscalation(ctx, r.Client, req.Spec.Namespace, req.Spec.Role)
+	}
+	if denied != nil {
+		operationrequestlog.Info("deny operation request", "name", req.Name, "role", req.Spec.Role, "reason", denied.Error())
+		return admission.Warnings{denied.Error()}, denied
+	}
 	return admission.Warnings{}, nil
 }
 
+// validateRoleEscalation checks the requester ranks above role in the workspace namespace and has at least the
+// permissions of role, so that no one can grant a role as powerful as its own. Built-in roles rank Owner > Manager
+// > Developer, and a WorkspaceRole can only be granted by a Manager or an Owner. The permissions of role are
+// resolved from the Role materialized in the workspace, it is either a built-in role or a WorkspaceRole.
+func validateRoleEscalation(ctx context.Context, c client.Client, namespace string, roleType RoleType) error {
+	request, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get admission request: %w", err)
+	}
+	rank, err := getRequesterRank(ctx, c, namespace, request)
+	if err != nil {
+		return err
+	}
+	minRank := roleType.Rank() + 1
+	if roleType.Rank() == 0 {
+		minRank = ManagerRoleType.Rank()
+	}
+	if rank < minRank {
+		return fmt.Errorf("user %s can not grant role %s, its role in workspace %s is not high enough",
+			request.UserInfo.Username, roleType, namespace)
+	}
+	return validateRolePermissions(ctx, c, request, namespace, roleType)
+}
+
+// validateRolePermissions checks the requester has at least the permissions of role in the workspace namespace
+func validateRolePermissions(ctx context.Context, c client.Client, request admission.Request, namespace string, roleType RoleType) error {
+	role := &rbacv1.Role{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: string(roleType)}, role); err != nil {
+		return fmt.Errorf("unable to get role %s of workspace %s: %w", roleType, namespace, err)
+	}
+	for _, rule := range role.Rules {
+		for _, attributes := range getResourceAttributes(namespace, rule) {
+			allowed, err := reviewAccess(ctx, c, request, attributes)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				resource := attributes.Resource
+				if attributes.Subresource != "" {
+					resource += "/" + attributes.Subresource
+				}
+				return fmt.Errorf("user %s can not grant role %s, it is not allowed to %s %s in group %q of workspace %s",
+					request.UserInfo.Username, roleType, attributes.Verb, resource, attributes.Group, namespace)
+			}
+		}
+	}
+	return nil
+}
+
+// getRequesterRank returns the rank of the highest built-in role bound to the requester in the workspace namespace.
+// A requester without a built-in role, like an administrator or a controller, ranks as Owner if it is allowed to do
+// everything in the namespace.
+func getRequesterRank(ctx context.Context, c client.Client, namespace string, request admission.Request) (int, error) {
+	rolebindings := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, rolebindings, client.InNamespace(namespace)); err != nil {
+		return 0, fmt.Errorf("unable to list role bindings of workspace %s: %w", namespace, err)
+	}
+	rank := 0
+	for _, rolebinding := range rolebindings.Items {
+		if rolebinding.RoleRef.Kind != "Role" || !slices.ContainsFunc(rolebinding.Subjects, func(subject rbacv1.Subject) bool {
+			return isRequester(subject, request.UserInfo)
+		}) {
+			continue
+		}
+		rank = max(rank, RoleType(rolebinding.RoleRef.Name).Rank())
+	}
+	if rank > 0 {
+		return rank, nil
+	}
+	allowed, err := reviewAccess(ctx, c, request, &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "*",
+		Group:     "*",
+		Resource:  "*",
+	})
+	if err != nil || !allowed {
+		return 0, err
+	}
+	return OwnerRoleType.Rank(), nil
+}
+
+// validateWorkspaceOwner checks the requester is the current owner of the group workspace in namespace
+func (r ReqValidator) validateWorkspaceOwner(ctx context.Context, request admission.Request, namespace string) error {
+	workspace := &User{}
+	if err := r.Get(ctx, client.ObjectKey{Name: strings.TrimPrefix(namespace, "ns-")}, workspace); err != nil {
+		return fmt.Errorf("unable to get workspace %s: %w", namespace, err)
+	}
+	owner := workspace.Annotations[UserAnnotationOwnerKey]
+	if owner == "" || !isUser(r.UsersSubject(owner), request.UserInfo) {
+		return fmt.Errorf("user %s can not transfer workspace %s, only its owner can", request.UserInfo.Username, namespace)
+	}
+	return nil
+}
+
+// isUser returns true if the requester authenticates as one of the subjects of a user, its groups are not the user
+func isUser(subjects []rbacv1.Subject, userInfo authenticationv1.UserInfo) bool {
+	return slices.ContainsFunc(subjects, func(subject rbacv1.Subject) bool {
+		return subject.Kind != rbacv1.GroupKind && isRequester(subject, userInfo)
+	})
+}
+
+// isRequester returns true if subject is the requester or one of its groups
+func isRequester(subject rbacv1.Subject, userInfo authenticationv1.UserInfo) bool {
+	switch subject.Kind {
+	case rbacv1.UserKind:
+		return subject.Name == userInfo.Username
+	case rbacv1.ServiceAccountKind:
+		return fmt.Sprintf("system:serviceaccount:%s:%s", subject.Namespace, subject.Name) == userInfo.Username
+	case rbacv1.GroupKind:
+		return slices.Contains(userInfo.Groups, subject.Name)
+	}
+	return false
+}
+
+// reviewAccess returns true if the requester is allowed to access attributes
+func reviewAccess(ctx context.Context, c client.Client, request admission.Request, attributes *authorizationv1.ResourceAttributes) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(request.UserInfo.Extra))
+	for k, v := range request.UserInfo.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               request.UserInfo.Username,
+			Groups:             request.UserInfo.Groups,
+			UID:                request.UserInfo.UID,
+			Extra:              extra,
+		},
+	}
+	if err := c.Create(ctx, review); err != nil {
+		return false, fmt.Errorf("unable to review the permissions of %s: %w", request.UserInfo.Username, err)
+	}
+	return review.Status.Allowed, nil
+}
+
+// getResourceAttributes expands rule to the resource attributes in namespace it grants,
+// the wildcards are kept so that only a requester with the same wildcard is allowed
+func getResourceAttributes(namespace string, rule rbacv1.PolicyRule) []*authorizationv1.ResourceAttributes {
+	names := rule.ResourceNames
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	var attributes []*authorizationv1.ResourceAttributes
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			resource, subresource, _ := strings.Cut(resource, "/")
+			for _, verb := range rule.Verbs {
+				for _, name := range names {
+					attributes = append(attributes, &authorizationv1.ResourceAttributes{
+						Namespace:   namespace,
+						Verb:        verb,
+						Group:       group,
+						Resource:    resource,
+						Subresource: subresource,
+						Name:        name,
+					})
+				}
+			}
+		}
+	}
+	return attributes
+}
+
 func (r ReqValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
 	// todo check request, _ := admission.RequestFromContext(ctx), request.UserInfo.Username if legal
 	oldReq, ok := oldObj.(*Operationrequest)
@@ -104,6 +305,9 @@ func (r ReqValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.O
 	if oldReq.Spec != newReq.Spec {
 		return admission.Warnings{"operation request spec do not support update"}, errors.New("operation request spec do not support update")
 	}
+	if oldReq.Annotations[UserAnnotationRequesterKey] != newReq.Annotations[UserAnnotationRequesterKey] {
+		return admission.Warnings{"operation request requester do not support update"}, errors.New("operation request requester do not support update")
+	}
 	return admission.Warnings{}, nil
 }
 
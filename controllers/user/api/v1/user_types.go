@@ -18,6 +18,8 @@ package v1
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,6 +37,47 @@ type UserSpec struct {
 	// +optional
 	//+kubebuilder:default:=7200
 	CSRExpirationSeconds int32 `json:"csrExpirationSeconds,omitempty"`
+
+	// Expiration is the lifecycle of a user which expires, such as a trial user.
+	// The user never expires if it is not set.
+	// +optional
+	Expiration *UserExpiration `json:"expiration,omitempty"`
+}
+
+type ExpirationPolicy string
+
+const (
+	// ExpirationPolicyRevoke revokes the kubeconfig of the user when it expires
+	ExpirationPolicyRevoke ExpirationPolicy = "Revoke"
+	// ExpirationPolicySuspend revokes the kubeconfig and scales down the workloads of the user when it expires
+	ExpirationPolicySuspend ExpirationPolicy = "Suspend"
+	// ExpirationPolicyDelete suspends the user when it expires, and deletes it after the grace period
+	ExpirationPolicyDelete ExpirationPolicy = "Delete"
+)
+
+type UserExpiration struct {
+	// Time is when the user expires, moving it to the future renews an expired user
+	// which is not deleted yet.
+	Time metav1.Time `json:"time"`
+	// Policy is what happens to the user when it expires.
+	// +optional
+	//+kubebuilder:validation:Enum=Revoke;Suspend;Delete
+	//+kubebuilder:default:=Suspend
+	Policy ExpirationPolicy `json:"policy,omitempty"`
+	// GracePeriod is how long an expired user is kept before it is deleted by the Delete policy.
+	// +optional
+	//+kubebuilder:default:="168h"
+	GracePeriod metav1.Duration `json:"gracePeriod,omitempty"`
+	// NotifyBefore is how long before the user expires or is deleted it is notified.
+	// +optional
+	//+kubebuilder:default:="72h"
+	NotifyBefore metav1.Duration `json:"notifyBefore,omitempty"`
+	// WorkspacePolicy is the workspace policy of the DeleteRequest created by the Delete policy, Transfer hands
+	// the group workspaces still owned by the user to their top members so that the deletion is not blocked.
+	// +optional
+	//+kubebuilder:validation:Enum=Block;Transfer
+	//+kubebuilder:default:=Transfer
+	WorkspacePolicy WorkspacePolicy `json:"workspacePolicy,omitempty"`
 }
 type RoleType string
 
@@ -44,6 +87,30 @@ const (
 	DeveloperRoleType RoleType = "Developer"
 )
 
+// Rank returns the rank of a built-in role, Owner > Manager > Developer, it is 0 for a WorkspaceRole
+func (r RoleType) Rank() int {
+	switch r {
+	case OwnerRoleType:
+		return 3
+	case ManagerRoleType:
+		return 2
+	case DeveloperRoleType:
+		return 1
+	}
+	return 0
+}
+
+// IsBuiltinRoleName reports whether name is the name of a built-in role regardless of case, the name of
+// a WorkspaceRole is lowercase and must not be taken for a built-in role
+func IsBuiltinRoleName(name string) bool {
+	for _, role := range []RoleType{OwnerRoleType, ManagerRoleType, DeveloperRoleType} {
+		if strings.EqualFold(name, string(role)) {
+			return true
+		}
+	}
+	return false
+}
+
 type UserPhase string
 
 // These are the valid phases of node.
@@ -51,6 +118,8 @@ const (
 	UserPending UserPhase = "Pending"
 	UserUnknown UserPhase = "Unknown"
 	UserActive  UserPhase = "Active"
+	// UserExpired means the kubeconfig of the user is revoked by its expiration
+	UserExpired UserPhase = "Expired"
 )
 
 // UserStatus defines the observed state of User
@@ -73,6 +142,11 @@ type ConditionType string
 const (
 	Initialized ConditionType = "Initialized"
 	Ready       ConditionType = "Ready"
+	// Expired is true once the user expires, it is owned by the user expiration controller
+	Expired ConditionType = "Expired"
+	// DeletionBlocked is true if the user is requested to be deleted but can not be, it is owned by the delete
+	// request controller
+	DeletionBlocked ConditionType = "DeletionBlocked"
 )
 
 type Condition struct {
@@ -122,6 +196,11 @@ func init() {
 	SchemeBuilder.Register(&User{}, &UserList{})
 }
 
+// IsExpired returns true if the user has an expiration which is not after now
+func (r *User) IsExpired(now time.Time) bool {
+	return r.Spec.Expiration != nil && !r.Spec.Expiration.Time.After(now)
+}
+
 func (r *User) validateCSRExpirationSeconds() error {
 	if r.Spec.CSRExpirationSeconds == 0 {
 		return errors.New("csrExpirationSeconds is not allowed to be 0")
@@ -0,0 +1,65 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkspaceRoleLabelKey is the label of the roles materialized from a WorkspaceRole in the user namespaces.
+const WorkspaceRoleLabelKey = "user.sealos.io/workspace-role"
+
+// WorkspaceRoleSpec defines the desired state of WorkspaceRole
+type WorkspaceRoleSpec struct {
+	// Description is a human-readable description of the role.
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Rules are the permissions granted by the role in a workspace.
+	//+kubebuilder:validation:MinItems=1
+	Rules []rbacv1.PolicyRule `json:"rules"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+//+kubebuilder:validation:XValidation:rule="!(self.metadata.name in ['owner', 'manager', 'developer'])",message="the name of a built-in role is reserved"
+//+kubebuilder:printcolumn:name="Description",type="string",JSONPath=".spec.description"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// WorkspaceRole is the Schema for the workspaceroles API, it is a custom role which can be
+// granted by an Operationrequest besides Owner, Manager and Developer. The user controller
+// materializes it as a Role with the same name in every user namespace, a Role of the same name
+// which is not materialized from it is never overwritten.
+type WorkspaceRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WorkspaceRoleSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WorkspaceRoleList contains a list of WorkspaceRole
+type WorkspaceRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WorkspaceRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WorkspaceRole{}, &WorkspaceRoleList{})
+}
@@ -24,11 +24,17 @@ import (
 	"github.com/go-logr/logr"
 
 	userv1 "github.com/labring/sealos/controllers/user/api/v1"
+	"github.com/labring/sealos/controllers/user/controllers/helper"
+	"github.com/labring/sealos/controllers/user/controllers/helper/audit"
 	"github.com/labring/sealos/controllers/user/controllers/helper/config"
+	"github.com/labring/sealos/controllers/user/controllers/helper/database"
 
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -40,6 +46,11 @@ type DeleteRequestReconciler struct {
 	Logger   logr.Logger
 	Recorder record.EventRecorder
 
+	// WorkspaceOwnerStore moves the billing owner of a transferred workspace, it is skipped if nil
+	WorkspaceOwnerStore database.WorkspaceOwnerStore
+	// AuditSink records the completed and failed requests, nothing is recorded if nil
+	AuditSink audit.Sink
+
 	// expirationTime is the time duration of the request is expired
 	expirationTime time.Duration
 	// retentionTime is the time duration of the request is retained after it is isCompleted
@@ -87,6 +98,11 @@ func (r *DeleteRequestReconciler) reconcile(ctx context.Context, request *userv1
 		if err := r.updateRequestStatus(ctx, request, userv1.RequestFailed); err != nil {
 			return ctrl.Result{}, err
 		}
+		r.audit(ctx, request, userv1.RequestFailed, fmt.Errorf("request is expired before user %s is deleted", request.Spec.User))
+		message := fmt.Sprintf("delete request %s is failed before the user is deleted, create a new request to delete it", request.Name)
+		if err := r.setDeletionBlocked(ctx, request.Spec.User, "DeleteRequestFailed", message); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
 		return ctrl.Result{}, nil
 	}
 
@@ -107,6 +123,26 @@ func (r *DeleteRequestReconciler) reconcile(ctx context.Context, request *userv1
 		return ctrl.Result{RequeueAfter: DeleteRequestRequeueDuration}, nil
 	}
 
+	// a user can not be deleted while it still owns group workspaces
+	if !isGroupUser(user) {
+		owned, err := r.releaseOwnedWorkspaces(ctx, request, user)
+		if err != nil {
+			r.Logger.Error(err, "release owned workspaces error", "name", user.Name)
+			r.Recorder.Eventf(request, corev1.EventTypeWarning, "ReleaseWorkspaceError", "release workspaces of user %s error: %s", user.Name, err.Error())
+			return ctrl.Result{}, err
+		}
+		if len(owned) > 0 {
+			r.Logger.Info("user still owns group workspaces", "name", user.Name, "workspaces", owned)
+			r.Recorder.Eventf(request, corev1.EventTypeWarning, "WorkspaceOwned", "user %s still owns workspaces %v, transfer them before deleting", user.Name, owned)
+			message := fmt.Sprintf("delete request %s is blocked since the user still owns workspaces %v, transfer them "+
+				"or request the deletion with workspace policy %s", request.Name, owned, userv1.WorkspacePolicyTransfer)
+			if err := r.setDeletionBlocked(ctx, user.Name, "WorkspaceOwned", message); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: DeleteRequestRequeueDuration}, nil
+		}
+	}
+
 	// delete user
 	if err := r.Delete(ctx, &user); err != nil {
 		r.Logger.Error(err, "delete user error", "name", user.Name)
@@ -135,9 +171,120 @@ func (r *DeleteRequestReconciler) reconcile(ctx context.Context, request *userv1
 		r.Recorder.Eventf(request, corev1.EventTypeWarning, "UpdateRequestStatusError", "update request %s status error: %s", request.Name, err.Error())
 		return ctrl.Result{}, err
 	}
+	r.audit(ctx, request, userv1.RequestCompleted, nil)
 	return ctrl.Result{}, nil
 }
 
+// audit writes the record of request that is finished in phase, a failure of the audit log does not fail the request
+func (r *DeleteRequestReconciler) audit(ctx context.Context, request *userv1.DeleteRequest, phase userv1.RequestPhase, err error) {
+	record := audit.Record{
+		Actor:     request.Annotations[userv1.UserAnnotationRequesterKey],
+		Action:    audit.ActionDeleteUser,
+		User:      request.Spec.User,
+		Namespace: config.GetUsersNamespace(request.Spec.User),
+		After:     string(phase),
+	}
+	if err := r.AuditSink.Write(ctx, record.Complete(err)); err != nil {
+		r.Logger.Error(err, "failed to write audit record", "name", request.Name)
+	}
+}
+
+// setDeletionBlocked surfaces why the user can not be deleted in its DeletionBlocked condition, so that a user
+// which is kept with its finalizers by a blocked or failed request is visible instead of silently left behind
+func (r *DeleteRequestReconciler) setDeletionBlocked(ctx context.Context, name, reason, message string) error {
+	condition := userv1.Condition{
+		Type:               userv1.DeletionBlocked,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		LastHeartbeatTime:  metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		user := &userv1.User{}
+		if err := r.Get(ctx, client.ObjectKey{Name: name}, user); err != nil {
+			return err
+		}
+		if !helper.DiffCondition(helper.GetCondition(user.Status.Conditions, &userv1.Condition{Type: userv1.DeletionBlocked}), &condition) {
+			return nil
+		}
+		user.Status.Conditions = helper.UpdateCondition(user.Status.Conditions, condition)
+		return r.Status().Update(ctx, user)
+	})
+}
+
+// releaseOwnedWorkspaces transfers the group workspaces owned by user to their top members if the workspace
+// policy of request is Transfer, it returns the workspaces that are still owned by user.
+func (r *DeleteRequestReconciler) releaseOwnedWorkspaces(ctx context.Context, request *userv1.DeleteRequest, user userv1.User) ([]string, error) {
+	groups := &userv1.UserList{}
+	if err := r.List(ctx, groups, client.MatchingLabels{userTypeLabel: userTypeGroup}); err != nil {
+		return nil, err
+	}
+	var owned []string
+	for _, group := range groups.Items {
+		if group.Name == user.Name || group.Annotations[userAnnotationOwnerKey] != user.Name || !group.DeletionTimestamp.IsZero() {
+			continue
+		}
+		namespace := config.GetUsersNamespace(group.Name)
+		if request.Spec.WorkspacePolicy != userv1.WorkspacePolicyTransfer {
+			owned = append(owned, namespace)
+			continue
+		}
+		successor, err := r.selectSuccessor(ctx, namespace, user.Name)
+		if err != nil {
+			return nil, err
+		}
+		if successor == "" {
+			owned = append(owned, namespace)
+			continue
+		}
+		_, err = transferWorkspace(ctx, r.Client, r.Scheme, r.WorkspaceOwnerStore, namespace, successor)
+		record := audit.Record{
+			Actor:     request.Annotations[userv1.UserAnnotationRequesterKey],
+			Action:    string(userv1.Transfer),
+			User:      successor,
+			Namespace: namespace,
+			Role:      string(userv1.OwnerRoleType),
+			Before:    user.Name,
+			After:     successor,
+		}
+		if err != nil {
+			record.After = user.Name
+		}
+		if err := r.AuditSink.Write(ctx, record.Complete(err)); err != nil {
+			r.Logger.Error(err, "failed to write audit record", "name", request.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Recorder.Eventf(request, corev1.EventTypeNormal, "TransferWorkspace", "transfer workspace %s from user %s to %s", namespace, user.Name, successor)
+	}
+	return owned, nil
+}
+
+// selectSuccessor returns the member of the workspace in namespace that takes it over from owner,
+// the members that are deleted are never selected
+func (r *DeleteRequestReconciler) selectSuccessor(ctx context.Context, namespace, owner string) (string, error) {
+	rolebindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, rolebindings, client.InNamespace(namespace), client.HasLabels{userLabelOwnerKey}); err != nil {
+		return "", err
+	}
+	members := rolebindings.Items[:0]
+	for _, rolebinding := range rolebindings.Items {
+		member := userv1.User{}
+		if err := r.Get(ctx, client.ObjectKey{Name: rolebinding.Labels[userLabelOwnerKey]}, &member); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return "", err
+			}
+			continue
+		}
+		if !isUserDeleted(member) && member.DeletionTimestamp.IsZero() {
+			members = append(members, rolebinding)
+		}
+	}
+	return helper.SelectWorkspaceSuccessor(members, owner), nil
+}
+
 // isRetained returns true if the request is isCompleted and exist for retention time
 func (r *DeleteRequestReconciler) isRetained(request *userv1.DeleteRequest) bool {
 	if request.Status.Phase == userv1.RequestCompleted && request.CreationTimestamp.Add(r.retentionTime).Before(time.Now()) {
@@ -173,6 +320,9 @@ func (r *DeleteRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Logger.V(1).Info("init reconcile deleterequest controller")
 	r.expirationTime = time.Minute * 10
 	r.retentionTime = time.Minute * 30
+	if r.AuditSink == nil {
+		r.AuditSink = audit.MeteredSink{Sink: audit.LogSink{Logger: r.Logger.WithName("audit")}}
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&userv1.DeleteRequest{}).
 		Complete(r)
@@ -202,10 +352,10 @@ func (r *DeleteRequestReconciler) updateRequestStatus(ctx context.Context, reque
 
 // isUserDeleted returns true if the user is deleted
 func isUserDeleted(user userv1.User) bool {
-	return user.Labels["user.sealos.io/status"] == "Deleted"
+	return user.Labels[userStatusLabel] == userStatusDeleted
 }
 
 // isGroupUser returns true if the user is a group user
 func isGroupUser(user userv1.User) bool {
-	return user.Labels["user.sealos.io/type"] == "Group"
+	return user.Labels[userTypeLabel] == userTypeGroup
 }
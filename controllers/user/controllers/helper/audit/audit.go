@@ -0,0 +1,148 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	ActionRenewKubeConfig = "RenewKubeConfig"
+	ActionDeleteUser      = "DeleteUser"
+
+	ResultSucceeded = "Succeeded"
+	ResultFailed    = "Failed"
+)
+
+// Record is an audit record of a change made by the user controller
+type Record struct {
+	Time time.Time `json:"time"`
+	// Actor is the kubernetes user that requested the change, it is empty if the change is made by the controller itself
+	Actor     string `json:"actor,omitempty"`
+	Action    string `json:"action"`
+	User      string `json:"user"`
+	Namespace string `json:"namespace,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+	Result    string `json:"result"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Complete sets the time and the result of record by err
+func (r Record) Complete(err error) Record {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Result = ResultSucceeded
+	if err != nil {
+		r.Result = ResultFailed
+		r.Message = err.Error()
+	}
+	return r
+}
+
+// Sink appends the audit records, a record is never updated or deleted once it is written
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
+
+var (
+	// recordsTotal counts the audit records by their action and whether they are written, so that an audit
+	// log which can not be written is alerted on instead of only logged
+	recordsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_controller_audit_records_total",
+		Help: "Number of audit records of the user controller by action and write result.",
+	}, []string{"action", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(recordsTotal)
+}
+
+// NopSink drops all records, it is only used if the audit log is disabled explicitly
+type NopSink struct{}
+
+func (NopSink) Write(context.Context, Record) error {
+	return nil
+}
+
+// LogSink writes the records to the log of the controller, it is the default sink as it never fails
+// and the log is collected with the other logs of the cluster
+type LogSink struct {
+	Logger logr.Logger
+}
+
+func (s LogSink) Write(_ context.Context, record Record) error {
+	s.Logger.Info("audit", "record", record)
+	return nil
+}
+
+// MeteredSink counts the records written to Sink in the user_controller_audit_records_total metric
+type MeteredSink struct {
+	Sink
+}
+
+func (s MeteredSink) Write(ctx context.Context, record Record) error {
+	err := s.Sink.Write(ctx, record)
+	result := ResultSucceeded
+	if err != nil {
+		result = ResultFailed
+	}
+	recordsTotal.WithLabelValues(record.Action, result).Inc()
+	return err
+}
+
+// FileSink appends the records to a file as JSON lines
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file %s: %w", path, err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit file %s: %w", s.file.Name(), err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	records := []Record{
+		Record{Actor: "alice", Action: "Grant", User: "bob", Namespace: "ns-team", Role: "Developer", After: "Developer"}.Complete(nil),
+		Record{Action: ActionDeleteUser, User: "bob"}.Complete(errors.New("user bob still owns workspaces")),
+	}
+	for i := 0; i < 2; i++ {
+		// records are appended to the existing file after a restart
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Write(context.Background(), records[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, record)
+	}
+	if len(got) != len(records) {
+		t.Fatalf("got %d records, want %d", len(got), len(records))
+	}
+	for i := range records {
+		if !got[i].Time.Equal(records[i].Time) {
+			t.Errorf("record %d time = %v, want %v", i, got[i].Time, records[i].Time)
+		}
+		got[i].Time = records[i].Time
+		if got[i] != records[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], records[i])
+		}
+	}
+	if got[0].Result != ResultSucceeded || got[1].Result != ResultFailed || got[1].Message == "" {
+		t.Errorf("unexpected results %+v", got)
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(context.Context, Record) error {
+	return errors.New("audit table is unavailable")
+}
+
+func TestMeteredSink(t *testing.T) {
+	failed := recordsTotal.WithLabelValues(ActionRenewKubeConfig, ResultFailed)
+	succeeded := recordsTotal.WithLabelValues(ActionRenewKubeConfig, ResultSucceeded)
+	beforeFailed, beforeSucceeded := testutil.ToFloat64(failed), testutil.ToFloat64(succeeded)
+
+	record := Record{Action: ActionRenewKubeConfig, User: "bob"}.Complete(nil)
+	if err := (MeteredSink{Sink: failingSink{}}).Write(context.Background(), record); err == nil {
+		t.Fatal("expected the error of the sink")
+	}
+	if err := (MeteredSink{Sink: LogSink{Logger: logr.Discard()}}).Write(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(failed) - beforeFailed; got != 1 {
+		t.Errorf("failed records = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(succeeded) - beforeSucceeded; got != 1 {
+		t.Errorf("succeeded records = %v, want 1", got)
+	}
+}
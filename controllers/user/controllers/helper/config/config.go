@@ -21,8 +21,11 @@ import (
 )
 
 type Config struct {
-	Global `yaml:"global"`
-	Kube   `yaml:"kube"`
+	Global   `yaml:"global"`
+	Kube     `yaml:"kube"`
+	OIDC     OIDC     `yaml:"oidc"`
+	Database Database `yaml:"database"`
+	Audit    Audit    `yaml:"audit"`
 }
 
 type Global struct {
@@ -49,6 +52,21 @@ type Database struct {
 	RegionalCockroachdbURI string `yaml:"regionalCockroachdbURI"`
 }
 
+// Audit configures where the audit records of the user controller are written
+type Audit struct {
+	// Sink is log, file, database or none to disable the audit log, it defaults to log. The /workspace/audit-logs
+	// API of the account service reads the database sink only, it returns no logs with the other sinks.
+	Sink     string `yaml:"sink"`
+	FilePath string `yaml:"filePath"`
+}
+
+const (
+	AuditSinkLog      = "log"
+	AuditSinkFile     = "file"
+	AuditSinkDatabase = "database"
+	AuditSinkNone     = "none"
+)
+
 func LoadConfig(path string, target interface{}) error {
 	configData, err := os.ReadFile(path)
 	if err != nil {
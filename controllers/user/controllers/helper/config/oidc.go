@@ -0,0 +1,100 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	EnvOIDCIssuerURL      = "OIDC_ISSUER_URL"
+	EnvOIDCClientID       = "OIDC_CLIENT_ID"
+	EnvOIDCExtraScopes    = "OIDC_EXTRA_SCOPES"
+	EnvOIDCUsernameClaim  = "OIDC_USERNAME_CLAIM"
+	EnvOIDCUsernamePrefix = "OIDC_USERNAME_PREFIX"
+	EnvOIDCGroupsClaim    = "OIDC_GROUPS_CLAIM"
+	EnvOIDCGroupsPrefix   = "OIDC_GROUPS_PREFIX"
+)
+
+// OIDC is the external identity provider of users. When it is enabled, the kubeconfig of a user
+// fetches a short-lived id token from the provider on every login instead of embedding a
+// service account token. The claims and prefixes must match the oidc flags of kube-apiserver.
+type OIDC struct {
+	Enabled   bool   `yaml:"enabled"`
+	IssuerURL string `yaml:"issuerURL"`
+	// ClientID is a public client of the provider, no client secret is given to users
+	ClientID    string   `yaml:"clientID"`
+	ExtraScopes []string `yaml:"extraScopes"`
+	// UsernameClaim is the claim of the sealos user name, default is sub
+	UsernameClaim  string `yaml:"usernameClaim"`
+	UsernamePrefix string `yaml:"usernamePrefix"`
+	// GroupsClaim is the claim of the groups, a group named <namespace>:<role> grants the role in the workspace
+	GroupsClaim  string `yaml:"groupsClaim"`
+	GroupsPrefix string `yaml:"groupsPrefix"`
+}
+
+// Env returns the environment variables of the OIDC configuration
+func (o OIDC) Env() map[string]string {
+	if !o.Enabled {
+		return map[string]string{}
+	}
+	return map[string]string{
+		EnvOIDCIssuerURL:      o.IssuerURL,
+		EnvOIDCClientID:       o.ClientID,
+		EnvOIDCExtraScopes:    strings.Join(o.ExtraScopes, ","),
+		EnvOIDCUsernameClaim:  o.UsernameClaim,
+		EnvOIDCUsernamePrefix: o.UsernamePrefix,
+		EnvOIDCGroupsClaim:    o.GroupsClaim,
+		EnvOIDCGroupsPrefix:   o.GroupsPrefix,
+	}
+}
+
+// GetOIDC returns the OIDC configuration from the environment, or nil if it is disabled
+func GetOIDC() *OIDC {
+	issuerURL := os.Getenv(EnvOIDCIssuerURL)
+	if issuerURL == "" {
+		return nil
+	}
+	o := &OIDC{
+		Enabled:        true,
+		IssuerURL:      issuerURL,
+		ClientID:       os.Getenv(EnvOIDCClientID),
+		UsernameClaim:  os.Getenv(EnvOIDCUsernameClaim),
+		UsernamePrefix: os.Getenv(EnvOIDCUsernamePrefix),
+		GroupsClaim:    os.Getenv(EnvOIDCGroupsClaim),
+		GroupsPrefix:   os.Getenv(EnvOIDCGroupsPrefix),
+	}
+	if scopes := os.Getenv(EnvOIDCExtraScopes); scopes != "" {
+		o.ExtraScopes = strings.Split(scopes, ",")
+	}
+	if o.UsernameClaim == "" {
+		o.UsernameClaim = "sub"
+	}
+	if o.GroupsClaim == "" {
+		o.GroupsClaim = "groups"
+	}
+	return o
+}
+
+// Username returns the kubernetes user name of user authenticated by the provider
+func (o OIDC) Username(user string) string {
+	return o.UsernamePrefix + user
+}
+
+// GroupName returns the kubernetes group which is granted role in the workspace namespace
+func (o OIDC) GroupName(namespace, role string) string {
+	return o.GroupsPrefix + namespace + ":" + role
+}
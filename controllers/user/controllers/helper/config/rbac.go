@@ -35,13 +35,34 @@ func GetDefaultNamespace() string {
 }
 
 func GetUsersSubject(user string) []rbacv1.Subject {
-	return []rbacv1.Subject{
-		{
-			Kind:      "ServiceAccount",
-			Name:      user,
-			Namespace: GetUserSystemNamespace(),
-		},
+	subjects := []rbacv1.Subject{GetServiceAccountSubject(user)}
+	// the user authenticated by the OIDC provider is bound together with its service account
+	if subject, ok := GetOIDCUserSubject(user); ok {
+		subjects = append(subjects, subject)
 	}
+	return subjects
+}
+
+// GetServiceAccountSubject returns the service account of user, its kubeconfig authenticates by its token
+func GetServiceAccountSubject(user string) rbacv1.Subject {
+	return rbacv1.Subject{
+		Kind:      "ServiceAccount",
+		Name:      user,
+		Namespace: GetUserSystemNamespace(),
+	}
+}
+
+// GetOIDCUserSubject returns the user authenticated by the OIDC provider, it is false if OIDC is disabled
+func GetOIDCUserSubject(user string) (rbacv1.Subject, bool) {
+	oidc := GetOIDC()
+	if oidc == nil {
+		return rbacv1.Subject{}, false
+	}
+	return rbacv1.Subject{
+		Kind:     rbacv1.UserKind,
+		APIGroup: rbacv1.GroupName,
+		Name:     oidc.Username(user),
+	}, true
 }
 
 func GetUserNameByNamespace(namespace string) string {
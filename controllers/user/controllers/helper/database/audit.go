@@ -0,0 +1,55 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/labring/sealos/controllers/pkg/database/cockroach"
+	"github.com/labring/sealos/controllers/pkg/types"
+	"github.com/labring/sealos/controllers/user/controllers/helper/audit"
+)
+
+// InitAuditTable creates the audit table if it does not exist
+func (c *Cockroach) InitAuditTable() error {
+	if c.db.Migrator().HasTable(&types.UserAuditLog{}) {
+		return nil
+	}
+	if err := c.db.AutoMigrate(&types.UserAuditLog{}); err != nil {
+		return fmt.Errorf("failed to create audit table: %w", err)
+	}
+	return nil
+}
+
+// Write appends record to the audit table, Cockroach is an audit.Sink
+func (c *Cockroach) Write(ctx context.Context, record audit.Record) error {
+	// the audit logs are written to the regional database that the account service queries them from
+	db := &cockroach.Cockroach{Localdb: c.db.WithContext(ctx)}
+	return db.CreateUserAuditLog(&types.UserAuditLog{
+		CreatedAt: record.Time,
+		Actor:     record.Actor,
+		Action:    record.Action,
+		User:      record.User,
+		Namespace: record.Namespace,
+		Role:      record.Role,
+		Before:    record.Before,
+		After:     record.After,
+		Result:    record.Result,
+		Message:   record.Message,
+	})
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	roleOwner   = "OWNER"
+	roleManager = "MANAGER"
+
+	joinStatusInWorkspace = "IN_WORKSPACE"
+)
+
+// WorkspaceOwnerStore keeps the billing owner of workspaces in the account database
+type WorkspaceOwnerStore interface {
+	// TransferWorkspaceOwner makes user to the billing owner of workspace, the previous owner from becomes a manager
+	TransferWorkspaceOwner(ctx context.Context, workspace, from, to string) error
+}
+
+// Cockroach is the WorkspaceOwnerStore of the regional cockroach database of account
+type Cockroach struct {
+	db *gorm.DB
+}
+
+func NewCockroach(uri string) (*Cockroach, error) {
+	db, err := gorm.Open(postgres.Open(uri), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect regional cockroach database: %w", err)
+	}
+	return &Cockroach{db: db}, nil
+}
+
+func (c *Cockroach) TransferWorkspaceOwner(ctx context.Context, workspace, from, to string) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var workspaceUID string
+		if err := tx.Table("Workspace").Select("uid").Where("id = ?", workspace).Take(&workspaceUID).Error; err != nil {
+			return fmt.Errorf("failed to get workspace %s: %w", workspace, err)
+		}
+		toUID, err := getUserCrUID(tx, to)
+		if err != nil {
+			return err
+		}
+		if from != "" {
+			fromUID, err := getUserCrUID(tx, from)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil {
+				if err := tx.Table("UserWorkspace").
+					Where(`"workspaceUid" = ? AND "userCrUid" = ? AND role = ?`, workspaceUID, fromUID, roleOwner).
+					Updates(map[string]interface{}{"role": roleManager, "updatedAt": time.Now()}).Error; err != nil {
+					return fmt.Errorf("failed to downgrade owner %s of workspace %s: %w", from, workspace, err)
+				}
+			}
+		}
+		result := tx.Table("UserWorkspace").
+			Where(`"workspaceUid" = ? AND "userCrUid" = ?`, workspaceUID, toUID).
+			Updates(map[string]interface{}{"role": roleOwner, "status": joinStatusInWorkspace, "updatedAt": time.Now()})
+		if result.Error != nil {
+			return fmt.Errorf("failed to transfer workspace %s to %s: %w", workspace, to, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		now := time.Now()
+		if err := tx.Table("UserWorkspace").Create(map[string]interface{}{
+			"workspaceUid": workspaceUID,
+			"userCrUid":    toUID,
+			"role":         roleOwner,
+			"status":       joinStatusInWorkspace,
+			"isPrivate":    false,
+			"joinAt":       now,
+			"createdAt":    now,
+			"updatedAt":    now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to transfer workspace %s to %s: %w", workspace, to, err)
+		}
+		return nil
+	})
+}
+
+func getUserCrUID(tx *gorm.DB, user string) (string, error) {
+	var uid string
+	if err := tx.Table("UserCr").Select("uid").Where(`"crName" = ?`, user).Take(&uid).Error; err != nil {
+		return "", fmt.Errorf("failed to get user %s: %w", user, err)
+	}
+	return uid, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"time"
+
+	v1 "github.com/labring/sealos/controllers/user/api/v1"
+)
+
+type ExpirationStage string
+
+const (
+	// ExpirationStageActive means the user is not expired yet
+	ExpirationStageActive ExpirationStage = "Active"
+	// ExpirationStageExpired means the user is expired, its kubeconfig is revoked and it may be suspended
+	ExpirationStageExpired ExpirationStage = "Expired"
+	// ExpirationStageDeleting means the grace period of the Delete policy is over and the user is deleted
+	ExpirationStageDeleting ExpirationStage = "Deleting"
+)
+
+// ExpirationPlan is the current stage of an expiring user and its next transition
+type ExpirationPlan struct {
+	Stage ExpirationStage
+	// NextStage is the stage the user transitions to at NextTime, it is empty if there is no next transition
+	NextStage ExpirationStage
+	NextTime  time.Time
+	// Notify is true if the next transition is within the notify time of the expiration
+	Notify bool
+	// RequeueAfter is the time to check the expiration again, 0 if there is no next transition
+	RequeueAfter time.Duration
+}
+
+// GetExpirationPlan returns the stage of expiration at now and the next transition
+func GetExpirationPlan(expiration *v1.UserExpiration, now time.Time) ExpirationPlan {
+	plan := ExpirationPlan{Stage: ExpirationStageActive}
+	if expiration == nil {
+		return plan
+	}
+	expireTime := expiration.Time.Time
+	deleteTime := expireTime.Add(expiration.GracePeriod.Duration)
+	switch {
+	case now.Before(expireTime):
+		plan.NextStage, plan.NextTime = ExpirationStageExpired, expireTime
+	case expiration.Policy != v1.ExpirationPolicyDelete:
+		plan.Stage = ExpirationStageExpired
+		return plan
+	case now.Before(deleteTime):
+		plan.Stage = ExpirationStageExpired
+		plan.NextStage, plan.NextTime = ExpirationStageDeleting, deleteTime
+	default:
+		plan.Stage = ExpirationStageDeleting
+		return plan
+	}
+
+	notifyTime := plan.NextTime.Add(-expiration.NotifyBefore.Duration)
+	plan.Notify = !now.Before(notifyTime)
+	plan.RequeueAfter = plan.NextTime.Sub(now)
+	if !plan.Notify {
+		plan.RequeueAfter = notifyTime.Sub(now)
+	}
+	return plan
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/labring/sealos/controllers/user/api/v1"
+)
+
+func TestGetExpirationPlan(t *testing.T) {
+	now := time.Now()
+	expiration := func(policy v1.ExpirationPolicy, expireAfter time.Duration) *v1.UserExpiration {
+		return &v1.UserExpiration{
+			Time:         metav1.NewTime(now.Add(expireAfter)),
+			Policy:       policy,
+			GracePeriod:  metav1.Duration{Duration: 7 * 24 * time.Hour},
+			NotifyBefore: metav1.Duration{Duration: 72 * time.Hour},
+		}
+	}
+	tests := []struct {
+		name        string
+		expiration  *v1.UserExpiration
+		want        ExpirationStage
+		wantNext    ExpirationStage
+		wantNotify  bool
+		wantRequeue time.Duration
+	}{
+		{name: "never expires", expiration: nil, want: ExpirationStageActive},
+		{
+			name:        "before notify",
+			expiration:  expiration(v1.ExpirationPolicySuspend, 96*time.Hour),
+			want:        ExpirationStageActive,
+			wantNext:    ExpirationStageExpired,
+			wantRequeue: 24 * time.Hour,
+		},
+		{
+			name:        "notify expiration",
+			expiration:  expiration(v1.ExpirationPolicySuspend, time.Hour),
+			want:        ExpirationStageActive,
+			wantNext:    ExpirationStageExpired,
+			wantNotify:  true,
+			wantRequeue: time.Hour,
+		},
+		{name: "suspended", expiration: expiration(v1.ExpirationPolicySuspend, -time.Hour), want: ExpirationStageExpired},
+		{
+			name:        "grace period",
+			expiration:  expiration(v1.ExpirationPolicyDelete, -time.Hour),
+			want:        ExpirationStageExpired,
+			wantNext:    ExpirationStageDeleting,
+			wantRequeue: 7*24*time.Hour - time.Hour - 72*time.Hour,
+		},
+		{
+			name:        "notify deletion",
+			expiration:  expiration(v1.ExpirationPolicyDelete, -6*24*time.Hour),
+			want:        ExpirationStageExpired,
+			wantNext:    ExpirationStageDeleting,
+			wantNotify:  true,
+			wantRequeue: 24 * time.Hour,
+		},
+		{name: "deleting", expiration: expiration(v1.ExpirationPolicyDelete, -8*24*time.Hour), want: ExpirationStageDeleting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := GetExpirationPlan(tt.expiration, now)
+			if plan.Stage != tt.want || plan.NextStage != tt.wantNext || plan.Notify != tt.wantNotify || plan.RequeueAfter != tt.wantRequeue {
+				t.Errorf("GetExpirationPlan() = %+v", plan)
+			}
+		})
+	}
+}
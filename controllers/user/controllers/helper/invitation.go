@@ -0,0 +1,73 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "github.com/labring/sealos/controllers/user/api/v1"
+)
+
+// HashInvitationToken returns the sha256 of an invitation token
+func HashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RoleWithin returns true if role is a built-in role which is not more powerful than ceiling
+func RoleWithin(role, ceiling v1.RoleType) bool {
+	return role.Rank() > 0 && role.Rank() <= ceiling.Rank()
+}
+
+// ValidateInvitationAcceptance checks the acceptance of a pending invitation at now,
+// and returns the role granted to the invitee
+func ValidateInvitationAcceptance(invitation *v1.Invitation, now time.Time) (v1.RoleType, error) {
+	accept := invitation.Spec.Accept
+	if accept == nil {
+		return "", errors.New("invitation is not accepted")
+	}
+	if invitation.Status.ExpirationTime != nil && !invitation.Status.ExpirationTime.After(now) {
+		return "", errors.New("invitation is expired")
+	}
+	if invitation.Spec.User != "" && invitation.Spec.User != accept.User {
+		return "", fmt.Errorf("invitation is not for user %s", accept.User)
+	}
+	if invitation.Status.TokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(HashInvitationToken(accept.Token)), []byte(invitation.Status.TokenHash)) != 1 {
+		return "", errors.New("invalid invitation token")
+	}
+	role := accept.Role
+	if role == "" {
+		role = invitation.Spec.Role
+	}
+	// the owner is only handed over by a transfer, even if the invitation was created before it is checked
+	if role == v1.OwnerRoleType {
+		return "", fmt.Errorf("role %s can not be granted by an invitation", role)
+	}
+	if role == invitation.Spec.Role {
+		return role, nil
+	}
+	if invitation.Spec.RoleCeiling == "" || !RoleWithin(role, invitation.Spec.RoleCeiling) {
+		return "", fmt.Errorf("role %s is not allowed by the invitation", role)
+	}
+	return role, nil
+}
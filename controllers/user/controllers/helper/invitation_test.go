@@ -0,0 +1,119 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/labring/sealos/controllers/user/api/v1"
+)
+
+func TestValidateInvitationAcceptance(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newInvitation := func(user string, role, ceiling v1.RoleType, accept *v1.InvitationAcceptance) *v1.Invitation {
+		return &v1.Invitation{
+			Spec: v1.InvitationSpec{User: user, Role: role, RoleCeiling: ceiling, Accept: accept},
+			Status: v1.InvitationStatus{
+				Phase:          v1.InvitationPending,
+				TokenHash:      HashInvitationToken("secret"),
+				ExpirationTime: &metav1.Time{Time: now.Add(time.Hour)},
+			},
+		}
+	}
+	tests := []struct {
+		name       string
+		invitation *v1.Invitation
+		now        time.Time
+		want       v1.RoleType
+		wantErr    bool
+	}{
+		{
+			name:       "not accepted",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, "", nil),
+			now:        now,
+			wantErr:    true,
+		},
+		{
+			name:       "accept the role of invitation",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, "", &v1.InvitationAcceptance{User: "bob", Token: "secret"}),
+			now:        now,
+			want:       v1.DeveloperRoleType,
+		},
+		{
+			name:       "accept a workspace role by anyone",
+			invitation: newInvitation("", "app-deployer", "", &v1.InvitationAcceptance{User: "alice", Token: "secret"}),
+			now:        now,
+			want:       "app-deployer",
+		},
+		{
+			name:       "expired",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, "", &v1.InvitationAcceptance{User: "bob", Token: "secret"}),
+			now:        now.Add(time.Hour),
+			wantErr:    true,
+		},
+		{
+			name:       "another user",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, "", &v1.InvitationAcceptance{User: "alice", Token: "secret"}),
+			now:        now,
+			wantErr:    true,
+		},
+		{
+			name:       "invalid token",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, "", &v1.InvitationAcceptance{User: "bob", Token: "guess"}),
+			now:        now,
+			wantErr:    true,
+		},
+		{
+			name:       "role within ceiling",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, v1.ManagerRoleType, &v1.InvitationAcceptance{User: "bob", Token: "secret", Role: v1.ManagerRoleType}),
+			now:        now,
+			want:       v1.ManagerRoleType,
+		},
+		{
+			name:       "role above ceiling",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, v1.ManagerRoleType, &v1.InvitationAcceptance{User: "bob", Token: "secret", Role: v1.OwnerRoleType}),
+			now:        now,
+			wantErr:    true,
+		},
+		{
+			name:       "owner",
+			invitation: newInvitation("bob", v1.OwnerRoleType, "", &v1.InvitationAcceptance{User: "bob", Token: "secret"}),
+			now:        now,
+			wantErr:    true,
+		},
+		{
+			name:       "another role without ceiling",
+			invitation: newInvitation("bob", v1.DeveloperRoleType, "", &v1.InvitationAcceptance{User: "bob", Token: "secret", Role: v1.ManagerRoleType}),
+			now:        now,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateInvitationAcceptance(tt.invitation, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateInvitationAcceptance() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateInvitationAcceptance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
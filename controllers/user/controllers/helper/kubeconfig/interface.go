@@ -66,6 +66,14 @@ type WebhookConfig struct {
 	webhookURL string
 }
 
+type OIDCConfig struct {
+	*DefaultConfig
+	namespace   string
+	issuerURL   string
+	clientID    string
+	extraScopes []string
+}
+
 func GetKubernetesHost(config *rest.Config) string {
 	host, port := os.Getenv("SEALOS_CLOUD_HOST"), os.Getenv("APISERVER_PORT")
 	if len(host) != 0 && len(port) != 0 {
@@ -129,6 +137,19 @@ func (d *DefaultConfig) WithServiceAccountConfig(namespace string, sa *v1.Servic
 	}
 }
 
+// WithOIDCConfig returns a kubeconfig which gets a short-lived id token from the OIDC issuer
+// by the kubelogin exec plugin, no credential is stored in it. clientID is a public client
+// which authenticates by PKCE, since anything in the kubeconfig is known to its users.
+func (d *DefaultConfig) WithOIDCConfig(namespace, issuerURL, clientID string, extraScopes []string) Interface {
+	return &OIDCConfig{
+		DefaultConfig: d,
+		namespace:     namespace,
+		issuerURL:     issuerURL,
+		clientID:      clientID,
+		extraScopes:   extraScopes,
+	}
+}
+
 func (d *DefaultConfig) WithWebhookConfigConfig(webhookURL string) Interface {
 	return &WebhookConfig{
 		DefaultConfig: d,
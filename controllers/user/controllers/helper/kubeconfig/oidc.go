@@ -0,0 +1,75 @@
+/*
+Copyright 2024 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeconfig
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const oidcLoginInstallHint = `kubectl oidc-login is required to use this kubeconfig, install it by:
+  kubectl krew install oidc-login
+See https://github.com/int128/kubelogin for more details.`
+
+func (c *OIDCConfig) Apply(config *rest.Config, _ client.Client) (*api.Config, error) {
+	// make sure cadata is loaded into config under incluster mode
+	if err := rest.LoadTLSFiles(config); err != nil {
+		return nil, err
+	}
+	args := []string{
+		"oidc-login",
+		"get-token",
+		"--oidc-issuer-url=" + c.issuerURL,
+		"--oidc-client-id=" + c.clientID,
+		// the client is public, PKCE instead of a client secret protects the authorization code
+		"--oidc-use-pkce",
+	}
+	for _, scope := range c.extraScopes {
+		args = append(args, "--oidc-extra-scope="+scope)
+	}
+	ctx := fmt.Sprintf("%s@%s", c.user, c.clusterName)
+	return &api.Config{
+		Clusters: map[string]*api.Cluster{
+			c.clusterName: {
+				Server:                   GetKubernetesHost(config),
+				CertificateAuthorityData: config.TLSClientConfig.CAData,
+			},
+		},
+		Contexts: map[string]*api.Context{
+			ctx: {
+				Cluster:   c.clusterName,
+				AuthInfo:  c.user,
+				Namespace: c.namespace,
+			},
+		},
+		AuthInfos: map[string]*api.AuthInfo{
+			c.user: {
+				Exec: &api.ExecConfig{
+					APIVersion:      "client.authentication.k8s.io/v1beta1",
+					Command:         "kubectl",
+					Args:            args,
+					InstallHint:     oidcLoginInstallHint,
+					InteractiveMode: api.IfAvailableExecInteractiveMode,
+				},
+			},
+		},
+		CurrentContext: ctx,
+	}, nil
+}
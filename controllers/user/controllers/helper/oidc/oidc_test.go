@@ -0,0 +1,43 @@
+/*
+Copyright 2024 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/labring/sealos/controllers/user/controllers/helper/oidc/oidctest"
+)
+
+func TestDiscover(t *testing.T) {
+	idp, err := oidctest.NewServer("sealos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idp.Close()
+
+	provider, err := Discover(context.Background(), idp.URL)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if provider.TokenEndpoint != idp.URL+"/token" {
+		t.Errorf("Discover() token endpoint = %s, want %s", provider.TokenEndpoint, idp.URL+"/token")
+	}
+	if _, err := Discover(context.Background(), idp.URL+"/"); err == nil {
+		t.Errorf("Discover() with mismatched issuer should return error")
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	v1 "github.com/labring/sealos/controllers/user/api/v1"
+)
+
+// SelectWorkspaceSuccessor returns the member of a workspace that takes it over from owner, the member with
+// the highest built-in role wins and the earliest member is preferred between equals. The members are the users
+// of the role bindings labeled with their owner, it returns "" if there is no other member.
+func SelectWorkspaceSuccessor(bindings []rbacv1.RoleBinding, owner string) string {
+	var successor *rbacv1.RoleBinding
+	for i := range bindings {
+		binding := &bindings[i]
+		user := binding.Labels[v1.UserLabelOwnerKey]
+		if user == "" || user == owner || binding.DeletionTimestamp != nil {
+			continue
+		}
+		if successor == nil || isPreferredMember(binding, successor) {
+			successor = binding
+		}
+	}
+	if successor == nil {
+		return ""
+	}
+	return successor.Labels[v1.UserLabelOwnerKey]
+}
+
+func isPreferredMember(binding, than *rbacv1.RoleBinding) bool {
+	rank, thanRank := v1.RoleType(binding.RoleRef.Name).Rank(), v1.RoleType(than.RoleRef.Name).Rank()
+	if rank != thanRank {
+		return rank > thanRank
+	}
+	if !binding.CreationTimestamp.Equal(&than.CreationTimestamp) {
+		return binding.CreationTimestamp.Before(&than.CreationTimestamp)
+	}
+	return binding.Labels[v1.UserLabelOwnerKey] < than.Labels[v1.UserLabelOwnerKey]
+}
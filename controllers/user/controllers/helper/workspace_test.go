@@ -0,0 +1,95 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/labring/sealos/controllers/user/api/v1"
+)
+
+func TestSelectWorkspaceSuccessor(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newBinding := func(user string, role v1.RoleType, age time.Duration) rbacv1.RoleBinding {
+		return rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "rb-" + user,
+				Labels:            map[string]string{v1.UserLabelOwnerKey: user},
+				CreationTimestamp: metav1.Time{Time: now.Add(-age)},
+			},
+			RoleRef: rbacv1.RoleRef{Kind: "Role", Name: string(role)},
+		}
+	}
+	tests := []struct {
+		name     string
+		bindings []rbacv1.RoleBinding
+		want     string
+	}{
+		{
+			name:     "no other member",
+			bindings: []rbacv1.RoleBinding{newBinding("alice", v1.OwnerRoleType, time.Hour)},
+			want:     "",
+		},
+		{
+			name: "manager is preferred",
+			bindings: []rbacv1.RoleBinding{
+				newBinding("alice", v1.OwnerRoleType, 3*time.Hour),
+				newBinding("bob", v1.DeveloperRoleType, 2*time.Hour),
+				newBinding("carol", v1.ManagerRoleType, time.Hour),
+			},
+			want: "carol",
+		},
+		{
+			name: "earliest member between equals",
+			bindings: []rbacv1.RoleBinding{
+				newBinding("alice", v1.OwnerRoleType, 3*time.Hour),
+				newBinding("bob", v1.DeveloperRoleType, time.Hour),
+				newBinding("carol", v1.DeveloperRoleType, 2*time.Hour),
+			},
+			want: "carol",
+		},
+		{
+			name: "built-in role is preferred to workspace role",
+			bindings: []rbacv1.RoleBinding{
+				newBinding("alice", v1.OwnerRoleType, 3*time.Hour),
+				newBinding("bob", "app-deployer", 2*time.Hour),
+				newBinding("carol", v1.DeveloperRoleType, time.Hour),
+			},
+			want: "carol",
+		},
+		{
+			name: "binding without owner label is ignored",
+			bindings: []rbacv1.RoleBinding{
+				newBinding("alice", v1.OwnerRoleType, 3*time.Hour),
+				{ObjectMeta: metav1.ObjectMeta{Name: "oidc-manager"}, RoleRef: rbacv1.RoleRef{Kind: "Role", Name: string(v1.ManagerRoleType)}},
+				newBinding("bob", v1.DeveloperRoleType, time.Hour),
+			},
+			want: "bob",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectWorkspaceSuccessor(tt.bindings, "alice"); got != tt.want {
+				t.Errorf("SelectWorkspaceSuccessor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	userv1 "github.com/labring/sealos/controllers/user/api/v1"
+	"github.com/labring/sealos/controllers/user/controllers/helper"
+)
+
+// InvitationReconciler reconciles an Invitation object
+type InvitationReconciler struct {
+	client.Client
+
+	Logger   logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=user.sealos.io,resources=invitations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=user.sealos.io,resources=invitations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=user.sealos.io,resources=invitations/finalizers,verbs=update
+
+func (r *InvitationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	invitation := &userv1.Invitation{}
+	if err := r.Get(ctx, req.NamespacedName, invitation); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if invitation.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+	return r.reconcile(ctx, invitation)
+}
+
+func (r *InvitationReconciler) reconcile(ctx context.Context, invitation *userv1.Invitation) (ctrl.Result, error) {
+	r.Logger.V(1).Info("reconcile invitation", "invitation", client.ObjectKeyFromObject(invitation), "phase", invitation.Status.Phase)
+	switch invitation.Status.Phase {
+	case userv1.InvitationAccepted, userv1.InvitationExpired, userv1.InvitationRevoked:
+		// the token is single-use, it is deleted once the invitation is finished
+		return ctrl.Result{}, r.deleteToken(ctx, invitation)
+	}
+
+	if invitation.Status.TokenHash == "" {
+		if err := r.issueToken(ctx, invitation); err != nil {
+			r.Recorder.Eventf(invitation, v1.EventTypeWarning, "IssueToken", "Issue token of invitation %s is error: %v", invitation.Name, err)
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(invitation, v1.EventTypeNormal, "Invite", "Invite user %s as %s, token is saved in secret %s",
+			invitation.Spec.User, invitation.Spec.Role, invitation.Status.TokenSecretName)
+	}
+
+	now := time.Now()
+	switch {
+	case invitation.Spec.Revoked:
+		r.Recorder.Eventf(invitation, v1.EventTypeNormal, "Revoke", "Invitation %s is revoked", invitation.Name)
+		return ctrl.Result{}, r.finish(ctx, invitation, userv1.InvitationRevoked)
+	case !invitation.Status.ExpirationTime.After(now):
+		r.Recorder.Eventf(invitation, v1.EventTypeNormal, "Expire", "Invitation %s is expired", invitation.Name)
+		return ctrl.Result{}, r.finish(ctx, invitation, userv1.InvitationExpired)
+	case invitation.Spec.Accept != nil:
+		return r.accept(ctx, invitation, now)
+	}
+	return ctrl.Result{RequeueAfter: invitation.Status.ExpirationTime.Sub(now)}, nil
+}
+
+// issueToken generates the token of invitation and keeps it in a secret owned by the invitation
+func (r *InvitationReconciler) issueToken(ctx context.Context, invitation *userv1.Invitation) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("invitation-%s", invitation.Name),
+			Namespace: invitation.Namespace,
+		},
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Type = v1.SecretTypeOpaque
+		secret.Data = map[string][]byte{userv1.InvitationTokenKey: []byte(token)}
+		return controllerutil.SetControllerReference(invitation, secret, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("unable to create token secret: %w", err)
+	}
+
+	if invitation.Spec.User != "" && invitation.Labels[userv1.InvitationLabelInviteeKey] != invitation.Spec.User {
+		if invitation.Labels == nil {
+			invitation.Labels = make(map[string]string)
+		}
+		invitation.Labels[userv1.InvitationLabelInviteeKey] = invitation.Spec.User
+		if err := r.Update(ctx, invitation); err != nil {
+			return err
+		}
+	}
+	invitation.Status.Phase = userv1.InvitationPending
+	invitation.Status.TokenSecretName = secret.Name
+	invitation.Status.TokenHash = helper.HashInvitationToken(token)
+	invitation.Status.ExpirationTime = &metav1.Time{Time: invitation.CreationTimestamp.Add(invitation.Spec.TTL.Duration)}
+	return r.Status().Update(ctx, invitation)
+}
+
+// accept grants the role to the invitee if the acceptance is valid, an invalid acceptance is
+// reported in the status and the invitation keeps pending
+func (r *InvitationReconciler) accept(ctx context.Context, invitation *userv1.Invitation, now time.Time) (ctrl.Result, error) {
+	role, err := helper.ValidateInvitationAcceptance(invitation, now)
+	if err == nil {
+		err = r.Get(ctx, client.ObjectKey{Namespace: invitation.Namespace, Name: string(role)}, &rbacv1.Role{})
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("role %s is not found in workspace", role)
+		}
+	}
+	bindUser := &userv1.User{}
+	if err == nil {
+		err = r.Get(ctx, client.ObjectKey{Name: invitation.Spec.Accept.User}, bindUser)
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("user %s is not found", invitation.Spec.Accept.User)
+		}
+	}
+	if err != nil {
+		r.Recorder.Eventf(invitation, v1.EventTypeWarning, "Reject", "Reject acceptance of invitation %s: %v", invitation.Name, err)
+		if invitation.Status.Message != err.Error() {
+			invitation.Status.Message = err.Error()
+			if err := r.Status().Update(ctx, invitation); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: invitation.Status.ExpirationTime.Sub(now)}, nil
+	}
+
+	request := &userv1.Operationrequest{
+		Spec: userv1.OperationrequestSpec{
+			Namespace: invitation.Namespace,
+			User:      bindUser.Name,
+			Role:      role,
+			Action:    userv1.Grant,
+		},
+	}
+	if err := r.bind(ctx, request, bindUser); err != nil {
+		r.Recorder.Eventf(invitation, v1.EventTypeWarning, "Accept", "Grant role %s to user %s is error: %v", role, bindUser.Name, err)
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Eventf(invitation, v1.EventTypeNormal, "Accept", "User %s accepted invitation %s as %s", bindUser.Name, invitation.Name, role)
+
+	invitation.Status.AcceptedBy = bindUser.Name
+	invitation.Status.AcceptedRole = role
+	invitation.Status.AcceptedTime = &metav1.Time{Time: now}
+	invitation.Status.Message = ""
+	return ctrl.Result{}, r.finish(ctx, invitation, userv1.InvitationAccepted)
+}
+
+// bind creates the role binding of the request, an existing binding of another role is replaced. The owner is
+// never bound here, it is only handed over by a transfer which also moves the owner of the workspace.
+func (r *InvitationReconciler) bind(ctx context.Context, request *userv1.Operationrequest, bindUser *userv1.User) error {
+	if request.Spec.Role == userv1.OwnerRoleType {
+		return fmt.Errorf("role %s can not be granted by an invitation", request.Spec.Role)
+	}
+	return applyRoleBinding(ctx, r.Client, r.Scheme, conventRequestToRolebinding(request), bindUser)
+}
+
+func (r *InvitationReconciler) finish(ctx context.Context, invitation *userv1.Invitation, phase userv1.InvitationPhase) error {
+	invitation.Status.Phase = phase
+	if err := r.Status().Update(ctx, invitation); err != nil {
+		return err
+	}
+	return r.deleteToken(ctx, invitation)
+}
+
+func (r *InvitationReconciler) deleteToken(ctx context.Context, invitation *userv1.Invitation) error {
+	if invitation.Status.TokenSecretName == "" {
+		return nil
+	}
+	secret := &v1.Secret{}
+	secret.Name = invitation.Status.TokenSecretName
+	secret.Namespace = invitation.Namespace
+	return client.IgnoreNotFound(r.Delete(ctx, secret))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InvitationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	const controllerName = "invitation_controller"
+	if r.Client == nil {
+		r.Client = mgr.GetClient()
+	}
+	r.Logger = ctrl.Log.WithName(controllerName)
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor(controllerName)
+	}
+	r.Scheme = mgr.GetScheme()
+	r.Logger.V(1).Info("init reconcile invitation controller")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&userv1.Invitation{}).
+		Owns(&v1.Secret{}).
+		Complete(r)
+}
@@ -29,7 +29,9 @@ import (
 	"github.com/go-logr/logr"
 
 	userv1 "github.com/labring/sealos/controllers/user/api/v1"
+	"github.com/labring/sealos/controllers/user/controllers/helper/audit"
 	"github.com/labring/sealos/controllers/user/controllers/helper/config"
+	"github.com/labring/sealos/controllers/user/controllers/helper/database"
 	"github.com/labring/sealos/controllers/user/controllers/helper/ratelimiter"
 
 	v1 "k8s.io/api/core/v1"
@@ -54,6 +56,11 @@ type OperationReqReconciler struct {
 	Recorder record.EventRecorder
 	userLock map[string]*sync.Mutex
 
+	// WorkspaceOwnerStore moves the billing owner of a transferred workspace, it is skipped if nil
+	WorkspaceOwnerStore database.WorkspaceOwnerStore
+	// AuditSink records the handled requests, nothing is recorded if nil
+	AuditSink audit.Sink
+
 	// expirationTime is the time duration of the request is expired
 	expirationTime time.Duration
 	// retentionTime is the time duration of the request is retained after it is isCompleted
@@ -74,6 +81,9 @@ func (r *OperationReqReconciler) SetupWithManager(mgr ctrl.Manager, opts ratelim
 	r.expirationTime = expTime
 	r.retentionTime = retTime
 	r.userLock = make(map[string]*sync.Mutex)
+	if r.AuditSink == nil {
+		r.AuditSink = audit.MeteredSink{Sink: audit.LogSink{Logger: r.Logger.WithName("audit")}}
+	}
 	r.Logger.V(1).Info("init reconcile operationrequest controller")
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&userv1.Operationrequest{}, builder.WithPredicates(namespaceOnlyPredicate(config.GetUserSystemNamespace()))).
@@ -180,61 +190,119 @@ func (r *OperationReqReconciler) reconcile(ctx context.Context, request *userv1.
 	}
 
 	// handle OperationRequest, create or delete rolebinding
+	record := r.newAuditRecord(ctx, request, user, rolebinding)
+	err = r.operate(ctx, request, user, rolebinding, setUpOwnerReferenceFc)
+	r.audit(ctx, request, record, err)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// update OperationRequest status to completed
+	err = r.updateRequestStatus(ctx, request, userv1.RequestCompleted)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Recorder.Eventf(request, v1.EventTypeNormal, "Completed", "Completed operation request %s/%s", request.Spec.Namespace, request.Name)
+	return ctrl.Result{RequeueAfter: OperationReqRequeueDuration}, nil
+}
+
+// operate creates, replaces or deletes the rolebinding of request, or transfers the workspace to the user of request
+func (r *OperationReqReconciler) operate(ctx context.Context, request *userv1.Operationrequest, user *userv1.User, rolebinding *rbacv1.RoleBinding, setUpOwnerReferenceFc func() error) error {
 	switch request.Spec.Action {
 	case userv1.Grant:
 		r.Recorder.Eventf(request, v1.EventTypeNormal, "Grant", "Grant role %s to user %s", request.Spec.Role, request.Spec.User)
 		if _, err := ctrl.CreateOrUpdate(ctx, r.Client, rolebinding, setUpOwnerReferenceFc); err != nil {
 			r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to create/update rolebinding", "Failed to create rolebinding %s/%s", rolebinding.Namespace, rolebinding.Name)
-			return ctrl.Result{}, err
+			return err
 		}
 		if request.Spec.Role == userv1.OwnerRoleType {
 			// update user annotation
 			user.Annotations[userv1.UserAnnotationOwnerKey] = request.Spec.User
 			if err := r.Update(ctx, user); err != nil {
 				r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to update user", "Failed to update user %s", request.Spec.User)
-				return ctrl.Result{}, err
+				return err
 			}
 		}
 	case userv1.Deprive:
 		r.Recorder.Eventf(request, v1.EventTypeNormal, "Deprive", "Deprive role %s from user %s", request.Spec.Role, request.Spec.User)
 		if err := r.Delete(ctx, rolebinding); client.IgnoreNotFound(err) != nil {
 			r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to delete rolebinding", "Failed to delete rolebinding %s/%s", rolebinding.Namespace, rolebinding.Name)
-			return ctrl.Result{}, err
+			return err
 		}
 	case userv1.Update:
 		r.Recorder.Eventf(request, v1.EventTypeNormal, "Update", "Update role %s to user %s", request.Spec.Role, request.Spec.User)
 		if err := r.Delete(ctx, rolebinding); client.IgnoreNotFound(err) != nil {
 			r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to delete rolebinding", "Failed to delete rolebinding %s/%s", rolebinding.Namespace, rolebinding.Name)
-			return ctrl.Result{}, err
+			return err
 		}
 		if err := r.Create(ctx, rolebinding); err != nil {
 			r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to create rolebinding", "Failed to create rolebinding %s/%s", rolebinding.Namespace, rolebinding.Name)
-			return ctrl.Result{}, err
+			return err
 		}
-		if err = setUpOwnerReferenceFc(); err != nil {
+		if err := setUpOwnerReferenceFc(); err != nil {
 			r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to set owner reference", "Failed to set owner reference for rolebinding %s/%s", rolebinding.Namespace, rolebinding.Name)
-			return ctrl.Result{}, err
+			return err
 		}
 		if request.Spec.Role == userv1.OwnerRoleType {
 			// update user annotation
 			user.Annotations[userv1.UserAnnotationOwnerKey] = request.Spec.User
 			if err := r.Update(ctx, user); err != nil {
 				r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to update user", "Failed to update user %s", request.Spec.User)
-				return ctrl.Result{}, err
+				return err
 			}
 		}
+	case userv1.Transfer:
+		r.Recorder.Eventf(request, v1.EventTypeNormal, "Transfer", "Transfer workspace %s to user %s", request.Spec.Namespace, request.Spec.User)
+		from, err := transferWorkspace(ctx, r.Client, r.Scheme, r.WorkspaceOwnerStore, request.Spec.Namespace, request.Spec.User)
+		if err != nil {
+			r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to transfer workspace", "Failed to transfer workspace %s to user %s: %v", request.Spec.Namespace, request.Spec.User, err)
+			return err
+		}
+		r.Logger.Info("transferred workspace", getLog(request, "previous owner", from)...)
 	default:
-		return ctrl.Result{}, fmt.Errorf("invalid action %s", request.Spec.Action)
+		return fmt.Errorf("invalid action %s", request.Spec.Action)
 	}
+	return nil
+}
 
-	// update OperationRequest status to completed
-	err = r.updateRequestStatus(ctx, request, userv1.RequestCompleted)
-	if err != nil {
-		return ctrl.Result{}, err
+// newAuditRecord returns the audit record of request with the role bound before it is handled,
+// the owner of the workspace is recorded instead for a transfer
+func (r *OperationReqReconciler) newAuditRecord(ctx context.Context, request *userv1.Operationrequest, user *userv1.User, rolebinding *rbacv1.RoleBinding) audit.Record {
+	record := audit.Record{
+		Time:      time.Now(),
+		Actor:     request.Annotations[userv1.UserAnnotationRequesterKey],
+		Action:    string(request.Spec.Action),
+		User:      request.Spec.User,
+		Namespace: request.Spec.Namespace,
+		Role:      string(request.Spec.Role),
+	}
+	if request.Spec.Action == userv1.Transfer {
+		record.Before = user.Annotations[userAnnotationOwnerKey]
+		return record
+	}
+	existing := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(rolebinding), existing); err == nil {
+		record.Before = existing.RoleRef.Name
 	}
+	return record
+}
 
-	r.Recorder.Eventf(request, v1.EventTypeNormal, "Completed", "Completed operation request %s/%s", request.Spec.Namespace, request.Name)
-	return ctrl.Result{RequeueAfter: OperationReqRequeueDuration}, nil
+// audit writes the record of request completed by err, a failure of the audit log does not fail the request
+func (r *OperationReqReconciler) audit(ctx context.Context, request *userv1.Operationrequest, record audit.Record, err error) {
+	switch request.Spec.Action {
+	case userv1.Grant, userv1.Update:
+		record.After = string(request.Spec.Role)
+	case userv1.Transfer:
+		record.After = request.Spec.User
+	}
+	if err != nil {
+		record.After = record.Before
+	}
+	if err := r.AuditSink.Write(ctx, record.Complete(err)); err != nil {
+		r.Logger.Error(err, "failed to write audit record", getLog(request)...)
+		r.Recorder.Eventf(request, v1.EventTypeWarning, "Failed to write audit record", "Failed to write audit record of %s: %v", request.Name, err)
+	}
 }
 
 // isRetained returns true if the request is isCompleted and exist for retention time
@@ -292,13 +360,7 @@ func conventRequestToRolebinding(request *userv1.Operationrequest) *rbacv1.RoleB
 				userLabelOwnerKey: request.Spec.User,
 			},
 		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      rbacv1.ServiceAccountKind,
-				Name:      request.Spec.User,
-				Namespace: config.GetUserSystemNamespace(),
-			},
-		},
+		Subjects: config.GetUsersSubject(request.Spec.User),
 		RoleRef: rbacv1.RoleRef{
 			Kind:     "Role",
 			Name:     string(request.Spec.Role),
@@ -21,11 +21,13 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"golang.org/x/exp/rand"
 
+	"github.com/labring/sealos/controllers/user/controllers/helper/audit"
 	"github.com/labring/sealos/controllers/user/controllers/helper/config"
 	"github.com/labring/sealos/controllers/user/controllers/helper/finalizer"
 	"github.com/labring/sealos/controllers/user/controllers/helper/hash"
@@ -53,6 +55,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	userv1 "github.com/labring/sealos/controllers/user/api/v1"
 	"github.com/labring/sealos/controllers/user/controllers/helper"
@@ -62,6 +65,8 @@ const (
 	userAnnotationCreatorKey = userv1.UserAnnotationCreatorKey
 	userAnnotationOwnerKey   = userv1.UserAnnotationOwnerKey
 	userLabelOwnerKey        = userv1.UserLabelOwnerKey
+	// oidcGroupRoleLabelKey is the label of the role bindings of OIDC groups, its value is the bound role
+	oidcGroupRoleLabelKey = "user.sealos.io/oidc-group-role"
 )
 
 // UserReconciler reconciles a User object
@@ -75,6 +80,9 @@ type UserReconciler struct {
 	finalizer          *finalizer.Finalizer
 	minRequeueDuration time.Duration
 	maxRequeueDuration time.Duration
+
+	// AuditSink records the renewed kubeconfigs, nothing is recorded if nil
+	AuditSink audit.Sink
 }
 
 type ctxKey string
@@ -153,6 +161,9 @@ func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager, opts ratelimiter.Rat
 	r.Logger.V(1).Info("init reconcile controller user")
 	r.minRequeueDuration = minRequeueDuration
 	r.maxRequeueDuration = maxRequeueDuration
+	if r.AuditSink == nil {
+		r.AuditSink = audit.MeteredSink{Sink: audit.LogSink{Logger: r.Logger.WithName("audit")}}
+	}
 
 	ownerEventHandler := handler.EnqueueRequestForOwner(r.Scheme, r.Client.RESTMapper(), &userv1.User{}, handler.OnlyControllerOwner())
 
@@ -162,6 +173,7 @@ func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager, opts ratelimiter.Rat
 		Watches(&rbacv1.RoleBinding{}, ownerEventHandler).
 		Watches(&v1.Secret{}, ownerEventHandler).
 		Watches(&v1.ServiceAccount{}, ownerEventHandler).
+		Watches(&userv1.WorkspaceRole{}, handler.EnqueueRequestsFromMapFunc(r.findUsersForWorkspaceRole)).
 		WithOptions(kubecontroller.Options{
 			MaxConcurrentReconciles: ratelimiter.GetConcurrent(opts),
 			RateLimiter:             ratelimiter.GetRateLimiter(opts),
@@ -170,6 +182,20 @@ func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager, opts ratelimiter.Rat
 		Complete(r)
 }
 
+// findUsersForWorkspaceRole enqueues all users, a WorkspaceRole is materialized in every user namespace
+func (r *UserReconciler) findUsersForWorkspaceRole(ctx context.Context, _ client.Object) []reconcile.Request {
+	users := &userv1.UserList{}
+	if err := r.List(ctx, users); err != nil {
+		r.Logger.Error(err, "list users for workspace role error")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(users.Items))
+	for _, user := range users.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&user)})
+	}
+	return requests
+}
+
 func (r *UserReconciler) reconcile(ctx context.Context, obj client.Object) (ctrl.Result, error) {
 	r.Logger.V(1).Info("update reconcile controller user", "request", client.ObjectKeyFromObject(obj))
 	startTime := time.Now()
@@ -183,21 +209,36 @@ func (r *UserReconciler) reconcile(ctx context.Context, obj client.Object) (ctrl
 		r.Logger.V(1).Info("finished reconcile", "user info", user.Name, "create time", user.CreationTimestamp, "reconcile cost time", time.Since(startTime))
 	}()
 
+	// the kubeconfig of an expired user is revoked by the expiration controller and must not be issued again
+	expired := user.IsExpired(time.Now())
 	pipelines := []func(ctx context.Context, user *userv1.User) context.Context{
 		r.initStatus,
 		r.syncNamespace,
 		r.syncServiceAccount,
-		r.syncServiceAccountSecrets,
-		r.syncKubeConfig,
+	}
+	if !expired {
+		// no long-lived service account token is issued if users login by the OIDC provider
+		if config.GetOIDC() == nil {
+			pipelines = append(pipelines, r.syncServiceAccountSecrets)
+		}
+		pipelines = append(pipelines, r.syncKubeConfig)
+	}
+	if config.GetOIDC() != nil {
+		pipelines = append(pipelines, r.revokeServiceAccountSecrets)
+	}
+	pipelines = append(pipelines,
 		r.syncRole,
 		r.syncRoleBinding,
 		r.syncFinalStatus,
-	}
+	)
 
 	for _, fn := range pipelines {
 		ctx = fn(ctx, user)
 	}
-	if user.Status.Phase != userv1.UserUnknown {
+	if expired {
+		user.Status.Phase = userv1.UserExpired
+		user.Status.KubeConfig = ""
+	} else if user.Status.Phase != userv1.UserUnknown {
 		user.Status.Phase = userv1.UserActive
 	}
 	err := r.updateStatus(ctx, client.ObjectKeyFromObject(obj), user.Status.DeepCopy())
@@ -298,19 +339,71 @@ func (r *UserReconciler) syncRole(ctx context.Context, user *userv1.User) contex
 		}
 	}()
 	//create three roles
-	r.createRole(ctx, roleCondition, user, userv1.OwnerRoleType)
-	r.createRole(ctx, roleCondition, user, userv1.ManagerRoleType)
-	r.createRole(ctx, roleCondition, user, userv1.DeveloperRoleType)
+	r.createRole(ctx, roleCondition, user, string(userv1.OwnerRoleType), config.GetUserRole(userv1.OwnerRoleType), nil)
+	r.createRole(ctx, roleCondition, user, string(userv1.ManagerRoleType), config.GetUserRole(userv1.ManagerRoleType), nil)
+	r.createRole(ctx, roleCondition, user, string(userv1.DeveloperRoleType), config.GetUserRole(userv1.DeveloperRoleType), nil)
+	r.syncWorkspaceRoles(ctx, roleCondition, user)
 
 	return ctx
 }
 
-func (r *UserReconciler) createRole(ctx context.Context, condition *userv1.Condition, user *userv1.User, roleType userv1.RoleType) {
+// syncWorkspaceRoles materializes every WorkspaceRole as a Role in the user namespace,
+// and deletes the roles of WorkspaceRoles which no longer exist
+func (r *UserReconciler) syncWorkspaceRoles(ctx context.Context, condition *userv1.Condition, user *userv1.User) {
+	workspaceRoles := &userv1.WorkspaceRoleList{}
+	if err := r.List(ctx, workspaceRoles); err != nil {
+		helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to list workspace roles: %w", err))
+		r.Recorder.Eventf(user, v1.EventTypeWarning, "syncUserRole", "Sync User namespace workspace roles %s is error: %v", user.Name, err)
+		return
+	}
+	names := make(map[string]struct{}, len(workspaceRoles.Items))
+	for _, workspaceRole := range workspaceRoles.Items {
+		if workspaceRole.DeletionTimestamp != nil {
+			continue
+		}
+		names[workspaceRole.Name] = struct{}{}
+		// a built-in role or any other role of the namespace is never overwritten by a WorkspaceRole
+		if userv1.IsBuiltinRoleName(workspaceRole.Name) {
+			helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("workspace role %s uses the name of a built-in role", workspaceRole.Name))
+			continue
+		}
+		role := &rbacv1.Role{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: config.GetUsersNamespace(user.Name), Name: workspaceRole.Name}, role); client.IgnoreNotFound(err) != nil {
+			helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to get role %s: %w", workspaceRole.Name, err))
+			continue
+		} else if err == nil && role.Labels[userv1.WorkspaceRoleLabelKey] != workspaceRole.Name {
+			helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("workspace role %s conflicts with role %s of the namespace", workspaceRole.Name, role.Name))
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "syncUserRole", "Workspace role %s conflicts with an existing role of the namespace", workspaceRole.Name)
+			continue
+		}
+		r.createRole(ctx, condition, user, workspaceRole.Name, workspaceRole.Spec.Rules, map[string]string{
+			userv1.WorkspaceRoleLabelKey: workspaceRole.Name,
+		})
+	}
+
+	roles := &rbacv1.RoleList{}
+	if err := r.List(ctx, roles, client.InNamespace(config.GetUsersNamespace(user.Name)), client.HasLabels{userv1.WorkspaceRoleLabelKey}); err != nil {
+		helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to list workspace roles of namespace: %w", err))
+		r.Recorder.Eventf(user, v1.EventTypeWarning, "syncUserRole", "Sync User namespace workspace roles %s is error: %v", user.Name, err)
+		return
+	}
+	for i := range roles.Items {
+		if _, ok := names[roles.Items[i].Labels[userv1.WorkspaceRoleLabelKey]]; ok {
+			continue
+		}
+		if err := r.Delete(ctx, &roles.Items[i]); client.IgnoreNotFound(err) != nil {
+			helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to delete workspace role %s: %w", roles.Items[i].Name, err))
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "syncUserRole", "Delete User namespace workspace role %s is error: %v", roles.Items[i].Name, err)
+		}
+	}
+}
+
+func (r *UserReconciler) createRole(ctx context.Context, condition *userv1.Condition, user *userv1.User, name string, rules []rbacv1.PolicyRule, labels map[string]string) {
 	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		var change controllerutil.OperationResult
 		var err error
 		role := &rbacv1.Role{}
-		role.Name = string(roleType)
+		role.Name = name
 		role.Namespace = config.GetUsersNamespace(user.Name)
 		role.Labels = map[string]string{}
 		if change, err = controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
@@ -318,7 +411,10 @@ func (r *UserReconciler) createRole(ctx context.Context, condition *userv1.Condi
 				userAnnotationCreatorKey: user.Name,
 				userAnnotationOwnerKey:   user.Annotations[userAnnotationOwnerKey],
 			}
-			role.Rules = config.GetUserRole(roleType)
+			if len(labels) > 0 {
+				role.Labels = labels
+			}
+			role.Rules = rules
 			return controllerutil.SetControllerReference(user, role, r.Scheme)
 		}); err != nil {
 			return fmt.Errorf("unable to create namespace role by User: %w", err)
@@ -348,6 +444,8 @@ func (r *UserReconciler) syncRoleBinding(ctx context.Context, user *userv1.User)
 			r.saveCondition(user, rbCondition.DeepCopy())
 		}
 	}()
+	// the OIDC user of an expired user is unbound, since its id token is still valid at the provider
+	expired := user.IsExpired(time.Now())
 	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		var change controllerutil.OperationResult
 		var err error
@@ -366,6 +464,9 @@ func (r *UserReconciler) syncRoleBinding(ctx context.Context, user *userv1.User)
 				Name:     string(userv1.OwnerRoleType),
 			}
 			roleBinding.Subjects = config.GetUsersSubject(user.Name)
+			if expired {
+				roleBinding.Subjects = []rbacv1.Subject{config.GetServiceAccountSubject(user.Name)}
+			}
 			return controllerutil.SetControllerReference(user, roleBinding, r.Scheme)
 		}); err != nil {
 			return fmt.Errorf("unable to create namespace role binding by User: %w", err)
@@ -377,8 +478,69 @@ func (r *UserReconciler) syncRoleBinding(ctx context.Context, user *userv1.User)
 		helper.SetConditionError(rbCondition, "SyncUserError", err)
 		r.Recorder.Eventf(user, v1.EventTypeWarning, "syncUserRoleBinding", "Sync User namespace role binding %s is error: %v", user.Name, err)
 	}
+	if oidc := config.GetOIDC(); oidc != nil {
+		r.syncGroupRoleBindings(ctx, rbCondition, user, oidc, expired)
+	}
 	return ctx
 }
+
+// syncGroupRoleBindings binds every role of the user namespace to the group <namespace>:<role> of the
+// OIDC provider, so that the workspace roles of users are managed by their groups in the provider.
+// The group role bindings of an expired user are removed.
+func (r *UserReconciler) syncGroupRoleBindings(ctx context.Context, condition *userv1.Condition, user *userv1.User, oidc *config.OIDC, expired bool) {
+	namespace := config.GetUsersNamespace(user.Name)
+	roles := &rbacv1.RoleList{}
+	if err := r.List(ctx, roles, client.InNamespace(namespace)); err != nil {
+		helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to list roles: %w", err))
+		r.Recorder.Eventf(user, v1.EventTypeWarning, "syncUserRoleBinding", "Sync User namespace group role bindings %s is error: %v", user.Name, err)
+		return
+	}
+	names := make(map[string]struct{}, len(roles.Items))
+	for _, role := range roles.Items {
+		if expired || !metav1.IsControlledBy(&role, user) {
+			continue
+		}
+		names[role.Name] = struct{}{}
+		roleBinding := &rbacv1.RoleBinding{}
+		roleBinding.Name = fmt.Sprintf("oidc-%s", strings.ToLower(role.Name))
+		roleBinding.Namespace = namespace
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+			roleBinding.Labels = map[string]string{oidcGroupRoleLabelKey: role.Name}
+			roleBinding.Annotations = map[string]string{
+				userAnnotationCreatorKey: user.Name,
+				userAnnotationOwnerKey:   user.Annotations[userAnnotationOwnerKey],
+			}
+			roleBinding.RoleRef = rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "Role",
+				Name:     role.Name,
+			}
+			roleBinding.Subjects = []rbacv1.Subject{{
+				Kind:     rbacv1.GroupKind,
+				APIGroup: rbacv1.GroupName,
+				Name:     oidc.GroupName(namespace, role.Name),
+			}}
+			return controllerutil.SetControllerReference(user, roleBinding, r.Scheme)
+		}); err != nil {
+			helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to create group role binding %s: %w", roleBinding.Name, err))
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "syncUserRoleBinding", "Sync User namespace group role binding %s is error: %v", roleBinding.Name, err)
+		}
+	}
+
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.InNamespace(namespace), client.HasLabels{oidcGroupRoleLabelKey}); err != nil {
+		helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to list group role bindings: %w", err))
+		return
+	}
+	for i := range roleBindings.Items {
+		if _, ok := names[roleBindings.Items[i].Labels[oidcGroupRoleLabelKey]]; ok {
+			continue
+		}
+		if err := r.Delete(ctx, &roleBindings.Items[i]); client.IgnoreNotFound(err) != nil {
+			helper.SetConditionError(condition, "SyncUserError", fmt.Errorf("unable to delete group role binding %s: %w", roleBindings.Items[i].Name, err))
+		}
+	}
+}
 func (r *UserReconciler) saveCondition(user *userv1.User, condition *userv1.Condition) {
 	user.Status.Conditions = helper.UpdateCondition(user.Status.Conditions, *condition)
 }
@@ -504,6 +666,50 @@ func (r *UserReconciler) syncServiceAccountSecrets(ctx context.Context, user *us
 	return ctx
 }
 
+// revokeServiceAccountSecrets deletes the service account token secrets issued before users login by the OIDC
+// provider, the kubeconfigs embedding their tokens are invalidated with them
+func (r *UserReconciler) revokeServiceAccountSecrets(ctx context.Context, user *userv1.User) context.Context {
+	secretsCondition := &userv1.Condition{
+		Type:               userv1.ConditionType("ServiceAccountSecretsSyncReady"),
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		LastHeartbeatTime:  metav1.Now(),
+		Reason:             string(userv1.Ready),
+		Message:            "service account secrets are revoked since users login by the OIDC provider",
+	}
+	condition := helper.GetCondition(user.Status.Conditions, secretsCondition)
+	defer func() {
+		if helper.DiffCondition(condition, secretsCondition) {
+			r.saveCondition(user, secretsCondition.DeepCopy())
+		}
+	}()
+	sa, ok := ctx.Value(ctxKey("serviceAccount")).(*v1.ServiceAccount)
+	if !ok {
+		helper.SetConditionError(secretsCondition, "SyncUserError", fmt.Errorf("revokeServiceAccountSecrets serviceAccount not found"))
+		return ctx
+	}
+	for _, ref := range sa.Secrets {
+		secret := &v1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: sa.Namespace, Name: ref.Name}, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			helper.SetConditionError(secretsCondition, "SyncUserError", fmt.Errorf("unable to get sa secret %s: %w", ref.Name, err))
+			return ctx
+		}
+		if secret.Type != v1.SecretTypeServiceAccountToken || secret.Annotations[v1.ServiceAccountNameKey] != sa.Name {
+			continue
+		}
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			helper.SetConditionError(secretsCondition, "SyncUserError", fmt.Errorf("unable to revoke sa secret %s: %w", ref.Name, err))
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "revokeServiceAccountSecrets", "Revoke User sa secret %s is error: %v", ref.Name, err)
+			return ctx
+		}
+		r.Recorder.Eventf(user, v1.EventTypeNormal, "revokeServiceAccountSecrets", "User sa secret %s is revoked since users login by the OIDC provider", ref.Name)
+	}
+	return ctx
+}
+
 func (r *UserReconciler) syncKubeConfig(ctx context.Context, user *userv1.User) context.Context {
 	userConditionType := userv1.ConditionType("KubeConfigSyncReady")
 	userCondition := &userv1.Condition{
@@ -520,14 +726,20 @@ func (r *UserReconciler) syncKubeConfig(ctx context.Context, user *userv1.User)
 			r.saveCondition(user, userCondition.DeepCopy())
 		}
 	}()
-	sa, ok := ctx.Value(ctxKey("serviceAccount")).(*v1.ServiceAccount)
-	if !ok {
-		helper.SetConditionError(userCondition, "SyncUserError", fmt.Errorf("serviceAccount not found"))
-		r.Recorder.Eventf(user, v1.EventTypeWarning, "syncKubeConfig", "Sync User namespace  kubeconfig %s is error: %v", user.Name, "serviceAccount not found")
-		return ctx
+	var cfg kubeconfig.Interface
+	if oidc := config.GetOIDC(); oidc != nil {
+		cfg = kubeconfig.NewConfig(user.Name, "", user.Spec.CSRExpirationSeconds).
+			WithOIDCConfig(config.GetUsersNamespace(user.Name), oidc.IssuerURL, oidc.ClientID, oidc.ExtraScopes)
+	} else {
+		sa, ok := ctx.Value(ctxKey("serviceAccount")).(*v1.ServiceAccount)
+		if !ok {
+			helper.SetConditionError(userCondition, "SyncUserError", fmt.Errorf("serviceAccount not found"))
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "syncKubeConfig", "Sync User namespace  kubeconfig %s is error: %v", user.Name, "serviceAccount not found")
+			return ctx
+		}
+		cfg = kubeconfig.NewConfig(user.Name, "", user.Spec.CSRExpirationSeconds).WithServiceAccountConfig(config.GetUserSystemNamespace(), sa)
 	}
 	user.Status.ObservedCSRExpirationSeconds = user.Spec.CSRExpirationSeconds
-	cfg := kubeconfig.NewConfig(user.Name, "", user.Spec.CSRExpirationSeconds).WithServiceAccountConfig(config.GetUserSystemNamespace(), sa)
 	apiConfig, err := cfg.Apply(r.config, r.Client)
 	if err != nil {
 		helper.SetConditionError(userCondition, "SyncKubeConfigError", err)
@@ -545,6 +757,20 @@ func (r *UserReconciler) syncKubeConfig(ctx context.Context, user *userv1.User)
 		r.Recorder.Eventf(user, v1.EventTypeWarning, "syncKubeConfig", "Output KubeConfig apply %s is error: %v", user.Name, err)
 		return ctx
 	}
+	if previous := user.Status.KubeConfig; previous != string(kubeData) {
+		record := audit.Record{
+			Action:    audit.ActionRenewKubeConfig,
+			User:      user.Name,
+			Namespace: config.GetUsersNamespace(user.Name),
+			After:     hash.HashToString(string(kubeData)),
+		}
+		if previous != "" {
+			record.Before = hash.HashToString(previous)
+		}
+		if err := r.AuditSink.Write(ctx, record.Complete(nil)); err != nil {
+			r.Logger.Error(err, "failed to write audit record", "user", user.Name)
+		}
+	}
 	user.Status.KubeConfig = string(kubeData)
 	userCondition.Message = fmt.Sprintf("renew sync kube config successfully hash %s", hash.HashToString(user.Status.KubeConfig))
 	return ctx
@@ -622,6 +848,12 @@ func (r *UserReconciler) updateStatus(ctx context.Context, nn types.NamespacedNa
 		if err := r.Get(ctx, nn, original); err != nil {
 			return err
 		}
+		// the Expired condition is owned by the expiration controller
+		expired := helper.GetCondition(original.Status.Conditions, &userv1.Condition{Type: userv1.Expired})
+		status.Conditions = helper.DeleteCondition(status.Conditions, userv1.Expired)
+		if expired.Status != "" {
+			status.Conditions = helper.UpdateCondition(status.Conditions, *expired)
+		}
 		original.Status = *status
 		return r.Client.Status().Update(ctx, original)
 	})
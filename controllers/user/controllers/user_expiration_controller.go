@@ -19,19 +19,37 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	userv1 "github.com/labring/sealos/controllers/user/api/v1"
+	"github.com/labring/sealos/controllers/user/controllers/helper"
+	"github.com/labring/sealos/controllers/user/controllers/helper/config"
 	"github.com/labring/sealos/controllers/user/controllers/helper/finalizer"
 )
 
-// UserExpirationReconciler reconciles a Secret object
+// UserExpirationReconciler enforces the expiration of users
 type UserExpirationReconciler struct {
 	Logger   logr.Logger
 	Recorder record.EventRecorder
@@ -87,11 +105,341 @@ func (r *UserExpirationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.config = mgr.GetConfig()
 	r.Logger.V(1).Info("init reconcile controller user expiration")
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&userv1.User{}).
+		Named(controllerName).
+		For(&userv1.User{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
 
-func (r *UserExpirationReconciler) reconcile(_ context.Context, _ client.Object) (ctrl.Result, error) {
-	//TODO add  Expiration logic
-	return ctrl.Result{}, nil
+const (
+	// expirationReplicasAnnotation keeps the replicas of a workload scaled down by the expiration
+	expirationReplicasAnnotation = "user.sealos.io/expiration-replicas"
+	expirationNotificationPrefix = "user-expiration-"
+	expirationDeleteRequestName  = "user-expiration-%s"
+	userStatusLabel              = "user.sealos.io/status"
+	userStatusDeleted            = "Deleted"
+
+	// expirationDeleteRequestRecheck is how often the delete request of an expired user is checked
+	expirationDeleteRequestRecheck = time.Minute
+)
+
+var notificationGVK = schema.GroupVersionKind{Group: "notification.sealos.io", Version: "v1", Kind: "Notification"}
+
+// reconcile enforces the expiration of user: the kubeconfig is revoked when it expires, workloads are scaled
+// down by the Suspend and Delete policies, and a DeleteRequest is created after the grace period of Delete.
+// The user is notified before each transition, and an expired user is restored once its expiration is renewed.
+func (r *UserExpirationReconciler) reconcile(ctx context.Context, obj client.Object) (ctrl.Result, error) {
+	user, ok := obj.(*userv1.User)
+	if !ok {
+		return ctrl.Result{}, errors.New("obj convert user is error")
+	}
+	plan := helper.GetExpirationPlan(user.Spec.Expiration, time.Now())
+	r.Logger.V(1).Info("reconcile user expiration", "user", user.Name, "stage", plan.Stage, "nextStage", plan.NextStage, "nextTime", plan.NextTime)
+
+	if plan.Stage == helper.ExpirationStageActive {
+		if helper.IsConditionTrue(user.Status.Conditions, userv1.Condition{Type: userv1.Expired, Status: v1.ConditionTrue}) {
+			if err := r.renew(ctx, user); err != nil {
+				r.Recorder.Eventf(user, v1.EventTypeWarning, "RenewUser", "Renew expired user %s is error: %v", user.Name, err)
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(user, v1.EventTypeNormal, "RenewUser", "User %s is renewed", user.Name)
+		}
+	} else {
+		if err := r.expire(ctx, user); err != nil {
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "ExpireUser", "Expire user %s is error: %v", user.Name, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if plan.Stage == helper.ExpirationStageDeleting {
+		if err := r.createDeleteRequest(ctx, user); err != nil {
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "DeleteExpiredUser", "Create delete request of user %s is error: %v", user.Name, err)
+			return ctrl.Result{}, err
+		}
+		// check the request again until the user is deleted, a failed request is replaced
+		return ctrl.Result{RequeueAfter: expirationDeleteRequestRecheck}, nil
+	}
+	if plan.Notify {
+		if err := r.notify(ctx, user, plan); err != nil {
+			r.Recorder.Eventf(user, v1.EventTypeWarning, "NotifyUserExpiration", "Notify user %s is error: %v", user.Name, err)
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: plan.RequeueAfter}, nil
+}
+
+// expire revokes the kubeconfig of user and suspends its workloads by the policy, it is idempotent
+func (r *UserExpirationReconciler) expire(ctx context.Context, user *userv1.User) error {
+	// the token of the kubeconfig is invalidated with its secret, user controller does not renew it for expired users
+	sa := &v1.ServiceAccount{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: config.GetUserSystemNamespace(), Name: user.Name}, sa); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("unable to get service account: %w", err)
+	}
+	for _, ref := range sa.Secrets {
+		secret := &v1.Secret{}
+		secret.Name = ref.Name
+		secret.Namespace = config.GetUserSystemNamespace()
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("unable to revoke kubeconfig: %w", err)
+		}
+	}
+	// the id token of an OIDC user is valid until it expires at the provider, so the user is unbound instead
+	if err := r.unbindOIDCUser(ctx, user); err != nil {
+		return fmt.Errorf("unable to unbind oidc user: %w", err)
+	}
+
+	policy := user.Spec.Expiration.Policy
+	reason, message := "KubeconfigRevoked", "user is expired and its kubeconfig is revoked"
+	if policy == userv1.ExpirationPolicySuspend || policy == userv1.ExpirationPolicyDelete {
+		if err := r.scaleWorkloads(ctx, config.GetUsersNamespace(user.Name), true); err != nil {
+			return fmt.Errorf("unable to suspend workloads: %w", err)
+		}
+		reason, message = "Suspended", "user is expired, its kubeconfig is revoked and its workloads are scaled down"
+	}
+	if policy == userv1.ExpirationPolicyDelete {
+		message = fmt.Sprintf("%s, it is deleted at %s", message, user.Spec.Expiration.Time.Add(user.Spec.Expiration.GracePeriod.Duration).Format(time.RFC3339))
+	}
+	return r.updateExpiredCondition(ctx, user, &userv1.Condition{
+		Type:               userv1.Expired,
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		LastHeartbeatTime:  metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// renew restores the workloads of a renewed user, its kubeconfig is issued again by user controller
+func (r *UserExpirationReconciler) renew(ctx context.Context, user *userv1.User) error {
+	if err := r.scaleWorkloads(ctx, config.GetUsersNamespace(user.Name), false); err != nil {
+		return fmt.Errorf("unable to resume workloads: %w", err)
+	}
+	if err := r.rebindOIDCUser(ctx, user); err != nil {
+		return fmt.Errorf("unable to rebind oidc user: %w", err)
+	}
+	return r.updateExpiredCondition(ctx, user, nil)
+}
+
+// unbindOIDCUser removes the OIDC user of user from all role bindings, and removes the group role bindings of its
+// namespace. The groups of the user in other workspaces are granted by the provider and must be removed there.
+func (r *UserExpirationReconciler) unbindOIDCUser(ctx context.Context, user *userv1.User) error {
+	subject, ok := config.GetOIDCUserSubject(user.Name)
+	if !ok {
+		return nil
+	}
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		roleBinding := &roleBindings.Items[i]
+		subjects := slices.DeleteFunc(slices.Clone(roleBinding.Subjects), func(s rbacv1.Subject) bool {
+			return s.Kind == subject.Kind && s.Name == subject.Name
+		})
+		if len(subjects) == len(roleBinding.Subjects) {
+			continue
+		}
+		roleBinding.Subjects = subjects
+		if err := r.Update(ctx, roleBinding); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return client.IgnoreNotFound(r.DeleteAllOf(ctx, &rbacv1.RoleBinding{},
+		client.InNamespace(config.GetUsersNamespace(user.Name)), client.HasLabels{oidcGroupRoleLabelKey}))
+}
+
+// rebindOIDCUser binds the OIDC user of a renewed user again in the workspaces it is granted by operation requests,
+// the role binding and group role bindings of its own namespace are restored by user controller
+func (r *UserExpirationReconciler) rebindOIDCUser(ctx context.Context, user *userv1.User) error {
+	subject, ok := config.GetOIDCUserSubject(user.Name)
+	if !ok {
+		return nil
+	}
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.MatchingLabels{userLabelOwnerKey: user.Name}); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		roleBinding := &roleBindings.Items[i]
+		if slices.ContainsFunc(roleBinding.Subjects, func(s rbacv1.Subject) bool {
+			return s.Kind == subject.Kind && s.Name == subject.Name
+		}) {
+			continue
+		}
+		roleBinding.Subjects = append(roleBinding.Subjects, subject)
+		if err := r.Update(ctx, roleBinding); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateExpiredCondition sets the Expired condition of user, or removes it if condition is nil
+func (r *UserExpirationReconciler) updateExpiredCondition(ctx context.Context, user *userv1.User, condition *userv1.Condition) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &userv1.User{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(user), latest); err != nil {
+			return err
+		}
+		if condition == nil {
+			latest.Status.Conditions = helper.DeleteCondition(latest.Status.Conditions, userv1.Expired)
+		} else {
+			if !helper.DiffCondition(helper.GetCondition(latest.Status.Conditions, &userv1.Condition{Type: userv1.Expired}), condition) {
+				return nil
+			}
+			latest.Status.Conditions = helper.UpdateCondition(latest.Status.Conditions, *condition)
+			latest.Status.Phase = userv1.UserExpired
+			latest.Status.KubeConfig = ""
+		}
+		return r.Status().Update(ctx, latest)
+	})
+}
+
+// scaleWorkloads scales the deployments and statefulsets in namespace down to zero and keeps their replicas
+// in an annotation, or scales them back to the kept replicas
+func (r *UserExpirationReconciler) scaleWorkloads(ctx context.Context, namespace string, down bool) error {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if err := r.scaleWorkload(ctx, deployment, &deployment.Spec.Replicas, down); err != nil {
+			return err
+		}
+	}
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := r.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	for i := range statefulSets.Items {
+		statefulSet := &statefulSets.Items[i]
+		if err := r.scaleWorkload(ctx, statefulSet, &statefulSet.Spec.Replicas, down); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *UserExpirationReconciler) scaleWorkload(ctx context.Context, obj client.Object, replicas **int32, down bool) error {
+	annotations := obj.GetAnnotations()
+	kept, scaled := annotations[expirationReplicasAnnotation]
+	if down == scaled {
+		return nil
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if down {
+		current := int32(1)
+		if *replicas != nil {
+			current = **replicas
+		}
+		annotations[expirationReplicasAnnotation] = strconv.Itoa(int(current))
+		*replicas = ptr.To(int32(0))
+	} else {
+		restored, err := strconv.Atoi(kept)
+		if err != nil {
+			return fmt.Errorf("invalid replicas %q of %s: %w", kept, obj.GetName(), err)
+		}
+		delete(annotations, expirationReplicasAnnotation)
+		*replicas = ptr.To(int32(restored))
+	}
+	obj.SetAnnotations(annotations)
+	return r.Update(ctx, obj)
+}
+
+// createDeleteRequest marks user deleted and requests its deletion, the request deletes the user and its namespace.
+// A failed request is kept for its retention time, so it is replaced by a new one to retry the deletion.
+func (r *UserExpirationReconciler) createDeleteRequest(ctx context.Context, user *userv1.User) error {
+	if user.Labels[userStatusLabel] != userStatusDeleted {
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest := &userv1.User{}
+			if err := r.Get(ctx, client.ObjectKeyFromObject(user), latest); err != nil {
+				return err
+			}
+			if latest.Labels == nil {
+				latest.Labels = make(map[string]string)
+			}
+			latest.Labels[userStatusLabel] = userStatusDeleted
+			return r.Update(ctx, latest)
+		}); err != nil {
+			return err
+		}
+	}
+	workspacePolicy := user.Spec.Expiration.WorkspacePolicy
+	if workspacePolicy == "" {
+		workspacePolicy = userv1.WorkspacePolicyTransfer
+	}
+	request := &userv1.DeleteRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf(expirationDeleteRequestName, user.Name)},
+		Spec:       userv1.DeleteRequestSpec{User: user.Name, WorkspacePolicy: workspacePolicy},
+	}
+	if err := r.Create(ctx, request); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		existing := &userv1.DeleteRequest{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(request), existing); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if existing.Status.Phase != userv1.RequestFailed {
+			return nil
+		}
+		if err := r.Delete(ctx, existing, client.Preconditions{UID: &existing.UID}); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.Recorder.Eventf(user, v1.EventTypeWarning, "DeleteExpiredUser", "Delete request %s of expired user %s is failed, it is created again", request.Name, user.Name)
+		if err := r.Create(ctx, request); err != nil {
+			// the failed request is not gone yet, it is replaced next time
+			if apierrors.IsAlreadyExists(err) {
+				return nil
+			}
+			return err
+		}
+	}
+	r.Logger.Info("create delete request for expired user", "user", user.Name, "request", request.Name)
+	r.Recorder.Eventf(user, v1.EventTypeNormal, "DeleteExpiredUser", "Delete request %s is created for expired user %s", request.Name, user.Name)
+	return nil
+}
+
+// notify sends a notification to the namespace of user before it transitions to the next stage,
+// the notification is sent once for each transition
+func (r *UserExpirationReconciler) notify(ctx context.Context, user *userv1.User, plan helper.ExpirationPlan) error {
+	var title, message string
+	switch plan.NextStage {
+	case helper.ExpirationStageExpired:
+		title = "Your account is about to expire"
+		message = fmt.Sprintf("Your account expires at %s, its kubeconfig will be revoked", plan.NextTime.Format(time.RFC3339))
+		if user.Spec.Expiration.Policy != userv1.ExpirationPolicyRevoke {
+			message += " and its workloads will be scaled down"
+		}
+	case helper.ExpirationStageDeleting:
+		title = "Your account is about to be deleted"
+		message = fmt.Sprintf("Your account is expired and will be deleted with all its resources at %s", plan.NextTime.Format(time.RFC3339))
+	default:
+		return nil
+	}
+	ntf := &unstructured.Unstructured{}
+	ntf.SetGroupVersionKind(notificationGVK)
+	ntf.SetName(fmt.Sprintf("%s%s-%d", expirationNotificationPrefix, strings.ToLower(string(plan.NextStage)), plan.NextTime.Unix()))
+	ntf.SetNamespace(config.GetUsersNamespace(user.Name))
+	ntf.SetLabels(map[string]string{"isRead": "false"})
+	if err := unstructured.SetNestedMap(ntf.Object, map[string]interface{}{
+		"title":        title,
+		"message":      message,
+		"from":         "User-System",
+		"importance":   "High",
+		"desktopPopup": true,
+		"timestamp":    time.Now().Unix(),
+	}, "spec"); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, ntf); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	r.Recorder.Eventf(user, v1.EventTypeNormal, "NotifyUserExpiration", "User %s is notified: %s", user.Name, message)
+	return nil
 }
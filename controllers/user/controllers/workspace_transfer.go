@@ -0,0 +1,149 @@
+/*
+Copyright 2022 labring.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/labring/sealos/controllers/user/api/v1"
+	"github.com/labring/sealos/controllers/user/controllers/helper/config"
+	"github.com/labring/sealos/controllers/user/controllers/helper/database"
+)
+
+const (
+	userTypeLabel = "user.sealos.io/type"
+	userTypeGroup = "Group"
+)
+
+// transferWorkspace makes user to the owner of the group workspace in namespace: the Owner role binding of
+// user is created, the previous owner is downgraded to Manager, the owner annotations of the workspace are
+// updated and the billing owner is moved in the account database if store is set. It returns the previous owner.
+func transferWorkspace(ctx context.Context, c client.Client, scheme *runtime.Scheme, store database.WorkspaceOwnerStore, namespace, to string) (string, error) {
+	workspace := &userv1.User{}
+	if err := c.Get(ctx, client.ObjectKey{Name: config.GetUserNameByNamespace(namespace)}, workspace); err != nil {
+		return "", fmt.Errorf("unable to get workspace %s: %w", namespace, err)
+	}
+	if !isGroupUser(*workspace) {
+		return "", fmt.Errorf("workspace %s is not a group workspace", namespace)
+	}
+	newOwner := &userv1.User{}
+	if err := c.Get(ctx, client.ObjectKey{Name: to}, newOwner); err != nil {
+		return "", fmt.Errorf("unable to get user %s: %w", to, err)
+	}
+	if isUserDeleted(*newOwner) {
+		return "", fmt.Errorf("user %s is deleted", to)
+	}
+	from := workspace.Annotations[userAnnotationOwnerKey]
+
+	if err := applyRoleBinding(ctx, c, scheme, newWorkspaceRoleBinding(namespace, to, userv1.OwnerRoleType), newOwner); err != nil {
+		return "", fmt.Errorf("unable to grant owner of workspace %s to %s: %w", namespace, to, err)
+	}
+	// the billing owner is moved before the annotations, so that a failed transfer is retried with the same previous owner
+	if store != nil {
+		if err := store.TransferWorkspaceOwner(ctx, namespace, from, to); err != nil {
+			return "", err
+		}
+	}
+	if from != "" && from != to {
+		if err := downgradeWorkspaceOwner(ctx, c, scheme, namespace, from); err != nil {
+			return "", fmt.Errorf("unable to downgrade owner %s of workspace %s: %w", from, namespace, err)
+		}
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(workspace), workspace); err != nil {
+			return err
+		}
+		if workspace.Annotations == nil {
+			workspace.Annotations = make(map[string]string)
+		}
+		workspace.Annotations[userAnnotationOwnerKey] = to
+		return c.Update(ctx, workspace)
+	}); err != nil {
+		return "", fmt.Errorf("unable to update owner of workspace %s: %w", namespace, err)
+	}
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ns := &v1.Namespace{}
+		if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = make(map[string]string)
+		}
+		if ns.Labels == nil {
+			ns.Labels = make(map[string]string)
+		}
+		ns.Annotations[userAnnotationOwnerKey] = to
+		ns.Labels[userLabelOwnerKey] = to
+		return c.Update(ctx, ns)
+	}); err != nil {
+		return "", fmt.Errorf("unable to update owner of namespace %s: %w", namespace, err)
+	}
+	return from, nil
+}
+
+// downgradeWorkspaceOwner replaces the Owner role binding of user in namespace with a Manager one,
+// nothing is done if the user or its role binding is gone
+func downgradeWorkspaceOwner(ctx context.Context, c client.Client, scheme *runtime.Scheme, namespace, user string) error {
+	owner := &userv1.User{}
+	if err := c.Get(ctx, client.ObjectKey{Name: user}, owner); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	rolebinding := newWorkspaceRoleBinding(namespace, user, userv1.ManagerRoleType)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(rolebinding), &rbacv1.RoleBinding{}); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return applyRoleBinding(ctx, c, scheme, rolebinding, owner)
+}
+
+func newWorkspaceRoleBinding(namespace, user string, role userv1.RoleType) *rbacv1.RoleBinding {
+	return conventRequestToRolebinding(&userv1.Operationrequest{
+		Spec: userv1.OperationrequestSpec{
+			Namespace: namespace,
+			User:      user,
+			Role:      role,
+		},
+	})
+}
+
+// applyRoleBinding creates or updates rolebinding owned by bindUser, an existing binding of another role is replaced
+func applyRoleBinding(ctx context.Context, c client.Client, scheme *runtime.Scheme, rolebinding *rbacv1.RoleBinding, bindUser *userv1.User) error {
+	existing := &rbacv1.RoleBinding{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(rolebinding), existing); err == nil {
+		if existing.RoleRef != rolebinding.RoleRef {
+			// roleRef of a role binding is immutable
+			if err := c.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	_, err := ctrl.CreateOrUpdate(ctx, c, rolebinding, func() error {
+		return ctrl.SetControllerReference(bindUser, rolebinding, scheme)
+	})
+	return err
+}
@@ -18,7 +18,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
@@ -39,7 +41,10 @@ import (
 
 	userv1 "github.com/labring/sealos/controllers/user/api/v1"
 	"github.com/labring/sealos/controllers/user/controllers"
+	"github.com/labring/sealos/controllers/user/controllers/helper/audit"
 	configpkg "github.com/labring/sealos/controllers/user/controllers/helper/config"
+	"github.com/labring/sealos/controllers/user/controllers/helper/database"
+	"github.com/labring/sealos/controllers/user/controllers/helper/oidc"
 	ratelimiter "github.com/labring/sealos/controllers/user/controllers/helper/ratelimiter"
 	//+kubebuilder:scaffold:imports
 )
@@ -138,10 +143,47 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.UserReconciler{}).SetupWithManager(mgr, rateLimiterOptions, minRequeueDuration, maxRequeueDuration, restartPredicateDuration); err != nil {
+	if config.OIDC.Enabled {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, err := oidc.Discover(ctx, config.OIDC.IssuerURL)
+		cancel()
+		if err != nil {
+			setupLog.Error(err, "unable to discover OIDC issuer", "issuer", config.OIDC.IssuerURL)
+			os.Exit(1)
+		}
+		setupLog.Info("users login by OIDC issuer", "issuer", config.OIDC.IssuerURL)
+	}
+
+	var (
+		accountDB           *database.Cockroach
+		workspaceOwnerStore database.WorkspaceOwnerStore
+	)
+	if uri := config.Database.RegionalCockroachdbURI; uri != "" {
+		accountDB, err = database.NewCockroach(uri)
+		if err != nil {
+			setupLog.Error(err, "unable to connect account database")
+			os.Exit(1)
+		}
+		workspaceOwnerStore = accountDB
+	} else {
+		setupLog.Info("account database is not configured, the billing owner of workspaces is not transferred")
+	}
+	auditSink, err := newAuditSink(config.Audit, accountDB)
+	if err != nil {
+		setupLog.Error(err, "unable to create audit sink", "sink", config.Audit.Sink)
+		os.Exit(1)
+	}
+
+	if err = (&controllers.UserReconciler{
+		AuditSink: auditSink,
+	}).SetupWithManager(mgr, rateLimiterOptions, minRequeueDuration, maxRequeueDuration, restartPredicateDuration); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "User")
 		os.Exit(1)
 	}
+	if err = (&controllers.UserExpirationReconciler{}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "UserExpiration")
+		os.Exit(1)
+	}
 
 	if os.Getenv("DISABLE_WEBHOOKS") == "true" {
 		setupLog.Info("disable all webhooks")
@@ -152,17 +194,30 @@ func main() {
 		}
 	}
 
-	if err = (&controllers.OperationReqReconciler{}).SetupWithManager(mgr, rateLimiterOptions, operationReqExpirationTime, operationReqRetentionTime); err != nil {
+	if err = (&controllers.OperationReqReconciler{
+		WorkspaceOwnerStore: workspaceOwnerStore,
+		AuditSink:           auditSink,
+	}).SetupWithManager(mgr, rateLimiterOptions, operationReqExpirationTime, operationReqRetentionTime); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Operationrequest")
 		os.Exit(1)
 	}
-	if err = (&userv1.Operationrequest{}).SetupWebhookWithManager(mgr); err != nil {
+	if err = (&userv1.Operationrequest{}).SetupWebhookWithManager(mgr, configpkg.GetUsersSubject); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Operationrequest")
 		os.Exit(1)
 	}
+	if err = (&userv1.Invitation{}).SetupWebhookWithManager(mgr, configpkg.GetUsersSubject); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Invitation")
+		os.Exit(1)
+	}
+	if err = (&controllers.InvitationReconciler{}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Invitation")
+		os.Exit(1)
+	}
 	if err = (&controllers.DeleteRequestReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		WorkspaceOwnerStore: workspaceOwnerStore,
+		AuditSink:           auditSink,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DeleteRequest")
 		os.Exit(1)
@@ -197,5 +252,37 @@ func setConfigToEnv(cfg configpkg.Config) error {
 	if err := os.Setenv("SEALOS_CLOUD_HOST", cfg.Global.CloudDomain); err != nil {
 		return err
 	}
+	for k, v := range cfg.OIDC.Env() {
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
 	return os.Setenv("APISERVER_PORT", cfg.Kube.APIServerPort)
 }
+
+// newAuditSink returns the sink of the audit records configured by cfg, the database sink writes to the account database.
+// The records are written to the log if no sink is configured, and the writes of all sinks are counted in a metric.
+func newAuditSink(cfg configpkg.Audit, accountDB *database.Cockroach) (audit.Sink, error) {
+	switch cfg.Sink {
+	case "", configpkg.AuditSinkLog:
+		return audit.MeteredSink{Sink: audit.LogSink{Logger: ctrl.Log.WithName("audit")}}, nil
+	case configpkg.AuditSinkNone:
+		return audit.NopSink{}, nil
+	case configpkg.AuditSinkFile:
+		sink, err := audit.NewFileSink(cfg.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		return audit.MeteredSink{Sink: sink}, nil
+	case configpkg.AuditSinkDatabase:
+		if accountDB == nil {
+			return nil, errors.New("audit sink database requires the regionalCockroachdbURI of database")
+		}
+		if err := accountDB.InitAuditTable(); err != nil {
+			return nil, err
+		}
+		return audit.MeteredSink{Sink: accountDB}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %s", cfg.Sink)
+	}
+}
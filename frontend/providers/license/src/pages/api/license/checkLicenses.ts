@@ -8,7 +8,16 @@ import { formatTimeToDay } from '@/utils/tools';
 import * as k8s from '@kubernetes/client-node';
 import type { NextApiRequest, NextApiResponse } from 'next';
 
-const createLicenseNotification = (licenses: LicenseCR[], timeUntilExpiration: number) => {
+const isDegraded = (license: LicenseCR) =>
+  !!license.status.conditions?.some(
+    (condition) => condition.type === 'Degraded' && condition.status === 'True'
+  );
+
+const createLicenseNotification = (
+  licenses: LicenseCR[],
+  timeUntilExpiration: number,
+  degraded = false
+) => {
   if (licenses.length === 0) {
     return json2Notification({
       namespace: 'sealos',
@@ -31,6 +40,27 @@ const createLicenseNotification = (licenses: LicenseCR[], timeUntilExpiration: n
 
   const daysUntilExpiration = Math.ceil(timeUntilExpiration / (24 * 60 * 60));
 
+  if (degraded) {
+    return json2Notification({
+      namespace: 'sealos',
+      name: `license-notification-${Date.now()}`,
+      desktopPopup: true,
+      i18ns: {
+        zh: {
+          from: 'License',
+          title: '许可证处于宽限期',
+          message: '您的许可证已过期，在宽限期内部分功能受限。请在宽限期结束前续期。'
+        },
+        en: {
+          from: 'License',
+          title: 'License In Grace Period',
+          message:
+            'Your license has expired and some features are degraded during its grace period. Please renew it before the grace period ends.'
+        }
+      }
+    });
+  }
+
   if (timeUntilExpiration <= 0) {
     return json2Notification({
       namespace: 'sealos',
@@ -133,7 +163,10 @@ export default async function handler(req: NextApiRequest, res: NextApiResponse<
         items: LicenseCR[];
       };
     };
-    const licenses = response.body.items.filter((item) => item.status.phase === 'Active');
+    // a license in its grace period still counts, but its features are degraded
+    const licenses = response.body.items.filter(
+      (item) => item.status?.phase === 'Active' || item.status?.phase === 'GracePeriod'
+    );
 
     // Delete Old Notifications
     const notifications = await listNotifications('sealos');
@@ -165,8 +198,9 @@ export default async function handler(req: NextApiRequest, res: NextApiResponse<
     const now = Math.floor(Date.now() / 1000); // current time in seconds
     const timeUntilExpiration = maxExpTime - now; // time until expiration in seconds
     const isExpired = timeUntilExpiration <= 0;
+    const degraded = licenses.some(isDegraded);
 
-    const notification = createLicenseNotification(licenses, timeUntilExpiration);
+    const notification = createLicenseNotification(licenses, timeUntilExpiration, degraded);
     notification && (await createYaml(defaultKc, [notification]));
 
     jsonRes(res, {
@@ -178,6 +212,7 @@ export default async function handler(req: NextApiRequest, res: NextApiResponse<
           timeUntilExpiration: formatTimeToDay(timeUntilExpiration),
           isExpired: isExpired
         },
+        isDegraded: degraded,
         totalActiveLicenses: licenses.length
       }
     });
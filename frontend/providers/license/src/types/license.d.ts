@@ -62,8 +62,15 @@ export type LicenseCR = {
   status: {
     activationTime: string;
     expirationTime: string;
-    phase: 'Active' | 'Failed';
+    phase: 'Pending' | 'Active' | 'Failed' | 'GracePeriod';
     reason: string;
+    gracePeriodEndTime?: string;
+    conditions?: {
+      type: string;
+      status: 'True' | 'False' | 'Unknown';
+      reason: string;
+      message: string;
+    }[];
   };
 };
 
@@ -0,0 +1,79 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/labring/sealos/pkg/host"
+)
+
+var examplePrepare = `
+install missing packages from the offline package set and apply kernel settings:
+	sealctl host prepare --packages-dir /var/lib/sealos/data/default/rootfs/packages
+only validate the prerequisites, fail if any of them is not satisfied:
+	sealctl host prepare --check --strict
+`
+
+func newHostCmd() *cobra.Command {
+	var hostCmd = &cobra.Command{
+		Use:   "host",
+		Short: "host prerequisites management",
+	}
+	hostCmd.AddCommand(newHostPrepareCmd())
+	return hostCmd
+}
+
+func newHostPrepareCmd() *cobra.Command {
+	opts := host.DefaultOptions()
+	var sysctls map[string]string
+	var prepareCmd = &cobra.Command{
+		Use:          "prepare",
+		Short:        "install or validate packages, kernel modules and sysctl settings required by kubernetes",
+		Example:      examplePrepare,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for k, v := range sysctls {
+				opts.Sysctls[k] = v
+			}
+			report, err := host.NewPreparer(opts).Prepare()
+			if report != nil {
+				printPrepareReport(report)
+			}
+			return err
+		},
+	}
+	prepareCmd.Flags().StringVar(&opts.PackagesDir, "packages-dir", "", "dir of offline packages, laid out as <dir>/<debian|rhel>/<arch>/")
+	prepareCmd.Flags().StringSliceVar(&opts.Binaries, "binaries", opts.Binaries, "binaries that must be present on host")
+	prepareCmd.Flags().StringSliceVar(&opts.Modules, "modules", opts.Modules, "kernel modules to load and persist in modules-load.d")
+	prepareCmd.Flags().StringToStringVar(&sysctls, "sysctl", nil, "extra sysctl settings to apply and persist in sysctl.d, eg. vm.max_map_count=262144")
+	prepareCmd.Flags().BoolVar(&opts.CheckOnly, "check", false, "only validate the host, do not change anything")
+	prepareCmd.Flags().BoolVar(&opts.Strict, "strict", false, "fail if any prerequisite is not satisfied")
+	return prepareCmd
+}
+
+func printPrepareReport(report *host.Report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "KIND\tITEM\tACTION\tDETAIL\n")
+	for _, c := range report.Changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Kind, c.Item, c.Action, c.Detail)
+	}
+	_ = w.Flush()
+}
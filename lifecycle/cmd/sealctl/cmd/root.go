@@ -71,6 +71,7 @@ func init() {
 			Message: "Machine Management Commands:",
 			Commands: []*cobra.Command{
 				newHostsNameCmd(),
+				newHostCmd(),
 				newInitSystemCmd(),
 			},
 		},
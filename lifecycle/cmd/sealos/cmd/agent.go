@@ -0,0 +1,60 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/labring/sealos/pkg/agent"
+)
+
+var exampleAgent = `
+run inside the cluster and reconcile Cluster resources in namespace sealos-system:
+	sealos agent --namespace sealos-system --ssh-secret sealos-ssh
+set SEALOS_STATE_PASSPHRASE to restore an encrypted state mirrored by "sealos cluster export --to-secret".
+`
+
+func newAgentCmd() *cobra.Command {
+	opts := &agent.Options{}
+	cmd := &cobra.Command{
+		Use:     "agent",
+		Short:   "Run as a controller that applies Cluster resources, for GitOps",
+		Example: exampleAgent,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.StatePassphrase = os.Getenv(passphraseEnvKey)
+			a, err := agent.New(opts)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer cancel()
+			return a.Run(ctx)
+		},
+	}
+	setRequireBuildahAnnotation(cmd)
+	cmd.Flags().StringVar(&opts.Kubeconfig, "kubeconfig", "", "path of kubeconfig, in-cluster config is used if empty")
+	cmd.Flags().StringVar(&opts.Namespace, "namespace", "", "namespace of Cluster resources to watch, all namespaces if empty")
+	cmd.Flags().StringVar(&opts.SSHSecret, "ssh-secret", "", "name of the secret holding ssh credentials, in the namespace of each Cluster resource")
+	cmd.Flags().DurationVar(&opts.ResyncPeriod, "resync-period", 10*time.Minute, "period to reconcile all Cluster resources again")
+	cmd.Flags().BoolVar(&opts.AllowMasterDelete, "allow-master-delete", false, "allow Cluster resources to remove masters from the cluster")
+	return cmd
+}
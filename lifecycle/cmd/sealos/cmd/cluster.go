@@ -0,0 +1,175 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/labring/sealos/pkg/client-go/kubernetes"
+	"github.com/labring/sealos/pkg/clusterstate"
+	"github.com/labring/sealos/pkg/constants"
+	"github.com/labring/sealos/pkg/utils/logger"
+)
+
+const passphraseEnvKey = "SEALOS_STATE_PASSPHRASE"
+
+var exampleClusterExport = `
+export the local state of the default cluster:
+	sealos cluster export -o state.tar
+encrypt certificates, tokens and the Clusterfile with a passphrase:
+	SEALOS_STATE_PASSPHRASE=xxx sealos cluster export -o state.tar
+also mirror the encrypted state into a secret inside the cluster:
+	SEALOS_STATE_PASSPHRASE=xxx sealos cluster export -o state.tar --to-secret
+`
+
+var exampleClusterImport = `
+restore the state from an archive:
+	sealos cluster import -f state.tar
+restore the state from the secret inside the cluster, run on any master:
+	sealos cluster import --from-secret --kubeconfig /etc/kubernetes/admin.conf -c default
+`
+
+func newClusterCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "cluster",
+		Short: "Backup and restore the local state of cluster",
+	}
+	cmd.AddCommand(newClusterExportCmd())
+	cmd.AddCommand(newClusterImportCmd())
+	return cmd
+}
+
+func newClusterExportCmd() *cobra.Command {
+	var (
+		output            string
+		toSecret          bool
+		insecurePlaintext bool
+	)
+	cmd := &cobra.Command{
+		Use:     "export",
+		Short:   "Export Clusterfile, runtime configs, pki, tokens and image list of cluster",
+		Example: exampleClusterExport,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" && !toSecret {
+				return errors.New("at least one of --output or --to-secret must be specified")
+			}
+			passphrase := os.Getenv(passphraseEnvKey)
+			if toSecret && passphrase == "" && !insecurePlaintext {
+				return fmt.Errorf("refusing to store pki, tokens and Clusterfile unencrypted in a secret, set %s or --insecure-plaintext", passphraseEnvKey)
+			}
+			buf := &bytes.Buffer{}
+			manifest, err := clusterstate.Export(clusterName, buf, clusterstate.Options{Passphrase: passphrase})
+			if err != nil {
+				return fmt.Errorf("failed to export cluster state: %v", err)
+			}
+			if output != "" {
+				if err = os.WriteFile(output, buf.Bytes(), 0600); err != nil {
+					return err
+				}
+				logger.Info("exported %d files of cluster %s to %s, encrypted: %v", len(manifest.Files), clusterName, output, manifest.Encrypted)
+			}
+			if toSecret {
+				if passphrase == "" {
+					logger.Warn("state is mirrored into secret without encryption")
+				}
+				client, err := kubernetes.NewKubernetesClient(constants.NewPathResolver(clusterName).AdminFile(), "")
+				if err != nil {
+					return err
+				}
+				if err = clusterstate.SaveToSecret(client.Kubernetes(), clusterName, buf.Bytes()); err != nil {
+					return err
+				}
+				logger.Info("mirrored state of cluster %s to secret %s/%s", clusterName, clusterstate.SecretNamespace, clusterstate.SecretName(clusterName))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&clusterName, "cluster", "c", "default", "name of cluster to export")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "path of the state archive to write")
+	cmd.Flags().BoolVar(&toSecret, "to-secret", false, fmt.Sprintf("mirror the state into secret %s/%s inside the cluster", clusterstate.SecretNamespace, clusterstate.SecretName("<cluster>")))
+	cmd.Flags().BoolVar(&insecurePlaintext, "insecure-plaintext", false, fmt.Sprintf("allow --to-secret to store the state unencrypted when %s is not set", passphraseEnvKey))
+	return cmd
+}
+
+func newClusterImportCmd() *cobra.Command {
+	var (
+		input      string
+		fromSecret bool
+		kubeconfig string
+		force      bool
+	)
+	cmd := &cobra.Command{
+		Use:     "import",
+		Short:   "Import the local state of cluster exported by `sealos cluster export`",
+		Example: exampleClusterImport,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				r   io.Reader
+				err error
+			)
+			switch {
+			case input != "" && fromSecret:
+				return errors.New("--file and --from-secret are mutually exclusive")
+			case input != "":
+				f, err := os.Open(input)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			case fromSecret:
+				client, err := kubernetes.NewKubernetesClient(kubeconfig, "")
+				if err != nil {
+					return err
+				}
+				data, err := clusterstate.LoadFromSecret(client.Kubernetes(), clusterName)
+				if err != nil {
+					return err
+				}
+				r = bytes.NewReader(data)
+			default:
+				return errors.New("one of --file or --from-secret must be specified")
+			}
+			manifest, err := clusterstate.Import(r, clusterstate.ImportOptions{
+				ClusterName: clusterName,
+				Passphrase:  os.Getenv(passphraseEnvKey),
+				Force:       force,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to import cluster state: %v", err)
+			}
+			logger.Info("imported %d files of cluster %s exported at %s by sealos %s",
+				len(manifest.Files), manifest.ClusterName, manifest.CreatedAt.Format("2006-01-02 15:04:05"), manifest.SealosVersion)
+			if len(manifest.Images) > 0 {
+				logger.Info("images used by cluster %s: %v", manifest.ClusterName, manifest.Images)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&clusterName, "cluster", "c", "default", "name of cluster to restore, must match the name stored in the state")
+	cmd.Flags().StringVarP(&input, "file", "f", "", "path of the state archive to read")
+	cmd.Flags().BoolVar(&fromSecret, "from-secret", false, "read the state from the secret inside the cluster")
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "path of kubeconfig used to read the secret, defaults to $HOME/.kube/config")
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite the local state if it already exists")
+	return cmd
+}
@@ -0,0 +1,177 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/labring/sealos/pkg/constants"
+	"github.com/labring/sealos/pkg/runtime"
+	"github.com/labring/sealos/pkg/runtime/factory"
+	"github.com/labring/sealos/pkg/utils/confirm"
+	"github.com/labring/sealos/pkg/utils/iputils"
+	"github.com/labring/sealos/pkg/utils/logger"
+	"github.com/labring/sealos/pkg/utils/yaml"
+)
+
+var exampleDrift = `
+show the drift of static pod manifests, kubelet config and containerd config on masters:
+	sealos drift
+take the live values on master 192.168.0.2 into the Clusterfile:
+	sealos drift --adopt --from 192.168.0.2
+overwrite the live values with the desired ones:
+	sealos drift --reapply
+`
+
+func newDriftCmd() *cobra.Command {
+	var (
+		adopt   bool
+		from    string
+		reapply bool
+		force   bool
+	)
+	var driftCmd = &cobra.Command{
+		Use:          "drift",
+		Short:        "detect configuration drift of control-plane components",
+		Example:      exampleDrift,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if adopt && reapply {
+				return errors.New("--adopt and --reapply cannot be used together")
+			}
+			cf, err := loadLocalClusterFile(clusterName)
+			if err != nil {
+				return err
+			}
+			cluster := cf.GetCluster()
+			rt, err := factory.New(cluster, cf.GetRuntimeConfig())
+			if err != nil {
+				return fmt.Errorf("create runtime failed: %v", err)
+			}
+			dm, ok := rt.(runtime.DriftManager)
+			if !ok {
+				return fmt.Errorf("drift detection is not supported by distribution %s", cluster.GetDistribution())
+			}
+			drifts, err := dm.DetectDrift()
+			if err != nil {
+				return err
+			}
+			if len(drifts) == 0 {
+				logger.Info("no drift found in cluster %s", cluster.Name)
+				return nil
+			}
+			printDrifts(drifts)
+
+			switch {
+			case adopt:
+				if from == "" {
+					from = cluster.GetMaster0IPAndPort()
+				}
+				if !confirmDrift(force, fmt.Sprintf("Are you sure to adopt the live values on %s into the Clusterfile?", from)) {
+					return nil
+				}
+				components, err := dm.AdoptDrift(hostWithPort(drifts, from), drifts)
+				if err != nil {
+					return err
+				}
+				objects := []interface{}{cluster}
+				objects = append(objects, components...)
+				for i := range cf.GetConfigs() {
+					objects = append(objects, cf.GetConfigs()[i])
+				}
+				if err = yaml.MarshalFile(constants.Clusterfile(cluster.Name), objects...); err != nil {
+					return err
+				}
+				logger.Info("succeeded in adopting live values into %s", constants.Clusterfile(cluster.Name))
+			case reapply:
+				if !confirmDrift(force, "Are you sure to overwrite the live values with the desired ones? Control-plane components will be restarted.") {
+					return nil
+				}
+				if err = dm.ReapplyDrift(drifts); err != nil {
+					return err
+				}
+				logger.Info("succeeded in reapplying desired config")
+			}
+			return nil
+		},
+	}
+	driftCmd.Flags().StringVarP(&clusterName, "cluster", "c", "default", "name of cluster to detect drift")
+	driftCmd.Flags().BoolVar(&adopt, "adopt", false, "take the live values into the Clusterfile and kubeadm configmaps")
+	driftCmd.Flags().StringVar(&from, "from", "", "master to adopt the live values from, default is master0")
+	driftCmd.Flags().BoolVar(&reapply, "reapply", false, "overwrite the live values with the desired ones")
+	driftCmd.Flags().BoolVar(&force, "force", false, "do not ask for confirmation")
+	return driftCmd
+}
+
+func confirmDrift(force bool, prompt string) bool {
+	if force {
+		return true
+	}
+	yes, err := confirm.Confirm(prompt, "you have canceled")
+	if err != nil {
+		logger.Error("%v", err)
+	}
+	return yes
+}
+
+// hostWithPort returns the host of drifts that matches host with or without ssh port.
+func hostWithPort(drifts []runtime.Drift, host string) string {
+	for _, d := range drifts {
+		if d.Host == host || iputils.GetHostIP(d.Host) == host {
+			return d.Host
+		}
+	}
+	return host
+}
+
+func printDrifts(drifts []runtime.Drift) {
+	value := func(s string) string {
+		if s == "" {
+			return "<none>"
+		}
+		return s
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "HOST\tCOMPONENT\tKEY\tDESIRED\tLIVE\n")
+	var files []runtime.Drift
+	for _, d := range drifts {
+		if strings.Contains(d.Desired, "\n") || strings.Contains(d.Live, "\n") {
+			files = append(files, d)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Host, d.Component, d.Key, "<file>", "<file>")
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Host, d.Component, d.Key, value(d.Desired), value(d.Live))
+	}
+	_ = w.Flush()
+	for _, d := range files {
+		diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(d.Desired),
+			B:        difflib.SplitLines(d.Live),
+			FromFile: "desired " + d.Key,
+			ToFile:   fmt.Sprintf("%s %s", d.Host, d.Key),
+			Context:  3,
+		})
+		fmt.Println()
+		fmt.Print(diff)
+	}
+}
@@ -25,13 +25,16 @@ import (
 
 	"github.com/labring/sealos/pkg/buildah"
 	"github.com/labring/sealos/pkg/constants"
+	"github.com/labring/sealos/pkg/events"
 	"github.com/labring/sealos/pkg/system"
 	"github.com/labring/sealos/pkg/utils/file"
 	"github.com/labring/sealos/pkg/utils/logger"
 )
 
 var (
-	debug bool
+	debug      bool
+	eventsFile string
+	eventsAddr string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -46,7 +49,9 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	events.Close()
+	if err != nil {
 		if rootCmd.SilenceErrors {
 			fmt.Println(err)
 		}
@@ -57,6 +62,8 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(onBootOnDie)
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug logger")
+	rootCmd.PersistentFlags().StringVar(&eventsFile, "events-file", "", "append structured step events to the file as JSON lines")
+	rootCmd.PersistentFlags().StringVar(&eventsAddr, "events-addr", "", "serve structured step events as server-sent events on a loopback address or unix socket, eg. 127.0.0.1:9090 or unix:/run/sealos-events.sock")
 	buildah.RegisterRootCommand(rootCmd)
 
 	groups := templates.CommandGroups{
@@ -68,6 +75,8 @@ func init() {
 				newRunCmd(),
 				newResetCmd(),
 				newStatusCmd(),
+				newClusterCmd(),
+				newDriftCmd(),
 			},
 		},
 		{
@@ -88,6 +97,7 @@ func init() {
 			Message: "Experimental Commands:",
 			Commands: []*cobra.Command{
 				newRegistryCmd(rootCmd.Name()),
+				newAgentCmd(),
 			},
 		},
 		{
@@ -123,6 +133,18 @@ func onBootOnDie() {
 
 	logger.CfgConsoleAndFileLogger(debug, constants.LogPath(), "sealos", false)
 	sreglog.CfgConsoleAndFileLogger(debug, constants.LogPath(), "sealos", false)
+
+	events.AddSink(events.NewAuditSink())
+	if eventsFile != "" {
+		sink, err := events.NewFileSink(eventsFile)
+		errExit(err)
+		events.AddSink(sink)
+	}
+	if eventsAddr != "" {
+		b, err := events.Serve(eventsAddr)
+		errExit(err)
+		events.AddSink(b)
+	}
 }
 
 func errExit(err error) {
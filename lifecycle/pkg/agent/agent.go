@@ -0,0 +1,264 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/labring/sealos/pkg/apply/applydrivers"
+	"github.com/labring/sealos/pkg/apply/processor"
+	"github.com/labring/sealos/pkg/client-go/kubernetes"
+	"github.com/labring/sealos/pkg/clusterfile"
+	"github.com/labring/sealos/pkg/clusterstate"
+	"github.com/labring/sealos/pkg/constants"
+	v2 "github.com/labring/sealos/pkg/types/v1beta1"
+	fileutil "github.com/labring/sealos/pkg/utils/file"
+	"github.com/labring/sealos/pkg/utils/logger"
+)
+
+var ClusterResource = schema.GroupVersionResource{
+	Group:    v2.GroupName,
+	Version:  v2.SchemeGroupVersion.Version,
+	Resource: "clusters",
+}
+
+type Options struct {
+	Kubeconfig string
+	Namespace  string
+	// SSHSecret is the name of the secret in Namespace that holds ssh credentials,
+	// it can be overridden by SSHSecretAnnotation on each Cluster resource.
+	SSHSecret    string
+	ResyncPeriod time.Duration
+	// AllowMasterDelete allows a Cluster resource to remove masters from the cluster.
+	AllowMasterDelete bool
+	// StatePassphrase decrypts the state mirrored into the secret when the local
+	// state is restored from it.
+	StatePassphrase string
+}
+
+// Agent watches Cluster resources and applies them to the cluster with the same
+// name in the local run root, the same way as `sealos apply` does.
+type Agent struct {
+	opts   *Options
+	client kubernetes.Client
+	queue  workqueue.RateLimitingInterface
+}
+
+func New(opts *Options) (*Agent, error) {
+	client, err := kubernetes.NewKubernetesClient(opts.Kubeconfig, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Agent{
+		opts:   opts,
+		client: client,
+		queue:  workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}, nil
+}
+
+func (a *Agent) Run(ctx context.Context) error {
+	defer a.queue.ShutDown()
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(a.client.KubernetesDynamic(), a.opts.ResyncPeriod, a.opts.Namespace, nil)
+	informer := factory.ForResource(ClusterResource).Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: a.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// status updates made by the agent itself do not bump the generation,
+			// periodic resyncs deliver the same resource version.
+			o, n := oldObj.(*unstructured.Unstructured), newObj.(*unstructured.Unstructured)
+			if o.GetGeneration() != n.GetGeneration() || o.GetResourceVersion() == n.GetResourceVersion() {
+				a.enqueue(newObj)
+			}
+		},
+	}); err != nil {
+		return err
+	}
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("failed to wait for cluster informer to sync")
+	}
+	logger.Info("sealos agent started, watching %s in namespace %q", ClusterResource.String(), a.opts.Namespace)
+
+	go func() {
+		<-ctx.Done()
+		a.queue.ShutDown()
+	}()
+	// clusters are reconciled one by one, the processors share global state.
+	for a.processNextItem(ctx, informer.GetIndexer()) {
+	}
+	return nil
+}
+
+func (a *Agent) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		logger.Error("failed to get key of object: %v", err)
+		return
+	}
+	a.queue.Add(key)
+}
+
+func (a *Agent) processNextItem(ctx context.Context, indexer cache.Indexer) bool {
+	item, shutdown := a.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer a.queue.Done(item)
+	key := item.(string)
+
+	obj, exists, err := indexer.GetByKey(key)
+	if err == nil && exists {
+		err = a.reconcile(ctx, obj.(*unstructured.Unstructured))
+	}
+	if err != nil {
+		logger.Error("failed to reconcile cluster %s: %v", key, err)
+		a.queue.AddRateLimited(item)
+		return true
+	}
+	a.queue.Forget(item)
+	return true
+}
+
+func (a *Agent) reconcile(ctx context.Context, obj *unstructured.Unstructured) error {
+	resource := &v2.Cluster{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), resource); err != nil {
+		return fmt.Errorf("failed to convert cluster resource: %v", err)
+	}
+	if resource.DeletionTimestamp != nil {
+		// deleting the resource never resets the cluster.
+		return nil
+	}
+	// the same lock is held by sealos commands changing the cluster on this host,
+	// a locked cluster is retried later.
+	unlock, err := clusterfile.Lock(resource.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := a.loadCurrent(resource.Name)
+	if err != nil {
+		return a.fail(ctx, obj, err)
+	}
+	override, err := a.sshOverride(ctx, resource)
+	if err != nil {
+		return a.fail(ctx, obj, err)
+	}
+	p, err := newPlan(current, resource, override, a.opts.AllowMasterDelete)
+	if err != nil {
+		return a.fail(ctx, obj, err)
+	}
+	if p.empty() {
+		logger.Debug("cluster %s is up to date", resource.Name)
+		return a.updateStatus(ctx, obj, current, nil)
+	}
+	logger.Info("applying cluster %s, new images: %v", resource.Name, p.newImages)
+	// the agent is not interactive, apps are overridden without confirmation. Deleting
+	// masters is guarded by Options.AllowMasterDelete when the plan is computed instead.
+	applier, err := applydrivers.NewDefaultApplier(processor.WithForceOverride(ctx), p.desired, nil, p.newImages)
+	if err != nil {
+		return a.fail(ctx, obj, err)
+	}
+	applyErr := applier.Apply()
+	if err = a.updateStatus(ctx, obj, p.desired, applyErr); err != nil {
+		return err
+	}
+	return applyErr
+}
+
+// loadCurrent returns the cluster in the local run root, the state is restored
+// from the secret mirrored by `sealos cluster export --to-secret` if it is missing.
+func (a *Agent) loadCurrent(name string) (*v2.Cluster, error) {
+	path := constants.Clusterfile(name)
+	if !fileutil.IsExist(path) {
+		logger.Info("local state of cluster %s not found, restoring it from secret", name)
+		data, err := clusterstate.LoadFromSecret(a.client.Kubernetes(), name)
+		if err != nil {
+			return nil, fmt.Errorf("local state of cluster %s not found and cannot be restored: %v", name, err)
+		}
+		if _, err = clusterstate.Import(bytes.NewReader(data), clusterstate.ImportOptions{
+			ClusterName: name,
+			Passphrase:  a.opts.StatePassphrase,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	cf := clusterfile.NewClusterFile(path)
+	if err := cf.Process(); err != nil {
+		return nil, err
+	}
+	current := cf.GetCluster()
+	if current.CreationTimestamp.IsZero() {
+		return nil, fmt.Errorf("cluster %s has not been created, the agent only manages existing clusters", name)
+	}
+	return current, nil
+}
+
+func (a *Agent) sshOverride(ctx context.Context, resource *v2.Cluster) (*v2.SSH, error) {
+	name := a.opts.SSHSecret
+	if v, ok := resource.Annotations[SSHSecretAnnotation]; ok {
+		name = v
+	}
+	if name == "" {
+		return nil, nil
+	}
+	secret, err := a.client.Kubernetes().CoreV1().Secrets(resource.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ssh secret %s/%s: %v", resource.Namespace, name, err)
+	}
+	return sshFromSecret(secret)
+}
+
+func (a *Agent) fail(ctx context.Context, obj *unstructured.Unstructured, err error) error {
+	if updateErr := a.updateStatus(ctx, obj, nil, err); updateErr != nil {
+		logger.Error("%v", updateErr)
+	}
+	return err
+}
+
+func (a *Agent) updateStatus(ctx context.Context, obj *unstructured.Unstructured, cluster *v2.Cluster, reconcileErr error) error {
+	status := v2.ClusterStatus{}
+	if cluster != nil {
+		status = *cluster.Status.DeepCopy()
+	}
+	status = reconcileStatus(status, reconcileErr)
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+	obj = obj.DeepCopy()
+	obj.Object["status"] = content
+	client := a.client.KubernetesDynamic().Resource(ClusterResource).Namespace(obj.GetNamespace())
+	if _, err = client.UpdateStatus(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			// a newer version will be reconciled again.
+			return nil
+		}
+		return fmt.Errorf("failed to update status of cluster %s: %v", obj.GetName(), err)
+	}
+	return nil
+}
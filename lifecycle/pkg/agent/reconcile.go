@@ -0,0 +1,117 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/labring/sealos/pkg/ssh"
+	v2 "github.com/labring/sealos/pkg/types/v1beta1"
+	"github.com/labring/sealos/pkg/utils/iputils"
+)
+
+// keys of the ssh secret, named after the fields of v1beta1.SSH.
+const (
+	sshUserKey     = "user"
+	sshPasswdKey   = "passwd"
+	sshPkDataKey   = "pkData"
+	sshPkPasswdKey = "pkPasswd"
+	sshPortKey     = "port"
+)
+
+// SSHSecretAnnotation overrides the ssh secret of a single Cluster resource.
+const SSHSecretAnnotation = "apps.sealos.io/ssh-secret"
+
+func sshFromSecret(secret *v1.Secret) (*v2.SSH, error) {
+	s := &v2.SSH{
+		User:     string(secret.Data[sshUserKey]),
+		Passwd:   string(secret.Data[sshPasswdKey]),
+		PkData:   string(secret.Data[sshPkDataKey]),
+		PkPasswd: string(secret.Data[sshPkPasswdKey]),
+	}
+	if port, ok := secret.Data[sshPortKey]; ok {
+		p, err := strconv.ParseUint(string(port), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh port in secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+		s.Port = uint16(p)
+	}
+	return s, nil
+}
+
+// plan is the set of changes needed to move the local cluster to the resource.
+type plan struct {
+	desired   *v2.Cluster
+	newImages []string
+}
+
+func (p *plan) empty() bool {
+	return p.desired == nil
+}
+
+// newPlan computes the desired cluster from the current local cluster and the
+// Cluster resource. Only hosts and images are taken from the resource, the rest
+// of the spec always comes from the local Clusterfile so that the resource can
+// never override runtime settings it does not own. The hosts are kept when the
+// resource does not set them, and masters are only removed if allowMasterDelete.
+func newPlan(current, resource *v2.Cluster, override *v2.SSH, allowMasterDelete bool) (*plan, error) {
+	desired := current.DeepCopy()
+	if len(resource.Spec.Hosts) > 0 {
+		desired.Spec.Hosts = resource.Spec.Hosts
+	}
+	if len(desired.GetMasterIPAndPortList()) == 0 {
+		return nil, fmt.Errorf("cluster %s has no master in hosts", resource.Name)
+	}
+	ssh.OverSSHConfig(&desired.Spec.SSH, override)
+
+	p := &plan{}
+	for _, img := range resource.Spec.Image {
+		if !slices.Contains(current.Spec.Image, img) {
+			p.newImages = append(p.newImages, img)
+		}
+	}
+	mj, md := iputils.GetDiffHosts(current.GetMasterIPAndPortList(), desired.GetMasterIPAndPortList())
+	nj, nd := iputils.GetDiffHosts(current.GetNodeIPAndPortList(), desired.GetNodeIPAndPortList())
+	if len(md) > 0 && !allowMasterDelete {
+		return nil, fmt.Errorf("refusing to delete masters %v of cluster %s, run the agent with --allow-master-delete to allow it", md, resource.Name)
+	}
+	if len(mj)+len(md)+len(nj)+len(nd) > 0 || len(p.newImages) > 0 {
+		p.desired = desired
+	}
+	return p, nil
+}
+
+// reconcileStatus recomputes the phase and condition from the result of the last
+// reconcile, so that a recovered cluster does not keep reporting a stale failure.
+func reconcileStatus(status v2.ClusterStatus, reconcileErr error) v2.ClusterStatus {
+	if reconcileErr != nil {
+		status.Phase = v2.ClusterFailed
+		status.Conditions = v2.UpdateCondition(status.Conditions, v2.NewFailedClusterCondition(reconcileErr.Error()))
+		return status
+	}
+	status.Phase = v2.ClusterSuccess
+	conditions := make([]v2.ClusterCondition, 0, len(status.Conditions))
+	for _, cond := range status.Conditions {
+		if cond.Type != v2.ClusterConditionTypeError {
+			conditions = append(conditions, cond)
+		}
+	}
+	status.Conditions = v2.UpdateCondition(conditions, v2.NewSuccessClusterCondition())
+	return status
+}
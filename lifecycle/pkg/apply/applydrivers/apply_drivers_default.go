@@ -83,6 +83,11 @@ type Applier struct {
 }
 
 func (c *Applier) Apply() error {
+	unlock, err := clusterfile.Lock(c.ClusterDesired.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	// clusterErr and appErr should not appear in the same time
 	var clusterErr, appErr error
 	defer func() {
@@ -250,6 +255,11 @@ func (c *Applier) scaleCluster(mj, md, nj, nd []string) error {
 }
 
 func (c *Applier) Delete() error {
+	unlock, err := clusterfile.Lock(c.ClusterDesired.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	t := metav1.Now()
 	c.ClusterDesired.DeletionTimestamp = &t
 	defer func() {
@@ -48,3 +48,16 @@ func GetEnvs(ctx context.Context) map[string]string {
 	}
 	return nil
 }
+
+type forceOverrideKey struct{}
+
+// WithForceOverride makes the install processors created with ctx override the apps
+// already in the cluster without confirmation.
+func WithForceOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceOverrideKey{}, true)
+}
+
+func GetForceOverride(ctx context.Context) bool {
+	v, _ := ctx.Value(forceOverrideKey{}).(bool)
+	return v
+}
@@ -49,13 +49,7 @@ func (c *CreateProcessor) Execute(cluster *v2.Cluster) error {
 	if err != nil {
 		return err
 	}
-	for _, f := range pipeLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return executePipeline(cluster, pipeLine, false)
 }
 
 func (c *CreateProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
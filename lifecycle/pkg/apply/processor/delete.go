@@ -46,13 +46,7 @@ func (d DeleteProcessor) Execute(cluster *v2.Cluster) (err error) {
 		return err
 	}
 	// TODO if error is exec net process ???
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			logger.Warn("failed to exec delete process, %s", err.Error())
-		}
-	}
-
-	return nil
+	return executePipeline(cluster, pipLine, true)
 }
 func (d DeleteProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
 	var todoList []func(cluster *v2.Cluster) error
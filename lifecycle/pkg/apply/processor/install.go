@@ -48,6 +48,7 @@ type InstallProcessor struct {
 	NewMounts        []v2.MountImage
 	NewImages        []string
 	ExtraEnvs        map[string]string // parsing from CLI arguments
+	ForceOverride    bool              // override the apps in the cluster without confirmation
 	imagesToOverride []string
 }
 
@@ -56,14 +57,7 @@ func (c *InstallProcessor) Execute(cluster *v2.Cluster) error {
 	if err != nil {
 		return err
 	}
-
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return executePipeline(cluster, pipLine, false)
 }
 
 func (c *InstallProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
@@ -106,7 +100,7 @@ func (c *InstallProcessor) SyncStatusAndCheck(_ *v2.Cluster) error {
 func (c *InstallProcessor) ConfirmOverrideApps(_ *v2.Cluster) error {
 	logger.Info("Executing ConfirmOverrideApps Pipeline in InstallProcessor")
 
-	if ForceOverride || len(c.imagesToOverride) == 0 {
+	if c.ForceOverride || len(c.imagesToOverride) == 0 {
 		return nil
 	}
 
@@ -120,7 +114,7 @@ func (c *InstallProcessor) ConfirmOverrideApps(_ *v2.Cluster) error {
 		// return a cancelled error to stop apply process.
 		return ErrCancelled
 	}
-	ForceOverride = true
+	c.ForceOverride = true
 	return nil
 }
 
@@ -163,7 +157,7 @@ func (c *InstallProcessor) PreProcess(cluster *v2.Cluster) error {
 		index, mount := cluster.FindImage(img)
 		var ctrName string
 		if mount != nil {
-			if !ForceOverride {
+			if !c.ForceOverride {
 				continue
 			}
 			logger.Debug("trying to override app %s", img)
@@ -282,10 +276,11 @@ func NewInstallProcessor(ctx context.Context, clusterFile clusterfile.Interface,
 	}
 
 	return &InstallProcessor{
-		ClusterFile: clusterFile,
-		Buildah:     bder,
-		Guest:       gs,
-		NewImages:   images,
-		ExtraEnvs:   GetEnvs(ctx),
+		ClusterFile:   clusterFile,
+		Buildah:       bder,
+		Guest:         gs,
+		NewImages:     images,
+		ExtraEnvs:     GetEnvs(ctx),
+		ForceOverride: ForceOverride || GetForceOverride(ctx),
 	}, nil
 }
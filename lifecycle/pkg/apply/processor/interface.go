@@ -19,12 +19,16 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"reflect"
+	goruntime "runtime"
+	"strings"
 
 	"github.com/containers/storage"
 	"golang.org/x/exp/slices"
 
 	"github.com/labring/sealos/pkg/buildah"
 	"github.com/labring/sealos/pkg/constants"
+	"github.com/labring/sealos/pkg/events"
 	"github.com/labring/sealos/pkg/exec"
 	"github.com/labring/sealos/pkg/filesystem/registry"
 	"github.com/labring/sealos/pkg/ssh"
@@ -41,6 +45,33 @@ type Interface interface {
 	Execute(cluster *v2.Cluster) error
 }
 
+// executePipeline runs the steps of a processor in order and emits an event
+// for each of them, the remaining steps are skipped on error unless continueOnError.
+func executePipeline(cluster *v2.Cluster, pipeline []func(cluster *v2.Cluster) error, continueOnError bool) error {
+	for _, f := range pipeline {
+		err := events.Run(cluster.Name, stepName(f), "", func() error {
+			return f(cluster)
+		})
+		if err == nil {
+			continue
+		}
+		if !continueOnError {
+			return err
+		}
+		logger.Warn("failed to exec %s, %s", stepName(f), err.Error())
+	}
+	return nil
+}
+
+// stepName returns the name of the method value, eg. CreateProcessor.Check.
+func stepName(f func(cluster *v2.Cluster) error) string {
+	name := goruntime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimPrefix(name, "processor.")
+	name = strings.TrimSuffix(name, "-fm")
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
+
 // compatible with older sealos versions
 func SyncNewVersionConfig(clusterName string) {
 	d := constants.NewPathResolver(clusterName)
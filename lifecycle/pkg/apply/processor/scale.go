@@ -54,14 +54,7 @@ func (c *ScaleProcessor) Execute(cluster *v2.Cluster) error {
 	if err != nil {
 		return err
 	}
-
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return executePipeline(cluster, pipLine, false)
 }
 
 func (c *ScaleProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
@@ -0,0 +1,37 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"path/filepath"
+)
+
+// hostPrepareLabel is set on rootfs images that ship an offline package set,
+// its value is the dir of packages relative to the rootfs.
+const hostPrepareLabel = "host-prepare"
+
+type hostPrepareApplier struct{ common }
+
+func (*hostPrepareApplier) String() string { return "host_prepare_applier" }
+
+func (*hostPrepareApplier) Filter(ctx Context, _ string) bool {
+	_, ok := ctx.GetCluster().GetAllLabels()[hostPrepareLabel]
+	return ok
+}
+
+func (*hostPrepareApplier) Apply(ctx Context, host string) error {
+	packagesDir := filepath.Join(ctx.GetPathResolver().RootFSPath(), ctx.GetCluster().GetAllLabels()[hostPrepareLabel])
+	return ctx.GetRemoter().HostPrepare(host, packagesDir)
+}
@@ -0,0 +1,91 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/labring/sealos/pkg/constants"
+)
+
+const lockFileName = ".lock"
+
+var ErrClusterLocked = errors.New("cluster is being changed by another sealos process")
+
+var (
+	locksMu sync.Mutex
+	locks   = map[string]*clusterLock{}
+)
+
+type clusterLock struct {
+	f    *os.File
+	refs int
+}
+
+// Lock takes an exclusive lock on the local state of the cluster. sealos commands
+// and the agent hold it while they change the cluster, so that they never apply
+// to the same cluster at the same time. The lock is reentrant within a process.
+func Lock(clusterName string) (unlock func(), err error) {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+	if l, ok := locks[clusterName]; ok {
+		l.refs++
+		return releaseFunc(clusterName), nil
+	}
+
+	dir := constants.ClusterDir(clusterName)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrClusterLocked, clusterName)
+		}
+		return nil, err
+	}
+	locks[clusterName] = &clusterLock{f: f, refs: 1}
+	return releaseFunc(clusterName), nil
+}
+
+func releaseFunc(clusterName string) func() {
+	var once sync.Once
+	return func() { once.Do(func() { release(clusterName) }) }
+}
+
+func release(clusterName string) {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+	l, ok := locks[clusterName]
+	if !ok {
+		return
+	}
+	if l.refs--; l.refs > 0 {
+		return
+	}
+	delete(locks, clusterName)
+	_ = unix.Flock(int(l.f.Fd()), unix.LOCK_UN)
+	_ = l.f.Close()
+}
@@ -0,0 +1,59 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/labring/sealos/pkg/constants"
+)
+
+func TestLock(t *testing.T) {
+	constants.DefaultRuntimeRootDir = t.TempDir()
+
+	unlock, err := Lock("default")
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	// reentrant within the same process
+	unlockAgain, err := Lock("default")
+	if err != nil {
+		t.Fatalf("Lock() again error = %v", err)
+	}
+	unlockAgain()
+	unlockAgain()
+
+	// another open file description stands for another process
+	f, err := os.Open(filepath.Join(constants.ClusterDir("default"), lockFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); !errors.Is(err, unix.EWOULDBLOCK) {
+		t.Fatalf("Flock() while locked error = %v, want %v", err, unix.EWOULDBLOCK)
+	}
+	unlock()
+	if err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		t.Fatalf("Flock() after unlock error = %v", err)
+	}
+	if _, err = Lock("default"); !errors.Is(err, ErrClusterLocked) {
+		t.Errorf("Lock() held by another process error = %v, want %v", err, ErrClusterLocked)
+	}
+}
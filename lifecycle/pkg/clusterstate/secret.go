@@ -0,0 +1,65 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterstate
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/labring/sealos/pkg/client-go/kubernetes"
+)
+
+const (
+	SecretNamespace = metav1.NamespaceSystem
+	secretDataKey   = "state.tar"
+	secretLabelKey  = "sealos.io/cluster-state"
+)
+
+// SecretName returns the name of the secret that mirrors the state of the cluster.
+func SecretName(clusterName string) string {
+	return fmt.Sprintf("sealos-cluster-state-%s", clusterName)
+}
+
+// SaveToSecret mirrors an exported state archive into a secret inside the cluster,
+// so that any master is able to reconstruct the local state.
+func SaveToSecret(client clientset.Interface, clusterName string, data []byte) error {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SecretName(clusterName),
+			Namespace: SecretNamespace,
+			Labels:    map[string]string{secretLabelKey: clusterName},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{secretDataKey: data},
+	}
+	return kubernetes.NewKubeIdempotency(client).CreateOrUpdateSecret(secret)
+}
+
+// LoadFromSecret returns the state archive saved by SaveToSecret.
+func LoadFromSecret(client clientset.Interface, clusterName string) ([]byte, error) {
+	secret, err := client.CoreV1().Secrets(SecretNamespace).Get(context.TODO(), SecretName(clusterName), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[secretDataKey]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in secret %s/%s", secretDataKey, SecretNamespace, secret.Name)
+	}
+	return data, nil
+}
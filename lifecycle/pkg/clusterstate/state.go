@@ -0,0 +1,304 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterstate
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labring/sealos/pkg/clusterfile"
+	"github.com/labring/sealos/pkg/constants"
+	"github.com/labring/sealos/pkg/types/v1beta1"
+	"github.com/labring/sealos/pkg/utils/file"
+	"github.com/labring/sealos/pkg/utils/logger"
+	"github.com/labring/sealos/pkg/version"
+)
+
+const (
+	// FormatVersion is bumped whenever the layout of the state archive changes.
+	FormatVersion = "v1"
+
+	manifestFileName = "manifest.json"
+	encryptedSuffix  = ".enc"
+)
+
+var ErrClusterStateExists = errors.New("cluster state already exists, use force to overwrite it")
+
+// Manifest describes the content of a state archive.
+type Manifest struct {
+	FormatVersion string    `json:"formatVersion"`
+	SealosVersion string    `json:"sealosVersion"`
+	ClusterName   string    `json:"clusterName"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Images        []string  `json:"images,omitempty"`
+	Encrypted     bool      `json:"encrypted"`
+	Files         []string  `json:"files"`
+}
+
+type Options struct {
+	// Passphrase encrypts sensitive files when it is not empty.
+	Passphrase string
+}
+
+type ImportOptions struct {
+	// ClusterName is the cluster to restore into, it must match the name
+	// stored in the archive.
+	ClusterName string
+	Passphrase  string
+	// Force overwrites the local state of a cluster with the same name.
+	Force bool
+}
+
+// dirs under the run root that must be saved, paths are relative to the run root.
+var stateDirs = []string{constants.EtcDirName, constants.PkiDirName}
+
+// isSensitive reports whether the file contains credentials: certificates and keys,
+// kubeconfig, bootstrap tokens and the Clusterfile that may hold ssh passwords.
+func isSensitive(name string) bool {
+	switch name {
+	case constants.DefaultClusterFileName,
+		filepath.Join(constants.EtcDirName, "admin.conf"),
+		filepath.Join(constants.EtcDirName, "kubeadm-token.json"):
+		return true
+	}
+	return strings.HasPrefix(name, constants.PkiDirName+"/")
+}
+
+// Export writes the local state of the cluster into w as a tar stream.
+func Export(clusterName string, w io.Writer, opts Options) (*Manifest, error) {
+	pathResolver := constants.NewPathResolver(clusterName)
+	runRoot := pathResolver.RunRoot()
+	cluster, err := clusterfile.GetClusterFromFile(constants.Clusterfile(clusterName))
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{constants.DefaultClusterFileName}
+	for _, dir := range stateDirs {
+		root := filepath.Join(runRoot, dir)
+		if !file.IsExist(root) {
+			logger.Debug("skip non-existent state dir %s", root)
+			continue
+		}
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(runRoot, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files[1:])
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		SealosVersion: version.Get().GitVersion,
+		ClusterName:   clusterName,
+		CreatedAt:     time.Now().UTC(),
+		Images:        clusterImages(cluster.Spec.Image, cluster.Status.Mounts),
+		Encrypted:     opts.Passphrase != "",
+		Files:         files,
+	}
+
+	tw := tar.NewWriter(w)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = writeEntry(tw, manifestFileName, data, 0644); err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		path := filepath.Join(runRoot, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, err
+		}
+		if manifest.Encrypted && isSensitive(name) {
+			if data, err = encrypt(opts.Passphrase, data); err != nil {
+				return nil, fmt.Errorf("failed to encrypt %s: %v", name, err)
+			}
+			name += encryptedSuffix
+		}
+		if err = writeEntry(tw, name, data, info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, tw.Close()
+}
+
+// Import restores the state saved by Export into the local run root.
+func Import(r io.Reader, opts ImportOptions) (*Manifest, error) {
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := entries[manifestFileName]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in state archive", manifestFileName)
+	}
+	manifest := &Manifest{}
+	if err = json.Unmarshal(data.content, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", manifestFileName, err)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported state format version %q", manifest.FormatVersion)
+	}
+	if manifest.Encrypted && opts.Passphrase == "" {
+		return nil, errors.New("state archive is encrypted, passphrase is required")
+	}
+
+	// never trust the name stored in the archive, it decides where files are written.
+	clusterName := opts.ClusterName
+	if !validClusterName(clusterName) {
+		return nil, fmt.Errorf("invalid cluster name %q", clusterName)
+	}
+	if manifest.ClusterName != clusterName {
+		return nil, fmt.Errorf("state archive belongs to cluster %q, not %q", manifest.ClusterName, clusterName)
+	}
+	runRoot := constants.NewPathResolver(clusterName).RunRoot()
+	if file.IsExist(constants.Clusterfile(clusterName)) && !opts.Force {
+		return nil, ErrClusterStateExists
+	}
+
+	// decode everything before touching the disk, so that a wrong
+	// passphrase does not leave a half-restored cluster behind.
+	decoded := make(map[string]entry, len(manifest.Files))
+	for _, name := range manifest.Files {
+		if !validRelPath(name) {
+			return nil, fmt.Errorf("state archive contains invalid name %q", name)
+		}
+		stored := name
+		if manifest.Encrypted && isSensitive(name) {
+			stored += encryptedSuffix
+		}
+		e, ok := entries[stored]
+		if !ok {
+			return nil, fmt.Errorf("file %s not found in state archive", stored)
+		}
+		if stored != name {
+			if e.content, err = decrypt(opts.Passphrase, e.content); err != nil {
+				return nil, err
+			}
+		}
+		decoded[name] = e
+	}
+
+	for name, e := range decoded {
+		path := filepath.Join(runRoot, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err = file.AtomicWriteFile(path, e.content, e.mode); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+	}
+	return manifest, nil
+}
+
+type entry struct {
+	content []byte
+	mode    os.FileMode
+}
+
+func readEntries(r io.Reader) (map[string]entry, error) {
+	entries := make(map[string]entry)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// nosemgrep: go.lang.security.decompression_bomb.potential-dos-via-decompression-bomb
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		entries[hdr.Name] = entry{content: content, mode: os.FileMode(hdr.Mode).Perm()}
+	}
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, mode os.FileMode) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode),
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func clusterImages(images []string, mounts []v1beta1.MountImage) []string {
+	seen := make(map[string]struct{})
+	var ret []string
+	add := func(img string) {
+		if _, ok := seen[img]; ok || img == "" {
+			return
+		}
+		seen[img] = struct{}{}
+		ret = append(ret, img)
+	}
+	for _, img := range images {
+		add(img)
+	}
+	for _, m := range mounts {
+		add(m.ImageName)
+	}
+	return ret
+}
+
+// check for path traversal, names in the archive must also be clean so that
+// "pki/.." can not resolve to the state directory itself
+func validRelPath(p string) bool {
+	return !strings.Contains(p, `\`) && filepath.IsLocal(p) && filepath.Clean(p) == p
+}
+
+// a cluster name is used as a single directory under the run root.
+func validClusterName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && filepath.IsLocal(name) && name != "."
+}
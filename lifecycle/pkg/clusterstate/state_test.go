@@ -0,0 +1,195 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterstate
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labring/sealos/pkg/constants"
+)
+
+const testClusterfile = `apiVersion: apps.sealos.io/v1beta1
+kind: Cluster
+metadata:
+  name: default
+spec:
+  image:
+  - labring/kubernetes:v1.25.0
+  - labring/calico:v3.24.1
+  ssh:
+    passwd: s3cret
+status:
+  mounts:
+  - name: default-kubernetes
+    imageName: labring/kubernetes:v1.25.0
+    type: rootfs
+  - name: default-helm
+    imageName: labring/helm:v3.8.2
+    type: application
+`
+
+func prepareState(t *testing.T) map[string]string {
+	t.Helper()
+	constants.DefaultRuntimeRootDir = t.TempDir()
+	files := map[string]string{
+		constants.DefaultClusterFileName: testClusterfile,
+		"etc/admin.conf":                 "kubeconfig",
+		"etc/kubeadm-init.yaml":          "kind: InitConfiguration",
+		"pki/ca.key":                     "ca-key",
+		"pki/etcd/ca.crt":                "etcd-ca",
+	}
+	root := constants.ClusterDir("default")
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestExportImport(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+	}{
+		{name: "plain"},
+		{name: "encrypted", passphrase: "passw0rd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := prepareState(t)
+			buf := &bytes.Buffer{}
+			manifest, err := Export("default", buf, Options{Passphrase: tt.passphrase})
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			wantImages := []string{"labring/kubernetes:v1.25.0", "labring/calico:v3.24.1", "labring/helm:v3.8.2"}
+			if !reflect.DeepEqual(manifest.Images, wantImages) {
+				t.Errorf("Export() images = %v, want %v", manifest.Images, wantImages)
+			}
+			if tt.passphrase != "" && bytes.Contains(buf.Bytes(), []byte("s3cret")) {
+				t.Errorf("Export() sensitive data is not encrypted")
+			}
+
+			if _, err = Import(bytes.NewReader(buf.Bytes()), ImportOptions{ClusterName: "default", Passphrase: tt.passphrase}); !errors.Is(err, ErrClusterStateExists) {
+				t.Errorf("Import() error = %v, want %v", err, ErrClusterStateExists)
+			}
+			if err = os.RemoveAll(constants.ClusterDir("default")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = Import(bytes.NewReader(buf.Bytes()), ImportOptions{ClusterName: "default", Passphrase: tt.passphrase}); err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			for name, want := range files {
+				got, err := os.ReadFile(filepath.Join(constants.ClusterDir("default"), name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("Import() %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportWrongPassphrase(t *testing.T) {
+	prepareState(t)
+	buf := &bytes.Buffer{}
+	if _, err := Export("default", buf, Options{Passphrase: "right"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(constants.ClusterDir("default")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(bytes.NewReader(buf.Bytes()), ImportOptions{ClusterName: "default", Passphrase: "wrong"}); !errors.Is(err, ErrInvalidPassphrase) {
+		t.Fatalf("Import() error = %v, want %v", err, ErrInvalidPassphrase)
+	}
+	if _, err := os.Stat(constants.Clusterfile("default")); !os.IsNotExist(err) {
+		t.Errorf("Import() with wrong passphrase must not write any file")
+	}
+}
+
+func TestExportEncryptedEntries(t *testing.T) {
+	prepareState(t)
+	buf := &bytes.Buffer{}
+	if _, err := Export("default", buf, Options{Passphrase: "passw0rd"}); err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(hdr.Name, encryptedSuffix)
+		if isSensitive(name) != strings.HasSuffix(hdr.Name, encryptedSuffix) {
+			t.Errorf("entry %s: encrypted = %v, want %v", hdr.Name, !isSensitive(name), isSensitive(name))
+		}
+	}
+}
+
+func TestValidRelPath(t *testing.T) {
+	tests := map[string]bool{
+		"Clusterfile":        true,
+		"pki/ca.crt":         true,
+		"":                   false,
+		"/etc/passwd":        false,
+		"..":                 false,
+		"../Clusterfile":     false,
+		"pki/..":             false,
+		"pki/../../secret":   false,
+		`pki\..\Clusterfile`: false,
+	}
+	for p, want := range tests {
+		if got := validRelPath(p); got != want {
+			t.Errorf("validRelPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestImportClusterName(t *testing.T) {
+	prepareState(t)
+	buf := &bytes.Buffer{}
+	if _, err := Export("default", buf, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(constants.ClusterDir("default")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", ".", "..", "../../etc", "a/b", `a\b`, "other"} {
+		if _, err := Import(bytes.NewReader(buf.Bytes()), ImportOptions{ClusterName: name}); err == nil {
+			t.Errorf("Import() into cluster %q expected error", name)
+		}
+	}
+	if _, err := os.Stat(constants.Clusterfile("default")); !os.IsNotExist(err) {
+		t.Errorf("Import() with invalid cluster name must not write any file")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/labring/sealos/pkg/utils/logger"
+)
+
+type Type string
+
+const (
+	StepStarted  Type = "StepStarted"
+	StepFinished Type = "StepFinished"
+)
+
+// Event is emitted by processors, runtimes and guest execution for every step
+// of a long-running operation.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    Type      `json:"type"`
+	Cluster string    `json:"cluster,omitempty"`
+	Step    string    `json:"step"`
+	Host    string    `json:"host,omitempty"`
+	// Duration is only set on StepFinished, in seconds.
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type Sink interface {
+	Emit(*Event) error
+}
+
+var (
+	mu    sync.RWMutex
+	sinks []Sink
+)
+
+// AddSink registers a sink that receives all events emitted after this call.
+func AddSink(s Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+	sinks = append(sinks, s)
+}
+
+// Close closes and removes all registered sinks.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range sinks {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				logger.Debug("failed to close event sink: %v", err)
+			}
+		}
+	}
+	sinks = nil
+}
+
+func emit(e *Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, s := range sinks {
+		if err := s.Emit(e); err != nil {
+			logger.Debug("failed to emit event %s of step %s: %v", e.Type, e.Step, err)
+		}
+	}
+}
+
+// Start emits a StepStarted event and returns the function that emits the
+// matching StepFinished event, host is empty for cluster-wide steps.
+//
+//	done := events.Start(cluster.Name, "join node", host)
+//	err := join(host)
+//	done(err)
+func Start(cluster, step, host string) func(error) {
+	start := time.Now()
+	emit(&Event{Time: start, Type: StepStarted, Cluster: cluster, Step: step, Host: host})
+	return func(err error) {
+		e := &Event{
+			Time:     time.Now(),
+			Type:     StepFinished,
+			Cluster:  cluster,
+			Step:     step,
+			Host:     host,
+			Duration: time.Since(start).Seconds(),
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		emit(e)
+	}
+}
+
+// Run runs fn as a step.
+func Run(cluster, step, host string, fn func() error) error {
+	done := Start(cluster, step, host)
+	err := fn()
+	done(err)
+	return err
+}
@@ -0,0 +1,160 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labring/sealos/pkg/constants"
+)
+
+func decodeLines(t *testing.T, data []byte) []Event {
+	t.Helper()
+	var ret []Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid json line %q: %v", scanner.Text(), err)
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+func TestRun(t *testing.T) {
+	defer Close()
+	buf := &bytes.Buffer{}
+	AddSink(NewJSONLinesSink(buf))
+
+	_ = Run("default", "join node", "192.168.0.2:22", func() error { return nil })
+	wantErr := errors.New("ssh timeout")
+	if err := Run("default", "join node", "192.168.0.3:22", func() error { return wantErr }); err != wantErr {
+		t.Fatalf("Run() error = %v, want %v", err, wantErr)
+	}
+
+	got := decodeLines(t, buf.Bytes())
+	if len(got) != 4 {
+		t.Fatalf("got %d events, want 4", len(got))
+	}
+	wantTypes := []Type{StepStarted, StepFinished, StepStarted, StepFinished}
+	for i, e := range got {
+		if e.Type != wantTypes[i] || e.Cluster != "default" || e.Step != "join node" {
+			t.Errorf("event %d = %+v", i, e)
+		}
+	}
+	if got[1].Error != "" || got[3].Error != wantErr.Error() {
+		t.Errorf("unexpected errors in finished events: %q, %q", got[1].Error, got[3].Error)
+	}
+	if got[3].Host != "192.168.0.3:22" {
+		t.Errorf("event host = %s, want 192.168.0.3:22", got[3].Host)
+	}
+}
+
+func TestAuditSink(t *testing.T) {
+	constants.DefaultRuntimeRootDir = t.TempDir()
+	defer Close()
+	AddSink(NewAuditSink())
+
+	Start("default", "Check", "")(nil)
+	Start("", "no cluster", "")(nil)
+
+	data, err := os.ReadFile(AuditFile("default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeLines(t, data); len(got) != 2 {
+		t.Errorf("audit trail has %d events, want 2", len(got))
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %s", ct)
+	}
+	// wait for the handler to subscribe
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		n := len(b.subscribers)
+		b.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err = b.Emit(&Event{Type: StepStarted, Cluster: "default", Step: "Init"}); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e Event
+	if err = json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "data: ")), &e); err != nil {
+		t.Fatalf("invalid event %q: %v", line, err)
+	}
+	if e.Step != "Init" {
+		t.Errorf("event step = %s, want Init", e.Step)
+	}
+}
+
+func TestListen(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: ":0"},
+		{addr: "127.0.0.1:0"},
+		{addr: "localhost:0"},
+		{addr: "0.0.0.0:0", wantErr: true},
+		{addr: "192.168.0.1:0", wantErr: true},
+		{addr: "unix:" + filepath.Join(t.TempDir(), "events.sock")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			listener, err := listen(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if listener != nil {
+				_ = listener.Close()
+			}
+		})
+	}
+}
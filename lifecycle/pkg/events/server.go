@@ -0,0 +1,157 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labring/sealos/pkg/utils/logger"
+)
+
+const subscriberBufferSize = 256
+
+// Broadcaster is a sink that serves events to HTTP clients as server-sent events.
+// Slow clients drop events instead of blocking the operation.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan []byte]struct{})}
+}
+
+func (b *Broadcaster) Emit(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- data:
+		default:
+			logger.Debug("drop event %s of step %s for slow subscriber", e.Type, e.Step)
+		}
+	}
+	return nil
+}
+
+func (b *Broadcaster) subscribe() chan []byte {
+	ch := make(chan []byte, subscriberBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *Broadcaster) unsubscribe(ch chan []byte) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+func (b *Broadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-ch:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Serve starts a local HTTP server that streams events on /events. Events are served
+// without authentication, so addr must be a loopback address or a unix socket
+// given as unix:<path>.
+func Serve(addr string) (*Broadcaster, error) {
+	listener, err := listen(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve events on %s: %v", addr, err)
+	}
+	b := NewBroadcaster()
+	mux := http.NewServeMux()
+	mux.Handle("/events", b)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("failed to serve events: %v", err)
+		}
+	}()
+	logger.Info("serving events on %s/events", listener.Addr())
+	return b, nil
+}
+
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		// only the user running sealos can read the events.
+		if err = os.Chmod(path, 0600); err != nil {
+			_ = listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if !isLoopback(host) {
+		return nil, fmt.Errorf("host %s is not a loopback address, use 127.0.0.1, ::1, localhost or unix:<path>", host)
+	}
+	return net.Listen("tcp", net.JoinHostPort(host, port))
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,113 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/labring/sealos/pkg/constants"
+)
+
+const auditFileName = "events.jsonl"
+
+// JSONLinesSink writes one JSON object per line.
+type JSONLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// NewFileSink appends events to the file as JSON lines.
+func NewFileSink(path string) (*JSONLinesSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesSink(f), nil
+}
+
+func (s *JSONLinesSink) Emit(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+func (s *JSONLinesSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// AuditSink keeps the audit trail of each cluster in its run directory.
+type AuditSink struct {
+	mu    sync.Mutex
+	files map[string]*JSONLinesSink
+}
+
+func NewAuditSink() *AuditSink {
+	return &AuditSink{files: make(map[string]*JSONLinesSink)}
+}
+
+// AuditFile returns the path of the audit trail of the cluster.
+func AuditFile(clusterName string) string {
+	return filepath.Join(constants.ClusterDir(clusterName), auditFileName)
+}
+
+func (s *AuditSink) Emit(e *Event) error {
+	if e.Cluster == "" {
+		return nil
+	}
+	s.mu.Lock()
+	sink, ok := s.files[e.Cluster]
+	if !ok {
+		var err error
+		if sink, err = NewFileSink(AuditFile(e.Cluster)); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.files[e.Cluster] = sink
+	}
+	s.mu.Unlock()
+	return sink.Emit(e)
+}
+
+func (s *AuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for name, sink := range s.files {
+		if closeErr := sink.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(s.files, name)
+	}
+	return err
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/labring/sealos/fork/golang/expansion"
 	"github.com/labring/sealos/pkg/env"
+	"github.com/labring/sealos/pkg/events"
 	"github.com/labring/sealos/pkg/exec"
 	"github.com/labring/sealos/pkg/ssh"
 	v2 "github.com/labring/sealos/pkg/types/v1beta1"
@@ -57,9 +58,11 @@ func (d *Default) Apply(cluster *v2.Cluster, mounts []v2.MountImage, targetHosts
 				envs := maps.Merge(m.Env, envGetter.Getenv(node))
 				cmds := formalizeImageCommands(cluster, i, m, envs)
 				eg.Go(func() error {
-					return execer.CmdAsyncWithContext(ctx, node,
-						stringsutil.RenderShellWithEnv(strings.Join(cmds, "; "), envs),
-					)
+					return events.Run(cluster.Name, "guest "+m.Name, node, func() error {
+						return execer.CmdAsyncWithContext(ctx, node,
+							stringsutil.RenderShellWithEnv(strings.Join(cmds, "; "), envs),
+						)
+					})
 				})
 			}
 			if err := eg.Wait(); err != nil {
@@ -69,9 +72,12 @@ func (d *Default) Apply(cluster *v2.Cluster, mounts []v2.MountImage, targetHosts
 			// on run on the first master
 			envs := maps.Merge(m.Env, envGetter.Getenv(cluster.GetMaster0IP()))
 			cmds := formalizeImageCommands(cluster, i, m, envs)
-			if err := execer.CmdAsync(cluster.GetMaster0IPAndPort(),
-				stringsutil.RenderShellWithEnv(strings.Join(cmds, "; "), envs),
-			); err != nil {
+			master0 := cluster.GetMaster0IPAndPort()
+			if err := events.Run(cluster.Name, "guest "+m.Name, master0, func() error {
+				return execer.CmdAsync(master0,
+					stringsutil.RenderShellWithEnv(strings.Join(cmds, "; "), envs),
+				)
+			}); err != nil {
 				return err
 			}
 		}
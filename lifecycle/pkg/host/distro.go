@@ -0,0 +1,114 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Family string
+
+const (
+	FamilyDebian  Family = "debian"
+	FamilyRHEL    Family = "rhel"
+	FamilyUnknown Family = "unknown"
+)
+
+// PackageExt returns the extension of offline packages used by the family.
+func (f Family) PackageExt() string {
+	switch f {
+	case FamilyDebian:
+		return ".deb"
+	case FamilyRHEL:
+		return ".rpm"
+	}
+	return ""
+}
+
+// InstallCommand returns the command to install local package files.
+func (f Family) InstallCommand(files []string) (string, []string) {
+	switch f {
+	case FamilyDebian:
+		return "dpkg", append([]string{"-i", "--force-confold"}, files...)
+	case FamilyRHEL:
+		return "rpm", append([]string{"-Uvh", "--replacepkgs"}, files...)
+	}
+	return "", nil
+}
+
+var familyIDs = map[string]Family{
+	"debian":    FamilyDebian,
+	"ubuntu":    FamilyDebian,
+	"deepin":    FamilyDebian,
+	"uos":       FamilyDebian,
+	"rhel":      FamilyRHEL,
+	"centos":    FamilyRHEL,
+	"fedora":    FamilyRHEL,
+	"rocky":     FamilyRHEL,
+	"almalinux": FamilyRHEL,
+	"anolis":    FamilyRHEL,
+	"openeuler": FamilyRHEL,
+	"kylin":     FamilyRHEL,
+	"ol":        FamilyRHEL,
+}
+
+type Distro struct {
+	ID        string
+	VersionID string
+	Family    Family
+}
+
+func (d *Distro) String() string {
+	return fmt.Sprintf("%s %s (%s)", d.ID, d.VersionID, d.Family)
+}
+
+// DetectDistro parses the os-release file to find the distro family,
+// ID_LIKE is used as a fallback for derivatives.
+func DetectDistro(osReleasePath string) (*Distro, error) {
+	data, err := os.ReadFile(osReleasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", osReleasePath, err)
+	}
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[k] = strings.Trim(v, `"'`)
+	}
+	d := &Distro{
+		ID:        strings.ToLower(values["ID"]),
+		VersionID: values["VERSION_ID"],
+		Family:    FamilyUnknown,
+	}
+	candidates := append([]string{d.ID}, strings.Fields(strings.ToLower(values["ID_LIKE"]))...)
+	for _, id := range candidates {
+		if f, ok := familyIDs[id]; ok {
+			d.Family = f
+			break
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,331 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/labring/sealos/pkg/utils/file"
+	"github.com/labring/sealos/pkg/utils/logger"
+)
+
+const (
+	osReleasePath   = "/etc/os-release"
+	modulesLoadPath = "/etc/modules-load.d/sealos.conf"
+	sysctlPath      = "/etc/sysctl.d/99-sealos.conf"
+	procModulesPath = "/proc/modules"
+	procSysPath     = "/proc/sys"
+)
+
+const (
+	KindPackage = "package"
+	KindModule  = "module"
+	KindSysctl  = "sysctl"
+)
+
+const (
+	ActionOK        = "ok"
+	ActionInstalled = "installed"
+	ActionLoaded    = "loaded"
+	ActionSet       = "set"
+	ActionPersisted = "persisted"
+	ActionMissing   = "missing"
+	ActionFailed    = "failed"
+)
+
+type Options struct {
+	// PackagesDir holds offline packages, laid out as <dir>/<family>/<arch>/*.{deb,rpm}.
+	PackagesDir string
+	Binaries    []string
+	Modules     []string
+	Sysctls     map[string]string
+	// CheckOnly only validates the host and never changes it.
+	CheckOnly bool
+	// Strict returns an error on any unmet prerequisite instead of a warning.
+	Strict bool
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		Binaries: []string{"conntrack", "socat", "ipset"},
+		Modules:  []string{"overlay", "br_netfilter", "ip_vs", "ip_vs_rr", "ip_vs_wrr", "ip_vs_sh", "nf_conntrack"},
+		Sysctls: map[string]string{
+			"net.ipv4.ip_forward":                 "1",
+			"net.bridge.bridge-nf-call-iptables":  "1",
+			"net.bridge.bridge-nf-call-ip6tables": "1",
+		},
+	}
+}
+
+type Change struct {
+	Kind   string
+	Item   string
+	Action string
+	Detail string
+}
+
+type Report struct {
+	Distro  *Distro
+	Changes []Change
+}
+
+// Failed returns the changes that leave the host unprepared.
+func (r *Report) Failed() []Change {
+	var ret []Change
+	for _, c := range r.Changes {
+		if c.Action == ActionMissing || c.Action == ActionFailed {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func (r *Report) add(kind, item, action, detail string) {
+	r.Changes = append(r.Changes, Change{Kind: kind, Item: item, Action: action, Detail: detail})
+}
+
+type Preparer struct {
+	opts *Options
+	// root is the prefix of all host paths, used for testing.
+	root     string
+	arch     string
+	run      func(name string, args ...string) ([]byte, error)
+	lookPath func(string) (string, error)
+}
+
+func NewPreparer(opts *Options) *Preparer {
+	return &Preparer{
+		opts: opts,
+		arch: runtime.GOARCH,
+		run: func(name string, args ...string) ([]byte, error) {
+			// nosemgrep: go.lang.security.audit.dangerous-exec-command.dangerous-exec-command
+			return exec.Command(name, args...).CombinedOutput() // #nosec
+		},
+		lookPath: exec.LookPath,
+	}
+}
+
+func (p *Preparer) path(elem ...string) string {
+	return filepath.Join(append([]string{p.root}, elem...)...)
+}
+
+// Prepare installs or validates the prerequisites and reports what it changed.
+func (p *Preparer) Prepare() (*Report, error) {
+	distro, err := DetectDistro(p.path(osReleasePath))
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Distro: distro}
+	logger.Info("preparing host of distro %s", distro)
+
+	if err = p.preparePackages(report); err != nil {
+		return report, err
+	}
+	if err = p.prepareModules(report); err != nil {
+		return report, err
+	}
+	if err = p.prepareSysctls(report); err != nil {
+		return report, err
+	}
+	if failed := report.Failed(); len(failed) > 0 {
+		for _, c := range failed {
+			logger.Warn("host prerequisite %s %s is %s: %s", c.Kind, c.Item, c.Action, c.Detail)
+		}
+		if p.opts.Strict {
+			return report, fmt.Errorf("%d host prerequisites are not satisfied", len(failed))
+		}
+	}
+	return report, nil
+}
+
+func (p *Preparer) missingBinaries() []string {
+	var missing []string
+	for _, bin := range p.opts.Binaries {
+		if _, err := p.lookPath(bin); err != nil {
+			missing = append(missing, bin)
+		}
+	}
+	return missing
+}
+
+func (p *Preparer) preparePackages(report *Report) error {
+	missing := p.missingBinaries()
+	for _, bin := range p.opts.Binaries {
+		if !slices.Contains(missing, bin) {
+			report.add(KindPackage, bin, ActionOK, "")
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if p.opts.CheckOnly {
+		for _, bin := range missing {
+			report.add(KindPackage, bin, ActionMissing, "not found in PATH")
+		}
+		return nil
+	}
+	packages, err := p.offlinePackages(report.Distro.Family)
+	if err != nil {
+		return err
+	}
+	if len(packages) == 0 {
+		for _, bin := range missing {
+			report.add(KindPackage, bin, ActionMissing, "no offline package available")
+		}
+		return nil
+	}
+	name, args := report.Distro.Family.InstallCommand(packages)
+	logger.Info("installing %d offline packages for %v", len(packages), missing)
+	if out, err := p.run(name, args...); err != nil {
+		logger.Debug("failed to install offline packages: %s", out)
+		for _, bin := range missing {
+			report.add(KindPackage, bin, ActionFailed, fmt.Sprintf("%s: %v", name, err))
+		}
+		return nil
+	}
+	stillMissing := p.missingBinaries()
+	for _, bin := range missing {
+		if slices.Contains(stillMissing, bin) {
+			report.add(KindPackage, bin, ActionMissing, "not provided by offline packages")
+		} else {
+			report.add(KindPackage, bin, ActionInstalled, "")
+		}
+	}
+	return nil
+}
+
+func (p *Preparer) offlinePackages(family Family) ([]string, error) {
+	if p.opts.PackagesDir == "" || family == FamilyUnknown {
+		return nil, nil
+	}
+	dir := filepath.Join(p.opts.PackagesDir, string(family), p.arch)
+	if !file.IsDir(dir) {
+		logger.Debug("offline packages dir %s not found", dir)
+		return nil, nil
+	}
+	return file.GetFileListBySuffix(dir, family.PackageExt())
+}
+
+func (p *Preparer) loadedModules() (map[string]struct{}, error) {
+	data, err := os.ReadFile(p.path(procModulesPath))
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[string]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			loaded[fields[0]] = struct{}{}
+		}
+	}
+	return loaded, nil
+}
+
+func (p *Preparer) prepareModules(report *Report) error {
+	if len(p.opts.Modules) == 0 {
+		return nil
+	}
+	loaded, err := p.loadedModules()
+	if err != nil {
+		return err
+	}
+	// only the modules that are loaded are persisted, a failed one is retried on the next run
+	var applied []string
+	for _, m := range p.opts.Modules {
+		if _, ok := loaded[m]; ok {
+			report.add(KindModule, m, ActionOK, "")
+			applied = append(applied, m)
+			continue
+		}
+		if p.opts.CheckOnly {
+			report.add(KindModule, m, ActionMissing, "not loaded")
+			continue
+		}
+		if out, err := p.run("modprobe", m); err != nil {
+			report.add(KindModule, m, ActionFailed, strings.TrimSpace(string(out)))
+			continue
+		}
+		report.add(KindModule, m, ActionLoaded, "")
+		applied = append(applied, m)
+	}
+	if p.opts.CheckOnly || len(applied) == 0 {
+		return nil
+	}
+	content := strings.Join(applied, "\n") + "\n"
+	return p.persist(report, KindModule, modulesLoadPath, content)
+}
+
+func (p *Preparer) prepareSysctls(report *Report) error {
+	if len(p.opts.Sysctls) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(p.opts.Sysctls))
+	for k := range p.opts.Sysctls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	// only the sysctls that are set are persisted, a failed one is retried on the next run
+	var lines []string
+	for _, k := range keys {
+		want := p.opts.Sysctls[k]
+		current, err := os.ReadFile(p.path(procSysPath, strings.ReplaceAll(k, ".", "/")))
+		if err == nil && strings.TrimSpace(string(current)) == want {
+			report.add(KindSysctl, k, ActionOK, want)
+			lines = append(lines, fmt.Sprintf("%s = %s", k, want))
+			continue
+		}
+		if p.opts.CheckOnly {
+			report.add(KindSysctl, k, ActionMissing, fmt.Sprintf("want %s, got %q", want, strings.TrimSpace(string(current))))
+			continue
+		}
+		if out, err := p.run("sysctl", "-w", fmt.Sprintf("%s=%s", k, want)); err != nil {
+			report.add(KindSysctl, k, ActionFailed, strings.TrimSpace(string(out)))
+			continue
+		}
+		report.add(KindSysctl, k, ActionSet, want)
+		lines = append(lines, fmt.Sprintf("%s = %s", k, want))
+	}
+	if p.opts.CheckOnly || len(lines) == 0 {
+		return nil
+	}
+	return p.persist(report, KindSysctl, sysctlPath, strings.Join(lines, "\n")+"\n")
+}
+
+// persist writes the settings so that they survive reboot, the file is
+// only rewritten when its content changes.
+func (p *Preparer) persist(report *Report, kind, path, content string) error {
+	target := p.path(path)
+	if current, err := os.ReadFile(target); err == nil && string(current) == content {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := file.AtomicWriteFile(target, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to persist %s settings: %v", kind, err)
+	}
+	report.add(kind, path, ActionPersisted, "")
+	return nil
+}
@@ -0,0 +1,230 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectDistro(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease string
+		want      Family
+	}{
+		{
+			name:      "ubuntu",
+			osRelease: "NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"22.04\"\n",
+			want:      FamilyDebian,
+		},
+		{
+			name:      "rocky",
+			osRelease: "NAME=\"Rocky Linux\"\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"9.2\"\n",
+			want:      FamilyRHEL,
+		},
+		{
+			name:      "derivative",
+			osRelease: "ID=mydistro\nID_LIKE=\"centos\"\n",
+			want:      FamilyRHEL,
+		},
+		{
+			name:      "unknown",
+			osRelease: "ID=alpine\n",
+			want:      FamilyUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "os-release")
+			writeFile(t, path, tt.osRelease)
+			got, err := DetectDistro(path)
+			if err != nil {
+				t.Fatalf("DetectDistro() error = %v", err)
+			}
+			if got.Family != tt.want {
+				t.Errorf("DetectDistro() family = %v, want %v", got.Family, tt.want)
+			}
+		})
+	}
+}
+
+type fakeHost struct {
+	installed map[string]bool
+	// failing are the commands that fail
+	failing  map[string]bool
+	commands []string
+}
+
+func newTestPreparer(t *testing.T, opts *Options, h *fakeHost) *Preparer {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, osReleasePath), "ID=ubuntu\nVERSION_ID=22.04\n")
+	writeFile(t, filepath.Join(root, procModulesPath), "overlay 151552 0 - Live 0x0000000000000000\n")
+	writeFile(t, filepath.Join(root, procSysPath, "net/ipv4/ip_forward"), "1\n")
+	return &Preparer{
+		opts: opts,
+		root: root,
+		arch: "amd64",
+		run: func(name string, args ...string) ([]byte, error) {
+			command := name + " " + strings.Join(args, " ")
+			h.commands = append(h.commands, command)
+			if h.failing[command] {
+				return []byte("failed"), errors.New("exit status 1")
+			}
+			if name == "dpkg" {
+				h.installed["conntrack"] = true
+			}
+			return nil, nil
+		},
+		lookPath: func(bin string) (string, error) {
+			if h.installed[bin] {
+				return "/usr/bin/" + bin, nil
+			}
+			return "", errors.New("not found")
+		},
+	}
+}
+
+func testOptions(pkgDir string) *Options {
+	return &Options{
+		PackagesDir: pkgDir,
+		Binaries:    []string{"conntrack", "socat"},
+		Modules:     []string{"overlay", "br_netfilter"},
+		Sysctls: map[string]string{
+			"net.ipv4.ip_forward":                "1",
+			"net.bridge.bridge-nf-call-iptables": "1",
+		},
+	}
+}
+
+func actions(r *Report) map[string]string {
+	ret := make(map[string]string)
+	for _, c := range r.Changes {
+		ret[c.Kind+"/"+c.Item] = c.Action
+	}
+	return ret
+}
+
+func TestPrepare(t *testing.T) {
+	pkgDir := t.TempDir()
+	writeFile(t, filepath.Join(pkgDir, "debian", "amd64", "conntrack_1.4.6_amd64.deb"), "")
+	h := &fakeHost{installed: map[string]bool{"socat": true}}
+	p := newTestPreparer(t, testOptions(pkgDir), h)
+
+	report, err := p.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	want := map[string]string{
+		"package/conntrack":                         ActionInstalled,
+		"package/socat":                             ActionOK,
+		"module/overlay":                            ActionOK,
+		"module/br_netfilter":                       ActionLoaded,
+		"module/" + modulesLoadPath:                 ActionPersisted,
+		"sysctl/net.ipv4.ip_forward":                ActionOK,
+		"sysctl/net.bridge.bridge-nf-call-iptables": ActionSet,
+		"sysctl/" + sysctlPath:                      ActionPersisted,
+	}
+	got := actions(report)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Prepare() %s = %q, want %q", k, got[k], v)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(p.root, sysctlPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "net.bridge.bridge-nf-call-iptables = 1") {
+		t.Errorf("sysctl settings are not persisted: %s", data)
+	}
+
+	// the second run must not rewrite the persisted files
+	report, err = p.Prepare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := actions(report)["sysctl/"+sysctlPath]; ok {
+		t.Errorf("Prepare() rewrote unchanged %s", sysctlPath)
+	}
+}
+
+func TestPrepareCheckOnly(t *testing.T) {
+	h := &fakeHost{installed: map[string]bool{}}
+	opts := testOptions("")
+	opts.CheckOnly = true
+	opts.Strict = true
+	p := newTestPreparer(t, opts, h)
+
+	report, err := p.Prepare()
+	if err == nil {
+		t.Fatalf("Prepare() in strict mode must fail on unmet prerequisites")
+	}
+	if len(h.commands) != 0 {
+		t.Errorf("Prepare() in check mode ran commands %v", h.commands)
+	}
+	if n := len(report.Failed()); n != 4 {
+		t.Errorf("Prepare() failed = %d, want 4", n)
+	}
+	if _, err := os.Stat(filepath.Join(p.root, modulesLoadPath)); !os.IsNotExist(err) {
+		t.Errorf("Prepare() in check mode must not persist settings")
+	}
+}
+
+func TestPrepareNotPersistFailed(t *testing.T) {
+	h := &fakeHost{
+		installed: map[string]bool{"conntrack": true, "socat": true},
+		failing: map[string]bool{
+			"modprobe br_netfilter":                          true,
+			"sysctl -w net.bridge.bridge-nf-call-iptables=1": true,
+		},
+	}
+	p := newTestPreparer(t, testOptions(""), h)
+
+	report, err := p.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if n := len(report.Failed()); n != 2 {
+		t.Errorf("Prepare() failed = %d, want 2", n)
+	}
+	modules, err := os.ReadFile(filepath.Join(p.root, modulesLoadPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(modules) != "overlay\n" {
+		t.Errorf("Prepare() persisted modules %q, want only the loaded ones", modules)
+	}
+	sysctls, err := os.ReadFile(filepath.Join(p.root, sysctlPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sysctls) != "net.ipv4.ip_forward = 1\n" {
+		t.Errorf("Prepare() persisted sysctls %q, want only the set ones", sysctls)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import "errors"
+
+// ErrNothingToAdopt is returned by AdoptDrift when none of the drifts found on the
+// host can be taken into the runtime config.
+var ErrNothingToAdopt = errors.New("no drift can be adopted")
+
+// Drift is a setting of a component on a host whose live value is different
+// from the value rendered by sealos. An empty Desired means the setting is only
+// present on the host, an empty Live means it is missing on the host.
+type Drift struct {
+	Host      string
+	Component string
+	Key       string
+	Desired   string
+	Live      string
+}
+
+type DriftManager interface {
+	// DetectDrift compares the live configs of all masters with the desired ones.
+	DetectDrift() ([]Drift, error)
+	// AdoptDrift takes the live values found on host into the runtime config
+	// and returns the updated runtime config components. It returns ErrNothingToAdopt
+	// when no drift of host can be adopted.
+	AdoptDrift(host string, drifts []Drift) ([]any, error)
+	// ReapplyDrift overwrites the live values with the desired ones.
+	ReapplyDrift(drifts []Drift) error
+}
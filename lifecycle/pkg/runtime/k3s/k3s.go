@@ -25,6 +25,7 @@ import (
 
 	"github.com/labring/sealos/pkg/constants"
 	"github.com/labring/sealos/pkg/env"
+	"github.com/labring/sealos/pkg/events"
 	"github.com/labring/sealos/pkg/exec"
 	"github.com/labring/sealos/pkg/ssh"
 	v2 "github.com/labring/sealos/pkg/types/v1beta1"
@@ -146,10 +147,12 @@ func (k *K3s) SyncNodeIPVS(mastersIPList, nodeIPList []string) error {
 
 func (k *K3s) runPipelines(phase string, pipelines ...func() error) error {
 	logger.Info("starting %s", phase)
-	for i := range pipelines {
-		if err := pipelines[i](); err != nil {
-			return fmt.Errorf("failed to %s: %v", phase, err)
+	return events.Run(k.cluster.GetName(), phase, "", func() error {
+		for i := range pipelines {
+			if err := pipelines[i](); err != nil {
+				return fmt.Errorf("failed to %s: %v", phase, err)
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
@@ -0,0 +1,696 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	"sigs.k8s.io/yaml"
+
+	"github.com/labring/sealos/pkg/client-go/kubernetes"
+	"github.com/labring/sealos/pkg/runtime"
+	"github.com/labring/sealos/pkg/runtime/kubernetes/types"
+	fileutil "github.com/labring/sealos/pkg/utils/file"
+	"github.com/labring/sealos/pkg/utils/iputils"
+	"github.com/labring/sealos/pkg/utils/logger"
+)
+
+const (
+	staticPodManifestsDir      = "/etc/kubernetes/manifests"
+	kubeletConfigPath          = "/var/lib/kubelet/config.yaml"
+	containerdConfigPath       = "/etc/containerd/config.toml"
+	rootfsContainerdConfigName = "config.toml"
+
+	etcdComponent       = "etcd"
+	kubeletComponent    = "kubelet"
+	containerdComponent = "containerd"
+	// driftImageKey is the key of the image drift of a static pod.
+	driftImageKey = "image"
+)
+
+type staticPodComponent struct {
+	name string
+	// path of the component in ClusterConfiguration.
+	path      []string
+	extraArgs func(*kubeadm.ClusterConfiguration) *[]kubeadm.Arg
+	// flags rendered by kubeadm itself are only compared if they are overridden by extraArgs.
+	managed sets.Set[string]
+	// image tag of the component follows the kubernetes version.
+	versioned bool
+}
+
+var staticPodComponents = []staticPodComponent{
+	{
+		name: kubernetes.KubeAPIServer,
+		path: []string{"apiServer"},
+		extraArgs: func(c *kubeadm.ClusterConfiguration) *[]kubeadm.Arg {
+			return &c.APIServer.ExtraArgs
+		},
+		managed: sets.New[string]("advertise-address", "allow-privileged", "authorization-mode", "client-ca-file",
+			"enable-admission-plugins", "enable-bootstrap-token-auth", "etcd-cafile", "etcd-certfile", "etcd-keyfile",
+			"etcd-servers", "kubelet-client-certificate", "kubelet-client-key", "kubelet-preferred-address-types",
+			"proxy-client-cert-file", "proxy-client-key-file", "requestheader-allowed-names",
+			"requestheader-client-ca-file", "requestheader-extra-headers-prefix", "requestheader-group-headers",
+			"requestheader-username-headers", "secure-port", "service-account-issuer", "service-account-key-file",
+			"service-account-signing-key-file", "service-cluster-ip-range", "tls-cert-file", "tls-private-key-file",
+			"feature-gates"),
+		versioned: true,
+	},
+	{
+		name: kubernetes.KubeControllerManager,
+		path: []string{"controllerManager"},
+		extraArgs: func(c *kubeadm.ClusterConfiguration) *[]kubeadm.Arg {
+			return &c.ControllerManager.ExtraArgs
+		},
+		managed: sets.New[string]("allocate-node-cidrs", "authentication-kubeconfig", "authorization-kubeconfig",
+			"bind-address", "client-ca-file", "cluster-cidr", "cluster-name", "cluster-signing-cert-file",
+			"cluster-signing-key-file", "controllers", "kubeconfig", "leader-elect", "node-cidr-mask-size",
+			"requestheader-client-ca-file", "root-ca-file", "service-account-private-key-file",
+			"service-cluster-ip-range", "use-service-account-credentials", "feature-gates"),
+		versioned: true,
+	},
+	{
+		name: kubernetes.KubeScheduler,
+		path: []string{"scheduler"},
+		extraArgs: func(c *kubeadm.ClusterConfiguration) *[]kubeadm.Arg {
+			return &c.Scheduler.ExtraArgs
+		},
+		managed: sets.New[string]("authentication-kubeconfig", "authorization-kubeconfig", "bind-address",
+			"kubeconfig", "leader-elect", "feature-gates"),
+		versioned: true,
+	},
+	{
+		name: etcdComponent,
+		path: []string{"etcd", "local"},
+		extraArgs: func(c *kubeadm.ClusterConfiguration) *[]kubeadm.Arg {
+			if c.Etcd.Local == nil {
+				return nil
+			}
+			return &c.Etcd.Local.ExtraArgs
+		},
+		managed: sets.New[string]("advertise-client-urls", "cert-file", "client-cert-auth", "data-dir",
+			"experimental-initial-corrupt-check", "experimental-watch-progress-notify-interval",
+			"initial-advertise-peer-urls", "initial-cluster", "initial-cluster-state", "key-file",
+			"listen-client-urls", "listen-metrics-urls", "listen-peer-urls", "name", "peer-cert-file",
+			"peer-client-cert-auth", "peer-key-file", "peer-trusted-ca-file", "snapshot-count", "trusted-ca-file"),
+	},
+}
+
+func findStaticPodComponent(name string) (staticPodComponent, bool) {
+	for _, c := range staticPodComponents {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return staticPodComponent{}, false
+}
+
+// kubelet settings that are rendered for each node.
+var kubeletIgnoredKeys = sets.New[string]("apiVersion", "kind", "cgroupDriver", "containerRuntimeEndpoint")
+
+// desiredConfig renders the kubeadm config the same way as GetRawConfig.
+func (k *KubeadmRuntime) desiredConfig() (*types.KubeadmConfig, map[string]interface{}, error) {
+	k.kubeadmConfig = types.NewKubeadmConfig()
+	if err := k.CompleteKubeadmConfig(); err != nil {
+		return nil, nil, err
+	}
+	conversion, err := k.kubeadmConfig.ToConvertedKubeadmConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	kubelet, err := toMap(conversion.KubeletConfiguration)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k.kubeadmConfig, kubelet, nil
+}
+
+func (k *KubeadmRuntime) DetectDrift() ([]runtime.Drift, error) {
+	cfg, kubelet, err := k.desiredConfig()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		mu     sync.Mutex
+		drifts []runtime.Drift
+	)
+	eg, _ := errgroup.WithContext(context.Background())
+	for _, master := range k.getMasterIPAndPortList() {
+		master := master
+		eg.Go(func() error {
+			ret, err := k.detectHostDrift(master, cfg, kubelet)
+			if err != nil {
+				return fmt.Errorf("failed to detect drift on %s: %v", master, err)
+			}
+			mu.Lock()
+			drifts = append(drifts, ret...)
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(drifts, func(i, j int) bool {
+		return drifts[i].Host < drifts[j].Host
+	})
+	return drifts, nil
+}
+
+func (k *KubeadmRuntime) detectHostDrift(host string, cfg *types.KubeadmConfig, kubelet map[string]interface{}) ([]runtime.Drift, error) {
+	var drifts []runtime.Drift
+	for _, c := range staticPodComponents {
+		args := c.extraArgs(&cfg.ClusterConfiguration)
+		if args == nil {
+			continue
+		}
+		pod, err := k.fetchStaticPod(host, c.name)
+		if err != nil {
+			return nil, err
+		}
+		container := findContainer(pod, c.name)
+		if container == nil {
+			return nil, fmt.Errorf("container %s not found in static pod manifest", c.name)
+		}
+		drifts = append(drifts, diffSettings(c.name, argsToMap(*args), parseFlags(container), c.managed)...)
+		if c.versioned {
+			if tag := imageTag(container.Image); tag != cfg.KubernetesVersion {
+				drifts = append(drifts, runtime.Drift{Component: c.name, Key: driftImageKey, Desired: cfg.KubernetesVersion, Live: tag})
+			}
+		}
+	}
+
+	live, err := k.fetchKubeletConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	drifts = append(drifts, diffDesiredSettings(kubeletComponent, flatten(kubelet, kubeletIgnoredKeys), flatten(live, kubeletIgnoredKeys))...)
+
+	desired, err := k.execer.Cmd(host, fmt.Sprintf("cat %s", path.Join(k.pathResolver.RootFSEtcPath(), rootfsContainerdConfigName)))
+	if err != nil {
+		logger.Debug("containerd config is not rendered by rootfs on %s, skip it", host)
+	} else {
+		// a missing config is reported as drift.
+		out, _ := k.execer.Cmd(host, fmt.Sprintf("cat %s", containerdConfigPath))
+		if string(out) != string(desired) {
+			drifts = append(drifts, runtime.Drift{Component: containerdComponent, Key: containerdConfigPath, Desired: string(desired), Live: string(out)})
+		}
+	}
+	for i := range drifts {
+		drifts[i].Host = host
+	}
+	return drifts, nil
+}
+
+func (k *KubeadmRuntime) AdoptDrift(host string, drifts []runtime.Drift) ([]any, error) {
+	drifts = adoptableDrifts(host, drifts)
+	if len(drifts) == 0 {
+		return nil, fmt.Errorf("%w on %s", runtime.ErrNothingToAdopt, host)
+	}
+	cfg, kubelet, err := k.desiredConfig()
+	if err != nil {
+		return nil, err
+	}
+	live, err := k.fetchKubeletConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	adopted := make(map[string][]kubeadm.Arg)
+	var kubeletDrifts []runtime.Drift
+	for _, d := range drifts {
+		switch d.Component {
+		case kubeletComponent:
+			if err = adoptSetting(kubelet, live, d); err != nil {
+				return nil, err
+			}
+			kubeletDrifts = append(kubeletDrifts, d)
+		default:
+			c, ok := findStaticPodComponent(d.Component)
+			if !ok {
+				return nil, fmt.Errorf("unknown component %s", d.Component)
+			}
+			args := c.extraArgs(&cfg.ClusterConfiguration)
+			*args = setArg(*args, d.Key, d.Live)
+			adopted[c.name] = *args
+		}
+	}
+	if err = fromMap(kubelet, &cfg.KubeletConfiguration); err != nil {
+		return nil, err
+	}
+	if err = k.uploadAdoptedConfig(adopted, kubeletDrifts, live); err != nil {
+		return nil, err
+	}
+	return cfg.GetComponents(), nil
+}
+
+// adoptableDrifts returns the drifts of host that can be taken into the runtime config.
+func adoptableDrifts(host string, drifts []runtime.Drift) []runtime.Drift {
+	var ret []runtime.Drift
+	for _, d := range drifts {
+		if d.Host != host {
+			continue
+		}
+		switch {
+		case d.Component == containerdComponent:
+			logger.Warn("containerd config is rendered from the rootfs, %s on %s cannot be adopted", d.Key, host)
+		case d.Component != kubeletComponent && d.Key == driftImageKey:
+			logger.Warn("image of %s on %s follows the cluster image, it cannot be adopted", d.Component, host)
+		default:
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// uploadAdoptedConfig updates the kubeadm configmaps so that joining and upgrading
+// with kubeadm render the adopted values as well.
+func (k *KubeadmRuntime) uploadAdoptedConfig(adopted map[string][]kubeadm.Arg, kubeletDrifts []runtime.Drift, live map[string]interface{}) error {
+	exp, err := k.getKubeExpansion()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if len(adopted) > 0 {
+		data, err := exp.FetchKubeadmConfig(ctx)
+		if err != nil {
+			return err
+		}
+		obj, err := toMapFromYAML([]byte(data))
+		if err != nil {
+			return err
+		}
+		listArgs := strings.HasSuffix(fmt.Sprint(obj["apiVersion"]), types.KubeadmV1beta4)
+		for name, args := range adopted {
+			c, _ := findStaticPodComponent(name)
+			if err = unstructured.SetNestedField(obj, encodeArgs(args, listArgs), append(c.path, "extraArgs")...); err != nil {
+				return err
+			}
+		}
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		logger.Info("update kubeadm-config with adopted extraArgs")
+		if err = exp.UpdateKubeadmConfig(ctx, string(out)); err != nil {
+			return err
+		}
+	}
+	if len(kubeletDrifts) > 0 {
+		data, err := exp.FetchKubeletConfig(ctx)
+		if err != nil {
+			return err
+		}
+		obj, err := toMapFromYAML([]byte(data))
+		if err != nil {
+			return err
+		}
+		for _, d := range kubeletDrifts {
+			if err = adoptSetting(obj, live, d); err != nil {
+				return err
+			}
+		}
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		logger.Info("update kubelet-config with adopted settings")
+		return exp.UpdateKubeletConfig(ctx, string(out))
+	}
+	return nil
+}
+
+func (k *KubeadmRuntime) ReapplyDrift(drifts []runtime.Drift) error {
+	_, kubelet, err := k.desiredConfig()
+	if err != nil {
+		return err
+	}
+	byHost := make(map[string][]runtime.Drift)
+	var hosts []string
+	for _, d := range drifts {
+		if _, ok := byHost[d.Host]; !ok {
+			hosts = append(hosts, d.Host)
+		}
+		byHost[d.Host] = append(byHost[d.Host], d)
+	}
+	// masters are restarted one by one to keep the control plane available.
+	for _, host := range hosts {
+		if err = k.reapplyHostDrift(host, byHost[host], kubelet); err != nil {
+			return fmt.Errorf("failed to reapply desired config on %s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+func (k *KubeadmRuntime) reapplyHostDrift(host string, drifts []runtime.Drift, kubelet map[string]interface{}) error {
+	byComponent := make(map[string][]runtime.Drift)
+	for _, d := range drifts {
+		byComponent[d.Component] = append(byComponent[d.Component], d)
+	}
+	for _, c := range staticPodComponents {
+		ds, ok := byComponent[c.name]
+		if !ok {
+			continue
+		}
+		pod, err := k.fetchStaticPod(host, c.name)
+		if err != nil {
+			return err
+		}
+		container := findContainer(pod, c.name)
+		if container == nil {
+			return fmt.Errorf("container %s not found in static pod manifest", c.name)
+		}
+		patchContainer(container, ds)
+		data, err := yaml.Marshal(pod)
+		if err != nil {
+			return err
+		}
+		logger.Info("reapply static pod manifest of %s on %s", c.name, host)
+		if err = k.copyDriftFile(host, data, path.Join(staticPodManifestsDir, c.name+".yaml")); err != nil {
+			return err
+		}
+	}
+	if _, ok := byComponent[containerdComponent]; ok {
+		logger.Info("reapply containerd config on %s", host)
+		if err := k.sshCmdAsync(host, fmt.Sprintf("cp -f %s %s && systemctl restart containerd",
+			path.Join(k.pathResolver.RootFSEtcPath(), rootfsContainerdConfigName), containerdConfigPath)); err != nil {
+			return err
+		}
+	}
+	if ds, ok := byComponent[kubeletComponent]; ok {
+		live, err := k.fetchKubeletConfig(host)
+		if err != nil {
+			return err
+		}
+		for _, d := range ds {
+			if err = reapplySetting(live, kubelet, d); err != nil {
+				return err
+			}
+		}
+		data, err := yaml.Marshal(live)
+		if err != nil {
+			return err
+		}
+		logger.Info("reapply kubelet config on %s", host)
+		if err = k.copyDriftFile(host, data, kubeletConfigPath); err != nil {
+			return err
+		}
+		return k.sshCmdAsync(host, "systemctl restart kubelet")
+	}
+	return nil
+}
+
+func (k *KubeadmRuntime) copyDriftFile(host string, data []byte, dst string) error {
+	src := path.Join(k.pathResolver.TmpPath(), fmt.Sprintf("drift-%s-%s", iputils.GetHostIP(host), path.Base(dst)))
+	if err := fileutil.WriteFile(src, data); err != nil {
+		return err
+	}
+	return k.sshCopy(host, src, dst)
+}
+
+func (k *KubeadmRuntime) fetchStaticPod(host, name string) (*v1.Pod, error) {
+	data, err := k.execer.Cmd(host, fmt.Sprintf("cat %s", path.Join(staticPodManifestsDir, name+".yaml")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static pod manifest of %s: %v", name, err)
+	}
+	pod := &v1.Pod{}
+	if err = yaml.Unmarshal(data, pod); err != nil {
+		return nil, fmt.Errorf("failed to decode static pod manifest of %s: %v", name, err)
+	}
+	return pod, nil
+}
+
+func (k *KubeadmRuntime) fetchKubeletConfig(host string) (map[string]interface{}, error) {
+	data, err := k.execer.Cmd(host, fmt.Sprintf("cat %s", kubeletConfigPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubelet config: %v", err)
+	}
+	return toMapFromYAML(data)
+}
+
+func findContainer(pod *v1.Pod, name string) *v1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	return nil
+}
+
+func parseFlag(s string) (name, value string, ok bool) {
+	if !strings.HasPrefix(s, "--") {
+		return "", "", false
+	}
+	name, value, _ = strings.Cut(strings.TrimPrefix(s, "--"), "=")
+	return name, value, true
+}
+
+func parseFlags(c *v1.Container) map[string]string {
+	ret := make(map[string]string)
+	for _, s := range append(append([]string{}, c.Command...), c.Args...) {
+		if name, value, ok := parseFlag(s); ok {
+			ret[name] = value
+		}
+	}
+	return ret
+}
+
+func argsToMap(args []kubeadm.Arg) map[string]string {
+	ret := make(map[string]string, len(args))
+	for _, arg := range args {
+		ret[arg.Name] = arg.Value
+	}
+	return ret
+}
+
+func setArg(args []kubeadm.Arg, name, value string) []kubeadm.Arg {
+	ret := make([]kubeadm.Arg, 0, len(args)+1)
+	for _, arg := range args {
+		if arg.Name != name {
+			ret = append(ret, arg)
+		}
+	}
+	if value != "" {
+		ret = append(ret, kubeadm.Arg{Name: name, Value: value})
+	}
+	return ret
+}
+
+// encodeArgs encodes extraArgs as a list in v1beta4 and as a map in older versions.
+func encodeArgs(args []kubeadm.Arg, list bool) interface{} {
+	if list {
+		ret := make([]interface{}, 0, len(args))
+		for _, arg := range args {
+			ret = append(ret, map[string]interface{}{"name": arg.Name, "value": arg.Value})
+		}
+		return ret
+	}
+	ret := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		ret[arg.Name] = arg.Value
+	}
+	return ret
+}
+
+func imageTag(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
+
+func setImageTag(image, tag string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + ":" + tag
+}
+
+// patchContainer sets the desired flags and image of the drifts to the container.
+func patchContainer(c *v1.Container, drifts []runtime.Drift) {
+	want := make(map[string]runtime.Drift)
+	for _, d := range drifts {
+		if d.Key == driftImageKey {
+			c.Image = setImageTag(c.Image, d.Desired)
+			continue
+		}
+		want[d.Key] = d
+	}
+	patch := func(in []string) []string {
+		var ret []string
+		for _, s := range in {
+			name, _, ok := parseFlag(s)
+			d, drifted := want[name]
+			if !ok || !drifted {
+				ret = append(ret, s)
+				continue
+			}
+			delete(want, name)
+			if d.Desired != "" {
+				ret = append(ret, fmt.Sprintf("--%s=%s", name, d.Desired))
+			}
+		}
+		return ret
+	}
+	c.Command = patch(c.Command)
+	c.Args = patch(c.Args)
+	var missing []string
+	for name, d := range want {
+		if d.Desired != "" {
+			missing = append(missing, fmt.Sprintf("--%s=%s", name, d.Desired))
+		}
+	}
+	sort.Strings(missing)
+	if len(c.Command) > 0 {
+		c.Command = append(c.Command, missing...)
+	} else {
+		c.Args = append(c.Args, missing...)
+	}
+}
+
+// diffSettings compares the desired and live settings by key, keys in managed
+// are only compared if they are desired.
+func diffSettings(component string, desired, live map[string]string, managed sets.Set[string]) []runtime.Drift {
+	var drifts []runtime.Drift
+	for _, key := range sets.List(sets.KeySet(desired).Union(sets.KeySet(live))) {
+		d, inDesired := desired[key]
+		l, inLive := live[key]
+		switch {
+		case inDesired && inLive && d == l:
+		case !inDesired && managed.Has(key):
+		default:
+			drifts = append(drifts, runtime.Drift{Component: component, Key: key, Desired: d, Live: l})
+		}
+	}
+	return drifts
+}
+
+// diffDesiredSettings only compares the keys that desired sets, kubeadm injects
+// settings such as clusterDNS and clusterDomain into the kubelet config on each node.
+func diffDesiredSettings(component string, desired, live map[string]string) []runtime.Drift {
+	var drifts []runtime.Drift
+	for _, key := range sets.List(sets.KeySet(desired)) {
+		if l, ok := live[key]; !ok || l != desired[key] {
+			drifts = append(drifts, runtime.Drift{Component: component, Key: key, Desired: desired[key], Live: l})
+		}
+	}
+	return drifts
+}
+
+// flatten turns nested maps to dot separated keys, other values are encoded as JSON.
+func flatten(m map[string]interface{}, ignored sets.Set[string]) map[string]string {
+	ret := make(map[string]string)
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			key := prefix + k
+			if ignored.Has(key) || v == nil {
+				continue
+			}
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(key+".", sub)
+				continue
+			}
+			data, _ := json.Marshal(v)
+			ret[key] = string(data)
+		}
+	}
+	walk("", m)
+	return ret
+}
+
+// splitKey splits a flattened key into the fields of m, field names may contain dots,
+// eg. evictionHard.memory.available.
+func splitKey(m map[string]interface{}, key string) []string {
+	if _, ok := m[key]; ok {
+		return []string{key}
+	}
+	for k, v := range m {
+		sub, ok := v.(map[string]interface{})
+		if ok && strings.HasPrefix(key, k+".") {
+			if rest := splitKey(sub, strings.TrimPrefix(key, k+".")); rest != nil {
+				return append([]string{k}, rest...)
+			}
+		}
+	}
+	return nil
+}
+
+// setSetting sets the JSON encoded value to the field of dst located by src,
+// an empty value removes the field from dst.
+func setSetting(dst, src map[string]interface{}, key, value string) error {
+	fields := splitKey(src, key)
+	if fields == nil {
+		return fmt.Errorf("setting %s not found", key)
+	}
+	if value == "" {
+		unstructured.RemoveNestedField(dst, fields...)
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return err
+	}
+	return unstructured.SetNestedField(dst, v, fields...)
+}
+
+// adoptSetting takes the live value of the drift into desired.
+func adoptSetting(desired, live map[string]interface{}, d runtime.Drift) error {
+	if d.Live == "" {
+		return setSetting(desired, desired, d.Key, "")
+	}
+	return setSetting(desired, live, d.Key, d.Live)
+}
+
+// reapplySetting takes the desired value of the drift into live, a key is never
+// removed from live.
+func reapplySetting(live, desired map[string]interface{}, d runtime.Drift) error {
+	if d.Desired == "" {
+		return nil
+	}
+	return setSetting(live, desired, d.Key, d.Desired)
+}
+
+func toMap(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]interface{})
+	return ret, json.Unmarshal(data, &ret)
+}
+
+func toMapFromYAML(data []byte) (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+	return ret, yaml.Unmarshal(data, &ret)
+}
+
+func fromMap(m map[string]interface{}, obj interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, obj)
+}
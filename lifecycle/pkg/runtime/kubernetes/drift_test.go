@@ -0,0 +1,126 @@
+// Copyright © 2024 sealos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/labring/sealos/pkg/runtime"
+)
+
+func TestDiffSettings(t *testing.T) {
+	desired := map[string]string{"audit-log-maxage": "30", "bind-address": "0.0.0.0", "v": "2"}
+	live := map[string]string{"audit-log-maxage": "7", "bind-address": "0.0.0.0", "secure-port": "6443", "max-requests-inflight": "800"}
+	got := diffSettings("kube-apiserver", desired, live, sets.New[string]("secure-port"))
+	want := []runtime.Drift{
+		{Component: "kube-apiserver", Key: "audit-log-maxage", Desired: "30", Live: "7"},
+		{Component: "kube-apiserver", Key: "max-requests-inflight", Live: "800"},
+		{Component: "kube-apiserver", Key: "v", Desired: "2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSettings() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPatchContainer(t *testing.T) {
+	c := &v1.Container{
+		Image:   "registry.k8s.io/kube-apiserver:v1.25.0",
+		Command: []string{"kube-apiserver", "--audit-log-maxage=7", "--max-requests-inflight=800", "--secure-port=6443"},
+	}
+	patchContainer(c, []runtime.Drift{
+		{Key: "audit-log-maxage", Desired: "30", Live: "7"},
+		{Key: "max-requests-inflight", Live: "800"},
+		{Key: "v", Desired: "2"},
+		{Key: driftImageKey, Desired: "v1.25.1", Live: "v1.25.0"},
+	})
+	want := []string{"kube-apiserver", "--audit-log-maxage=30", "--secure-port=6443", "--v=2"}
+	if !reflect.DeepEqual(c.Command, want) {
+		t.Errorf("patchContainer() command = %v, want %v", c.Command, want)
+	}
+	if c.Image != "registry.k8s.io/kube-apiserver:v1.25.1" {
+		t.Errorf("patchContainer() image = %s", c.Image)
+	}
+}
+
+func TestKubeletSettings(t *testing.T) {
+	desired := map[string]interface{}{
+		"kind":         "KubeletConfiguration",
+		"cgroupDriver": "systemd",
+		"maxPods":      float64(110),
+		"evictionHard": map[string]interface{}{"memory.available": "100Mi"},
+	}
+	live := map[string]interface{}{
+		"kind":         "KubeletConfiguration",
+		"cgroupDriver": "cgroupfs",
+		"maxPods":      float64(220),
+		"evictionHard": map[string]interface{}{"memory.available": "500Mi"},
+		// injected by kubeadm, never a drift
+		"clusterDNS":    []interface{}{"10.96.0.10"},
+		"clusterDomain": "cluster.local",
+	}
+	drifts := diffDesiredSettings(kubeletComponent, flatten(desired, kubeletIgnoredKeys), flatten(live, kubeletIgnoredKeys))
+	if len(drifts) != 2 {
+		t.Fatalf("diffDesiredSettings() = %+v, want 2 drifts", drifts)
+	}
+	if drifts[0].Key != "evictionHard.memory.available" || drifts[1].Key != "maxPods" {
+		t.Fatalf("diffDesiredSettings() keys = %s, %s", drifts[0].Key, drifts[1].Key)
+	}
+	reapplied, err := toMap(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range append(drifts, runtime.Drift{Component: kubeletComponent, Key: "clusterDomain"}) {
+		if err = reapplySetting(reapplied, desired, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reapplied["maxPods"] != float64(110) {
+		t.Errorf("reapplySetting() maxPods = %v, want 110", reapplied["maxPods"])
+	}
+	if reapplied["clusterDomain"] != "cluster.local" {
+		t.Errorf("reapplySetting() must not remove clusterDomain, got %v", reapplied["clusterDomain"])
+	}
+	for _, d := range drifts {
+		if err := adoptSetting(desired, live, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := desired["evictionHard"].(map[string]interface{})["memory.available"]; got != "500Mi" {
+		t.Errorf("adoptSetting() evictionHard = %v, want 500Mi", got)
+	}
+	if desired["maxPods"] != float64(220) {
+		t.Errorf("adoptSetting() maxPods = %v, want 220", desired["maxPods"])
+	}
+}
+
+func TestAdoptableDrifts(t *testing.T) {
+	drifts := []runtime.Drift{
+		{Host: "192.168.0.2:22", Component: "kube-apiserver", Key: "audit-log-maxage", Desired: "30", Live: "7"},
+		{Host: "192.168.0.2:22", Component: "kube-apiserver", Key: driftImageKey, Desired: "v1.25.1", Live: "v1.25.0"},
+		{Host: "192.168.0.2:22", Component: containerdComponent, Key: "config.toml", Desired: "a", Live: "b"},
+		{Host: "192.168.0.3:22", Component: kubeletComponent, Key: "maxPods", Desired: "110", Live: "200"},
+	}
+	got := adoptableDrifts("192.168.0.2:22", drifts)
+	if !reflect.DeepEqual(got, drifts[:1]) {
+		t.Errorf("adoptableDrifts() = %+v, want %+v", got, drifts[:1])
+	}
+	if got = adoptableDrifts("192.168.0.4:22", drifts); len(got) != 0 {
+		t.Errorf("adoptableDrifts() of unknown host = %+v, want none", got)
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/labring/sealos/pkg/registry/helpers"
 
+	"github.com/labring/sealos/pkg/events"
 	"github.com/labring/sealos/pkg/ssh"
 	"github.com/labring/sealos/pkg/utils/file"
 	"github.com/labring/sealos/pkg/utils/logger"
@@ -38,6 +39,12 @@ import (
 func (k *KubeadmRuntime) InitMaster0() error {
 	logger.Info("start to init master0...")
 	master0 := k.getMaster0IPAndPort()
+	return events.Run(k.cluster.GetName(), "init master0", master0, func() error {
+		return k.initMaster0(master0)
+	})
+}
+
+func (k *KubeadmRuntime) initMaster0(master0 string) error {
 	if err := k.imagePull(master0, ""); err != nil {
 		return err
 	}
@@ -164,27 +171,9 @@ func (k *KubeadmRuntime) joinMasters(masters []string) error {
 	}
 	for _, master := range masters {
 		logger.Info("start to join %s as master", master)
-		if err = k.imagePull(master, ""); err != nil {
-			return err
-		}
-		logger.Debug("start to generate cert for master %s", master)
-		err = k.execCert(master)
-		if err != nil {
-			return fmt.Errorf("failed to create cert for master %s: %v", master, err)
-		}
-
-		err = k.sshCmdAsync(master, joinCmd)
-		if err != nil {
-			return fmt.Errorf("exec kubeadm join in %s failed %v", master, err)
-		}
-
-		err = k.execHostsAppend(master, master, k.getAPIServerDomain())
-		if err != nil {
-			return fmt.Errorf("add master0 apiserver domain hosts in %s failed %v", master, err)
-		}
-
-		err = k.copyMasterKubeConfig(master)
-		if err != nil {
+		if err = events.Run(k.cluster.GetName(), "join master", master, func() error {
+			return k.joinMaster(master, joinCmd)
+		}); err != nil {
 			return err
 		}
 		logger.Info("succeeded in joining %s as master", master)
@@ -192,6 +181,23 @@ func (k *KubeadmRuntime) joinMasters(masters []string) error {
 	return nil
 }
 
+func (k *KubeadmRuntime) joinMaster(master, joinCmd string) error {
+	if err := k.imagePull(master, ""); err != nil {
+		return err
+	}
+	logger.Debug("start to generate cert for master %s", master)
+	if err := k.execCert(master); err != nil {
+		return fmt.Errorf("failed to create cert for master %s: %v", master, err)
+	}
+	if err := k.sshCmdAsync(master, joinCmd); err != nil {
+		return fmt.Errorf("exec kubeadm join in %s failed %v", master, err)
+	}
+	if err := k.execHostsAppend(master, master, k.getAPIServerDomain()); err != nil {
+		return fmt.Errorf("add master0 apiserver domain hosts in %s failed %v", master, err)
+	}
+	return k.copyMasterKubeConfig(master)
+}
+
 func (k *KubeadmRuntime) SyncNodeIPVS(mastersIPList, nodeIPList []string) error {
 	return k.syncNodeIPVSYaml(str2.RemoveDuplicate(mastersIPList), nodeIPList)
 }
@@ -205,7 +211,9 @@ func (k *KubeadmRuntime) deleteMasters(masters []string) error {
 		master := master
 		eg.Go(func() error {
 			logger.Info("start to delete master %s", master)
-			if err := k.deleteMaster(master); err != nil {
+			if err := events.Run(k.cluster.GetName(), "delete master", master, func() error {
+				return k.deleteMaster(master)
+			}); err != nil {
 				logger.Error("delete master %s failed %v", master, err)
 			} else {
 				logger.Info("succeeded in deleting master %s", master)
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"path"
 
+	"github.com/labring/sealos/pkg/events"
 	"github.com/labring/sealos/pkg/ssh"
 	"github.com/labring/sealos/pkg/utils/file"
 	"github.com/labring/sealos/pkg/utils/iputils"
@@ -44,28 +45,30 @@ func (k *KubeadmRuntime) joinNodes(newNodesIPList []string) error {
 	for _, node := range newNodesIPList {
 		node := node
 		eg.Go(func() error {
-			logger.Info("start to join %s as worker", node)
-			k.mu.Lock()
-			err = k.copyKubeadmConfigToNode(node)
-			if err != nil {
-				return fmt.Errorf("failed to copy join node kubeadm config %s %v", node, err)
-			}
-			k.mu.Unlock()
-			logger.Info("run ipvs once module: %s", node)
-			err = k.execIPVS(node, masters)
-			if err != nil {
-				return fmt.Errorf("run ipvs once failed %v", err)
-			}
-			logger.Info("start join node: %s", node)
-			joinCmd := k.Command(JoinNode)
-			if joinCmd == "" {
-				return fmt.Errorf("get join node command failed, kubernetes version is %s", k.getKubeVersion())
-			}
-			if err = k.sshCmdAsync(node, joinCmd); err != nil {
-				return fmt.Errorf("failed to join node %s %v", node, err)
-			}
-			logger.Info("succeeded in joining %s as worker", node)
-			return nil
+			return events.Run(k.cluster.GetName(), "join node", node, func() error {
+				logger.Info("start to join %s as worker", node)
+				k.mu.Lock()
+				err = k.copyKubeadmConfigToNode(node)
+				if err != nil {
+					return fmt.Errorf("failed to copy join node kubeadm config %s %v", node, err)
+				}
+				k.mu.Unlock()
+				logger.Info("run ipvs once module: %s", node)
+				err = k.execIPVS(node, masters)
+				if err != nil {
+					return fmt.Errorf("run ipvs once failed %v", err)
+				}
+				logger.Info("start join node: %s", node)
+				joinCmd := k.Command(JoinNode)
+				if joinCmd == "" {
+					return fmt.Errorf("get join node command failed, kubernetes version is %s", k.getKubeVersion())
+				}
+				if err = k.sshCmdAsync(node, joinCmd); err != nil {
+					return fmt.Errorf("failed to join node %s %v", node, err)
+				}
+				logger.Info("succeeded in joining %s as worker", node)
+				return nil
+			})
 		})
 	}
 	return eg.Wait()
@@ -99,7 +102,9 @@ func (k *KubeadmRuntime) deleteNodes(nodes []string) error {
 		node := node
 		eg.Go(func() error {
 			logger.Info("start to delete worker %s", node)
-			if err := k.deleteNode(node); err != nil {
+			if err := events.Run(k.cluster.GetName(), "delete node", node, func() error {
+				return k.deleteNode(node)
+			}); err != nil {
 				return fmt.Errorf("delete node %s failed %v", node, err)
 			}
 			logger.Info("succeeded in deleting worker %s", node)
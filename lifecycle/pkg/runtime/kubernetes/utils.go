@@ -26,16 +26,19 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/labring/sealos/pkg/client-go/kubernetes"
+	"github.com/labring/sealos/pkg/events"
 	"github.com/labring/sealos/pkg/utils/logger"
 )
 
 func (k *KubeadmRuntime) runPipelines(phase string, pipelines ...func() error) error {
-	for i := range pipelines {
-		if err := pipelines[i](); err != nil {
-			return fmt.Errorf("failed to %s: %v", phase, err)
+	return events.Run(k.cluster.GetName(), phase, "", func() error {
+		for i := range pipelines {
+			if err := pipelines[i](); err != nil {
+				return fmt.Errorf("failed to %s: %v", phase, err)
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 func (k *KubeadmRuntime) SendJoinMasterKubeConfigs(masters []string, files ...string) error {
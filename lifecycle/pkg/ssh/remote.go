@@ -38,6 +38,7 @@ const (
 	cGroupCommandFmt      = "cri cgroup-driver --short"
 	socketCommandFmt      = "cri socket"
 	initSystemCommandFmt  = "initsystem %s %s"
+	hostPrepareCommandFmt = "host prepare --packages-dir %s"
 )
 
 type RenderTemplate func(name, defaultStr string, data map[string]interface{}) (string, error)
@@ -108,6 +109,10 @@ func (s *Remote) Token(ip, config, certificateKey string) (string, error) {
 	return s.outputRemoteUtilSubcommand(ip, fmt.Sprintf(tokenCommandFmt, config, certificateKey))
 }
 
+func (s *Remote) HostPrepare(ip, packagesDir string) error {
+	return s.executeRemoteUtilSubcommand(ip, fmt.Sprintf(hostPrepareCommandFmt, packagesDir))
+}
+
 func (s *Remote) CGroup(ip string) (string, error) {
 	return s.outputRemoteUtilSubcommand(ip, cGroupCommandFmt)
 }
@@ -511,6 +511,60 @@ func GetTransfer(c *gin.Context) {
 	})
 }
 
+// GetWorkspaceAuditLogs
+// @Summary Get workspace audit logs
+// @Description Get the audit logs of the role bindings, kubeconfigs and deletions of users in a workspace, only for its owner and managers.
+// @Description The logs are written by the user controller only if its audit sink is database, they are empty with the other sinks.
+// @Tags AuditLogs
+// @Accept json
+// @Produce json
+// @Param request body helper.GetWorkspaceAuditLogsReq true "Get workspace audit logs request"
+// @Success 200 {object} map[string]interface{} "successfully get workspace audit logs"
+// @Failure 400 {object} map[string]interface{} "failed to parse get workspace audit logs request"
+// @Failure 401 {object} map[string]interface{} "authenticate error"
+// @Failure 403 {object} map[string]interface{} "no permission to get workspace audit logs"
+// @Failure 500 {object} map[string]interface{} "failed to get workspace audit logs"
+// @Router /account/v1alpha1/workspace/audit-logs [post]
+func GetWorkspaceAuditLogs(c *gin.Context) {
+	req, err := helper.ParseGetWorkspaceAuditLogsReq(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to parse get workspace audit logs request: %v", err)})
+		return
+	}
+	if err := authenticateRequest(c, req); err != nil {
+		c.JSON(http.StatusUnauthorized, helper.ErrorMessage{Error: fmt.Sprintf("authenticate error : %v", err)})
+		return
+	}
+	role, err := dao.DBClient.GetWorkspaceRole(req.Namespace, types.UserQueryOpts{ID: req.Auth.UserID, UID: req.Auth.UserUID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get workspace role : %v", err)})
+		return
+	}
+	if role != types.RoleOwner && role != types.RoleManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("no permission to get audit logs of workspace %s", req.Namespace)})
+		return
+	}
+	auditLogsResp, err := dao.DBClient.GetUserAuditLogs(&types.GetUserAuditLogsReq{
+		Namespace: req.Namespace,
+		Action:    req.Action,
+		LimitReq: types.LimitReq{
+			Page:     req.Page,
+			PageSize: req.PageSize,
+			TimeRange: types.TimeRange{
+				StartTime: req.TimeRange.StartTime,
+				EndTime:   req.TimeRange.EndTime,
+			},
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get workspace audit logs : %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": auditLogsResp,
+	})
+}
+
 // GetAPPCosts
 // @Summary Get app costs
 // @Description Get app costs within a specified time range
@@ -95,6 +95,8 @@ type Interface interface {
 	GetSubscriptionPlan(planName string) (*types.SubscriptionPlan, error)
 	RefundAmount(ref types.PaymentRefund, postDo func(types.PaymentRefund) error) error
 	CreateCorporate(corporate types.Corporate) error
+	GetWorkspaceRole(workspace string, ops types.UserQueryOpts) (types.Role, error)
+	GetUserAuditLogs(req *types.GetUserAuditLogsReq) (*types.GetUserAuditLogsResp, error)
 }
 
 type Account struct {
@@ -140,6 +142,21 @@ func (g *Cockroach) GetAccountWithWorkspace(workspace string) (*types.Account, e
 	return g.ck.GetAccountWithWorkspace(workspace)
 }
 
+func (g *Cockroach) GetWorkspaceRole(workspace string, ops types.UserQueryOpts) (types.Role, error) {
+	if ops.UID == uuid.Nil {
+		user, err := g.ck.GetUser(&ops)
+		if err != nil {
+			return "", fmt.Errorf("failed to get user: %v", err)
+		}
+		ops.UID = user.UID
+	}
+	return g.ck.GetWorkspaceRole(workspace, ops.UID)
+}
+
+func (g *Cockroach) GetUserAuditLogs(req *types.GetUserAuditLogsReq) (*types.GetUserAuditLogsResp, error) {
+	return g.ck.GetUserAuditLogs(req)
+}
+
 func (g *Cockroach) GetWorkspaceName(namespaces []string) ([][]string, error) {
 	workspaceList := make([][]string, 0)
 	workspaces, err := g.ck.GetWorkspace(namespaces...)
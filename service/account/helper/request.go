@@ -449,6 +449,36 @@ func ParseGetTransferRecordReq(c *gin.Context) (*GetTransferRecordReq, error) {
 	return transferReq, nil
 }
 
+type GetWorkspaceAuditLogsReq struct {
+	UserTimeRangeReq `json:",inline" bson:",inline"`
+
+	// @Summary Workspace
+	// @Description The namespace of the workspace
+	// @JSONSchema required
+	Namespace string `json:"namespace" bson:"namespace" binding:"required" example:"ns-admin"`
+
+	// @Summary Action
+	// @Description The action of the audit logs, such as Grant, Update, Deprive, Transfer, RenewKubeConfig or DeleteUser, empty for all
+	Action string `json:"action,omitempty" bson:"action" example:"Grant"`
+
+	// @Summary Page
+	// @Description Page
+	Page int `json:"page,omitempty" bson:"page" example:"1"`
+
+	// @Summary Page Size
+	// @Description Page Size
+	PageSize int `json:"pageSize,omitempty" bson:"pageSize" example:"10"`
+}
+
+func ParseGetWorkspaceAuditLogsReq(c *gin.Context) (*GetWorkspaceAuditLogsReq, error) {
+	auditLogsReq := &GetWorkspaceAuditLogsReq{}
+	if err := c.ShouldBindJSON(auditLogsReq); err != nil {
+		return nil, fmt.Errorf("bind json error: %v", err)
+	}
+	setDefaultTimeRange(&auditLogsReq.TimeRange)
+	return auditLogsReq, nil
+}
+
 func ParseGetCostAppListReq(c *gin.Context) (*GetCostAppListReq, error) {
 	costAppList := &GetCostAppListReq{}
 	if err := c.ShouldBindJSON(costAppList); err != nil {
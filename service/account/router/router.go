@@ -73,7 +73,8 @@ func RegisterPayRouter() {
 		POST(helper.UseGiftCode, api.UseGiftCode).
 		POST(helper.UserUsage, api.UserUsage).
 		POST(helper.GetRechargeDiscount, api.GetRechargeDiscount).
-		POST(helper.GetUserRealNameInfo, api.GetUserRealNameInfo)
+		POST(helper.GetUserRealNameInfo, api.GetUserRealNameInfo).
+		POST(helper.GetWorkspaceAuditLogs, api.GetWorkspaceAuditLogs)
 	adminGroup := router.Group(helper.AdminGroup).
 		GET(helper.AdminGetAccountWithWorkspace, api.AdminGetAccountWithWorkspaceID).
 		GET(helper.AdminGetUserRealNameInfo, api.AdminGetUserRealNameInfo).
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/labring/sealos/service/devbox/middleware"
+	"github.com/labring/sealos/service/devbox/pkg/gateway"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     middleware.CheckOrigin,
+	Subprotocols:    []string{middleware.TerminalSubprotocol},
+}
+
+// TerminalTicket issues a short-lived ticket of the terminal of a devbox to the user authenticated by kubeconfig,
+// browsers offer it as a websocket subprotocol since they can not set the Authorization header of websockets
+func TerminalTicket(tickets *middleware.TicketStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, err := tickets.Issue(middleware.GetKubeUser(c), c.Param("name"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"ticket":      ticket,
+			"subprotocol": middleware.TicketSubprotocolPrefix + ticket,
+			"expiresIn":   int(middleware.DefaultTicketTTL.Seconds()),
+		})
+	}
+}
+
+// Terminal upgrades the request to a websocket and attaches it to a shell in the devbox container
+func Terminal(c *gin.Context) {
+	user := middleware.GetKubeUser(c)
+	devbox, ok := getDevbox(c, user)
+	if !ok {
+		return
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// upgrader has replied with an error
+		return
+	}
+	defer conn.Close()
+
+	session := gateway.NewSession(gateway.SessionKindTerminal, user.Name, user.Namespace, devbox.Name, c.ClientIP())
+	session.Pod = devbox.Pod
+	session.Target = devbox.Container
+	session.Begin()
+	err = gateway.ServeTerminal(c.Request.Context(), user.Config, devbox, conn, session)
+	session.End(err)
+	if err != nil {
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+		return
+	}
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// PortForward proxies the request to an app port of the devbox
+func PortForward(c *gin.Context) {
+	user := middleware.GetKubeUser(c)
+	port, err := strconv.ParseInt(c.Param("port"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid port"})
+		return
+	}
+	devbox, ok := getDevbox(c, user)
+	if !ok {
+		return
+	}
+	containerPort, err := devbox.ContainerPort(int32(port))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	session := gateway.NewSession(gateway.SessionKindPortForward, user.Name, user.Namespace, devbox.Name, c.ClientIP())
+	session.Pod = devbox.Pod
+	session.Target = strconv.Itoa(int(containerPort)) + c.Param("path")
+	proxy, err := gateway.NewPortForwardProxy(user.Config, devbox, containerPort, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var proxyErr error
+	proxy.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+		proxyErr = err
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	session.Begin()
+	c.Request.URL.Path = c.Param("path")
+	c.Request.URL.RawPath = ""
+	proxy.ServeHTTP(c.Writer, c.Request)
+	session.End(proxyErr)
+}
+
+func getDevbox(c *gin.Context, user *middleware.KubeUser) (*gateway.Devbox, bool) {
+	devbox, err := gateway.GetDevbox(c.Request.Context(), user.Config, user.Namespace, c.Param("name"))
+	switch {
+	case err == nil:
+		return devbox, true
+	case apierrors.IsNotFound(err):
+		c.JSON(http.StatusNotFound, gin.H{"error": "devbox not found"})
+	case apierrors.IsForbidden(err):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, gateway.ErrDevboxNotRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return nil, false
+}
@@ -27,6 +27,16 @@ func main() {
 	r.Use(gin.Recovery())
 	r.Use(middleware.CORS())
 	r.POST("/tag", middleware.TokenAuth, api.Tag)
+	// gateway to devboxes, requests are authenticated by kubeconfig and audited per session
+	// the tickets are sealed by the key shared by all the replicas, so that any of them redeems a ticket
+	tickets, err := middleware.NewTicketStore([]byte(os.Getenv("TICKET_KEY")), middleware.DefaultTicketTTL)
+	if err != nil {
+		slog.Error("Failed to create ticket store", "Error", err)
+		return
+	}
+	r.POST("/devbox/:name/terminal/ticket", middleware.KubeconfigAuth("create", "pods", "exec"), api.TerminalTicket(tickets))
+	r.GET("/devbox/:name/terminal", middleware.WebSocketAuth(tickets, "create", "pods", "exec"), api.Terminal)
+	r.Any("/devbox/:name/ports/:port/*path", middleware.KubeconfigAuth("get", "pods", "proxy"), api.PortForward)
 	if err := r.Run(":8092"); err != nil {
 		slog.Error("Failed to start server", "Error", err)
 		return
@@ -1,14 +1,53 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+const (
+	// KubeUserKey is the gin context key of the user authenticated by KubeconfigAuth
+	KubeUserKey = "kubeUser"
+	// KubeTokenHeader carries the token returned by the exec plugin of a kubeconfig, like the id token of
+	// kubectl oidc-login, the plugin itself is never run by the gateway
+	KubeTokenHeader = "X-Kube-Token"
+)
+
+var (
+	ErrNoKubeconfig = errors.New("kubeconfig is required")
+	ErrNoNamespace  = errors.New("no namespace found in kubeconfig")
+	ErrNoHost       = errors.New("unable to get the kubernetes host")
+	ErrNoPermission = errors.New("no permission for this namespace")
+	ErrNoUser       = errors.New("unable to get the user of kubeconfig")
+
+	ErrUnsupportedKubeconfig = errors.New("unsupported kubeconfig")
+)
+
+// KubeUser is a user authenticated by kubeconfig, requests to the cluster are made with its credentials
+type KubeUser struct {
+	Name      string
+	Namespace string
+	Config    *rest.Config
+}
+
 func TokenAuth(c *gin.Context) {
 	key := c.Request.Header.Get("Authorization")
 	if err := parseToken(key); err != nil {
@@ -30,3 +69,166 @@ func parseToken(token string) error {
 
 	return err
 }
+
+// KubeconfigAuth authenticates the url encoded kubeconfig in the Authorization header and checks that the user
+// can perform verb on the resource in its namespace. The kubeconfig is never read from the url, since urls are logged.
+func KubeconfigAuth(verb, resource, subresource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kubeconfig := c.Request.Header.Get("Authorization")
+		execToken := c.Request.Header.Get(KubeTokenHeader)
+		user, err := AuthenticateKubeconfig(c.Request.Context(), kubeconfig, execToken, verb, resource, subresource)
+		if err != nil {
+			slog.Error("Failed to authenticate kubeconfig", "Error", err)
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrNoPermission) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"msg": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Set(KubeUserKey, user)
+		c.Next()
+	}
+}
+
+// GetKubeUser returns the user authenticated by KubeconfigAuth
+func GetKubeUser(c *gin.Context) *KubeUser {
+	user, _ := c.MustGet(KubeUserKey).(*KubeUser)
+	return user
+}
+
+// AuthenticateKubeconfig parses kubeconfig, the kubernetes host is replaced by the in cluster one unless it is
+// whitelisted by WHITELIST_KUBERNETES_HOSTS, then a SelfSubjectAccessReview checks the permission of the user,
+// and a SelfSubjectReview tells the name of the user. execToken is the token of a kubeconfig using an exec plugin.
+func AuthenticateKubeconfig(ctx context.Context, kubeconfig, execToken, verb, resource, subresource string) (*KubeUser, error) {
+	if kubeconfig == "" {
+		return nil, ErrNoKubeconfig
+	}
+	if decoded, err := url.PathUnescape(kubeconfig); err == nil {
+		kubeconfig = decoded
+	}
+	raw, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	kubeContext, ok := raw.Contexts[raw.CurrentContext]
+	if !ok || kubeContext.Namespace == "" {
+		return nil, ErrNoNamespace
+	}
+	config, err := restConfigFromContext(raw, kubeContext, execToken)
+	if err != nil {
+		return nil, err
+	}
+	if !isWhitelistKubernetesHost(config.Host) {
+		host := getKubernetesHostFromEnv()
+		if host == "" {
+			return nil, ErrNoHost
+		}
+		config.Host = host
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to new client: %w", err)
+	}
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   kubeContext.Namespace,
+				Verb:        verb,
+				Version:     "v1",
+				Resource:    resource,
+				Subresource: subresource,
+			},
+		},
+	}
+	resp, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check resource access: %w", err)
+	}
+	if !resp.Status.Allowed {
+		return nil, ErrNoPermission
+	}
+	// the user name in kubeconfig is chosen by the client, take the one the api server authenticated
+	self, err := client.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to review user: %w", err)
+	}
+	if self.Status.UserInfo.Username == "" {
+		return nil, ErrNoUser
+	}
+	return &KubeUser{
+		Name:      self.Status.UserInfo.Username,
+		Namespace: kubeContext.Namespace,
+		Config:    config,
+	}, nil
+}
+
+// restConfigFromContext builds the rest config of kubeContext, only a server with inline certificate authority and
+// a bearer token or inline client certificates are accepted. Everything else, like auth providers and file paths,
+// runs or reads something on this server and is rejected. An exec plugin, which is how users of an OIDC provider
+// login, is never run here, the token it returned to the client is sent as execToken and used instead.
+func restConfigFromContext(raw *clientcmdapi.Config, kubeContext *clientcmdapi.Context, execToken string) (*rest.Config, error) {
+	cluster, ok := raw.Clusters[kubeContext.Cluster]
+	if !ok || cluster.Server == "" {
+		return nil, fmt.Errorf("%w: cluster %q has no server", ErrUnsupportedKubeconfig, kubeContext.Cluster)
+	}
+	if cluster.CertificateAuthority != "" || cluster.InsecureSkipTLSVerify || cluster.TLSServerName != "" ||
+		cluster.ProxyURL != "" || cluster.DisableCompression || len(cluster.Extensions) > 0 {
+		return nil, fmt.Errorf("%w: cluster %q only supports server and certificate-authority-data",
+			ErrUnsupportedKubeconfig, kubeContext.Cluster)
+	}
+	authInfo, ok := raw.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("%w: user %q not found", ErrUnsupportedKubeconfig, kubeContext.AuthInfo)
+	}
+	if authInfo.ClientCertificate != "" || authInfo.ClientKey != "" || authInfo.TokenFile != "" ||
+		authInfo.Impersonate != "" || authInfo.ImpersonateUID != "" || len(authInfo.ImpersonateGroups) > 0 ||
+		len(authInfo.ImpersonateUserExtra) > 0 || authInfo.Username != "" || authInfo.Password != "" ||
+		authInfo.AuthProvider != nil || len(authInfo.Extensions) > 0 {
+		return nil, fmt.Errorf("%w: user %q only supports token, client-certificate-data and client-key-data or exec",
+			ErrUnsupportedKubeconfig, kubeContext.AuthInfo)
+	}
+	if authInfo.Exec != nil {
+		if execToken == "" || authInfo.Token != "" || len(authInfo.ClientCertificateData) > 0 || len(authInfo.ClientKeyData) > 0 {
+			return nil, fmt.Errorf("%w: user %q uses an exec plugin, send only the token it returns in the %s header",
+				ErrUnsupportedKubeconfig, kubeContext.AuthInfo, KubeTokenHeader)
+		}
+		return &rest.Config{
+			Host:            cluster.Server,
+			BearerToken:     execToken,
+			TLSClientConfig: rest.TLSClientConfig{CAData: cluster.CertificateAuthorityData},
+		}, nil
+	}
+	hasCert := len(authInfo.ClientCertificateData) > 0 || len(authInfo.ClientKeyData) > 0
+	if hasCert && (len(authInfo.ClientCertificateData) == 0 || len(authInfo.ClientKeyData) == 0) {
+		return nil, fmt.Errorf("%w: user %q needs both client-certificate-data and client-key-data",
+			ErrUnsupportedKubeconfig, kubeContext.AuthInfo)
+	}
+	if !hasCert && authInfo.Token == "" {
+		return nil, fmt.Errorf("%w: user %q has no credentials", ErrUnsupportedKubeconfig, kubeContext.AuthInfo)
+	}
+	return &rest.Config{
+		Host:        cluster.Server,
+		BearerToken: authInfo.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData:   cluster.CertificateAuthorityData,
+			CertData: authInfo.ClientCertificateData,
+			KeyData:  authInfo.ClientKeyData,
+		},
+	}, nil
+}
+
+func isWhitelistKubernetesHost(host string) bool {
+	hosts := strings.Split(os.Getenv("WHITELIST_KUBERNETES_HOSTS"), ",")
+	return host != "" && slices.Contains(hosts, host)
+}
+
+func getKubernetesHostFromEnv() string {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if len(host) == 0 || len(port) == 0 {
+		return ""
+	}
+	return "https://" + net.JoinHostPort(host, port)
+}
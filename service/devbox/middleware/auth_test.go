@@ -1,10 +1,22 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes/scheme"
 )
 
 func TestAuth(t *testing.T) {
@@ -21,3 +33,181 @@ func GenerateTokenWithExpiry(secret string, duration time.Duration) (string, err
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secret))
 }
+
+func TestAuthenticateKubeconfig(t *testing.T) {
+	var allowed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/apis/authentication.k8s.io/v1/selfsubjectreviews" {
+			self := &authenticationv1.SelfSubjectReview{}
+			self.Status.UserInfo.Username = "system:serviceaccount:ns-test:test"
+			_ = json.NewEncoder(w).Encode(self)
+			return
+		}
+		review := &authorizationv1.SelfSubjectAccessReview{}
+		if _, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, review); err != nil {
+			t.Errorf("invalid review: %v", err)
+			return
+		}
+		if review.Spec.ResourceAttributes.Namespace != "ns-test" || review.Spec.ResourceAttributes.Subresource != "exec" {
+			t.Errorf("unexpected resource attributes: %+v", review.Spec.ResourceAttributes)
+		}
+		review.Status.Allowed = allowed
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	defer server.Close()
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: sealos
+  cluster:
+    server: %s
+contexts:
+- name: sealos
+  context:
+    cluster: sealos
+    namespace: ns-test
+    user: test
+current-context: sealos
+users:
+- name: test
+  user:
+    token: token
+`, server.URL)
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := AuthenticateKubeconfig(context.Background(), kubeconfig, "", "create", "pods", "exec"); !errors.Is(err, ErrNoHost) {
+		t.Errorf("AuthenticateKubeconfig() error = %v, want %v", err, ErrNoHost)
+	}
+
+	t.Setenv("WHITELIST_KUBERNETES_HOSTS", server.URL)
+	if _, err := AuthenticateKubeconfig(context.Background(), kubeconfig, "", "create", "pods", "exec"); !errors.Is(err, ErrNoPermission) {
+		t.Errorf("AuthenticateKubeconfig() error = %v, want %v", err, ErrNoPermission)
+	}
+
+	allowed = true
+	user, err := AuthenticateKubeconfig(context.Background(), url.PathEscape(kubeconfig), "", "create", "pods", "exec")
+	if err != nil {
+		t.Fatalf("AuthenticateKubeconfig() error = %v", err)
+	}
+	if user.Name != "system:serviceaccount:ns-test:test" || user.Namespace != "ns-test" || user.Config.Host != server.URL {
+		t.Errorf("AuthenticateKubeconfig() = %+v", user)
+	}
+}
+
+func TestAuthenticateKubeconfigExec(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer id-token" {
+			t.Errorf("Authorization = %s, want the token of the exec plugin", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/apis/authentication.k8s.io/v1/selfsubjectreviews" {
+			self := &authenticationv1.SelfSubjectReview{}
+			self.Status.UserInfo.Username = "oidc:test"
+			_ = json.NewEncoder(w).Encode(self)
+			return
+		}
+		review := &authorizationv1.SelfSubjectAccessReview{}
+		review.Status.Allowed = true
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	defer server.Close()
+	t.Setenv("WHITELIST_KUBERNETES_HOSTS", server.URL)
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: sealos
+  cluster:
+    server: %s
+contexts:
+- name: sealos
+  context:
+    cluster: sealos
+    namespace: ns-test
+    user: test
+current-context: sealos
+users:
+- name: test
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: kubectl
+      args:
+      - oidc-login
+      - get-token
+`, server.URL)
+
+	if _, err := AuthenticateKubeconfig(context.Background(), kubeconfig, "", "create", "pods", "exec"); !errors.Is(err, ErrUnsupportedKubeconfig) {
+		t.Errorf("AuthenticateKubeconfig() without token error = %v, want %v", err, ErrUnsupportedKubeconfig)
+	}
+	user, err := AuthenticateKubeconfig(context.Background(), kubeconfig, "id-token", "create", "pods", "exec")
+	if err != nil {
+		t.Fatalf("AuthenticateKubeconfig() error = %v", err)
+	}
+	if user.Name != "oidc:test" || user.Config.BearerToken != "id-token" || user.Config.ExecProvider != nil {
+		t.Errorf("AuthenticateKubeconfig() = %+v", user)
+	}
+}
+
+func TestAuthenticateKubeconfigUnsupported(t *testing.T) {
+	t.Setenv("WHITELIST_KUBERNETES_HOSTS", "https://127.0.0.1:6443")
+	kubeconfig := func(user string) string {
+		return `apiVersion: v1
+kind: Config
+clusters:
+- name: sealos
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: sealos
+  context:
+    cluster: sealos
+    namespace: ns-test
+    user: test
+current-context: sealos
+users:
+- name: test
+  user:
+` + user
+	}
+	tests := map[string]string{
+		"exec": `    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: /bin/sh
+`,
+		"auth provider": `    auth-provider:
+      name: oidc
+`,
+		"token file": `    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token
+`,
+		"client certificate file": `    client-certificate: /etc/kubernetes/pki/admin.crt
+    client-key: /etc/kubernetes/pki/admin.key
+`,
+		"impersonate": `    token: token
+    as: admin
+`,
+		"no credentials": `    username: admin
+`,
+	}
+	for name, user := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := AuthenticateKubeconfig(context.Background(), kubeconfig(user), "", "create", "pods", "exec"); !errors.Is(err, ErrUnsupportedKubeconfig) {
+				t.Errorf("AuthenticateKubeconfig() error = %v, want %v", err, ErrUnsupportedKubeconfig)
+			}
+		})
+	}
+}
+
+func TestKubeconfigAuthIgnoresQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/devbox/test/terminal?authorization=kubeconfig", nil)
+	KubeconfigAuth("create", "pods", "exec")(c)
+	if !c.IsAborted() || w.Code != http.StatusUnauthorized {
+		t.Errorf("KubeconfigAuth() code = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -1,6 +1,11 @@
 package middleware
 
 import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -13,3 +18,27 @@ func CORS() gin.HandlerFunc {
 	config.AllowHeaders = []string{"*"}
 	return cors.New(config)
 }
+
+// CheckOrigin allows websocket requests from the same host, or from the domains in ALLOWED_ORIGIN_DOMAINS and
+// their subdomains. Requests without origin are not sent by browsers and are allowed.
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range strings.Split(os.Getenv("ALLOWED_ORIGIN_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
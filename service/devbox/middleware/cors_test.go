@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	t.Setenv("ALLOWED_ORIGIN_DOMAINS", "cloud.sealos.io, sealos.run")
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "", want: true},
+		{origin: "https://devbox.example.com", want: true},
+		{origin: "https://cloud.sealos.io", want: true},
+		{origin: "https://devbox.cloud.sealos.io", want: true},
+		{origin: "https://abc.sealos.run:443", want: true},
+		{origin: "https://evilcloud.sealos.io", want: false},
+		{origin: "https://cloud.sealos.io.evil.com", want: false},
+		{origin: "null", want: false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://devbox.example.com/devbox/test/terminal", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := CheckOrigin(r); got != tt.want {
+			t.Errorf("CheckOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
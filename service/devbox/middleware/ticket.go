@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// TerminalSubprotocol is the websocket subprotocol of the terminal, it is selected by the server so that
+	// browsers accept the upgrade when they offer a ticket as another subprotocol
+	TerminalSubprotocol = "devbox.terminal"
+	// TicketSubprotocolPrefix prefixes the ticket offered as a websocket subprotocol, browsers can not set the
+	// Authorization header of websockets and urls are logged, so the ticket is sent in Sec-WebSocket-Protocol
+	TicketSubprotocolPrefix = "devbox.ticket."
+
+	DefaultTicketTTL = 30 * time.Second
+)
+
+var (
+	ErrInvalidTicket = errors.New("invalid or expired ticket")
+	ErrNoTicketKey   = errors.New("ticket key is required")
+)
+
+// ticketClaims is sealed in a ticket, it carries the credentials of the user so that any replica redeems it
+type ticketClaims struct {
+	User        string `json:"user"`
+	Namespace   string `json:"namespace"`
+	Devbox      string `json:"devbox"`
+	Expires     int64  `json:"exp"`
+	Host        string `json:"host"`
+	BearerToken string `json:"token,omitempty"`
+	CAData      []byte `json:"ca,omitempty"`
+	CertData    []byte `json:"cert,omitempty"`
+	KeyData     []byte `json:"key,omitempty"`
+}
+
+// TicketStore issues the short-lived tickets of authenticated users. A ticket is self-contained, the user and its
+// credentials are sealed by AES-GCM with the key shared by all the replicas, so it is redeemed by any replica for
+// the devbox it was issued for before it expires. A replica redeems a ticket once, it keeps the redeemed tickets
+// until they expire.
+type TicketStore struct {
+	ttl      time.Duration
+	aead     cipher.AEAD
+	mu       sync.Mutex
+	redeemed map[string]time.Time
+}
+
+// NewTicketStore returns a TicketStore sealing the tickets with key, all the replicas must use the same key
+func NewTicketStore(key []byte, ttl time.Duration) (*TicketStore, error) {
+	if len(key) == 0 {
+		return nil, ErrNoTicketKey
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &TicketStore{ttl: ttl, aead: aead, redeemed: map[string]time.Time{}}, nil
+}
+
+// Issue returns a new ticket of user for devbox
+func (s *TicketStore) Issue(user *KubeUser, devbox string) (string, error) {
+	payload, err := json.Marshal(ticketClaims{
+		User:        user.Name,
+		Namespace:   user.Namespace,
+		Devbox:      devbox,
+		Expires:     time.Now().Add(s.ttl).UnixNano(),
+		Host:        user.Config.Host,
+		BearerToken: user.Config.BearerToken,
+		CAData:      user.Config.CAData,
+		CertData:    user.Config.CertData,
+		KeyData:     user.Config.KeyData,
+	})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, payload, nil)), nil
+}
+
+// Redeem consumes the ticket and returns its user if it is issued for devbox and not expired
+func (s *TicketStore) Redeem(id, devbox string) (*KubeUser, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, ErrInvalidTicket
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	payload, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidTicket
+	}
+	var claims ticketClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidTicket
+	}
+	now := time.Now()
+	expires := time.Unix(0, claims.Expires)
+	if claims.Devbox != devbox || now.After(expires) {
+		return nil, ErrInvalidTicket
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, t := range s.redeemed {
+		if now.After(t) {
+			delete(s.redeemed, k)
+		}
+	}
+	if _, ok := s.redeemed[string(nonce)]; ok {
+		return nil, ErrInvalidTicket
+	}
+	s.redeemed[string(nonce)] = expires
+	return &KubeUser{
+		Name:      claims.User,
+		Namespace: claims.Namespace,
+		Config: &rest.Config{
+			Host:        claims.Host,
+			BearerToken: claims.BearerToken,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData:   claims.CAData,
+				CertData: claims.CertData,
+				KeyData:  claims.KeyData,
+			},
+		},
+	}, nil
+}
+
+// WebSocketAuth authenticates websocket requests by the ticket in Sec-WebSocket-Protocol, requests without a
+// ticket are authenticated by KubeconfigAuth, which is what non-browser clients use.
+func WebSocketAuth(tickets *TicketStore, verb, resource, subresource string) gin.HandlerFunc {
+	kubeconfigAuth := KubeconfigAuth(verb, resource, subresource)
+	return func(c *gin.Context) {
+		id, ok := ticketFromSubprotocols(c.Request)
+		if !ok {
+			kubeconfigAuth(c)
+			return
+		}
+		user, err := tickets.Redeem(id, c.Param("name"))
+		if err != nil {
+			slog.Error("Failed to redeem ticket", "Error", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"msg": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Set(KubeUserKey, user)
+		c.Next()
+	}
+}
+
+func ticketFromSubprotocols(r *http.Request) (string, bool) {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if id, ok := strings.CutPrefix(protocol, TicketSubprotocolPrefix); ok && id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
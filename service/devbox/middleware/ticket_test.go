@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/rest"
+)
+
+func newTestTicketStore(t *testing.T, key string, ttl time.Duration) *TicketStore {
+	store, err := NewTicketStore([]byte(key), ttl)
+	if err != nil {
+		t.Fatalf("NewTicketStore() error = %v", err)
+	}
+	return store
+}
+
+func TestTicketStore(t *testing.T) {
+	if _, err := NewTicketStore(nil, time.Minute); !errors.Is(err, ErrNoTicketKey) {
+		t.Errorf("NewTicketStore() without key error = %v, want %v", err, ErrNoTicketKey)
+	}
+
+	store := newTestTicketStore(t, "key", time.Minute)
+	user := &KubeUser{Name: "test", Namespace: "ns-test", Config: &rest.Config{Host: "https://apiserver", BearerToken: "token"}}
+	id, err := store.Issue(user, "devbox")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if _, err := store.Redeem(id, "other"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("Redeem() for another devbox error = %v, want %v", err, ErrInvalidTicket)
+	}
+
+	// a ticket is redeemed by another replica sharing the key, but only once by each replica
+	replica := newTestTicketStore(t, "key", time.Minute)
+	got, err := replica.Redeem(id, "devbox")
+	if err != nil || !reflect.DeepEqual(got, user) {
+		t.Fatalf("Redeem() = %v, %v, want the user", got, err)
+	}
+	if _, err := replica.Redeem(id, "devbox"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("Redeem() twice error = %v, want %v", err, ErrInvalidTicket)
+	}
+
+	if _, err := newTestTicketStore(t, "other", time.Minute).Redeem(id, "devbox"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("Redeem() with another key error = %v, want %v", err, ErrInvalidTicket)
+	}
+	tampered := []byte(id)
+	tampered[len(tampered)/2] ^= 'A' ^ 'B'
+	if _, err := store.Redeem(string(tampered), "devbox"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("Redeem() tampered error = %v, want %v", err, ErrInvalidTicket)
+	}
+
+	expired := newTestTicketStore(t, "key", -time.Second)
+	id, _ = expired.Issue(user, "devbox")
+	if _, err := expired.Redeem(id, "devbox"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("Redeem() expired error = %v, want %v", err, ErrInvalidTicket)
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newTestTicketStore(t, "key", time.Minute)
+	user := &KubeUser{Name: "test", Namespace: "ns-test", Config: &rest.Config{Host: "https://apiserver", BearerToken: "token"}}
+	id, _ := store.Issue(user, "devbox")
+
+	r := gin.New()
+	r.GET("/devbox/:name/terminal", WebSocketAuth(store, "create", "pods", "exec"), func(c *gin.Context) {
+		if !reflect.DeepEqual(GetKubeUser(c), user) {
+			t.Errorf("user = %v, want the user of the ticket", GetKubeUser(c))
+		}
+		c.Status(http.StatusOK)
+	})
+
+	for _, tt := range []struct {
+		name     string
+		protocol string
+		want     int
+	}{
+		{name: "ticket", protocol: TerminalSubprotocol + ", " + TicketSubprotocolPrefix + id, want: http.StatusOK},
+		{name: "redeemed ticket", protocol: TicketSubprotocolPrefix + id, want: http.StatusUnauthorized},
+		{name: "no ticket nor kubeconfig", protocol: TerminalSubprotocol, want: http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/devbox/devbox/terminal", nil)
+			req.Header.Set("Sec-WebSocket-Protocol", tt.protocol)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	SessionKindTerminal    = "terminal"
+	SessionKindPortForward = "port-forward"
+)
+
+// Session is an audited access to a devbox, it is logged when it begins and ends
+type Session struct {
+	ID         string
+	Kind       string
+	User       string
+	Namespace  string
+	Devbox     string
+	Pod        string
+	Target     string
+	RemoteAddr string
+	Start      time.Time
+
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+}
+
+// NewSession returns a session with a random id
+func NewSession(kind, user, namespace, devbox, remoteAddr string) *Session {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return &Session{
+		ID:         hex.EncodeToString(id),
+		Kind:       kind,
+		User:       user,
+		Namespace:  namespace,
+		Devbox:     devbox,
+		RemoteAddr: remoteAddr,
+		Start:      time.Now(),
+	}
+}
+
+// AddIn counts bytes sent by the user
+func (s *Session) AddIn(n int) {
+	s.bytesIn.Add(int64(n))
+}
+
+// AddOut counts bytes sent to the user
+func (s *Session) AddOut(n int) {
+	s.bytesOut.Add(int64(n))
+}
+
+// Begin logs the start of the session
+func (s *Session) Begin() {
+	slog.Info("Devbox session started", s.attrs()...)
+}
+
+// End logs the end of the session with its duration, traffic and error
+func (s *Session) End(err error) {
+	attrs := append(s.attrs(),
+		"duration", time.Since(s.Start).String(),
+		"bytesIn", s.bytesIn.Load(),
+		"bytesOut", s.bytesOut.Load(),
+	)
+	if err != nil {
+		slog.Warn("Devbox session ended", append(attrs, "Error", err)...)
+		return
+	}
+	slog.Info("Devbox session ended", attrs...)
+}
+
+func (s *Session) attrs() []any {
+	return []any{
+		"audit", true,
+		"session", s.ID,
+		"kind", s.Kind,
+		"user", s.User,
+		"namespace", s.Namespace,
+		"devbox", s.Devbox,
+		"pod", s.Pod,
+		"target", s.Target,
+		"remoteAddr", s.RemoteAddr,
+	}
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	ErrDevboxNotRunning = errors.New("devbox is not running")
+	ErrPortNotExposed   = errors.New("port is not an app port of devbox")
+
+	devboxGVR = schema.GroupVersionResource{Group: "devbox.sealos.io", Version: "v1alpha1", Resource: "devboxes"}
+)
+
+// Devbox is the running pod of a devbox and its app ports
+type Devbox struct {
+	Name      string
+	Namespace string
+	Pod       string
+	// Container is the devbox container, it is named after the devbox
+	Container string
+	AppPorts  []corev1.ServicePort
+}
+
+// GetDevbox returns the running pod of devbox name with the credentials of config
+func GetDevbox(ctx context.Context, config *rest.Config, namespace, name string) (*Devbox, error) {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := dynamicClient.Resource(devboxGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	devbox := &Devbox{Name: name, Namespace: namespace, Container: name}
+	// the controller publishes the app ports merged with the template in status, the spec only has the ports
+	// set in the devbox itself and is read until the controller has seen the devbox
+	ports, found, err := unstructured.NestedSlice(obj.Object, "status", "appPorts")
+	if err == nil && !found {
+		ports, _, err = unstructured.NestedSlice(obj.Object, "spec", "config", "appPorts")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid app ports of devbox %s: %w", name, err)
+	}
+	for _, p := range ports {
+		m, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var port corev1.ServicePort
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &port); err != nil {
+			return nil, fmt.Errorf("invalid app ports of devbox %s: %w", name, err)
+		}
+		devbox.AppPorts = append(devbox.AppPorts, port)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			"app.kubernetes.io/name":       name,
+			"app.kubernetes.io/managed-by": "sealos",
+			"app.kubernetes.io/part-of":    "devbox",
+		}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			devbox.Pod = pod.Name
+			return devbox, nil
+		}
+	}
+	return nil, ErrDevboxNotRunning
+}
+
+// ContainerPort returns the container port of app port, the target port is used if it is set
+func (d *Devbox) ContainerPort(port int32) (int32, error) {
+	for _, p := range d.AppPorts {
+		if p.Port != port {
+			continue
+		}
+		if p.TargetPort.IntVal != 0 {
+			return p.TargetPort.IntVal, nil
+		}
+		return p.Port, nil
+	}
+	return 0, ErrPortNotExposed
+}
@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/labring/sealos/service/devbox/middleware"
+)
+
+// NewPortForwardProxy returns a reverse proxy to port of the devbox pod through the pod proxy of apiserver,
+// so that the request is authorized by kubernetes with the credentials of config.
+func NewPortForwardProxy(config *rest.Config, devbox *Devbox, port int32, session *Session) (*httputil.ReverseProxy, error) {
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	host, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s:%d/proxy", devbox.Namespace, devbox.Pod, port)
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = host.Scheme
+			r.Out.URL.Host = host.Host
+			r.Out.URL.Path = prefix + "/" + strings.TrimPrefix(r.In.URL.Path, "/")
+			r.Out.URL.RawPath = ""
+			r.Out.Host = host.Host
+			// the kubeconfig and the token of the user are only sent to apiserver by transport, never to the app
+			r.Out.Header.Del("Authorization")
+			r.Out.Header.Del(middleware.KubeTokenHeader)
+			if r.In.ContentLength > 0 {
+				session.AddIn(int(r.In.ContentLength))
+			}
+		},
+		Transport: transport,
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.ContentLength > 0 {
+				session.AddOut(int(resp.ContentLength))
+			}
+			return nil
+		},
+	}, nil
+}
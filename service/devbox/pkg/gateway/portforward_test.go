@@ -0,0 +1,64 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/labring/sealos/service/devbox/middleware"
+)
+
+func TestPortForwardProxy(t *testing.T) {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Authorization = %s, want it removed", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get(middleware.KubeTokenHeader) != "" {
+			t.Errorf("%s = %s, want it removed", middleware.KubeTokenHeader, r.Header.Get(middleware.KubeTokenHeader))
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer app.Close()
+	// the pod proxy of apiserver forwards the request to the app with the headers left by the gateway
+	appURL, _ := url.Parse(app.URL)
+	pods := httputil.NewSingleHostReverseProxy(appURL)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/ns-test/pods/devbox-0:8080/proxy/api/hello" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("name") != "world" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization = %s, want the token of the user", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get(middleware.KubeTokenHeader) != "" {
+			t.Errorf("%s = %s, want it removed", middleware.KubeTokenHeader, r.Header.Get(middleware.KubeTokenHeader))
+		}
+		r.Header.Del("Authorization")
+		pods.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	devbox := &Devbox{Name: "devbox", Namespace: "ns-test", Pod: "devbox-0"}
+	session := NewSession(SessionKindPortForward, "test", "ns-test", "devbox", "127.0.0.1")
+	proxy, err := NewPortForwardProxy(&rest.Config{Host: server.URL, BearerToken: "token"}, devbox, 8080, session)
+	if err != nil {
+		t.Fatalf("NewPortForwardProxy() error = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/hello?name=world", nil)
+	req.Header.Set("Authorization", "kubeconfig")
+	req.Header.Set(middleware.KubeTokenHeader, "id-token")
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("response = %d %s", rec.Code, rec.Body.String())
+	}
+	if session.bytesOut.Load() != 5 {
+		t.Errorf("bytes out = %d, want 5", session.bytesOut.Load())
+	}
+}
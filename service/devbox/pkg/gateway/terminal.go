@@ -0,0 +1,137 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	TerminalMessageStdin  = "stdin"
+	TerminalMessageResize = "resize"
+)
+
+// TerminalCommand starts a login shell, bash is preferred if the image has it
+var TerminalCommand = []string{"/bin/sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash -l; else exec sh -l; fi"}
+
+// TerminalMessage is a message sent by the terminal client, stdout is sent back as binary messages
+type TerminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+}
+
+// terminalStream adapts a websocket connection to the streams of remotecommand
+type terminalStream struct {
+	conn    *websocket.Conn
+	session *Session
+	sizes   chan remotecommand.TerminalSize
+	stdin   []byte
+	mu      sync.Mutex
+
+	// done is closed once the terminal is closed, sizes is never closed since both Read and Next use it
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ remotecommand.TerminalSizeQueue = &terminalStream{}
+
+func (t *terminalStream) Read(p []byte) (int, error) {
+	for len(t.stdin) == 0 {
+		_, data, err := t.conn.ReadMessage()
+		if err != nil {
+			t.close()
+			// a closed terminal ends the shell
+			return copy(p, "\x04"), io.EOF
+		}
+		var msg TerminalMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case TerminalMessageStdin:
+			t.session.AddIn(len(msg.Data))
+			t.stdin = []byte(msg.Data)
+		case TerminalMessageResize:
+			select {
+			case t.sizes <- remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows}:
+			default:
+			}
+		}
+	}
+	n := copy(p, t.stdin)
+	t.stdin = t.stdin[n:]
+	return n, nil
+}
+
+func (t *terminalStream) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	t.session.AddOut(len(p))
+	return len(p), nil
+}
+
+func (t *terminalStream) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-t.sizes:
+		return &size
+	case <-t.done:
+		return nil
+	}
+}
+
+func (t *terminalStream) close() {
+	t.closeOnce.Do(func() { close(t.done) })
+}
+
+// ServeTerminal execs a shell in the devbox container and streams it over conn until either side closes
+func ServeTerminal(ctx context.Context, config *rest.Config, devbox *Devbox, conn *websocket.Conn, session *Session) error {
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(devbox.Namespace).
+		Name(devbox.Pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: devbox.Container,
+			Command:   TerminalCommand,
+			Stdin:     true,
+			Stdout:    true,
+			TTY:       true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(config, http.MethodPost, req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+	stream := &terminalStream{
+		conn:    conn,
+		session: session,
+		sizes:   make(chan remotecommand.TerminalSize, 1),
+		done:    make(chan struct{}),
+	}
+	// the stream also ends when the shell exits first, stop the size queue of remotecommand then
+	defer stream.close()
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:             stream,
+		Stdout:            stream,
+		Tty:               true,
+		TerminalSizeQueue: stream,
+	})
+}
@@ -0,0 +1,25 @@
+package gateway
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+func TestTerminalStreamClose(t *testing.T) {
+	stream := &terminalStream{
+		sizes: make(chan remotecommand.TerminalSize, 1),
+		done:  make(chan struct{}),
+	}
+	stream.sizes <- remotecommand.TerminalSize{Width: 80, Height: 24}
+	if size := stream.Next(); size == nil || size.Width != 80 {
+		t.Errorf("Next() = %v, want the queued size", size)
+	}
+
+	// closed by both the reader and the owner
+	stream.close()
+	stream.close()
+	if size := stream.Next(); size != nil {
+		t.Errorf("Next() = %v after close, want nil", size)
+	}
+}